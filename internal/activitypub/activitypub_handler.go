@@ -0,0 +1,477 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/jobs"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+const outboxLength = 20
+
+// store is the part of database.Queries federation needs. The interop test
+// runs two instances on an in-memory one.
+type store interface {
+	UserExists(ctx context.Context, id uuid.UUID) (bool, error)
+	GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	GetFeedChirps(ctx context.Context, arg database.GetFeedChirpsParams) ([]database.Chirp, error)
+
+	CreateActorKey(ctx context.Context, arg database.CreateActorKeyParams) error
+	GetActorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error)
+
+	UpsertRemoteActor(ctx context.Context, arg database.UpsertRemoteActorParams) (database.RemoteActor, error)
+	GetRemoteActor(ctx context.Context, id string) (database.RemoteActor, error)
+	GetRemoteActorByKeyID(ctx context.Context, publicKeyID string) (database.RemoteActor, error)
+	DeleteRemoteActor(ctx context.Context, id string) (int64, error)
+
+	AddRemoteFollower(ctx context.Context, arg database.AddRemoteFollowerParams) error
+	RemoveRemoteFollower(ctx context.Context, arg database.RemoveRemoteFollowerParams) (int64, error)
+	CountRemoteFollowers(ctx context.Context, userID uuid.UUID) (int64, error)
+	GetFollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error)
+
+	CreateRemoteFollow(ctx context.Context, arg database.CreateRemoteFollowParams) (int64, error)
+	DeleteRemoteFollow(ctx context.Context, arg database.DeleteRemoteFollowParams) (string, error)
+	GetRemoteFollows(ctx context.Context, userID uuid.UUID) ([]database.RemoteFollow, error)
+	AcceptRemoteFollow(ctx context.Context, arg database.AcceptRemoteFollowParams) (int64, error)
+	RejectRemoteFollow(ctx context.Context, arg database.RejectRemoteFollowParams) (int64, error)
+	IsRemoteActorFollowed(ctx context.Context, actorID string) (bool, error)
+
+	CreateRemoteNote(ctx context.Context, arg database.CreateRemoteNoteParams) error
+	DeleteRemoteNote(ctx context.Context, arg database.DeleteRemoteNoteParams) (int64, error)
+	GetRemoteNotes(ctx context.Context, arg database.GetRemoteNotesParams) ([]database.RemoteNote, error)
+
+	CreateRemoteLike(ctx context.Context, arg database.CreateRemoteLikeParams) error
+	DeleteRemoteLike(ctx context.Context, arg database.DeleteRemoteLikeParams) (int64, error)
+}
+
+// ActivityPubHandler makes every user an ActivityPub actor at
+// /users/{userID}. Actor, note and collection documents are public, the
+// inboxes only take signed requests. Activities for other servers go out as
+// jobs.
+type ActivityPubHandler struct {
+	settings settings.Settings
+	baseURL  string
+	host     string
+	store    store
+	client   *http.Client
+	logger   *log.Logger
+
+	// Queues an activity for delivery, see Deliver.
+	enqueue func(ctx context.Context, d Delivery) error
+
+	mu   sync.Mutex
+	keys map[uuid.UUID]*rsa.PrivateKey
+}
+
+// NewActivityPubHandler needs s.BaseURL, ActivityPub IDs are absolute and
+// must not change. client makes every request to other servers.
+func NewActivityPubHandler(s settings.Settings, dbQueries *database.Queries, client *http.Client, logger *log.Logger) (*ActivityPubHandler, error) {
+	h, err := newActivityPubHandler(s, dbQueries, client, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	h.enqueue = func(ctx context.Context, d Delivery) error {
+		_, err := jobs.Enqueue(ctx, dbQueries, DeliverJob, d, jobs.Options{
+			UniqueKey:   d.ActivityID + " " + d.Inbox,
+			MaxAttempts: deliverAttempts,
+		})
+		return err
+	}
+
+	return h, nil
+}
+
+func newActivityPubHandler(s settings.Settings, st store, client *http.Client, logger *log.Logger) (*ActivityPubHandler, error) {
+	base, err := url.Parse(s.BaseURL)
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("invalid base url %q", s.BaseURL)
+	}
+
+	return &ActivityPubHandler{
+		settings: s,
+		baseURL:  strings.TrimSuffix(s.BaseURL, "/"),
+		host:     base.Host,
+		store:    st,
+		client:   client,
+		logger:   logger,
+		keys:     make(map[uuid.UUID]*rsa.PrivateKey),
+	}, nil
+}
+
+func (h *ActivityPubHandler) SetupRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /.well-known/webfinger", h.WebFinger)
+	mux.HandleFunc("GET /users/{userID}", h.GetActor)
+	mux.HandleFunc("GET /users/{userID}/outbox", h.GetOutbox)
+	mux.HandleFunc("GET /users/{userID}/followers", h.GetFollowers)
+	mux.HandleFunc("POST /users/{userID}/inbox", h.PostInbox)
+	mux.HandleFunc("POST /inbox", h.PostInbox)
+	mux.HandleFunc("GET /notes/{chirpID}", h.GetNote)
+}
+
+func (h *ActivityPubHandler) actorURL(userID uuid.UUID) string {
+	return h.baseURL + "/users/" + userID.String()
+}
+
+func (h *ActivityPubHandler) keyID(userID uuid.UUID) string {
+	return h.actorURL(userID) + "#main-key"
+}
+
+func (h *ActivityPubHandler) noteURL(chirpID uuid.UUID) string {
+	return h.baseURL + "/notes/" + chirpID.String()
+}
+
+// localID returns the user or chirp ID in a URL of ours under prefix.
+func (h *ActivityPubHandler) localID(rawURL, prefix string) (uuid.UUID, bool) {
+	rest, ok := strings.CutPrefix(rawURL, h.baseURL+prefix)
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(rest)
+	return id, err == nil
+}
+
+func (h *ActivityPubHandler) localUserID(actorURL string) (uuid.UUID, bool) {
+	return h.localID(actorURL, "/users/")
+}
+
+func (h *ActivityPubHandler) localChirpID(noteURL string) (uuid.UUID, bool) {
+	return h.localID(noteURL, "/notes/")
+}
+
+// actorKey returns a user's key pair, making one the first time.
+func (h *ActivityPubHandler) actorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	key, err := h.store.GetActorKey(ctx, userID)
+	if err == nil {
+		return key, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.ActorKey{}, fmt.Errorf("db get actor key: %w", err)
+	}
+
+	publicPEM, privatePEM, err := GenerateKey()
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+
+	err = h.store.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:        userID,
+		PublicKeyPem:  publicPEM,
+		PrivateKeyPem: privatePEM,
+	})
+	if err != nil {
+		return database.ActorKey{}, fmt.Errorf("db create actor key: %w", err)
+	}
+
+	// Another request may have won the race, its key is the one stored.
+	key, err = h.store.GetActorKey(ctx, userID)
+	if err != nil {
+		return database.ActorKey{}, fmt.Errorf("db get actor key: %w", err)
+	}
+
+	return key, nil
+}
+
+// privateKey is actorKey parsed, keys never change so they are cached.
+func (h *ActivityPubHandler) privateKey(ctx context.Context, userID uuid.UUID) (*rsa.PrivateKey, error) {
+	h.mu.Lock()
+	key, ok := h.keys[userID]
+	h.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	stored, err := h.actorKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err = ParsePrivateKey(stored.PrivateKeyPem)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	h.keys[userID] = key
+	h.mu.Unlock()
+
+	return key, nil
+}
+
+// writeActivity is response.JSON with the ActivityPub content type.
+func writeActivity(w http.ResponseWriter, status int, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		response.InternalServerError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	w.Write(payload)
+}
+
+// userID authenticates the request. It writes the error response itself and
+// reports ok=false in that case.
+func (h *ActivityPubHandler) userID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// localUser reads {userID} from the path. It writes the error response
+// itself and reports ok=false in that case.
+func (h *ActivityPubHandler) localUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.NotFound(w)
+		return uuid.Nil, false
+	}
+
+	exists, err := h.store.UserExists(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(localUser): db user exists (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return uuid.Nil, false
+	}
+
+	if !exists {
+		response.NotFound(w)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// WebFinger resolves acct:{userID}@{host} and actor URLs to the actor.
+func (h *ActivityPubHandler) WebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+
+	var userID uuid.UUID
+	var ok bool
+	if acct, isAcct := strings.CutPrefix(resource, "acct:"); isAcct {
+		user, host, _ := strings.Cut(acct, "@")
+		if host == h.host {
+			userID, ok = h.localID(h.baseURL+"/users/"+user, "/users/")
+		}
+	} else {
+		userID, ok = h.localUserID(resource)
+	}
+
+	if !ok {
+		response.NotFound(w)
+		return
+	}
+
+	exists, err := h.store.UserExists(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(WebFinger): db user exists (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !exists {
+		response.NotFound(w)
+		return
+	}
+
+	payload, err := json.Marshal(webfinger{
+		Subject: "acct:" + userID.String() + "@" + h.host,
+		Aliases: []string{h.actorURL(userID)},
+		Links: []webfingerLink{
+			{Rel: "self", Type: ContentType, Href: h.actorURL(userID)},
+		},
+	})
+	if err != nil {
+		response.InternalServerError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jrd+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
+func (h *ActivityPubHandler) GetActor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.localUser(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := h.actorKey(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetActor): actor key (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	actorURL := h.actorURL(userID)
+	writeActivity(w, http.StatusOK, Actor{
+		Context:           []string{activityStreams, securityV1},
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: userID.String(),
+		URL:               h.baseURL + "/api/chirps?author_id=" + userID.String(),
+		Inbox:             actorURL + "/inbox",
+		Outbox:            actorURL + "/outbox",
+		Followers:         actorURL + "/followers",
+		Endpoints:         &Endpoints{SharedInbox: h.baseURL + "/inbox"},
+		PublicKey: PublicKey{
+			ID:           h.keyID(userID),
+			Owner:        actorURL,
+			PublicKeyPEM: key.PublicKeyPem,
+		},
+	})
+}
+
+// note turns a public chirp into a Note addressed to everyone.
+func (h *ActivityPubHandler) note(chirp database.Chirp) Note {
+	note := Note{
+		ID:           h.noteURL(chirp.ID),
+		Type:         TypeNote,
+		AttributedTo: h.actorURL(chirp.UserID),
+		Content:      "<p>" + strings.ReplaceAll(html.EscapeString(chirp.Body), "\n", "<br>") + "</p>",
+		URL:          h.baseURL + "/api/chirps/" + chirp.ID.String(),
+		Published:    &chirp.CreatedAt.Time,
+		To:           []string{Public},
+		Cc:           []string{h.actorURL(chirp.UserID) + "/followers"},
+	}
+
+	if chirp.UpdatedAt.Time.After(chirp.CreatedAt.Time) {
+		note.Updated = &chirp.UpdatedAt.Time
+	}
+
+	return note
+}
+
+// publicChirp reports false for chirps that are hidden or not published yet,
+// they don't exist as far as other servers are concerned.
+func publicChirp(chirp database.Chirp) bool {
+	return !chirp.HiddenAt.Valid && !chirp.PublishAt.Valid
+}
+
+// GetOutbox lists the newest chirps as Create activities.
+func (h *ActivityPubHandler) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.localUser(w, r)
+	if !ok {
+		return
+	}
+
+	chirps, err := h.store.GetFeedChirps(r.Context(), database.GetFeedChirpsParams{
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		MaxChirps: outboxLength,
+	})
+	if err != nil {
+		h.logger.Printf("Error(GetOutbox): db get feed chirps (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	collection := OrderedCollection{
+		Context:      activityStreams,
+		ID:           h.actorURL(userID) + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   int64(len(chirps)),
+		OrderedItems: []any{},
+	}
+
+	for _, chirp := range chirps {
+		note := h.note(chirp)
+		object, err := json.Marshal(note)
+		if err != nil {
+			response.InternalServerError(w)
+			return
+		}
+
+		collection.OrderedItems = append(collection.OrderedItems, Activity{
+			ID:     note.ID + "#create",
+			Type:   TypeCreate,
+			Actor:  note.AttributedTo,
+			Object: object,
+			To:     note.To,
+			Cc:     note.Cc,
+		})
+	}
+
+	writeActivity(w, http.StatusOK, collection)
+}
+
+// GetFollowers only tells how many there are, who they are is nobody
+// else's business.
+func (h *ActivityPubHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.localUser(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := h.store.CountRemoteFollowers(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetFollowers): db count remote followers (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	writeActivity(w, http.StatusOK, OrderedCollection{
+		Context:    activityStreams,
+		ID:         h.actorURL(userID) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	})
+}
+
+func (h *ActivityPubHandler) GetNote(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		response.NotFound(w)
+		return
+	}
+
+	chirp, err := h.store.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(GetNote): db get chirp by id (chirp_id=%s): %v", chirpID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	if !publicChirp(chirp) {
+		response.NotFound(w)
+		return
+	}
+
+	note := h.note(chirp)
+	note.Context = activityStreams
+	writeActivity(w, http.StatusOK, note)
+}
@@ -0,0 +1,177 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/jobs"
+	"github.com/absurek/go-http-servers/internal/outbox"
+	"github.com/google/uuid"
+)
+
+// Servers go away for a while, with the job backoff this keeps trying for
+// about three and a half hours.
+const deliverAttempts = 12
+
+// Events are the outbox events federated to followers.
+var Events = []string{outbox.EventChirpCreated, outbox.EventChirpUpdated, outbox.EventChirpDeleted}
+
+// Delivery is an activity by a local user on its way to one inbox.
+type Delivery struct {
+	UserID     uuid.UUID       `json:"user_id"`
+	Inbox      string          `json:"inbox"`
+	ActivityID string          `json:"activity_id"`
+	Activity   json.RawMessage `json:"activity"`
+}
+
+// DeliverJob posts a Delivery, see Deliver.
+var DeliverJob = jobs.Kind[Delivery]{Name: "activitypub.deliver", Timeout: 1 * time.Minute}
+
+// Deliver signs the activity as its user and posts it. Servers answering
+// with a client error won't take it on a retry either, those are dropped.
+func (h *ActivityPubHandler) Deliver(ctx context.Context, d Delivery) error {
+	exists, err := h.store.UserExists(ctx, d.UserID)
+	if err != nil {
+		return fmt.Errorf("db user exists: %w", err)
+	}
+
+	// The account was deleted since, along with its key.
+	if !exists {
+		return nil
+	}
+
+	key, err := h.privateKey(ctx, d.UserID)
+	if err != nil {
+		return fmt.Errorf("actor key: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Inbox, bytes.NewReader(d.Activity))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", ContentType)
+
+	err = Sign(req, h.keyID(d.UserID), key, d.Activity, time.Now())
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post %s: %w", d.Inbox, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentSize))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		h.logger.Printf("Error(Deliver): %s refused %s (status=%d)", d.Inbox, d.ActivityID, resp.StatusCode)
+		return nil
+	default:
+		return fmt.Errorf("post %s: status %d", d.Inbox, resp.StatusCode)
+	}
+}
+
+// HandleOutbox is the relay subscription for Events. It queues a Create,
+// Update or Delete for every inbox with followers of the author.
+func (h *ActivityPubHandler) HandleOutbox(ctx context.Context, msg outbox.Message) error {
+	var data struct {
+		ChirpID  uuid.UUID `json:"chirp_id"`
+		AuthorID uuid.UUID `json:"author_id"`
+	}
+	err := json.Unmarshal(msg.Payload, &data)
+	if err != nil {
+		return fmt.Errorf("unmarshal %s payload: %w", msg.Event, err)
+	}
+
+	inboxes, err := h.store.GetFollowerInboxes(ctx, data.AuthorID)
+	if err != nil {
+		return fmt.Errorf("db get follower inboxes: %w", err)
+	}
+
+	if len(inboxes) == 0 {
+		return nil
+	}
+
+	activity, ok, err := h.chirpActivity(ctx, msg, data.ChirpID, data.AuthorID)
+	if err != nil || !ok {
+		return err
+	}
+
+	for _, inbox := range inboxes {
+		err = h.send(ctx, data.AuthorID, inbox, activity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chirpActivity builds the activity for a chirp event. Created and updated
+// chirps are read again, ok=false means the chirp isn't public anymore and
+// the event for that follows.
+func (h *ActivityPubHandler) chirpActivity(ctx context.Context, msg outbox.Message, chirpID, authorID uuid.UUID) (Activity, bool, error) {
+	// Activity IDs name the outbox event, a chirp deleted and restored gets
+	// a new Delete and Create every time.
+	activityID := fmt.Sprintf("%s#%s-%d", h.noteURL(chirpID), msg.Event, msg.ID)
+
+	if msg.Event == outbox.EventChirpDeleted {
+		tombstone, err := json.Marshal(Note{ID: h.noteURL(chirpID), Type: TypeTombstone})
+		if err != nil {
+			return Activity{}, false, fmt.Errorf("marshal tombstone: %w", err)
+		}
+
+		return Activity{
+			Context: activityStreams,
+			ID:      activityID,
+			Type:    TypeDelete,
+			Actor:   h.actorURL(authorID),
+			Object:  tombstone,
+			To:      []string{Public},
+		}, true, nil
+	}
+
+	chirp, err := h.store.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Activity{}, false, nil
+		}
+		return Activity{}, false, fmt.Errorf("db get chirp by id: %w", err)
+	}
+
+	if !publicChirp(chirp) {
+		return Activity{}, false, nil
+	}
+
+	note := h.note(chirp)
+	object, err := json.Marshal(note)
+	if err != nil {
+		return Activity{}, false, fmt.Errorf("marshal note: %w", err)
+	}
+
+	activityType := TypeCreate
+	if msg.Event == outbox.EventChirpUpdated {
+		activityType = TypeUpdate
+	}
+
+	return Activity{
+		Context: activityStreams,
+		ID:      activityID,
+		Type:    activityType,
+		Actor:   note.AttributedTo,
+		Object:  object,
+		To:      note.To,
+		Cc:      note.Cc,
+	}, true, nil
+}
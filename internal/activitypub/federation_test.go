@@ -0,0 +1,552 @@
+package activitypub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/outbox"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+// memStore is an in-memory store, just enough of the queries' semantics for
+// two instances to talk to each other.
+type memStore struct {
+	mu        sync.Mutex
+	users     map[uuid.UUID]bool
+	chirps    map[uuid.UUID]database.Chirp
+	keys      map[uuid.UUID]database.ActorKey
+	actors    map[string]database.RemoteActor
+	followers []database.RemoteFollower
+	follows   []database.RemoteFollow
+	notes     map[string]database.RemoteNote
+	likes     map[string]database.RemoteLike
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		users:  make(map[uuid.UUID]bool),
+		chirps: make(map[uuid.UUID]database.Chirp),
+		keys:   make(map[uuid.UUID]database.ActorKey),
+		actors: make(map[string]database.RemoteActor),
+		notes:  make(map[string]database.RemoteNote),
+		likes:  make(map[string]database.RemoteLike),
+	}
+}
+
+func (s *memStore) UserExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[id], nil
+}
+
+func (s *memStore) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirp, ok := s.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (s *memStore) GetFeedChirps(ctx context.Context, arg database.GetFeedChirpsParams) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var chirps []database.Chirp
+	for _, chirp := range s.chirps {
+		if chirp.UserID == arg.UserID.UUID && publicChirp(chirp) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	return chirps, nil
+}
+
+func (s *memStore) CreateActorKey(ctx context.Context, arg database.CreateActorKeyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[arg.UserID]; !ok {
+		s.keys[arg.UserID] = database.ActorKey{UserID: arg.UserID, PublicKeyPem: arg.PublicKeyPem, PrivateKeyPem: arg.PrivateKeyPem}
+	}
+	return nil
+}
+
+func (s *memStore) GetActorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[userID]
+	if !ok {
+		return database.ActorKey{}, sql.ErrNoRows
+	}
+	return key, nil
+}
+
+func (s *memStore) UpsertRemoteActor(ctx context.Context, arg database.UpsertRemoteActorParams) (database.RemoteActor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actor := database.RemoteActor{
+		ID:           arg.ID,
+		Inbox:        arg.Inbox,
+		SharedInbox:  arg.SharedInbox,
+		PublicKeyID:  arg.PublicKeyID,
+		PublicKeyPem: arg.PublicKeyPem,
+		FetchedAt:    time.Now(),
+	}
+	s.actors[arg.ID] = actor
+	return actor, nil
+}
+
+func (s *memStore) GetRemoteActor(ctx context.Context, id string) (database.RemoteActor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actor, ok := s.actors[id]
+	if !ok {
+		return database.RemoteActor{}, sql.ErrNoRows
+	}
+	return actor, nil
+}
+
+func (s *memStore) GetRemoteActorByKeyID(ctx context.Context, publicKeyID string) (database.RemoteActor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, actor := range s.actors {
+		if actor.PublicKeyID == publicKeyID {
+			return actor, nil
+		}
+	}
+	return database.RemoteActor{}, sql.ErrNoRows
+}
+
+func (s *memStore) DeleteRemoteActor(ctx context.Context, id string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.actors[id]; !ok {
+		return 0, nil
+	}
+	delete(s.actors, id)
+	return 1, nil
+}
+
+func (s *memStore) AddRemoteFollower(ctx context.Context, arg database.AddRemoteFollowerParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.followers = slices.DeleteFunc(s.followers, func(f database.RemoteFollower) bool {
+		return f.UserID == arg.UserID && f.ActorID == arg.ActorID
+	})
+	s.followers = append(s.followers, database.RemoteFollower{UserID: arg.UserID, ActorID: arg.ActorID, FollowID: arg.FollowID})
+	return nil
+}
+
+func (s *memStore) RemoveRemoteFollower(ctx context.Context, arg database.RemoveRemoteFollowerParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.followers)
+	s.followers = slices.DeleteFunc(s.followers, func(f database.RemoteFollower) bool {
+		return f.ActorID == arg.ActorID && f.FollowID == arg.FollowID
+	})
+	return int64(n - len(s.followers)), nil
+}
+
+func (s *memStore) CountRemoteFollowers(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, f := range s.followers {
+		if f.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memStore) GetFollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var inboxes []string
+	for _, f := range s.followers {
+		actor, ok := s.actors[f.ActorID]
+		if f.UserID != userID || !ok {
+			continue
+		}
+		inbox := actor.Inbox
+		if actor.SharedInbox.Valid {
+			inbox = actor.SharedInbox.String
+		}
+		if !slices.Contains(inboxes, inbox) {
+			inboxes = append(inboxes, inbox)
+		}
+	}
+	return inboxes, nil
+}
+
+func (s *memStore) CreateRemoteFollow(ctx context.Context, arg database.CreateRemoteFollowParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.follows {
+		if f.UserID == arg.UserID && f.ActorID == arg.ActorID {
+			return 0, nil
+		}
+	}
+	s.follows = append(s.follows, database.RemoteFollow{UserID: arg.UserID, ActorID: arg.ActorID, FollowID: arg.FollowID, CreatedAt: time.Now()})
+	return 1, nil
+}
+
+func (s *memStore) DeleteRemoteFollow(ctx context.Context, arg database.DeleteRemoteFollowParams) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.follows {
+		if f.UserID == arg.UserID && f.ActorID == arg.ActorID {
+			s.follows = slices.Delete(s.follows, i, i+1)
+			return f.FollowID, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+func (s *memStore) GetRemoteFollows(ctx context.Context, userID uuid.UUID) ([]database.RemoteFollow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var follows []database.RemoteFollow
+	for _, f := range s.follows {
+		if f.UserID == userID {
+			follows = append(follows, f)
+		}
+	}
+	return follows, nil
+}
+
+func (s *memStore) AcceptRemoteFollow(ctx context.Context, arg database.AcceptRemoteFollowParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.follows {
+		if f.FollowID == arg.FollowID && f.ActorID == arg.ActorID && !f.AcceptedAt.Valid {
+			s.follows[i].AcceptedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memStore) RejectRemoteFollow(ctx context.Context, arg database.RejectRemoteFollowParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.follows)
+	s.follows = slices.DeleteFunc(s.follows, func(f database.RemoteFollow) bool {
+		return f.FollowID == arg.FollowID && f.ActorID == arg.ActorID
+	})
+	return int64(n - len(s.follows)), nil
+}
+
+func (s *memStore) IsRemoteActorFollowed(ctx context.Context, actorID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.ContainsFunc(s.follows, func(f database.RemoteFollow) bool {
+		return f.ActorID == actorID
+	}), nil
+}
+
+func (s *memStore) CreateRemoteNote(ctx context.Context, arg database.CreateRemoteNoteParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.notes[arg.ID]; !ok {
+		s.notes[arg.ID] = database.RemoteNote{ID: arg.ID, ActorID: arg.ActorID, Content: arg.Content, InReplyTo: arg.InReplyTo, PublishedAt: arg.PublishedAt}
+	}
+	return nil
+}
+
+func (s *memStore) DeleteRemoteNote(ctx context.Context, arg database.DeleteRemoteNoteParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note, ok := s.notes[arg.ID]
+	if !ok || note.ActorID != arg.ActorID {
+		return 0, nil
+	}
+	delete(s.notes, arg.ID)
+	return 1, nil
+}
+
+func (s *memStore) GetRemoteNotes(ctx context.Context, arg database.GetRemoteNotesParams) ([]database.RemoteNote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var notes []database.RemoteNote
+	for _, note := range s.notes {
+		for _, f := range s.follows {
+			if f.UserID == arg.UserID && f.ActorID == note.ActorID && f.AcceptedAt.Valid {
+				notes = append(notes, note)
+			}
+		}
+	}
+	return notes, nil
+}
+
+func (s *memStore) CreateRemoteLike(ctx context.Context, arg database.CreateRemoteLikeParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.likes[arg.ID] = database.RemoteLike{ID: arg.ID, ActorID: arg.ActorID, ChirpID: arg.ChirpID}
+	return nil
+}
+
+func (s *memStore) DeleteRemoteLike(ctx context.Context, arg database.DeleteRemoteLikeParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	like, ok := s.likes[arg.ID]
+	if !ok || like.ActorID != arg.ActorID {
+		return 0, nil
+	}
+	delete(s.likes, arg.ID)
+	return 1, nil
+}
+
+// instance is one Chirpy server. Deliveries wait in queue until flush.
+type instance struct {
+	srv     *httptest.Server
+	store   *memStore
+	handler *ActivityPubHandler
+
+	mu    sync.Mutex
+	queue []Delivery
+}
+
+// newInstance starts a server with its own base URL. httptest uses one
+// certificate for every TLS server, so the instances trust each other.
+func newInstance(t *testing.T) *instance {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	inst := &instance{srv: srv, store: newMemStore()}
+
+	s := settings.Settings{BaseURL: srv.URL, JWTSecret: "secret"}
+	h, err := newActivityPubHandler(s, inst.store, srv.Client(), log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("newActivityPubHandler() error = %v", err)
+	}
+
+	h.enqueue = func(ctx context.Context, d Delivery) error {
+		inst.mu.Lock()
+		defer inst.mu.Unlock()
+		inst.queue = append(inst.queue, d)
+		return nil
+	}
+
+	h.SetupRoutes(mux)
+	inst.handler = h
+
+	return inst
+}
+
+func (inst *instance) addUser() uuid.UUID {
+	id := uuid.New()
+	inst.store.users[id] = true
+	return id
+}
+
+func (inst *instance) account(userID uuid.UUID) string {
+	u, _ := url.Parse(inst.srv.URL)
+	return userID.String() + "@" + u.Host
+}
+
+// flush delivers everything queued on any instance, including what the
+// deliveries queue in turn.
+func flush(t *testing.T, instances ...*instance) {
+	t.Helper()
+
+	for {
+		delivered := false
+		for _, inst := range instances {
+			inst.mu.Lock()
+			queue := inst.queue
+			inst.queue = nil
+			inst.mu.Unlock()
+
+			for _, d := range queue {
+				err := inst.handler.Deliver(context.Background(), d)
+				if err != nil {
+					t.Fatalf("Deliver(%s) error = %v", d.ActivityID, err)
+				}
+				delivered = true
+			}
+		}
+
+		if !delivered {
+			return
+		}
+	}
+}
+
+func chirpMessage(t *testing.T, id int64, event string, chirp database.Chirp) outbox.Message {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]uuid.UUID{"chirp_id": chirp.ID, "author_id": chirp.UserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return outbox.Message{ID: id, Event: event, AggregateID: chirp.ID, Payload: payload}
+}
+
+func TestFederation(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t)
+	b := newInstance(t)
+	alice := a.addUser()
+	bob := b.addUser()
+
+	// Bob follows alice by her handle, through the API.
+	jwt, err := auth.MakeJWT(bob, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/federation/follows", strings.NewReader(`{"account":"`+a.account(alice)+`"}`))
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	b.handler.Follow(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Follow status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	flush(t, a, b)
+
+	if count, _ := a.store.CountRemoteFollowers(ctx, alice); count != 1 {
+		t.Fatalf("alice has %d followers, want 1", count)
+	}
+
+	follows, _ := b.store.GetRemoteFollows(ctx, bob)
+	if len(follows) != 1 || !follows[0].AcceptedAt.Valid {
+		t.Fatalf("bob's follows = %+v, want one accepted", follows)
+	}
+
+	// Alice chirps, bob's server gets the note.
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		UserID:    alice,
+		Body:      "hello <fediverse>",
+		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	a.store.chirps[chirp.ID] = chirp
+
+	err = a.handler.HandleOutbox(ctx, chirpMessage(t, 1, outbox.EventChirpCreated, chirp))
+	if err != nil {
+		t.Fatalf("HandleOutbox() error = %v", err)
+	}
+	flush(t, a, b)
+
+	notes, _ := b.store.GetRemoteNotes(ctx, database.GetRemoteNotesParams{UserID: bob, MaxNotes: 10})
+	if len(notes) != 1 {
+		t.Fatalf("bob has %d notes, want 1", len(notes))
+	}
+
+	if notes[0].Content != "<p>hello &lt;fediverse&gt;</p>" {
+		t.Errorf("note content = %q", notes[0].Content)
+	}
+
+	// Bob likes it.
+	err = b.handler.send(ctx, bob, a.srv.URL+"/inbox", Activity{
+		Context: activityStreams,
+		ID:      b.handler.actorURL(bob) + "#likes/1",
+		Type:    TypeLike,
+		Actor:   b.handler.actorURL(bob),
+		Object:  json.RawMessage(`"` + notes[0].ID + `"`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flush(t, a, b)
+
+	if len(a.store.likes) != 1 {
+		t.Fatalf("alice's chirp has %d likes, want 1", len(a.store.likes))
+	}
+
+	// Alice deletes it, the note goes away.
+	err = a.handler.HandleOutbox(ctx, chirpMessage(t, 2, outbox.EventChirpDeleted, chirp))
+	if err != nil {
+		t.Fatalf("HandleOutbox() error = %v", err)
+	}
+	flush(t, a, b)
+
+	if len(b.store.notes) != 0 {
+		t.Errorf("bob has %d notes after the delete, want 0", len(b.store.notes))
+	}
+
+	// Bob unfollows.
+	ok, err := b.handler.unfollow(ctx, bob, a.handler.actorURL(alice))
+	if err != nil || !ok {
+		t.Fatalf("unfollow() = %v, %v", ok, err)
+	}
+	flush(t, a, b)
+
+	if count, _ := a.store.CountRemoteFollowers(ctx, alice); count != 0 {
+		t.Errorf("alice has %d followers after the unfollow, want 0", count)
+	}
+}
+
+func TestInboxRejectsForgedSignature(t *testing.T) {
+	a := newInstance(t)
+	b := newInstance(t)
+	alice := a.addUser()
+	bob := b.addUser()
+
+	body := []byte(`{"id":"` + b.handler.actorURL(bob) + `#follows/1","type":"Follow","actor":"` + b.handler.actorURL(bob) + `","object":"` + a.handler.actorURL(alice) + `"}`)
+
+	tests := []struct {
+		name string
+		sign func(r *http.Request)
+	}{
+		{
+			name: "unsigned",
+			sign: func(r *http.Request) {},
+		},
+		{
+			name: "someone else's key",
+			sign: func(r *http.Request) {
+				err := Sign(r, b.handler.keyID(bob), testKey(t), body, time.Now())
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, a.srv.URL+"/users/"+alice.String()+"/inbox", strings.NewReader(string(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", ContentType)
+			tt.sign(req)
+
+			resp, err := a.srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", resp.StatusCode)
+			}
+
+			if count, _ := a.store.CountRemoteFollowers(context.Background(), alice); count != 0 {
+				t.Errorf("alice has %d followers, want 0", count)
+			}
+		})
+	}
+}
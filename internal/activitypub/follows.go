@@ -0,0 +1,255 @@
+package activitypub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/google/uuid"
+)
+
+const remoteNotesLength = 50
+
+var ErrAlreadyFollowing = errors.New("already following")
+
+type followRequest struct {
+	// user@host or an actor URL.
+	Account string `json:"account" validate:"required"`
+}
+
+type followResponse struct {
+	Actor     string    `json:"actor"`
+	Accepted  bool      `json:"accepted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type remoteNoteResponse struct {
+	ID          string    `json:"id"`
+	Actor       string    `json:"actor"`
+	Content     string    `json:"content"`
+	InReplyTo   *string   `json:"in_reply_to"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// follow has a local user follow a remote account. Notes by the account are
+// kept once its server accepts.
+func (h *ActivityPubHandler) follow(ctx context.Context, userID uuid.UUID, account string) (database.RemoteActor, error) {
+	actorURL, err := h.resolve(ctx, account)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	if _, local := h.localUserID(actorURL); local {
+		return database.RemoteActor{}, fmt.Errorf("%w: %s is local", ErrInvalidActor, actorURL)
+	}
+
+	actor, err := h.fetchActor(ctx, actorURL)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	followID := h.actorURL(userID) + "#follows/" + uuid.NewString()
+	created, err := h.store.CreateRemoteFollow(ctx, database.CreateRemoteFollowParams{
+		UserID:   userID,
+		ActorID:  actor.ID,
+		FollowID: followID,
+	})
+	if err != nil {
+		return database.RemoteActor{}, fmt.Errorf("db create remote follow: %w", err)
+	}
+
+	if created == 0 {
+		return database.RemoteActor{}, ErrAlreadyFollowing
+	}
+
+	object, err := json.Marshal(actor.ID)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	err = h.send(ctx, userID, actor.Inbox, Activity{
+		Context: activityStreams,
+		ID:      followID,
+		Type:    TypeFollow,
+		Actor:   h.actorURL(userID),
+		Object:  object,
+	})
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	return actor, nil
+}
+
+// unfollow reports false if the user doesn't follow the actor.
+func (h *ActivityPubHandler) unfollow(ctx context.Context, userID uuid.UUID, actorURL string) (bool, error) {
+	followID, err := h.store.DeleteRemoteFollow(ctx, database.DeleteRemoteFollowParams{
+		UserID:  userID,
+		ActorID: actorURL,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("db delete remote follow: %w", err)
+	}
+
+	actor, err := h.store.GetRemoteActor(ctx, actorURL)
+	if err != nil {
+		return false, fmt.Errorf("db get remote actor: %w", err)
+	}
+
+	follow, err := json.Marshal(Activity{
+		ID:     followID,
+		Type:   TypeFollow,
+		Actor:  h.actorURL(userID),
+		Object: json.RawMessage(fmt.Sprintf("%q", actor.ID)),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = h.send(ctx, userID, actor.Inbox, Activity{
+		Context: activityStreams,
+		ID:      followID + "/undo",
+		Type:    TypeUndo,
+		Actor:   h.actorURL(userID),
+		Object:  follow,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (h *ActivityPubHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := request.DecodeJSON[followRequest](w, r)
+	if !ok {
+		return
+	}
+
+	actor, err := h.follow(r.Context(), userID, req.Account)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrAlreadyFollowing):
+			response.Error(w, http.StatusConflict, "already_following", err.Error())
+		case errors.Is(err, ErrInvalidActor):
+			response.Invalid(w, "account", response.FieldInvalid, err.Error())
+		default:
+			// Most likely the other server, nothing the user can fix.
+			h.logger.Printf("Error(Follow): follow %s (user_id=%s): %v", req.Account, userID, err)
+			response.Error(w, http.StatusBadRequest, "follow_failed", "account could not be followed")
+		}
+
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, followResponse{
+		Actor:     actor.ID,
+		Accepted:  false,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (h *ActivityPubHandler) GetFollows(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	follows, err := h.store.GetRemoteFollows(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetFollows): db get remote follows (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := []followResponse{}
+	for _, follow := range follows {
+		resp = append(resp, followResponse{
+			Actor:     follow.ActorID,
+			Accepted:  follow.AcceptedAt.Valid,
+			CreatedAt: follow.CreatedAt,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// Unfollow takes the actor URL as ?actor=, URLs don't fit in a path segment.
+func (h *ActivityPubHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	actorURL := r.URL.Query().Get("actor")
+	if actorURL == "" {
+		response.Invalid(w, "actor", response.FieldRequired, "missing actor")
+		return
+	}
+
+	unfollowed, err := h.unfollow(r.Context(), userID, actorURL)
+	if err != nil {
+		h.logger.Printf("Error(Unfollow): unfollow %s (user_id=%s): %v", actorURL, userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !unfollowed {
+		response.NotFound(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// GetRemoteNotes is the timeline of accounts the user follows on other
+// servers. content is HTML from those servers, clients must sanitize it.
+func (h *ActivityPubHandler) GetRemoteNotes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	notes, err := h.store.GetRemoteNotes(r.Context(), database.GetRemoteNotesParams{
+		UserID:   userID,
+		MaxNotes: remoteNotesLength,
+	})
+	if err != nil {
+		h.logger.Printf("Error(GetRemoteNotes): db get remote notes (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := []remoteNoteResponse{}
+	for _, note := range notes {
+		var inReplyTo *string
+		if note.InReplyTo.Valid {
+			inReplyTo = &note.InReplyTo.String
+		}
+
+		resp = append(resp, remoteNoteResponse{
+			ID:          note.ID,
+			Actor:       note.ActorID,
+			Content:     note.Content,
+			InReplyTo:   inReplyTo,
+			PublishedAt: note.PublishedAt,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,280 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HTTP Signatures as the fediverse uses them, draft-cavage-http-signatures
+// with rsa-sha256 keys.
+
+// How far a signed request's Date may be off. Deliveries are signed when
+// they are sent, retries included, this only has to cover clock skew.
+const SignatureTolerance = 1 * time.Hour
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrStaleSignature   = errors.New("signature date outside of tolerance")
+)
+
+// signature is a parsed Signature header.
+type signature struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+func parseSignature(header string) (signature, error) {
+	if header == "" {
+		return signature{}, ErrMissingSignature
+	}
+
+	var sig signature
+	for _, param := range splitParams(header) {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return signature{}, ErrInvalidSignature
+		}
+		value = strings.Trim(value, `"`)
+
+		switch strings.TrimSpace(name) {
+		case "keyId":
+			sig.KeyID = value
+		case "algorithm":
+			sig.Algorithm = value
+		case "headers":
+			sig.Headers = strings.Fields(strings.ToLower(value))
+		case "signature":
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return signature{}, ErrInvalidSignature
+			}
+			sig.Signature = decoded
+		}
+	}
+
+	// Only Date is signed when headers is left out.
+	if sig.Headers == nil {
+		sig.Headers = []string{"date"}
+	}
+
+	// hs2019 leaves the algorithm to the key, keys are RSA here.
+	if sig.KeyID == "" || sig.Signature == nil || (sig.Algorithm != "" && sig.Algorithm != "rsa-sha256" && sig.Algorithm != "hs2019") {
+		return signature{}, ErrInvalidSignature
+	}
+
+	return sig, nil
+}
+
+// splitParams splits on commas outside of quotes, base64 has none but keyId
+// URLs might.
+func splitParams(header string) []string {
+	var params []string
+	var quoted bool
+	start := 0
+	for i, c := range header {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			params = append(params, header[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(params, header[start:])
+}
+
+// signingString builds what gets signed from the named headers of r.
+func signingString(r *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		var value string
+		switch name {
+		case "(request-target)":
+			value = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case "host":
+			value = r.Host
+			if value == "" {
+				value = r.URL.Host
+			}
+		default:
+			values := r.Header.Values(name)
+			if len(values) == 0 {
+				return "", fmt.Errorf("%w: header %s is not set", ErrInvalidSignature, name)
+			}
+			value = strings.Join(values, ", ")
+		}
+
+		lines = append(lines, name+": "+value)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Sign adds Date, Digest for a body and a Signature over them to r.
+func Sign(r *http.Request, keyID string, key *rsa.PrivateKey, body []byte, now time.Time) error {
+	headers := []string{"(request-target)", "host", "date"}
+
+	r.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	if body != nil {
+		r.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	toSign, err := signingString(r, headers)
+	if err != nil {
+		return err
+	}
+
+	hashed := sha256.Sum256([]byte(toSign))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return fmt.Errorf("sign request: %w", err)
+	}
+
+	r.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+
+	return nil
+}
+
+// SignatureKeyID reports which key r claims to be signed with, so the
+// caller can look it up for Verify.
+func SignatureKeyID(r *http.Request) (string, error) {
+	sig, err := parseSignature(r.Header.Get("Signature"))
+	if err != nil {
+		return "", err
+	}
+
+	return sig.KeyID, nil
+}
+
+// Verify checks r's Signature against key. The signature has to cover the
+// request target, Host and Date, and for requests with a body the Digest,
+// which has to match body.
+func Verify(r *http.Request, body []byte, key *rsa.PublicKey, now time.Time) error {
+	sig, err := parseSignature(r.Header.Get("Signature"))
+	if err != nil {
+		return err
+	}
+
+	required := []string{"(request-target)", "host", "date"}
+	if r.Method == http.MethodPost {
+		required = append(required, "digest")
+	}
+	for _, name := range required {
+		if !slices.Contains(sig.Headers, name) {
+			return fmt.Errorf("%w: %s is not signed", ErrInvalidSignature, name)
+		}
+	}
+
+	toSign, err := signingString(r, sig.Headers)
+	if err != nil {
+		return err
+	}
+
+	hashed := sha256.Sum256([]byte(toSign))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if slices.Contains(sig.Headers, "digest") && r.Header.Get("Digest") != digest(body) {
+		return fmt.Errorf("%w: digest doesn't match the body", ErrInvalidSignature)
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("%w: invalid date", ErrInvalidSignature)
+	}
+
+	if date.Before(now.Add(-SignatureTolerance)) || date.After(now.Add(SignatureTolerance)) {
+		return ErrStaleSignature
+	}
+
+	return nil
+}
+
+// GenerateKey makes an actor key pair, PEM encoded.
+func GenerateKey() (publicPEM, privatePEM string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", fmt.Errorf("generate key: %w", err)
+	}
+
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal public key: %w", err)
+	}
+
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal private key: %w", err)
+	}
+
+	publicPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}))
+	privatePEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}))
+	return publicPEM, privatePEM, nil
+}
+
+func ParsePublicKey(publicPEM string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicPEM))
+	if block == nil {
+		return nil, errors.New("public key is not PEM")
+	}
+
+	// Some servers still publish PKCS#1 keys.
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not RSA")
+	}
+
+	return rsaKey, nil
+}
+
+func ParsePrivateKey(privatePEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, errors.New("private key is not PEM")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+
+	return rsaKey, nil
+}
@@ -0,0 +1,185 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	_, privatePEM, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	key, err := ParsePrivateKey(privatePEM)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() error = %v", err)
+	}
+
+	return key
+}
+
+func signedRequest(t *testing.T, key *rsa.PrivateKey, body []byte, now time.Time) *http.Request {
+	t.Helper()
+
+	r, err := http.NewRequest(http.MethodPost, "https://chirpy.example/users/1/inbox", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	err = Sign(r, "https://remote.example/users/bob#main-key", key, body, now)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	return r
+}
+
+func TestSignVerify(t *testing.T) {
+	key := testKey(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"Follow"}`)
+
+	r := signedRequest(t, key, body, now)
+
+	keyID, err := SignatureKeyID(r)
+	if err != nil {
+		t.Fatalf("SignatureKeyID() error = %v", err)
+	}
+
+	if keyID != "https://remote.example/users/bob#main-key" {
+		t.Errorf("SignatureKeyID() = %q", keyID)
+	}
+
+	err = Verify(r, body, &key.PublicKey, now.Add(time.Minute))
+	if err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	key := testKey(t)
+	other := testKey(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"Follow"}`)
+
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+		body   []byte
+		key    *rsa.PublicKey
+		now    time.Time
+		want   error
+	}{
+		{
+			name: "other key",
+			key:  &other.PublicKey,
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "tampered body",
+			body: []byte(`{"type":"Delete"}`),
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "tampered digest",
+			modify: func(r *http.Request) {
+				r.Header.Set("Digest", digest([]byte(`{"type":"Delete"}`)))
+			},
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "other target",
+			modify: func(r *http.Request) {
+				r.URL.Path = "/inbox"
+			},
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "stale",
+			now:  now.Add(2 * SignatureTolerance),
+			want: ErrStaleSignature,
+		},
+		{
+			name: "missing",
+			modify: func(r *http.Request) {
+				r.Header.Del("Signature")
+			},
+			want: ErrMissingSignature,
+		},
+		{
+			name: "digest not signed",
+			modify: func(r *http.Request) {
+				r.Header.Set("Signature", `keyId="k",algorithm="rsa-sha256",headers="(request-target) host date",signature="AAAA"`)
+			},
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "unknown algorithm",
+			modify: func(r *http.Request) {
+				r.Header.Set("Signature", `keyId="k",algorithm="hmac-sha256",headers="(request-target) host date digest",signature="AAAA"`)
+			},
+			want: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signedRequest(t, key, body, now)
+			if tt.modify != nil {
+				tt.modify(r)
+			}
+
+			verifyBody := body
+			if tt.body != nil {
+				verifyBody = tt.body
+			}
+
+			verifyKey := &key.PublicKey
+			if tt.key != nil {
+				verifyKey = tt.key
+			}
+
+			verifyNow := now
+			if !tt.now.IsZero() {
+				verifyNow = tt.now
+			}
+
+			err := Verify(r, verifyBody, verifyKey, verifyNow)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	sig, err := parseSignature(`keyId="https://remote.example/users/bob?key=1,2",algorithm="hs2019",headers="(request-target) Host date",signature="AQID"`)
+	if err != nil {
+		t.Fatalf("parseSignature() error = %v", err)
+	}
+
+	if sig.KeyID != "https://remote.example/users/bob?key=1,2" {
+		t.Errorf("KeyID = %q", sig.KeyID)
+	}
+
+	want := []string{"(request-target)", "host", "date"}
+	if len(sig.Headers) != len(want) {
+		t.Fatalf("Headers = %v, want %v", sig.Headers, want)
+	}
+	for i := range want {
+		if sig.Headers[i] != want[i] {
+			t.Errorf("Headers = %v, want %v", sig.Headers, want)
+		}
+	}
+
+	if !bytes.Equal(sig.Signature, []byte{1, 2, 3}) {
+		t.Errorf("Signature = %v", sig.Signature)
+	}
+}
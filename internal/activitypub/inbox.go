@@ -0,0 +1,388 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/google/uuid"
+)
+
+var (
+	errInvalidActivity = errors.New("invalid activity")
+	errUnknownTarget   = errors.New("unknown local actor")
+)
+
+// sameHost reports whether both URLs are on one server, an actor can only
+// speak for objects on its own.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return ua.Host != "" && ua.Host == ub.Host
+}
+
+// PostInbox takes activities from other servers, for one user or the whole
+// instance. Requests must be signed by the activity's actor. Activities are
+// handled idempotently, so redeliveries are harmless.
+func (h *ActivityPubHandler) PostInbox(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDocumentSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.PayloadTooLarge(w)
+			return
+		}
+
+		response.InvalidRequestBody(w)
+		return
+	}
+
+	var activity Activity
+	err = json.Unmarshal(body, &activity)
+	if err != nil || activity.ID == "" || activity.Type == "" || !sameHost(activity.ID, activity.Actor) {
+		response.InvalidRequestBody(w)
+		return
+	}
+
+	keyID, err := SignatureKeyID(r)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	actor, err := h.verify(r, body, keyID)
+	if err != nil {
+		// Deleted actors can't be fetched anymore. If we never heard of
+		// them there is nothing to delete either.
+		if deleted, err := parseObject(activity.Object); err == nil && activity.Type == TypeDelete && deleted.ID == activity.Actor {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		h.logger.Printf("Error(PostInbox): verify signature (key_id=%s): %v", keyID, err)
+		response.Unauthorized(w)
+		return
+	}
+
+	if actor.ID != activity.Actor {
+		response.Unauthorized(w)
+		return
+	}
+
+	err = h.handle(r.Context(), actor, activity, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidActivity):
+			response.Error(w, http.StatusBadRequest, "invalid_activity", err.Error())
+		case errors.Is(err, errUnknownTarget):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(PostInbox): handle %s (activity_id=%s): %v", activity.Type, activity.ID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verify checks the signature with the cached key first and with a freshly
+// fetched one if that fails.
+func (h *ActivityPubHandler) verify(r *http.Request, body []byte, keyID string) (database.RemoteActor, error) {
+	var lastErr error
+	for _, refresh := range []bool{false, true} {
+		actor, err := h.signer(r.Context(), keyID, refresh)
+		if err != nil {
+			return database.RemoteActor{}, err
+		}
+
+		key, err := ParsePublicKey(actor.PublicKeyPem)
+		if err != nil {
+			return database.RemoteActor{}, err
+		}
+
+		lastErr = Verify(r, body, key, time.Now())
+		if lastErr == nil {
+			return actor, nil
+		}
+
+		// A stale date is the sender's problem, a new key won't help.
+		if errors.Is(lastErr, ErrStaleSignature) {
+			break
+		}
+	}
+
+	return database.RemoteActor{}, lastErr
+}
+
+func (h *ActivityPubHandler) handle(ctx context.Context, actor database.RemoteActor, activity Activity, body []byte) error {
+	switch activity.Type {
+	case TypeFollow:
+		return h.handleFollow(ctx, actor, activity, body)
+	case TypeUndo:
+		return h.handleUndo(ctx, actor, activity)
+	case TypeAccept, TypeReject:
+		return h.handleFollowResponse(ctx, actor, activity)
+	case TypeCreate:
+		return h.handleCreate(ctx, actor, activity)
+	case TypeLike:
+		return h.handleLike(ctx, actor, activity)
+	case TypeDelete:
+		return h.handleDelete(ctx, actor, activity)
+	default:
+		// Announce, Update and friends, nothing we keep track of.
+		return nil
+	}
+}
+
+// handleFollow accepts every Follow of a local user right away.
+func (h *ActivityPubHandler) handleFollow(ctx context.Context, actor database.RemoteActor, activity Activity, body []byte) error {
+	target, err := parseObject(activity.Object)
+	if err != nil {
+		return errInvalidActivity
+	}
+
+	userID, ok := h.localUserID(target.ID)
+	if !ok {
+		return errUnknownTarget
+	}
+
+	exists, err := h.store.UserExists(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("db user exists: %w", err)
+	}
+
+	if !exists {
+		return errUnknownTarget
+	}
+
+	err = h.store.AddRemoteFollower(ctx, database.AddRemoteFollowerParams{
+		UserID:   userID,
+		ActorID:  actor.ID,
+		FollowID: activity.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("db add remote follower: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(activity.ID))
+	return h.send(ctx, userID, actor.Inbox, Activity{
+		Context: activityStreams,
+		ID:      h.actorURL(userID) + "#accepts/" + hex.EncodeToString(sum[:16]),
+		Type:    TypeAccept,
+		Actor:   h.actorURL(userID),
+		Object:  body,
+	})
+}
+
+// handleUndo takes back a Follow or a Like. The object may be just the ID,
+// then whichever of the two it was is removed.
+func (h *ActivityPubHandler) handleUndo(ctx context.Context, actor database.RemoteActor, activity Activity) error {
+	undone, err := parseObject(activity.Object)
+	if err != nil || undone.ID == "" {
+		return errInvalidActivity
+	}
+
+	if undone.Type == "" || undone.Type == TypeFollow {
+		_, err = h.store.RemoveRemoteFollower(ctx, database.RemoveRemoteFollowerParams{
+			ActorID:  actor.ID,
+			FollowID: undone.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("db remove remote follower: %w", err)
+		}
+	}
+
+	if undone.Type == "" || undone.Type == TypeLike {
+		_, err = h.store.DeleteRemoteLike(ctx, database.DeleteRemoteLikeParams{
+			ID:      undone.ID,
+			ActorID: actor.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("db delete remote like: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// handleFollowResponse settles a Follow one of our users sent.
+func (h *ActivityPubHandler) handleFollowResponse(ctx context.Context, actor database.RemoteActor, activity Activity) error {
+	follow, err := parseObject(activity.Object)
+	if err != nil || follow.ID == "" {
+		return errInvalidActivity
+	}
+
+	if activity.Type == TypeAccept {
+		_, err = h.store.AcceptRemoteFollow(ctx, database.AcceptRemoteFollowParams{
+			FollowID: follow.ID,
+			ActorID:  actor.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("db accept remote follow: %w", err)
+		}
+
+		return nil
+	}
+
+	_, err = h.store.RejectRemoteFollow(ctx, database.RejectRemoteFollowParams{
+		FollowID: follow.ID,
+		ActorID:  actor.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("db reject remote follow: %w", err)
+	}
+
+	return nil
+}
+
+// handleCreate keeps notes by actors our users follow and replies to our
+// chirps, anything else isn't for us.
+func (h *ActivityPubHandler) handleCreate(ctx context.Context, actor database.RemoteActor, activity Activity) error {
+	var note Note
+	err := json.Unmarshal(activity.Object, &note)
+	if err != nil {
+		return errInvalidActivity
+	}
+
+	if note.Type != TypeNote {
+		return nil
+	}
+
+	if note.AttributedTo != actor.ID || !sameHost(note.ID, actor.ID) {
+		return fmt.Errorf("%w: note is not by the actor", errInvalidActivity)
+	}
+
+	_, isReply := h.localChirpID(note.InReplyTo)
+	if !isReply {
+		followed, err := h.store.IsRemoteActorFollowed(ctx, actor.ID)
+		if err != nil {
+			return fmt.Errorf("db is remote actor followed: %w", err)
+		}
+
+		if !followed {
+			return nil
+		}
+	}
+
+	published := time.Now()
+	if note.Published != nil {
+		published = *note.Published
+	}
+
+	err = h.store.CreateRemoteNote(ctx, database.CreateRemoteNoteParams{
+		ID:          note.ID,
+		ActorID:     actor.ID,
+		Content:     note.Content,
+		InReplyTo:   sql.NullString{String: note.InReplyTo, Valid: note.InReplyTo != ""},
+		PublishedAt: published,
+	})
+	if err != nil {
+		return fmt.Errorf("db create remote note: %w", err)
+	}
+
+	return nil
+}
+
+// handleLike counts likes of public chirps, likes of anything else are
+// dropped.
+func (h *ActivityPubHandler) handleLike(ctx context.Context, actor database.RemoteActor, activity Activity) error {
+	liked, err := parseObject(activity.Object)
+	if err != nil {
+		return errInvalidActivity
+	}
+
+	chirpID, ok := h.localChirpID(liked.ID)
+	if !ok {
+		return nil
+	}
+
+	chirp, err := h.store.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("db get chirp by id: %w", err)
+	}
+
+	if !publicChirp(chirp) {
+		return nil
+	}
+
+	err = h.store.CreateRemoteLike(ctx, database.CreateRemoteLikeParams{
+		ID:      activity.ID,
+		ActorID: actor.ID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("db create remote like: %w", err)
+	}
+
+	return nil
+}
+
+// handleDelete removes a note, or everything of an actor deleting itself.
+func (h *ActivityPubHandler) handleDelete(ctx context.Context, actor database.RemoteActor, activity Activity) error {
+	deleted, err := parseObject(activity.Object)
+	if err != nil || deleted.ID == "" {
+		return errInvalidActivity
+	}
+
+	if deleted.ID == actor.ID {
+		_, err = h.store.DeleteRemoteActor(ctx, actor.ID)
+		if err != nil {
+			return fmt.Errorf("db delete remote actor: %w", err)
+		}
+
+		return nil
+	}
+
+	_, err = h.store.DeleteRemoteNote(ctx, database.DeleteRemoteNoteParams{
+		ID:      deleted.ID,
+		ActorID: actor.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("db delete remote note: %w", err)
+	}
+
+	return nil
+}
+
+// send queues an activity by a local user for an inbox.
+func (h *ActivityPubHandler) send(ctx context.Context, userID uuid.UUID, inbox string, activity Activity) error {
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", activity.Type, err)
+	}
+
+	err = h.enqueue(ctx, Delivery{
+		UserID:     userID,
+		Inbox:      inbox,
+		ActivityID: activity.ID,
+		Activity:   payload,
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue %s delivery: %w", activity.Type, err)
+	}
+
+	return nil
+}
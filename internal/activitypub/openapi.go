@@ -0,0 +1,50 @@
+package activitypub
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+// Operations are the routes under /api/, the ActivityPub ones are for other
+// servers and follow the ActivityPub spec.
+var Operations = []openapi.Operation{
+	{
+		Pattern:     "POST /api/federation/follows",
+		Tag:         "federation",
+		Summary:     "Follow an account on another server",
+		Description: "account is user@host or an actor URL. The follow is accepted once the other server says so.",
+		Auth:        openapi.AuthBearer,
+		Request:     followRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusCreated, Description: "The follow, pending", Body: followResponse{}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict},
+	},
+	{
+		Pattern:   "GET /api/federation/follows",
+		Tag:       "federation",
+		Summary:   "List followed accounts on other servers",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The follows", Body: []followResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		Pattern: "DELETE /api/federation/follows",
+		Tag:     "federation",
+		Summary: "Unfollow an account on another server",
+		Auth:    openapi.AuthBearer,
+		Params: []openapi.Param{
+			{Name: "actor", In: "query", Description: "The actor URL.", Required: true},
+		},
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Unfollowed"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:     "GET /api/federation/notes",
+		Tag:         "federation",
+		Summary:     "List notes of followed accounts",
+		Description: "content is HTML from the other servers, sanitize it before rendering.",
+		Auth:        openapi.AuthBearer,
+		Responses:   []openapi.Response{{Status: http.StatusOK, Description: "The newest notes", Body: []remoteNoteResponse{}}},
+		Errors:      []int{http.StatusUnauthorized},
+	},
+}
@@ -0,0 +1,159 @@
+package activitypub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/absurek/go-http-servers/internal/database"
+)
+
+// Nothing a server sends us should come close, actors with a lot of
+// attachments included.
+const maxDocumentSize = 1 << 20
+
+var ErrInvalidActor = errors.New("invalid remote actor")
+
+// get fetches a JSON document from another server.
+func (h *ActivityPubHandler) get(ctx context.Context, rawURL, accept string, v any) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an https URL", ErrInvalidActor, rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("get %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s: status %d", rawURL, resp.StatusCode)
+	}
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(v)
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", rawURL, err)
+	}
+
+	return nil
+}
+
+// fetchActor loads an actor document and stores what we need of it. The
+// document has to be served from the URL it claims as its ID.
+func (h *ActivityPubHandler) fetchActor(ctx context.Context, actorURL string) (database.RemoteActor, error) {
+	var actor Actor
+	err := h.get(ctx, actorURL, ContentType+", "+ldContentType, &actor)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	if actor.ID != actorURL || actor.Inbox == "" || actor.PublicKey.Owner != actor.ID || actor.PublicKey.PublicKeyPEM == "" {
+		return database.RemoteActor{}, fmt.Errorf("%w: %s", ErrInvalidActor, actorURL)
+	}
+
+	_, err = ParsePublicKey(actor.PublicKey.PublicKeyPEM)
+	if err != nil {
+		return database.RemoteActor{}, fmt.Errorf("%w: %s: %v", ErrInvalidActor, actorURL, err)
+	}
+
+	var sharedInbox sql.NullString
+	if actor.Endpoints != nil && actor.Endpoints.SharedInbox != "" {
+		sharedInbox = sql.NullString{String: actor.Endpoints.SharedInbox, Valid: true}
+	}
+
+	remote, err := h.store.UpsertRemoteActor(ctx, database.UpsertRemoteActorParams{
+		ID:           actor.ID,
+		Inbox:        actor.Inbox,
+		SharedInbox:  sharedInbox,
+		PublicKeyID:  actor.PublicKey.ID,
+		PublicKeyPem: actor.PublicKey.PublicKeyPEM,
+	})
+	if err != nil {
+		return database.RemoteActor{}, fmt.Errorf("db upsert remote actor: %w", err)
+	}
+
+	return remote, nil
+}
+
+// remoteActor is fetchActor for actors we may know already.
+func (h *ActivityPubHandler) remoteActor(ctx context.Context, actorURL string) (database.RemoteActor, error) {
+	actor, err := h.store.GetRemoteActor(ctx, actorURL)
+	if err == nil {
+		return actor, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.RemoteActor{}, fmt.Errorf("db get remote actor: %w", err)
+	}
+
+	return h.fetchActor(ctx, actorURL)
+}
+
+// signer finds the actor owning keyID. Keys are cached, refresh fetches the
+// actor again for when it rotated its key.
+func (h *ActivityPubHandler) signer(ctx context.Context, keyID string, refresh bool) (database.RemoteActor, error) {
+	if !refresh {
+		actor, err := h.store.GetRemoteActorByKeyID(ctx, keyID)
+		if err == nil {
+			return actor, nil
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return database.RemoteActor{}, fmt.Errorf("db get remote actor by key id: %w", err)
+		}
+	}
+
+	// Keys live in the actor document, usually as a fragment of it.
+	actorURL, _, _ := strings.Cut(keyID, "#")
+	actor, err := h.fetchActor(ctx, actorURL)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	if actor.PublicKeyID != keyID {
+		return database.RemoteActor{}, fmt.Errorf("%w: %s doesn't own key %s", ErrInvalidActor, actorURL, keyID)
+	}
+
+	return actor, nil
+}
+
+// resolve turns an account like user@host, with or without acct:, into an
+// actor URL with WebFinger. Actor URLs are returned as they are.
+func (h *ActivityPubHandler) resolve(ctx context.Context, account string) (string, error) {
+	if strings.HasPrefix(account, "https://") {
+		return account, nil
+	}
+
+	account = strings.TrimPrefix(strings.TrimPrefix(account, "acct:"), "@")
+	_, host, ok := strings.Cut(account, "@")
+	if !ok || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("%w: %q is not user@host", ErrInvalidActor, account)
+	}
+
+	var jrd webfinger
+	err := h.get(ctx, "https://"+host+"/.well-known/webfinger?resource="+url.QueryEscape("acct:"+account), "application/jrd+json, application/json", &jrd)
+	if err != nil {
+		return "", err
+	}
+
+	for _, link := range jrd.Links {
+		if link.Rel == "self" && (link.Type == ContentType || link.Type == ldContentType) {
+			return link.Href, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s has no ActivityPub actor", ErrInvalidActor, account)
+}
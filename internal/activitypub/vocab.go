@@ -0,0 +1,119 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ContentType = "application/activity+json"
+	// Servers may ask for either.
+	ldContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+	activityStreams = "https://www.w3.org/ns/activitystreams"
+	securityV1      = "https://w3id.org/security/v1"
+	// Addressing an activity here makes it public.
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+
+	TypeFollow = "Follow"
+	TypeAccept = "Accept"
+	TypeReject = "Reject"
+	TypeUndo   = "Undo"
+	TypeCreate = "Create"
+	TypeUpdate = "Update"
+	TypeDelete = "Delete"
+	TypeLike   = "Like"
+
+	TypeNote      = "Note"
+	TypeTombstone = "Tombstone"
+)
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPEM string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+type Note struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	AttributedTo string     `json:"attributedTo,omitempty"`
+	Content      string     `json:"content,omitempty"`
+	InReplyTo    string     `json:"inReplyTo,omitempty"`
+	URL          string     `json:"url,omitempty"`
+	Published    *time.Time `json:"published,omitempty"`
+	Updated      *time.Time `json:"updated,omitempty"`
+	To           []string   `json:"to,omitempty"`
+	Cc           []string   `json:"cc,omitempty"`
+}
+
+// Activity keeps its object raw, depending on the type it is a link or an
+// embedded object.
+type Activity struct {
+	Context any             `json:"@context,omitempty"`
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Actor   string          `json:"actor"`
+	Object  json.RawMessage `json:"object"`
+	To      []string        `json:"to,omitempty"`
+	Cc      []string        `json:"cc,omitempty"`
+}
+
+// object is what every embedded object has, enough to dispatch on.
+type object struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// parseObject reads an object that may be given as its ID only.
+func parseObject(raw json.RawMessage) (object, error) {
+	var id string
+	if json.Unmarshal(raw, &id) == nil {
+		return object{ID: id}, nil
+	}
+
+	var obj object
+	err := json.Unmarshal(raw, &obj)
+	return obj, err
+}
+
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int64  `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+type webfingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+type webfinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []webfingerLink `json:"links"`
+}
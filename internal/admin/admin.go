@@ -1,31 +1,135 @@
 package admin
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/chirps"
 	"github.com/absurek/go-http-servers/internal/database"
 	"github.com/absurek/go-http-servers/internal/metrics"
+	"github.com/absurek/go-http-servers/internal/moderation"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
 )
 
+// store is the part of database.Queries Admin needs.
+type store interface {
+	DeleteAllUsers(ctx context.Context) error
+	IsModerator(ctx context.Context, id uuid.UUID) (bool, error)
+	SuspendUser(ctx context.Context, id uuid.UUID) (int64, error)
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) error
+	GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+
+	GetJobs(ctx context.Context, arg database.GetJobsParams) ([]database.Job, error)
+	GetJobStats(ctx context.Context) ([]database.GetJobStatsRow, error)
+	GetJobSchedules(ctx context.Context) ([]database.JobSchedule, error)
+
+	GetModerationRules(ctx context.Context) ([]database.ModerationRule, error)
+	CreateModerationRule(ctx context.Context, arg database.CreateModerationRuleParams) (database.ModerationRule, error)
+	UpdateModerationRule(ctx context.Context, arg database.UpdateModerationRuleParams) (database.ModerationRule, error)
+	DeleteModerationRule(ctx context.Context, id uuid.UUID) (int64, error)
+	NotifyModerationRulesChanged(ctx context.Context) error
+
+	GetReports(ctx context.Context, arg database.GetReportsParams) ([]database.Report, error)
+	GetReportByID(ctx context.Context, id uuid.UUID) (database.Report, error)
+	ResolveReport(ctx context.Context, arg database.ResolveReportParams) (int64, error)
+	ResolveChirpReports(ctx context.Context, arg database.ResolveChirpReportsParams) (int64, error)
+	ResolveUserReports(ctx context.Context, arg database.ResolveUserReportsParams) (int64, error)
+	CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error)
+	GetModerationActions(ctx context.Context, arg database.GetModerationActionsParams) ([]database.ModerationAction, error)
+}
+
+// chirpModerator is the part of chirps.ChirpsHandler moderation needs.
+type chirpModerator interface {
+	HideChirp(ctx context.Context, chirpID uuid.UUID) (bool, error)
+	UnhideChirp(ctx context.Context, chirpID uuid.UUID) (bool, error)
+	RemoveChirp(ctx context.Context, chirp database.Chirp, deletedBy uuid.UUID) (bool, error)
+}
+
 type Admin struct {
-	db        *sql.DB
-	dbQueries *database.Queries
-	logger    *log.Logger
-	metrics   *metrics.Metrics
+	settings      settings.Settings
+	db            *sql.DB
+	store         store
+	moderator     *moderation.Moderator
+	chirpsHandler chirpModerator
+	logger        *log.Logger
+	metrics       *metrics.Metrics
 }
 
-func NewAdmin(db *sql.DB, dbQueries *database.Queries, metrics *metrics.Metrics, logger *log.Logger) *Admin {
+func NewAdmin(s settings.Settings, db *sql.DB, dbQueries *database.Queries, moderator *moderation.Moderator, chirpsHandler *chirps.ChirpsHandler, metrics *metrics.Metrics, logger *log.Logger) *Admin {
 	return &Admin{
-		db:        db,
-		dbQueries: dbQueries,
-		metrics:   metrics,
-		logger:    logger,
+		settings:      s,
+		db:            db,
+		store:         dbQueries,
+		moderator:     moderator,
+		chirpsHandler: chirpsHandler,
+		metrics:       metrics,
+		logger:        logger,
 	}
 }
 
 func (a *Admin) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/admin/reset", a.Reset)
 	mux.HandleFunc("/admin/metrics", a.metrics.GetMetrics)
+	mux.HandleFunc("GET /admin/metrics/outbox", a.moderatorsOnly(a.metrics.GetOutboxMetrics))
+	mux.HandleFunc("GET /admin/metrics/refresh-tokens", a.moderatorsOnly(a.metrics.GetRefreshTokenMetrics))
+	mux.HandleFunc("GET /admin/metrics/api-versions", a.moderatorsOnly(a.metrics.GetAPIVersionMetrics))
+
+	mux.HandleFunc("GET /admin/jobs", a.GetJobs)
+
+	mux.HandleFunc("GET /admin/moderation/rules", a.GetModerationRules)
+	mux.HandleFunc("POST /admin/moderation/rules", a.CreateModerationRule)
+	mux.HandleFunc("PUT /admin/moderation/rules/{ruleID}", a.UpdateModerationRule)
+	mux.HandleFunc("DELETE /admin/moderation/rules/{ruleID}", a.DeleteModerationRule)
+
+	mux.HandleFunc("GET /admin/moderation/reports", a.GetReports)
+	mux.HandleFunc("POST /admin/moderation/reports/{reportID}/actions", a.TakeAction)
+	mux.HandleFunc("GET /admin/moderation/actions", a.GetModerationActions)
+}
+
+// moderatorID authenticates a moderator. It writes the error response itself
+// and reports ok=false in that case.
+func (a *Admin) moderatorID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.UUID{}, false
+	}
+
+	userID, err := auth.ValidateJWT(jwt, a.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.UUID{}, false
+	}
+
+	isModerator, err := a.store.IsModerator(r.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		a.logger.Printf("Error(Admin): db is moderator (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return uuid.UUID{}, false
+	}
+
+	if !isModerator {
+		response.Forbidden(w)
+		return uuid.UUID{}, false
+	}
+
+	return userID, true
+}
+
+// moderatorsOnly serves next to moderators only, for handlers that don't
+// need to know who is asking.
+func (a *Admin) moderatorsOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := a.moderatorID(w, r); !ok {
+			return
+		}
+
+		next(w, r)
+	}
 }
@@ -0,0 +1,44 @@
+package admin
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/absurek/go-http-servers/internal/metrics"
+	"github.com/absurek/go-http-servers/internal/settings"
+)
+
+func TestMetricsNeedModerator(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	a := &Admin{
+		settings: settings.Settings{JWTSecret: "secret"},
+		metrics:  metrics.NewMetrics(logger),
+		logger:   logger,
+	}
+
+	mux := http.NewServeMux()
+	a.SetupRoutes(mux)
+
+	for _, path := range []string{
+		"/admin/metrics/outbox",
+		"/admin/metrics/refresh-tokens",
+		"/admin/metrics/api-versions",
+	} {
+		for _, authorization := range []string{"", "Bearer not-a-jwt"} {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			if authorization != "" {
+				r.Header.Set("Authorization", authorization)
+			}
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("GET %s (Authorization %q) status = %d, want %d", path, authorization, w.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/jobs"
+	"github.com/absurek/go-http-servers/internal/response"
+)
+
+const recentJobs = 100
+
+type jobResponse struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	UniqueKey   *string         `json:"unique_key"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil *time.Time      `json:"locked_until"`
+	LastError   *string         `json:"last_error"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at"`
+}
+
+type jobScheduleResponse struct {
+	Name      string     `json:"name"`
+	Spec      string     `json:"spec"`
+	NextRunAt time.Time  `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+}
+
+type jobsResponse struct {
+	// Job counts by kind, then status.
+	Stats     map[string]map[string]int64 `json:"stats"`
+	Schedules []jobScheduleResponse       `json:"schedules"`
+	Jobs      []jobResponse               `json:"jobs"`
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+
+	return &s.String
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func newJobResponse(job database.Job) jobResponse {
+	return jobResponse{
+		ID:          job.ID.String(),
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		Status:      job.Status,
+		UniqueKey:   nullString(job.UniqueKey),
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		LockedUntil: nullTime(job.LockedUntil),
+		LastError:   nullString(job.LastError),
+		CreatedAt:   job.CreatedAt,
+		FinishedAt:  nullTime(job.FinishedAt),
+	}
+}
+
+// GetJobs shows the job queue: counts per kind and status, the recurring
+// schedules and the most recent jobs, optionally only those with ?status=.
+func (a *Admin) GetJobs(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.moderatorID(w, r); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && !slices.Contains(jobs.Statuses, status) {
+		response.Invalid(w, "status", response.FieldInvalid, "invalid status")
+		return
+	}
+
+	stats, err := a.store.GetJobStats(r.Context())
+	if err != nil {
+		a.logger.Printf("Error(GetJobs): db get job stats: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	schedules, err := a.store.GetJobSchedules(r.Context())
+	if err != nil {
+		a.logger.Printf("Error(GetJobs): db get job schedules: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	recent, err := a.store.GetJobs(r.Context(), database.GetJobsParams{
+		Status:  sql.NullString{String: status, Valid: status != ""},
+		MaxJobs: recentJobs,
+	})
+	if err != nil {
+		a.logger.Printf("Error(GetJobs): db get jobs: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := jobsResponse{
+		Stats:     map[string]map[string]int64{},
+		Schedules: []jobScheduleResponse{},
+		Jobs:      []jobResponse{},
+	}
+
+	for _, row := range stats {
+		if resp.Stats[row.Kind] == nil {
+			resp.Stats[row.Kind] = map[string]int64{}
+		}
+		resp.Stats[row.Kind][row.Status] = row.Count
+	}
+
+	for _, schedule := range schedules {
+		resp.Schedules = append(resp.Schedules, jobScheduleResponse{
+			Name:      schedule.Name,
+			Spec:      schedule.Spec,
+			NextRunAt: schedule.NextRunAt,
+			LastRunAt: nullTime(schedule.LastRunAt),
+		})
+	}
+
+	for _, job := range recent {
+		resp.Jobs = append(resp.Jobs, newJobResponse(job))
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
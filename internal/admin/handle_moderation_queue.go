@@ -0,0 +1,403 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/google/uuid"
+)
+
+const (
+	actionDismiss = "dismiss"
+	actionHide    = "hide"
+	actionDelete  = "delete"
+	actionSuspend = "suspend"
+	actionRestore = "restore"
+
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var (
+	reportStatuses    = []string{"open", "dismissed", "actioned"}
+	moderationActions = []string{actionDismiss, actionHide, actionDelete, actionSuspend, actionRestore}
+
+	errNotAboutChirp = errors.New("the report is not about a chirp")
+	errNotHidden     = errors.New("the chirp is not hidden")
+)
+
+type reportResponse struct {
+	ID         string     `json:"id"`
+	ReporterID *string    `json:"reporter_id"`
+	UserID     string     `json:"user_id"`
+	ChirpID    *string    `json:"chirp_id"`
+	ChirpBody  *string    `json:"chirp_body"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ResolvedBy *string    `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type reportsResponse struct {
+	Reports    []reportResponse `json:"reports"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type moderationActionRequest struct {
+	Action string `json:"action" validate:"required"`
+	Reason string `json:"reason" validate:"required"`
+}
+
+func (req moderationActionRequest) Validate() []response.FieldError {
+	if req.Action != "" && !slices.Contains(moderationActions, req.Action) {
+		return []response.FieldError{{Field: "action", Code: response.FieldInvalid, Message: "invalid action"}}
+	}
+
+	return nil
+}
+
+type moderationActionResponse struct {
+	ID          string    `json:"id"`
+	ModeratorID *string   `json:"moderator_id"`
+	Action      string    `json:"action"`
+	ReportID    *string   `json:"report_id"`
+	UserID      string    `json:"user_id"`
+	ChirpID     *string   `json:"chirp_id"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type moderationActionsResponse struct {
+	Actions    []moderationActionResponse `json:"actions"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+}
+
+func optionalID(id uuid.NullUUID) *string {
+	if !id.Valid {
+		return nil
+	}
+
+	s := id.UUID.String()
+	return &s
+}
+
+func newReportResponse(report database.Report) reportResponse {
+	resp := reportResponse{
+		ID:         report.ID.String(),
+		ReporterID: optionalID(report.ReporterID),
+		UserID:     report.UserID.String(),
+		ChirpID:    optionalID(report.ChirpID),
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+		ResolvedBy: optionalID(report.ResolvedBy),
+		CreatedAt:  report.CreatedAt,
+	}
+
+	if report.ChirpBody.Valid {
+		resp.ChirpBody = &report.ChirpBody.String
+	}
+
+	if report.ResolvedAt.Valid {
+		resp.ResolvedAt = &report.ResolvedAt.Time
+	}
+
+	return resp
+}
+
+func newModerationActionResponse(action database.ModerationAction) moderationActionResponse {
+	return moderationActionResponse{
+		ID:          action.ID.String(),
+		ModeratorID: optionalID(action.ModeratorID),
+		Action:      action.Action,
+		ReportID:    optionalID(action.ReportID),
+		UserID:      action.UserID.String(),
+		ChirpID:     optionalID(action.ChirpID),
+		Reason:      action.Reason,
+		CreatedAt:   action.CreatedAt,
+	}
+}
+
+// page reads the limit and cursor query parameters. It writes the error
+// response itself and reports ok=false in that case.
+func page(w http.ResponseWriter, r *http.Request) (int, sql.NullTime, bool) {
+	query := r.URL.Query()
+
+	limit := defaultPageSize
+	if s := query.Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			response.Invalid(w, "limit", response.FieldInvalid, "invalid limit")
+			return 0, sql.NullTime{}, false
+		}
+	}
+
+	var cursor sql.NullTime
+	if s := query.Get("cursor"); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			response.Invalid(w, "cursor", response.FieldInvalid, "invalid cursor")
+			return 0, sql.NullTime{}, false
+		}
+		cursor = sql.NullTime{Time: t, Valid: true}
+	}
+
+	return limit, cursor, true
+}
+
+// GetReports is the moderator queue, oldest reports first.
+func (a *Admin) GetReports(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.moderatorID(w, r); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+
+	if !slices.Contains(reportStatuses, status) {
+		response.Invalid(w, "status", response.FieldInvalid, "invalid status")
+		return
+	}
+
+	limit, after, ok := page(w, r)
+	if !ok {
+		return
+	}
+
+	reports, err := a.store.GetReports(r.Context(), database.GetReportsParams{
+		Status:     status,
+		After:      after,
+		MaxReports: int32(limit),
+	})
+	if err != nil {
+		a.logger.Printf("Error(GetReports): db get reports: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := reportsResponse{
+		Reports: []reportResponse{},
+	}
+	for _, report := range reports {
+		resp.Reports = append(resp.Reports, newReportResponse(report))
+	}
+
+	if len(reports) == limit {
+		resp.NextCursor = reports[len(reports)-1].CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// TakeAction resolves a report. Dismissing only closes that report, acting
+// on the chirp or the author closes every open report about it as well.
+// Restoring undoes a hide, so unlike the other actions it is also taken on
+// resolved reports.
+func (a *Admin) TakeAction(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := a.moderatorID(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		response.Invalid(w, "reportID", response.FieldInvalid, "invalid report id")
+		return
+	}
+
+	req, ok := request.DecodeJSON[moderationActionRequest](w, r)
+	if !ok {
+		return
+	}
+
+	report, err := a.store.GetReportByID(r.Context(), reportID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			a.logger.Printf("Error(TakeAction): db get report (report_id=%s): %v", reportID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	if report.Status != "open" && req.Action != actionRestore {
+		response.Error(w, http.StatusBadRequest, "report_resolved", "the report is already resolved")
+		return
+	}
+
+	err = a.applyAction(r.Context(), moderatorID, req.Action, report)
+	if err != nil {
+		switch {
+		case errors.Is(err, errNotAboutChirp):
+			response.Error(w, http.StatusBadRequest, "not_about_chirp", err.Error())
+		case errors.Is(err, errNotHidden):
+			response.Error(w, http.StatusBadRequest, "not_hidden", err.Error())
+		default:
+			a.logger.Printf("Error(TakeAction): %s (report_id=%s): %v", req.Action, reportID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	action, err := a.store.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      req.Action,
+		ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+		UserID:      report.UserID,
+		ChirpID:     report.ChirpID,
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		a.logger.Printf("Error(TakeAction): db create moderation action (report_id=%s): %v", reportID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, newModerationActionResponse(action))
+}
+
+func (a *Admin) applyAction(ctx context.Context, moderatorID uuid.UUID, action string, report database.Report) error {
+	resolvedBy := uuid.NullUUID{UUID: moderatorID, Valid: true}
+
+	switch action {
+	case actionDismiss:
+		_, err := a.store.ResolveReport(ctx, database.ResolveReportParams{
+			ID:         report.ID,
+			Status:     "dismissed",
+			ResolvedBy: resolvedBy,
+		})
+		return err
+	case actionHide:
+		if !report.ChirpID.Valid {
+			return errNotAboutChirp
+		}
+
+		_, err := a.chirpsHandler.HideChirp(ctx, report.ChirpID.UUID)
+		if err != nil {
+			return err
+		}
+
+		_, err = a.store.ResolveChirpReports(ctx, database.ResolveChirpReportsParams{
+			ChirpID:    report.ChirpID,
+			Status:     "actioned",
+			ResolvedBy: resolvedBy,
+		})
+		return err
+	case actionDelete:
+		if !report.ChirpID.Valid {
+			return errNotAboutChirp
+		}
+
+		chirp, err := a.store.GetChirpByID(ctx, report.ChirpID.UUID)
+		if err != nil {
+			return fmt.Errorf("db get chirp: %w", err)
+		}
+
+		// Resolve the reports first, once the chirp is purged its reports
+		// lose their chirp_id.
+		_, err = a.store.ResolveChirpReports(ctx, database.ResolveChirpReportsParams{
+			ChirpID:    report.ChirpID,
+			Status:     "actioned",
+			ResolvedBy: resolvedBy,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = a.chirpsHandler.RemoveChirp(ctx, chirp, moderatorID)
+		return err
+	case actionSuspend:
+		_, err := a.store.SuspendUser(ctx, report.UserID)
+		if err != nil {
+			return fmt.Errorf("db suspend user: %w", err)
+		}
+
+		// Access tokens run out on their own, refresh tokens don't.
+		err = a.store.RevokeUserTokens(ctx, report.UserID)
+		if err != nil {
+			return fmt.Errorf("db revoke user tokens: %w", err)
+		}
+
+		_, err = a.store.ResolveUserReports(ctx, database.ResolveUserReportsParams{
+			UserID:     report.UserID,
+			ResolvedBy: resolvedBy,
+		})
+		return err
+	case actionRestore:
+		if !report.ChirpID.Valid {
+			return errNotAboutChirp
+		}
+
+		restored, err := a.chirpsHandler.UnhideChirp(ctx, report.ChirpID.UUID)
+		if err != nil {
+			return err
+		}
+
+		if !restored {
+			return errNotHidden
+		}
+
+		// The chirp is fine, reports still open about it are dismissed.
+		_, err = a.store.ResolveChirpReports(ctx, database.ResolveChirpReportsParams{
+			ChirpID:    report.ChirpID,
+			Status:     "dismissed",
+			ResolvedBy: resolvedBy,
+		})
+		return err
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// GetModerationActions is the audit log, newest first.
+func (a *Admin) GetModerationActions(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.moderatorID(w, r); !ok {
+		return
+	}
+
+	limit, before, ok := page(w, r)
+	if !ok {
+		return
+	}
+
+	actions, err := a.store.GetModerationActions(r.Context(), database.GetModerationActionsParams{
+		Before:     before,
+		MaxActions: int32(limit),
+	})
+	if err != nil {
+		a.logger.Printf("Error(GetModerationActions): db get moderation actions: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := moderationActionsResponse{
+		Actions: []moderationActionResponse{},
+	}
+	for _, action := range actions {
+		resp.Actions = append(resp.Actions, newModerationActionResponse(action))
+	}
+
+	if len(actions) == limit {
+		resp.NextCursor = actions[len(actions)-1].CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
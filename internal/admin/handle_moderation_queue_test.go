@@ -0,0 +1,314 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+// memStore implements the moderation queue part of store with the
+// semantics of the queries, the rest panics.
+type memStore struct {
+	store
+
+	moderators map[uuid.UUID]bool
+	chirps     map[uuid.UUID]database.Chirp
+	reports    []database.Report
+	actions    []database.ModerationAction
+}
+
+func (s *memStore) IsModerator(ctx context.Context, id uuid.UUID) (bool, error) {
+	return s.moderators[id], nil
+}
+
+func (s *memStore) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, ok := s.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (s *memStore) GetReports(ctx context.Context, arg database.GetReportsParams) ([]database.Report, error) {
+	var reports []database.Report
+	for _, report := range s.reports {
+		if report.Status == arg.Status && len(reports) < int(arg.MaxReports) {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+func (s *memStore) GetReportByID(ctx context.Context, id uuid.UUID) (database.Report, error) {
+	for _, report := range s.reports {
+		if report.ID == id {
+			return report, nil
+		}
+	}
+	return database.Report{}, sql.ErrNoRows
+}
+
+func (s *memStore) resolve(match func(database.Report) bool, status string, resolvedBy uuid.NullUUID) int64 {
+	var resolved int64
+	for i, report := range s.reports {
+		if report.Status == "open" && match(report) {
+			s.reports[i].Status = status
+			s.reports[i].ResolvedBy = resolvedBy
+			s.reports[i].ResolvedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			resolved++
+		}
+	}
+	return resolved
+}
+
+func (s *memStore) ResolveReport(ctx context.Context, arg database.ResolveReportParams) (int64, error) {
+	return s.resolve(func(report database.Report) bool { return report.ID == arg.ID }, arg.Status, arg.ResolvedBy), nil
+}
+
+func (s *memStore) ResolveChirpReports(ctx context.Context, arg database.ResolveChirpReportsParams) (int64, error) {
+	return s.resolve(func(report database.Report) bool { return report.ChirpID == arg.ChirpID }, arg.Status, arg.ResolvedBy), nil
+}
+
+func (s *memStore) CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error) {
+	action := database.ModerationAction{
+		ID:          uuid.New(),
+		ModeratorID: arg.ModeratorID,
+		Action:      arg.Action,
+		ReportID:    arg.ReportID,
+		UserID:      arg.UserID,
+		ChirpID:     arg.ChirpID,
+		Reason:      arg.Reason,
+		CreatedAt:   time.Now(),
+	}
+	s.actions = append(s.actions, action)
+	return action, nil
+}
+
+func (s *memStore) report(chirp database.Chirp) database.Report {
+	report := database.Report{
+		ID:         uuid.New(),
+		ReporterID: uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		UserID:     chirp.UserID,
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody:  sql.NullString{String: chirp.Body, Valid: true},
+		Reason:     "spam",
+		Status:     "open",
+		CreatedAt:  time.Now(),
+	}
+	s.reports = append(s.reports, report)
+	return report
+}
+
+// memChirps implements chirpModerator on the chirps of a memStore.
+type memChirps struct {
+	store *memStore
+}
+
+func (c memChirps) HideChirp(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	chirp, ok := c.store.chirps[chirpID]
+	if !ok || chirp.HiddenAt.Valid || chirp.DeletedAt.Valid {
+		return false, nil
+	}
+
+	chirp.HiddenAt = sql.NullTime{Time: time.Now(), Valid: true}
+	c.store.chirps[chirpID] = chirp
+	return true, nil
+}
+
+func (c memChirps) UnhideChirp(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	chirp, ok := c.store.chirps[chirpID]
+	if !ok || !chirp.HiddenAt.Valid || chirp.DeletedAt.Valid {
+		return false, nil
+	}
+
+	chirp.HiddenAt = sql.NullTime{}
+	c.store.chirps[chirpID] = chirp
+	return true, nil
+}
+
+func (c memChirps) RemoveChirp(ctx context.Context, chirp database.Chirp, deletedBy uuid.UUID) (bool, error) {
+	chirp.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	c.store.chirps[chirp.ID] = chirp
+	return true, nil
+}
+
+func newTestAdmin(t *testing.T) (*Admin, *memStore, string) {
+	t.Helper()
+
+	moderatorID := uuid.New()
+	jwt, err := auth.MakeJWT(moderatorID, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memStore{
+		moderators: map[uuid.UUID]bool{moderatorID: true},
+		chirps:     make(map[uuid.UUID]database.Chirp),
+	}
+
+	return &Admin{
+		settings:      settings.Settings{JWTSecret: "secret"},
+		store:         store,
+		chirpsHandler: memChirps{store: store},
+		logger:        log.New(io.Discard, "", 0),
+	}, store, jwt
+}
+
+func takeAction(a *Admin, jwt string, reportID uuid.UUID, action string) *httptest.ResponseRecorder {
+	body := `{"action": "` + action + `", "reason": "checked"}`
+	r := httptest.NewRequest(http.MethodPost, "/admin/moderation/reports/"+reportID.String()+"/actions", strings.NewReader(body))
+	r.SetPathValue("reportID", reportID.String())
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+jwt)
+
+	w := httptest.NewRecorder()
+	a.TakeAction(w, r)
+	return w
+}
+
+func openReports(t *testing.T, a *Admin, jwt string) []reportResponse {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/moderation/reports", nil)
+	r.Header.Set("Authorization", "Bearer "+jwt)
+	w := httptest.NewRecorder()
+	a.GetReports(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetReports() = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var resp reportsResponse
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatalf("unmarshal reports: %v", err)
+	}
+	return resp.Reports
+}
+
+func TestHideAndRestore(t *testing.T) {
+	a, store, jwt := newTestAdmin(t)
+
+	chirp := database.Chirp{ID: uuid.New(), UserID: uuid.New(), Body: "What a kerfuffle"}
+	store.chirps[chirp.ID] = chirp
+	first := store.report(chirp)
+	second := store.report(chirp)
+
+	if reports := openReports(t, a, jwt); len(reports) != 2 || reports[0].ID != first.ID.String() {
+		t.Fatalf("GetReports() = %+v, want both reports, oldest first", reports)
+	}
+
+	// Hiding the chirp closes every open report about it.
+	if w := takeAction(a, jwt, first.ID, actionHide); w.Code != http.StatusCreated {
+		t.Fatalf("hide = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	if !store.chirps[chirp.ID].HiddenAt.Valid {
+		t.Fatal("the chirp isn't hidden")
+	}
+
+	if reports := openReports(t, a, jwt); len(reports) != 0 {
+		t.Fatalf("GetReports() = %+v after the hide, want none open", reports)
+	}
+
+	if report, _ := store.GetReportByID(context.Background(), second.ID); report.Status != "actioned" {
+		t.Errorf("other report status = %q, want actioned", report.Status)
+	}
+
+	if w := takeAction(a, jwt, second.ID, actionHide); w.Code != http.StatusBadRequest {
+		t.Errorf("hide on a resolved report = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	// Restoring undoes the hide, even though the reports are resolved.
+	if w := takeAction(a, jwt, first.ID, actionRestore); w.Code != http.StatusCreated {
+		t.Fatalf("restore = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	if store.chirps[chirp.ID].HiddenAt.Valid {
+		t.Fatal("the chirp is still hidden")
+	}
+
+	if w := takeAction(a, jwt, first.ID, actionRestore); w.Code != http.StatusBadRequest {
+		t.Errorf("restore of a visible chirp = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	// Both actions are on record with who took them.
+	if len(store.actions) != 2 || store.actions[0].Action != actionHide || store.actions[1].Action != actionRestore {
+		t.Fatalf("actions = %+v, want the hide and the restore", store.actions)
+	}
+
+	for _, action := range store.actions {
+		if !action.ModeratorID.Valid || action.Reason != "checked" || action.ChirpID.UUID != chirp.ID {
+			t.Errorf("action = %+v, want the moderator, reason and chirp recorded", action)
+		}
+	}
+}
+
+func TestRestoreDismissesOpenReports(t *testing.T) {
+	a, store, jwt := newTestAdmin(t)
+
+	chirp := database.Chirp{ID: uuid.New(), UserID: uuid.New()}
+	store.chirps[chirp.ID] = chirp
+	hidden := store.report(chirp)
+	takeAction(a, jwt, hidden.ID, actionHide)
+
+	// Reported again through a link while hidden, then cleared.
+	late := store.report(chirp)
+	if w := takeAction(a, jwt, late.ID, actionRestore); w.Code != http.StatusCreated {
+		t.Fatalf("restore = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	if report, _ := store.GetReportByID(context.Background(), late.ID); report.Status != "dismissed" {
+		t.Errorf("open report status = %q, want dismissed", report.Status)
+	}
+
+	if report, _ := store.GetReportByID(context.Background(), hidden.ID); report.Status != "actioned" {
+		t.Errorf("hide report status = %q, want it to stay actioned", report.Status)
+	}
+}
+
+func TestTakeActionErrors(t *testing.T) {
+	a, store, jwt := newTestAdmin(t)
+
+	userReport := database.Report{ID: uuid.New(), UserID: uuid.New(), Reason: "spam", Status: "open"}
+	store.reports = append(store.reports, userReport)
+
+	for _, action := range []string{actionHide, actionRestore, actionDelete} {
+		if w := takeAction(a, jwt, userReport.ID, action); w.Code != http.StatusBadRequest {
+			t.Errorf("%s on a report about a user = %d, want %d", action, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	if w := takeAction(a, jwt, userReport.ID, "ban"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown action = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	if w := takeAction(a, jwt, uuid.New(), actionDismiss); w.Code != http.StatusNotFound {
+		t.Errorf("unknown report = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	userJWT, err := auth.MakeJWT(uuid.New(), "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := takeAction(a, userJWT, userReport.ID, actionDismiss); w.Code != http.StatusForbidden {
+		t.Errorf("action by a user = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	if len(store.actions) != 0 || store.reports[0].Status != "open" {
+		t.Errorf("rejected actions changed the report or recorded %d actions", len(store.actions))
+	}
+}
@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/moderation"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/google/uuid"
+)
+
+type moderationRuleRequest struct {
+	Kind     string `json:"kind"`
+	Pattern  string `json:"pattern"`
+	Action   string `json:"action"`
+	Position int32  `json:"position"`
+}
+
+type moderationRuleResponse struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	Position  int32     `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newModerationRuleResponse(rule database.ModerationRule) moderationRuleResponse {
+	return moderationRuleResponse{
+		ID:        rule.ID.String(),
+		Kind:      rule.Kind,
+		Pattern:   rule.Pattern,
+		Action:    rule.Action,
+		Position:  rule.Position,
+		CreatedAt: rule.CreatedAt,
+		UpdatedAt: rule.UpdatedAt,
+	}
+}
+
+// decodeModerationRule reads and validates a rule. It writes the error
+// response itself and reports ok=false in that case.
+func decodeModerationRule(w http.ResponseWriter, r *http.Request) (moderationRuleRequest, bool) {
+	req, ok := request.DecodeJSON[moderationRuleRequest](w, r)
+	if !ok {
+		return moderationRuleRequest{}, false
+	}
+
+	err := moderation.ValidateRule(moderation.Rule{
+		Kind:    req.Kind,
+		Pattern: req.Pattern,
+		Action:  req.Action,
+	})
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "invalid_rule", err.Error())
+		return moderationRuleRequest{}, false
+	}
+
+	return req, true
+}
+
+// rulesChanged reloads the rules here right away and tells the other
+// instances to do the same.
+func (a *Admin) rulesChanged(ctx context.Context) {
+	err := a.moderator.Reload(ctx)
+	if err != nil {
+		a.logger.Printf("Error(Admin): reload moderation rules: %v", err)
+	}
+
+	err = a.store.NotifyModerationRulesChanged(ctx)
+	if err != nil {
+		a.logger.Printf("Error(Admin): notify moderation rules changed: %v", err)
+	}
+}
+
+func (a *Admin) GetModerationRules(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.moderatorID(w, r); !ok {
+		return
+	}
+
+	rules, err := a.store.GetModerationRules(r.Context())
+	if err != nil {
+		a.logger.Printf("Error(GetModerationRules): db get moderation rules: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := []moderationRuleResponse{}
+	for _, rule := range rules {
+		resp = append(resp, newModerationRuleResponse(rule))
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+func (a *Admin) CreateModerationRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.moderatorID(w, r); !ok {
+		return
+	}
+
+	req, ok := decodeModerationRule(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := a.store.CreateModerationRule(r.Context(), database.CreateModerationRuleParams{
+		Kind:     req.Kind,
+		Pattern:  req.Pattern,
+		Action:   req.Action,
+		Position: req.Position,
+	})
+	if err != nil {
+		a.logger.Printf("Error(CreateModerationRule): db create moderation rule: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	a.rulesChanged(r.Context())
+	response.JSON(w, http.StatusCreated, newModerationRuleResponse(rule))
+}
+
+func (a *Admin) UpdateModerationRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.moderatorID(w, r); !ok {
+		return
+	}
+
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		response.Invalid(w, "ruleID", response.FieldInvalid, "invalid rule id")
+		return
+	}
+
+	req, ok := decodeModerationRule(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := a.store.UpdateModerationRule(r.Context(), database.UpdateModerationRuleParams{
+		ID:       ruleID,
+		Kind:     req.Kind,
+		Pattern:  req.Pattern,
+		Action:   req.Action,
+		Position: req.Position,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			response.NotFound(w)
+			return
+		}
+
+		a.logger.Printf("Error(UpdateModerationRule): db update moderation rule (rule_id=%s): %v", ruleID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	a.rulesChanged(r.Context())
+	response.JSON(w, http.StatusOK, newModerationRuleResponse(rule))
+}
+
+func (a *Admin) DeleteModerationRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.moderatorID(w, r); !ok {
+		return
+	}
+
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		response.Invalid(w, "ruleID", response.FieldInvalid, "invalid rule id")
+		return
+	}
+
+	rowsAffected, err := a.store.DeleteModerationRule(r.Context(), ruleID)
+	if err != nil {
+		a.logger.Printf("Error(DeleteModerationRule): db delete moderation rule (rule_id=%s): %v", ruleID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if rowsAffected == 0 {
+		response.NotFound(w)
+		return
+	}
+
+	a.rulesChanged(r.Context())
+	response.NoContent(w)
+}
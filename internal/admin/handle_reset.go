@@ -13,7 +13,7 @@ type resetResponse struct {
 func (a *Admin) Reset(w http.ResponseWriter, r *http.Request) {
 	a.metrics.Reset()
 
-	err := a.dbQueries.DeleteAllUsers(r.Context())
+	err := a.store.DeleteAllUsers(r.Context())
 	if err != nil {
 		response.InternalServerError(w)
 		return
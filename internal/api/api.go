@@ -4,13 +4,32 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
+	"sync"
 
+	"github.com/absurek/go-http-servers/internal/activitypub"
+	"github.com/absurek/go-http-servers/internal/blocks"
 	"github.com/absurek/go-http-servers/internal/chirps"
 	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/drafts"
+	"github.com/absurek/go-http-servers/internal/entitlements"
+	"github.com/absurek/go-http-servers/internal/exports"
+	"github.com/absurek/go-http-servers/internal/follows"
+	"github.com/absurek/go-http-servers/internal/idempotency"
+	"github.com/absurek/go-http-servers/internal/media"
+	"github.com/absurek/go-http-servers/internal/messages"
 	"github.com/absurek/go-http-servers/internal/metrics"
+	"github.com/absurek/go-http-servers/internal/moderation"
+	"github.com/absurek/go-http-servers/internal/notifications"
 	"github.com/absurek/go-http-servers/internal/polka"
+	"github.com/absurek/go-http-servers/internal/ratelimit"
+	"github.com/absurek/go-http-servers/internal/reports"
 	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/stream"
+	"github.com/absurek/go-http-servers/internal/subscriptions"
 	"github.com/absurek/go-http-servers/internal/users"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/absurek/go-http-servers/internal/webhooks"
+	"github.com/absurek/go-http-servers/internal/ws"
 )
 
 type Api struct {
@@ -20,15 +39,61 @@ type Api struct {
 	metrics   *metrics.Metrics
 	logger    *log.Logger
 
-	usersHandler  *users.UsersHandler
-	chirpsHandler *chirps.ChirpsHandler
-	polkaHandler  *polka.PolkaHandler
+	entitlements  *entitlements.Service
+	subscriptions *subscriptions.Service
+	limiter       *ratelimit.Limiter
+	keys          *idempotency.Keys
+
+	usersHandler   *users.UsersHandler
+	blocksHandler  *blocks.BlocksHandler
+	followsHandler *follows.FollowsHandler
+	chirpsHandler  *chirps.ChirpsHandler
+	draftsHandler  *drafts.DraftsHandler
+	entsHandler    *entitlements.EntitlementsHandler
+	exportsHandler *exports.ExportsHandler
+	mediaHandler   *media.MediaHandler
+	// Nil unless message keys are configured.
+	msgsHandler    *messages.MessagesHandler
+	notifsHandler  *notifications.NotificationsHandler
+	reportsHandler *reports.ReportsHandler
+	streamHandler  *stream.StreamHandler
+	wsHandler      *ws.WSHandler
+	subsHandler    *subscriptions.SubscriptionsHandler
+	hooksHandler   *webhooks.WebhooksHandler
+	polkaHandler   *polka.PolkaHandler
+	// Nil unless federation is enabled.
+	apHandler *activitypub.ActivityPubHandler
+
+	// The OpenAPI document, built on first request.
+	specOnce sync.Once
+	spec     []byte
+	specErr  error
 }
 
-func NewApi(s settings.Settings, db *sql.DB, dbQueries *database.Queries, metrics *metrics.Metrics, logger *log.Logger) *Api {
+func NewApi(s settings.Settings, db *sql.DB, dbQueries *database.Queries, blobStore media.BlobStore, keyring *messages.Keyring, broker *stream.Broker, hub *ws.Hub, notifier *notifications.Notifier, filter *visibility.Filter, moderator *moderation.Moderator, apHandler *activitypub.ActivityPubHandler, metrics *metrics.Metrics, logger *log.Logger) *Api {
+	entitlementsService := entitlements.NewService(dbQueries)
+	subscriptionsService := subscriptions.NewService(db, dbQueries, entitlementsService)
+
 	usersHandler := users.NewUsersHandler(s, db, dbQueries, logger)
-	chirpsHandler := chirps.NewChirpsHandler(s, db, dbQueries, logger)
-	polkaHandler := polka.NewPolkaHandler(s, db, dbQueries, logger)
+	blocksHandler := blocks.NewBlocksHandler(s, db, dbQueries, logger)
+	followsHandler := follows.NewFollowsHandler(s, dbQueries, filter, notifier, logger)
+	chirpsHandler := chirps.NewChirpsHandler(s, db, dbQueries, blobStore, stream.NewPublisher(dbQueries), notifier, filter, moderator, entitlementsService, logger)
+	draftsHandler := drafts.NewDraftsHandler(s, db, dbQueries, chirpsHandler, entitlementsService, logger)
+	entsHandler := entitlements.NewEntitlementsHandler(s, entitlementsService, logger)
+	exportsHandler := exports.NewExportsHandler(s, db, dbQueries, blobStore, logger)
+	mediaHandler := media.NewMediaHandler(s, db, dbQueries, blobStore, logger)
+	// Direct messages are encrypted at rest, without keys there are none.
+	var msgsHandler *messages.MessagesHandler
+	if keyring != nil {
+		msgsHandler = messages.NewMessagesHandler(s, db, dbQueries, keyring, filter, moderator, logger)
+	}
+	notifsHandler := notifications.NewNotificationsHandler(s, dbQueries, logger)
+	reportsHandler := reports.NewReportsHandler(s, db, dbQueries, filter, logger)
+	streamHandler := stream.NewStreamHandler(s, dbQueries, broker, filter, logger)
+	wsHandler := ws.NewWSHandler(s, dbQueries, hub, chirpsHandler, filter, logger)
+	subsHandler := subscriptions.NewSubscriptionsHandler(s, dbQueries, entitlementsService, logger)
+	hooksHandler := webhooks.NewWebhooksHandler(s, db, dbQueries, logger)
+	polkaHandler := polka.NewPolkaHandler(s, db, dbQueries, subscriptionsService, logger)
 
 	return &Api{
 		settings:  s,
@@ -37,25 +102,145 @@ func NewApi(s settings.Settings, db *sql.DB, dbQueries *database.Queries, metric
 		metrics:   metrics,
 		logger:    logger,
 
-		usersHandler:  usersHandler,
-		chirpsHandler: chirpsHandler,
-		polkaHandler:  polkaHandler,
+		entitlements:  entitlementsService,
+		subscriptions: subscriptionsService,
+		limiter:       ratelimit.NewLimiter(),
+		keys:          idempotency.NewKeys(s, dbQueries, logger),
+
+		usersHandler:   usersHandler,
+		blocksHandler:  blocksHandler,
+		followsHandler: followsHandler,
+		chirpsHandler:  chirpsHandler,
+		draftsHandler:  draftsHandler,
+		entsHandler:    entsHandler,
+		exportsHandler: exportsHandler,
+		mediaHandler:   mediaHandler,
+		msgsHandler:    msgsHandler,
+		notifsHandler:  notifsHandler,
+		reportsHandler: reportsHandler,
+		streamHandler:  streamHandler,
+		wsHandler:      wsHandler,
+		subsHandler:    subsHandler,
+		hooksHandler:   hooksHandler,
+		polkaHandler:   polkaHandler,
+		apHandler:      apHandler,
 	}
 }
 
-func (a *Api) SetupRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /api/healthz", a.GetHealthz)
+// ChirpsHandler is shared with the admin routes for moderation.
+func (a *Api) ChirpsHandler() *chirps.ChirpsHandler {
+	return a.chirpsHandler
+}
+
+// SubscriptionsService is shared with the subscription expirer.
+func (a *Api) SubscriptionsService() *subscriptions.Service {
+	return a.subscriptions
+}
+
+// route is an entry of the route table. Every route needs an Operation
+// documenting it, see operations.
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+func (a *Api) routes() []route {
+	routes := []route{
+		{"GET /api/healthz", a.GetHealthz},
+		{"GET /api/openapi.json", a.GetOpenAPI},
+		{"GET /api/docs", a.GetDocs},
+
+		{"POST /api/users", a.usersHandler.CreateUser},
+		{"PUT /api/users", a.usersHandler.UpdateUser},
+		{"DELETE /api/users", a.usersHandler.DeleteUser},
+		{"POST /api/users/restore", a.usersHandler.RestoreUser},
+		{"POST /api/login", a.usersHandler.Login},
+		{"POST /api/refresh", a.usersHandler.Refresh},
+		{"POST /api/revoke", a.usersHandler.Revoke},
+
+		{"POST /api/users/{userID}/block", a.blocksHandler.Block},
+		{"DELETE /api/users/{userID}/block", a.blocksHandler.Unblock},
+		{"POST /api/users/{userID}/mute", a.blocksHandler.Mute},
+		{"DELETE /api/users/{userID}/mute", a.blocksHandler.Unmute},
+		{"POST /api/users/{userID}/follow", a.followsHandler.Follow},
+		{"DELETE /api/users/{userID}/follow", a.followsHandler.Unfollow},
+		{"DELETE /api/me", a.usersHandler.DeleteUser},
+		{"GET /api/me/entitlements", a.entsHandler.GetEntitlements},
+		{"GET /api/me/subscription", a.subsHandler.GetSubscription},
+		{"GET /api/me/export", a.exportsHandler.GetExport},
+		{"GET " + exports.DownloadPathPrefix + "{exportID}", a.exportsHandler.Download},
+		{"GET /api/me/blocks", a.blocksHandler.GetBlocks},
+		{"GET /api/me/mutes", a.blocksHandler.GetMutes},
+		{"GET /api/me/following", a.followsHandler.GetFollowing},
+		{"POST /api/users/{userID}/report", a.reportsHandler.ReportUser},
+
+		{"GET /api/chirps", a.chirpsHandler.GetAllChirps},
+		{"POST /api/chirps", a.chirpsHandler.CreateChirp},
+		{"GET /api/chirps/{chirpID}", a.chirpsHandler.GetChirp},
+		{"PUT /api/chirps/{chirpID}", a.chirpsHandler.UpdateChirp},
+		{"DELETE /api/chirps/{chirpID}", a.chirpsHandler.DeleteChirp},
+		{"POST /api/chirps/{chirpID}/restore", a.chirpsHandler.RestoreChirp},
+		{"POST /api/chirps/{chirpID}/report", a.reportsHandler.ReportChirp},
 
-	mux.HandleFunc("POST /api/users", a.usersHandler.CreateUser)
-	mux.HandleFunc("PUT /api/users", a.usersHandler.UpdateUser)
-	mux.HandleFunc("POST /api/login", a.usersHandler.Login)
-	mux.HandleFunc("POST /api/refresh", a.usersHandler.Refresh)
-	mux.HandleFunc("POST /api/revoke", a.usersHandler.Revoke)
+		{"GET /api/drafts", a.draftsHandler.GetDrafts},
+		{"POST /api/drafts", a.draftsHandler.CreateDraft},
+		{"GET /api/drafts/{draftID}", a.draftsHandler.GetDraft},
+		{"PUT /api/drafts/{draftID}", a.draftsHandler.UpdateDraft},
+		{"DELETE /api/drafts/{draftID}", a.draftsHandler.DeleteDraft},
+		{"POST /api/drafts/{draftID}/publish", a.draftsHandler.PublishDraft},
 
-	mux.HandleFunc("GET /api/chirps", a.chirpsHandler.GetAllChirps)
-	mux.HandleFunc("POST /api/chirps", a.chirpsHandler.CreateChirp)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", a.chirpsHandler.GetChirp)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", a.chirpsHandler.DeleteChirp)
+		{"POST /api/attachments", a.mediaHandler.UploadAttachment},
+
+		{"GET /api/notifications", a.notifsHandler.GetNotifications},
+		{"POST /api/notifications/read", a.notifsHandler.MarkRead},
+		{"GET /api/notifications/preferences", a.notifsHandler.GetPreferences},
+		{"PUT /api/notifications/preferences", a.notifsHandler.UpdatePreferences},
+
+		{"GET /api/stream", a.streamHandler.GetStream},
+		{"GET /api/ws", a.wsHandler.Connect},
+		{"POST /api/ws/tickets", a.wsHandler.CreateTicket},
+
+		{"POST /api/polka/webhooks", a.polkaHandler.Webhooks},
+
+		{"POST /api/webhooks", a.hooksHandler.CreateWebhook},
+		{"GET /api/webhooks", a.hooksHandler.GetWebhooks},
+		{"DELETE /api/webhooks/{webhookID}", a.hooksHandler.DeleteWebhook},
+		{"GET /api/webhooks/{webhookID}/deliveries", a.hooksHandler.GetDeliveries},
+		{"POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", a.hooksHandler.Redeliver},
+
+		{"GET /api/v2/chirps", a.chirpsHandler.GetChirpsV2},
+		{"GET /api/v2/chirps/{chirpID}", a.chirpsHandler.GetChirpV2},
+	}
+
+	if a.msgsHandler != nil {
+		routes = append(routes, []route{
+			{"GET /api/conversations", a.msgsHandler.GetConversations},
+			{"POST /api/conversations", a.msgsHandler.CreateConversation},
+			{"GET /api/conversations/{conversationID}/messages", a.msgsHandler.GetMessages},
+			{"POST /api/conversations/{conversationID}/messages", a.msgsHandler.SendMessage},
+			{"POST /api/conversations/{conversationID}/read", a.msgsHandler.MarkRead},
+		}...)
+	}
+
+	if a.apHandler != nil {
+		routes = append(routes, []route{
+			{"POST /api/federation/follows", a.apHandler.Follow},
+			{"GET /api/federation/follows", a.apHandler.GetFollows},
+			{"DELETE /api/federation/follows", a.apHandler.Unfollow},
+			{"GET /api/federation/notes", a.apHandler.GetRemoteNotes},
+		}...)
+	}
+
+	return routes
+}
+
+// SetupRoutes mounts the API under /api/, every request goes through the
+// rate limiter first. v1 and v2 routes are mounted side by side.
+func (a *Api) SetupRoutes(mux *http.ServeMux) {
+	apiMux := http.NewServeMux()
+	for _, route := range a.routes() {
+		apiMux.HandleFunc(route.pattern, a.versioned(route))
+	}
 
-	mux.HandleFunc("POST /api/polka/webhooks", a.polkaHandler.Webhooks)
+	mux.Handle("/api/", a.rateLimit(a.keys.Middleware(apiMux)))
 }
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/absurek/go-http-servers/internal/activitypub"
+	"github.com/absurek/go-http-servers/internal/blocks"
+	"github.com/absurek/go-http-servers/internal/chirps"
+	"github.com/absurek/go-http-servers/internal/drafts"
+	"github.com/absurek/go-http-servers/internal/entitlements"
+	"github.com/absurek/go-http-servers/internal/exports"
+	"github.com/absurek/go-http-servers/internal/follows"
+	"github.com/absurek/go-http-servers/internal/idempotency"
+	"github.com/absurek/go-http-servers/internal/media"
+	"github.com/absurek/go-http-servers/internal/messages"
+	"github.com/absurek/go-http-servers/internal/notifications"
+	"github.com/absurek/go-http-servers/internal/openapi"
+	"github.com/absurek/go-http-servers/internal/polka"
+	"github.com/absurek/go-http-servers/internal/reports"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/stream"
+	"github.com/absurek/go-http-servers/internal/subscriptions"
+	"github.com/absurek/go-http-servers/internal/users"
+	"github.com/absurek/go-http-servers/internal/webhooks"
+	"github.com/absurek/go-http-servers/internal/ws"
+)
+
+const (
+	specTitle   = "Chirpy API"
+	specVersion = "1"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:   "GET /api/healthz",
+		Tag:       "meta",
+		Summary:   "Check that the server is up",
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "OK", ContentType: "text/plain", Body: openapi.Schema{"type": "string"}}},
+	},
+	{
+		Pattern:   "GET /api/openapi.json",
+		Tag:       "meta",
+		Summary:   "Get this document",
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The OpenAPI document", Body: openapi.Schema{"type": "object"}}},
+	},
+	{
+		Pattern:   "GET /api/docs",
+		Tag:       "meta",
+		Summary:   "Browse this document",
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "Interactive docs", ContentType: "text/html", Body: openapi.Schema{"type": "string"}}},
+	},
+}
+
+// allOperations documents every route the API may have.
+func allOperations() []openapi.Operation {
+	return slices.Concat(
+		Operations,
+		users.Operations,
+		blocks.Operations,
+		follows.Operations,
+		entitlements.Operations,
+		subscriptions.Operations,
+		exports.Operations,
+		reports.Operations,
+		chirps.Operations,
+		drafts.Operations,
+		media.Operations,
+		messages.Operations,
+		notifications.Operations,
+		stream.Operations,
+		ws.Operations,
+		polka.Operations,
+		webhooks.Operations,
+		activitypub.Operations,
+	)
+}
+
+var idempotencyKeyParam = openapi.Header(idempotency.Header, "Makes retries safe: the first response to a key is replayed to retries for 24 hours, with Idempotent-Replayed: true. "+
+	"Reusing a key for a different request is answered 422, a retry while the first request still runs 409.")
+
+// idempotent reports whether the route's method takes an Idempotency-Key.
+func idempotent(pattern string) bool {
+	method, _, _ := strings.Cut(pattern, " ")
+	return slices.Contains(idempotency.Methods, method)
+}
+
+// operations documents the routes the API has, in route table order. Every
+// request may also be turned away by the rate limiter, those of signed in
+// users to mutating routes take an Idempotency-Key, and routes with a
+// successor are deprecated.
+func (a *Api) operations() []openapi.Operation {
+	byPattern := make(map[string]openapi.Operation)
+	for _, op := range allOperations() {
+		byPattern[op.Pattern] = op
+	}
+
+	var ops []openapi.Operation
+	for _, route := range a.routes() {
+		op, ok := byPattern[route.pattern]
+		if !ok {
+			continue
+		}
+
+		op.Errors = slices.Concat(op.Errors, []int{http.StatusTooManyRequests})
+		if op.Auth == openapi.AuthBearer && idempotent(route.pattern) {
+			op.Params = slices.Concat(op.Params, []openapi.Param{idempotencyKeyParam})
+			op.Errors = slices.Concat(op.Errors, []int{http.StatusConflict, http.StatusUnprocessableEntity})
+		}
+
+		if successor, ok := successors[route.pattern]; ok {
+			op.Deprecated = true
+			op.Description = strings.TrimSpace(op.Description + " Deprecated in favor of " + successor + ", answers carry Deprecation, Sunset and Link headers until it is removed at the sunset.")
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops
+}
+
+func (a *Api) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	a.specOnce.Do(func() {
+		a.spec, a.specErr = openapi.Build(specTitle, specVersion, a.operations())
+	})
+
+	if a.specErr != nil {
+		a.logger.Printf("Error(GetOpenAPI): build spec: %v", a.specErr)
+		response.InternalServerError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(a.spec)
+}
+
+// docsPage renders /api/openapi.json with Swagger UI.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Chirpy API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="docs"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#docs" });
+  </script>
+</body>
+</html>
+`
+
+func (a *Api) GetDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(docsPage))
+}
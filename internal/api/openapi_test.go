@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/absurek/go-http-servers/internal/activitypub"
+	"github.com/absurek/go-http-servers/internal/messages"
+)
+
+// testApi has every optional route, handlers are never called.
+func testApi() *Api {
+	return &Api{
+		msgsHandler: &messages.MessagesHandler{},
+		apHandler:   &activitypub.ActivityPubHandler{},
+	}
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	documented := make(map[string]bool)
+	for _, op := range allOperations() {
+		if documented[op.Pattern] {
+			t.Errorf("%s is documented twice", op.Pattern)
+		}
+		documented[op.Pattern] = true
+	}
+
+	routed := make(map[string]bool)
+	for _, route := range testApi().routes() {
+		if !documented[route.pattern] {
+			t.Errorf("route %s has no OpenAPI operation, add one next to its handler", route.pattern)
+		}
+		routed[route.pattern] = true
+	}
+
+	for pattern := range documented {
+		if !routed[pattern] {
+			t.Errorf("operation %s documents a route that doesn't exist", pattern)
+		}
+	}
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	testApi().GetOpenAPI(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	err := json.Unmarshal(rec.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatalf("spec doesn't parse: %v", err)
+	}
+
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q, want 3.1.0", doc.OpenAPI)
+	}
+
+	if len(doc.Paths["/api/chirps/{chirpID}"]) != 3 {
+		t.Errorf("/api/chirps/{chirpID} has %d operations, want 3", len(doc.Paths["/api/chirps/{chirpID}"]))
+	}
+
+	// Every reference resolves.
+	for _, ref := range refs(rec.Body.String()) {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("%s doesn't resolve", ref)
+		}
+	}
+}
+
+func refs(spec string) []string {
+	var found []string
+	for _, part := range strings.Split(spec, `"$ref": "`)[1:] {
+		ref, _, _ := strings.Cut(part, `"`)
+		found = append(found, ref)
+	}
+
+	return found
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/entitlements"
+	"github.com/absurek/go-http-servers/internal/response"
+)
+
+// rateLimit counts every API request against the caller's entitlements.
+// Signed in users are counted by user ID, everyone else by IP address with
+// the free tier's limit. A broken token counts as anonymous, the handler
+// rejects it anyway.
+func (a *Api) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + clientIP(r)
+		limits := entitlements.Free
+
+		userID, err := auth.GetOptionalUserID(r.Header, a.settings.JWTSecret)
+		if err == nil && userID.Valid {
+			key = "user:" + userID.UUID.String()
+
+			limits, err = a.entitlements.ForUser(r.Context(), userID.UUID)
+			if err != nil {
+				a.logger.Printf("Error(rateLimit): entitlements (user_id=%s): %v", userID.UUID, err)
+				limits = entitlements.Free
+			}
+		}
+
+		res := a.limiter.Allow(key, limits.RequestsPerMinute, time.Now())
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+			response.TooManyRequests(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The API is versioned by path: v1 routes are under /api/, v2 routes under
+// /api/v2/. A v2 route changes the shape of a v1 one and shares its
+// handler's logic. The v1 route it replaces is deprecated until the sunset,
+// after which it can be removed.
+
+const v2Prefix = "/api/v2/"
+
+// successors maps deprecated routes to the routes replacing them.
+var successors = map[string]string{
+	"GET /api/chirps":           "GET /api/v2/chirps",
+	"GET /api/chirps/{chirpID}": "GET /api/v2/chirps/{chirpID}",
+}
+
+var (
+	// When the v1 routes with a successor were deprecated, and when they
+	// will stop working.
+	v1DeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	v1SunsetAt     = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// apiVersion is the version a route pattern belongs to.
+func apiVersion(pattern string) string {
+	_, path, _ := strings.Cut(pattern, " ")
+	if strings.HasPrefix(path, v2Prefix) {
+		return "v2"
+	}
+
+	return "v1"
+}
+
+// versioned counts requests to the route by version. Deprecated routes
+// also say so, per RFC 9745 and RFC 8594, and link to their successor.
+func (a *Api) versioned(route route) http.HandlerFunc {
+	version := apiVersion(route.pattern)
+	successor := successors[route.pattern]
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.metrics.AddAPIRequest(version)
+
+		if successor != "" {
+			a.metrics.AddDeprecatedRequest(route.pattern)
+
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", v1DeprecatedAt.Unix()))
+			w.Header().Set("Sunset", v1SunsetAt.Format(http.TimeFormat))
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successorPath(successor, r)))
+		}
+
+		route.handler(w, r)
+	}
+}
+
+// successorPath fills in the path parameters of the successor's pattern
+// with those of the request.
+func successorPath(successor string, r *http.Request) string {
+	_, path, _ := strings.Cut(successor, " ")
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, "{")
+		if !ok {
+			continue
+		}
+
+		name = strings.TrimSuffix(strings.TrimSuffix(name, "}"), "...")
+		segments[i] = url.PathEscape(r.PathValue(name))
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/absurek/go-http-servers/internal/metrics"
+)
+
+func TestSuccessorsRouted(t *testing.T) {
+	routed := make(map[string]bool)
+	for _, route := range testApi().routes() {
+		routed[route.pattern] = true
+	}
+
+	for deprecated, successor := range successors {
+		if !routed[deprecated] {
+			t.Errorf("deprecated route %s doesn't exist", deprecated)
+		}
+
+		if !routed[successor] {
+			t.Errorf("successor %s of %s doesn't exist", successor, deprecated)
+		}
+	}
+}
+
+func TestVersioned(t *testing.T) {
+	m := metrics.NewMetrics(log.New(io.Discard, "", 0))
+	a := &Api{metrics: m}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	mux := http.NewServeMux()
+	for _, route := range []route{
+		{"GET /api/chirps/{chirpID}", ok},
+		{"GET /api/v2/chirps/{chirpID}", ok},
+		{"POST /api/chirps", ok},
+	} {
+		mux.HandleFunc(route.pattern, a.versioned(route))
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		deprecated bool
+		link       string
+	}{
+		{name: "deprecated", method: http.MethodGet, path: "/api/chirps/abc", deprecated: true, link: `</api/v2/chirps/abc>; rel="successor-version"`},
+		{name: "successor", method: http.MethodGet, path: "/api/v2/chirps/abc"},
+		{name: "current v1", method: http.MethodPost, path: "/api/chirps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if got := rec.Header().Get("Deprecation") != ""; got != tt.deprecated {
+				t.Errorf("Deprecation = %q, want deprecated %v", rec.Header().Get("Deprecation"), tt.deprecated)
+			}
+
+			if got := rec.Header().Get("Sunset") != ""; got != tt.deprecated {
+				t.Errorf("Sunset = %q, want deprecated %v", rec.Header().Get("Sunset"), tt.deprecated)
+			}
+
+			if got := rec.Header().Get("Link"); got != tt.link {
+				t.Errorf("Link = %q, want %q", got, tt.link)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	m.GetAPIVersionMetrics(rec, httptest.NewRequest(http.MethodGet, "/admin/metrics/api-versions", nil))
+
+	var got struct {
+		Requests   map[string]int64 `json:"requests"`
+		Deprecated map[string]int64 `json:"deprecated"`
+	}
+	err := json.Unmarshal(rec.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("metrics don't parse: %v", err)
+	}
+
+	if got.Requests["v1"] != 2 || got.Requests["v2"] != 1 {
+		t.Errorf("requests = %v, want 2 to v1 and 1 to v2", got.Requests)
+	}
+
+	if got.Deprecated["GET /api/chirps/{chirpID}"] != 1 || len(got.Deprecated) != 1 {
+		t.Errorf("deprecated = %v, want 1 to GET /api/chirps/{chirpID}", got.Deprecated)
+	}
+}
+
+func TestDeprecatedDocumented(t *testing.T) {
+	for _, op := range testApi().operations() {
+		_, deprecated := successors[op.Pattern]
+		if op.Deprecated != deprecated {
+			t.Errorf("%s: deprecated = %v, want %v", op.Pattern, op.Deprecated, deprecated)
+		}
+	}
+}
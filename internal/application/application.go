@@ -8,24 +8,57 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/absurek/go-http-servers/internal/activitypub"
 	"github.com/absurek/go-http-servers/internal/admin"
 	"github.com/absurek/go-http-servers/internal/api"
+	"github.com/absurek/go-http-servers/internal/chirps"
 	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/exports"
+	"github.com/absurek/go-http-servers/internal/feeds"
+	"github.com/absurek/go-http-servers/internal/jobs"
+	"github.com/absurek/go-http-servers/internal/media"
+	"github.com/absurek/go-http-servers/internal/messages"
 	"github.com/absurek/go-http-servers/internal/metrics"
+	"github.com/absurek/go-http-servers/internal/moderation"
+	"github.com/absurek/go-http-servers/internal/notifications"
+	"github.com/absurek/go-http-servers/internal/outbox"
+	"github.com/absurek/go-http-servers/internal/purge"
+	"github.com/absurek/go-http-servers/internal/response"
 	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/stream"
+	"github.com/absurek/go-http-servers/internal/subscriptions"
+	"github.com/absurek/go-http-servers/internal/users"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/absurek/go-http-servers/internal/webhooks"
 	"github.com/absurek/go-http-servers/internal/website"
+	"github.com/absurek/go-http-servers/internal/ws"
 	_ "github.com/lib/pq"
 )
 
+const (
+	streamReplaySize  = 1024
+	defaultJobWorkers = 4
+)
+
 type Application struct {
 	settings settings.Settings
 	db       *sql.DB
 	server   *http.Server
 	logger   *log.Logger
 
+	broker             *stream.Broker
+	streamListener     *stream.Listener
+	moderationListener *moderation.Listener
+	notifier           *notifications.Notifier
+	scheduler          *chirps.Scheduler
+	queue              *jobs.Queue
+	deliverer          *webhooks.Deliverer
+	relay              *outbox.Relay
+
 	metrics *metrics.Metrics
 	website *website.Website
 	admin   *admin.Admin
@@ -41,20 +74,147 @@ func NewApplication(addr string, logger *log.Logger) (*Application, error) {
 	}
 	dbQueries := database.New(db)
 
+	blobStore, err := media.NewBlobStore(settings)
+	if err != nil {
+		return nil, fmt.Errorf("blob store: %w", err)
+	}
+
+	// Without MESSAGE_KEYS the server runs without direct messages.
+	var keyring *messages.Keyring
+	if settings.MessageKeys != "" {
+		keyring, err = messages.NewKeyring(settings.MessageKeys)
+		if err != nil {
+			return nil, fmt.Errorf("message keys: %w", err)
+		}
+	} else {
+		logger.Printf("MESSAGE_KEYS is not set, direct messages are disabled")
+	}
+
+	broker := stream.NewBroker(streamReplaySize)
+	streamListener, err := stream.NewListener(settings.DBUrl, broker, logger)
+	if err != nil {
+		return nil, fmt.Errorf("stream listener: %w", err)
+	}
+
+	hub := ws.NewHub()
+	go func() {
+		err := hub.Run(broker)
+		if err != nil {
+			logger.Printf("ERROR: WebSocket hub: %v", err)
+		}
+	}()
+
+	moderator := moderation.NewModerator(dbQueries)
+	err = moderator.Reload(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("moderation rules: %w", err)
+	}
+
+	moderationListener, err := moderation.NewListener(settings.DBUrl, moderator, logger)
+	if err != nil {
+		return nil, fmt.Errorf("moderation listener: %w", err)
+	}
+
+	filter := visibility.NewFilter(dbQueries)
+	notifier := notifications.NewNotifier(dbQueries, filter, logger)
+
+	deliverer := webhooks.NewDeliverer(dbQueries, webhooks.NewSender(webhooks.NewClient()), logger)
+
 	mux := &http.ServeMux{}
 	metr := metrics.NewMetrics(logger)
 
-	website := website.NewWebsite(metr, logger)
+	website := website.NewWebsite(blobStore, metr, logger)
 	website.SetupRoutes(mux)
 
-	admin := admin.NewAdmin(db, dbQueries, metr, logger)
-	admin.SetupRoutes(mux)
+	feeds := feeds.NewFeeds(settings, dbQueries, logger)
+	feeds.SetupRoutes(mux)
 
-	api := api.NewApi(settings, db, dbQueries, metr, logger)
+	// ActivityPub IDs are absolute URLs, without BASE_URL the server doesn't
+	// federate.
+	var apHandler *activitypub.ActivityPubHandler
+	if settings.BaseURL != "" {
+		apHandler, err = activitypub.NewActivityPubHandler(settings, dbQueries, webhooks.NewClient(), logger)
+		if err != nil {
+			return nil, fmt.Errorf("activitypub: %w", err)
+		}
+		apHandler.SetupRoutes(mux)
+	}
+
+	api := api.NewApi(settings, db, dbQueries, blobStore, keyring, broker, hub, notifier, filter, moderator, apHandler, metr, logger)
 	api.SetupRoutes(mux)
 
+	scheduler := chirps.NewScheduler(api.ChirpsHandler(), logger)
+
+	dispatcher := webhooks.NewDispatcher(dbQueries)
+	relay := outbox.NewRelay(db, dbQueries, metr, logger)
+	for _, event := range webhooks.Events {
+		relay.Subscribe(event, dispatcher.HandleOutbox)
+	}
+	if apHandler != nil {
+		for _, event := range activitypub.Events {
+			relay.Subscribe(event, apHandler.HandleOutbox)
+		}
+	}
+	relay.Start()
+
+	jobWorkers := defaultJobWorkers
+	if settings.JobWorkers != "" {
+		jobWorkers, err = strconv.Atoi(settings.JobWorkers)
+		if err != nil || jobWorkers < 1 {
+			return nil, fmt.Errorf("invalid JOB_WORKERS %q", settings.JobWorkers)
+		}
+	}
+
+	queue := jobs.NewQueue(db, dbQueries, jobWorkers, logger)
+
+	purger := purge.NewPurger(dbQueries, blobStore, logger)
+	jobs.Register(queue, purge.Job, purger.Run)
+	err = jobs.Schedule(queue, "purge", "@hourly", purge.Job, struct{}{})
+	if err != nil {
+		return nil, fmt.Errorf("schedule purge: %w", err)
+	}
+
+	jobs.Register(queue, subscriptions.ExpireJob, api.SubscriptionsService().Expire)
+	err = jobs.Schedule(queue, "subscriptions.expire", "* * * * *", subscriptions.ExpireJob, struct{}{})
+	if err != nil {
+		return nil, fmt.Errorf("schedule expire subscriptions: %w", err)
+	}
+
+	exporter := exports.NewExporter(dbQueries, blobStore, logger)
+	jobs.Register(queue, exports.BuildJob, exporter.Run)
+
+	tokenCollector := users.NewTokenCollector(dbQueries, metr, logger)
+	jobs.Register(queue, users.TokenGCJob, tokenCollector.Run)
+	err = jobs.Schedule(queue, "refresh_tokens.gc", "@hourly", users.TokenGCJob, struct{}{})
+	if err != nil {
+		return nil, fmt.Errorf("schedule refresh token gc: %w", err)
+	}
+
+	if apHandler != nil {
+		jobs.Register(queue, activitypub.DeliverJob, apHandler.Deliver)
+	}
+
+	// Moves messages off retired keys after a rotation.
+	if keyring != nil {
+		resealer := messages.NewResealer(dbQueries, keyring, logger)
+		jobs.Register(queue, messages.ResealJob, resealer.Run)
+
+		err = messages.EnqueueReseal(context.Background(), dbQueries, keyring)
+		if err != nil {
+			return nil, fmt.Errorf("enqueue reseal: %w", err)
+		}
+	}
+
+	err = queue.Start(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("job queue: %w", err)
+	}
+
+	admin := admin.NewAdmin(settings, db, dbQueries, moderator, api.ChirpsHandler(), metr, logger)
+	admin.SetupRoutes(mux)
+
 	server := &http.Server{
-		Handler: mux,
+		Handler: response.Middleware(mux),
 		Addr:    addr,
 	}
 
@@ -63,10 +223,20 @@ func NewApplication(addr string, logger *log.Logger) (*Application, error) {
 		db:       db,
 		server:   server,
 		logger:   logger,
-		metrics:  metr,
-		website:  website,
-		admin:    admin,
-		api:      api,
+
+		broker:             broker,
+		streamListener:     streamListener,
+		moderationListener: moderationListener,
+		notifier:           notifier,
+		scheduler:          scheduler,
+		queue:              queue,
+		deliverer:          deliverer,
+		relay:              relay,
+
+		metrics: metr,
+		website: website,
+		admin:   admin,
+		api:     api,
 	}, nil
 }
 
@@ -75,6 +245,8 @@ func (a *Application) ListenAndServe() error {
 }
 
 func (a *Application) Close() error {
+	a.streamListener.Close()
+	a.moderationListener.Close()
 	return a.db.Close()
 }
 
@@ -88,7 +260,19 @@ func (a *Application) ListenForInterrupt() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Streams never go idle on their own, end them before waiting on the
+	// server to drain. Closing the broker also stops the WebSocket hub.
+	a.broker.Close()
+
 	if err := a.server.Shutdown(ctx); err != nil {
 		a.logger.Fatal("Graceful shutdown failed:", err)
 	}
+
+	a.scheduler.Close()
+	// Jobs still running when the shutdown deadline passes are canceled and
+	// picked up again after the restart.
+	a.queue.Close(ctx)
+	a.notifier.Close()
+	a.relay.Close()
+	a.deliverer.Close()
 }
@@ -82,3 +82,24 @@ func GetApiKey(headers http.Header) (string, error) {
 
 	return parts[1], nil
 }
+
+// GetOptionalUserID authenticates the request if it carries a bearer token.
+// No Authorization header means an anonymous request, a bad token is still an
+// error.
+func GetOptionalUserID(headers http.Header, tokenSecret string) (uuid.NullUUID, error) {
+	if headers.Get("Authorization") == "" {
+		return uuid.NullUUID{}, nil
+	}
+
+	token, err := GetBearerToken(headers)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+
+	userID, err := ValidateJWT(token, tokenSecret)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
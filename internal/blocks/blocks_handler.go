@@ -0,0 +1,226 @@
+package blocks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+type relationResponse struct {
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// store is the part of database.Queries the handler needs.
+type store interface {
+	UserExists(ctx context.Context, id uuid.UUID) (bool, error)
+	BlockUser(ctx context.Context, arg database.BlockUserParams) error
+	UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error)
+	GetBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]database.UserBlock, error)
+	MuteUser(ctx context.Context, arg database.MuteUserParams) error
+	UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) (int64, error)
+	GetMutedUsers(ctx context.Context, muterID uuid.UUID) ([]database.UserMute, error)
+}
+
+type BlocksHandler struct {
+	settings settings.Settings
+	db       *sql.DB
+	store    store
+	logger   *log.Logger
+}
+
+func NewBlocksHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, logger *log.Logger) *BlocksHandler {
+	return &BlocksHandler{
+		settings: s,
+		db:       db,
+		store:    dbQueries,
+		logger:   logger,
+	}
+}
+
+// target authenticates the request and resolves the {userID} path value. It
+// writes the error response itself and reports ok=false in that case.
+func (h *BlocksHandler) target(w http.ResponseWriter, r *http.Request) (userID, targetID uuid.UUID, ok bool) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	userID, err = auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	targetID, err = uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.Invalid(w, "userID", response.FieldInvalid, "invalid user id")
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if targetID == userID {
+		response.Error(w, http.StatusBadRequest, "self_target", "can't target yourself")
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	exists, err := h.store.UserExists(r.Context(), targetID)
+	if err != nil {
+		h.logger.Printf("Error(BlocksHandler): db user exists (user_id=%s): %v", targetID, err)
+		response.InternalServerError(w)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if !exists {
+		response.NotFound(w)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	return userID, targetID, true
+}
+
+func (h *BlocksHandler) Block(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(Block): db block user (user_id=%s, target_id=%s): %v", userID, targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *BlocksHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := h.store.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(Unblock): db unblock user (user_id=%s, target_id=%s): %v", userID, targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *BlocksHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(Mute): db mute user (user_id=%s, target_id=%s): %v", userID, targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *BlocksHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := h.store.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(Unmute): db unmute user (user_id=%s, target_id=%s): %v", userID, targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *BlocksHandler) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	blocks, err := h.store.GetBlockedUsers(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetBlocks): db get blocked users (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := []relationResponse{}
+	for _, block := range blocks {
+		resp = append(resp, relationResponse{
+			UserID:    block.BlockedID.String(),
+			CreatedAt: block.CreatedAt.Time,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+func (h *BlocksHandler) GetMutes(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	mutes, err := h.store.GetMutedUsers(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetMutes): db get muted users (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := []relationResponse{}
+	for _, mute := range mutes {
+		resp = append(resp, relationResponse{
+			UserID:    mute.MutedID.String(),
+			CreatedAt: mute.CreatedAt.Time,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,303 @@
+package blocks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+type relation struct {
+	from, to uuid.UUID
+}
+
+// memStore implements store and visibility.Store with the semantics of the
+// queries.
+type memStore struct {
+	users  map[uuid.UUID]bool
+	blocks map[relation]time.Time
+	mutes  map[relation]time.Time
+}
+
+func (s *memStore) UserExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	return s.users[id], nil
+}
+
+func (s *memStore) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	key := relation{arg.BlockerID, arg.BlockedID}
+	if _, ok := s.blocks[key]; !ok {
+		s.blocks[key] = time.Now()
+	}
+	return nil
+}
+
+func (s *memStore) UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error) {
+	key := relation{arg.BlockerID, arg.BlockedID}
+	if _, ok := s.blocks[key]; !ok {
+		return 0, nil
+	}
+
+	delete(s.blocks, key)
+	return 1, nil
+}
+
+func (s *memStore) GetBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]database.UserBlock, error) {
+	var blocks []database.UserBlock
+	for key, createdAt := range s.blocks {
+		if key.from == blockerID {
+			blocks = append(blocks, database.UserBlock{BlockerID: key.from, BlockedID: key.to, CreatedAt: sql.NullTime{Time: createdAt, Valid: true}})
+		}
+	}
+	return blocks, nil
+}
+
+func (s *memStore) MuteUser(ctx context.Context, arg database.MuteUserParams) error {
+	key := relation{arg.MuterID, arg.MutedID}
+	if _, ok := s.mutes[key]; !ok {
+		s.mutes[key] = time.Now()
+	}
+	return nil
+}
+
+func (s *memStore) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) (int64, error) {
+	key := relation{arg.MuterID, arg.MutedID}
+	if _, ok := s.mutes[key]; !ok {
+		return 0, nil
+	}
+
+	delete(s.mutes, key)
+	return 1, nil
+}
+
+func (s *memStore) GetMutedUsers(ctx context.Context, muterID uuid.UUID) ([]database.UserMute, error) {
+	var mutes []database.UserMute
+	for key, createdAt := range s.mutes {
+		if key.from == muterID {
+			mutes = append(mutes, database.UserMute{MuterID: key.from, MutedID: key.to, CreatedAt: sql.NullTime{Time: createdAt, Valid: true}})
+		}
+	}
+	return mutes, nil
+}
+
+func (s *memStore) GetVisibilityRelations(ctx context.Context, viewerID uuid.UUID) ([]database.GetVisibilityRelationsRow, error) {
+	var relations []database.GetVisibilityRelationsRow
+	for key := range s.blocks {
+		if key.from == viewerID {
+			relations = append(relations, database.GetVisibilityRelationsRow{UserID: key.to, Relation: "blocked"})
+		}
+		if key.to == viewerID {
+			relations = append(relations, database.GetVisibilityRelationsRow{UserID: key.from, Relation: "blocked_by"})
+		}
+	}
+	for key := range s.mutes {
+		if key.from == viewerID {
+			relations = append(relations, database.GetVisibilityRelationsRow{UserID: key.to, Relation: "muted"})
+		}
+	}
+	return relations, nil
+}
+
+func (s *memStore) IsBlockedEitherWay(ctx context.Context, arg database.IsBlockedEitherWayParams) (bool, error) {
+	_, blocked := s.blocks[relation{arg.BlockerID, arg.BlockedID}]
+	_, blockedBy := s.blocks[relation{arg.BlockedID, arg.BlockerID}]
+	return blocked || blockedBy, nil
+}
+
+type testUser struct {
+	id  uuid.UUID
+	jwt string
+}
+
+func newTestHandler(t *testing.T) (*BlocksHandler, *memStore, *visibility.Filter, testUser, testUser) {
+	t.Helper()
+
+	store := &memStore{
+		users:  make(map[uuid.UUID]bool),
+		blocks: make(map[relation]time.Time),
+		mutes:  make(map[relation]time.Time),
+	}
+
+	var users []testUser
+	for range 2 {
+		user := testUser{id: uuid.New()}
+		jwt, err := auth.MakeJWT(user.id, "secret", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.jwt = jwt
+
+		store.users[user.id] = true
+		users = append(users, user)
+	}
+
+	return &BlocksHandler{
+		settings: settings.Settings{JWTSecret: "secret"},
+		store:    store,
+		logger:   log.New(io.Discard, "", 0),
+	}, store, visibility.NewFilter(store), users[0], users[1]
+}
+
+// call runs a handler for the relation of user to target.
+func call(handler http.HandlerFunc, method string, user testUser, targetID uuid.UUID) int {
+	r := httptest.NewRequest(method, "/api/users/"+targetID.String()+"/block", nil)
+	r.SetPathValue("userID", targetID.String())
+	if user.jwt != "" {
+		r.Header.Set("Authorization", "Bearer "+user.jwt)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+func rulesFor(t *testing.T, filter *visibility.Filter, user testUser) *visibility.Rules {
+	t.Helper()
+
+	rules, err := filter.ForViewer(context.Background(), uuid.NullUUID{UUID: user.id, Valid: true})
+	if err != nil {
+		t.Fatalf("ForViewer() error = %v", err)
+	}
+	return rules
+}
+
+func TestBlock(t *testing.T) {
+	h, _, filter, alice, bob := newTestHandler(t)
+
+	if code := call(h.Block, http.MethodPost, alice, bob.id); code != http.StatusNoContent {
+		t.Fatalf("Block() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	// Blocking twice is fine.
+	if code := call(h.Block, http.MethodPost, alice, bob.id); code != http.StatusNoContent {
+		t.Fatalf("second Block() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	// A block works both ways.
+	if rulesFor(t, filter, alice).CanSee(bob.id) {
+		t.Error("the blocker still sees the blocked user")
+	}
+	if rulesFor(t, filter, bob).CanSee(alice.id) {
+		t.Error("the blocked user still sees the blocker")
+	}
+
+	for _, pair := range [][2]uuid.UUID{{alice.id, bob.id}, {bob.id, alice.id}} {
+		canInteract, err := filter.CanInteract(context.Background(), pair[0], pair[1])
+		if err != nil {
+			t.Fatalf("CanInteract() error = %v", err)
+		}
+		if canInteract {
+			t.Errorf("CanInteract(%s, %s) = true across a block", pair[0], pair[1])
+		}
+	}
+
+	if code := call(h.Unblock, http.MethodDelete, alice, bob.id); code != http.StatusNoContent {
+		t.Fatalf("Unblock() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if !rulesFor(t, filter, alice).InTimeline(bob.id) || !rulesFor(t, filter, bob).InTimeline(alice.id) {
+		t.Error("the users don't see each other after the unblock")
+	}
+}
+
+func TestMute(t *testing.T) {
+	h, _, filter, alice, bob := newTestHandler(t)
+
+	if code := call(h.Mute, http.MethodPost, alice, bob.id); code != http.StatusNoContent {
+		t.Fatalf("Mute() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	// A mute only affects the muter's timelines and notifications.
+	rules := rulesFor(t, filter, alice)
+	if !rules.CanSee(bob.id) || rules.InTimeline(bob.id) || rules.ShouldNotify(bob.id) {
+		t.Error("the muted user should be reachable directly but left out of timelines and notifications")
+	}
+	if !rulesFor(t, filter, bob).InTimeline(alice.id) {
+		t.Error("the muted user doesn't see the muter")
+	}
+
+	canInteract, err := filter.CanInteract(context.Background(), bob.id, alice.id)
+	if err != nil {
+		t.Fatalf("CanInteract() error = %v", err)
+	}
+	if !canInteract {
+		t.Error("a mute stops the muted user from interacting")
+	}
+
+	if code := call(h.Unmute, http.MethodDelete, alice, bob.id); code != http.StatusNoContent {
+		t.Fatalf("Unmute() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if !rulesFor(t, filter, alice).InTimeline(bob.id) {
+		t.Error("the user is still muted after the unmute")
+	}
+}
+
+func TestBlockTarget(t *testing.T) {
+	h, store, _, alice, bob := newTestHandler(t)
+
+	tests := []struct {
+		name     string
+		user     testUser
+		targetID uuid.UUID
+		want     int
+	}{
+		{name: "No token", user: testUser{id: alice.id}, targetID: bob.id, want: http.StatusUnauthorized},
+		{name: "Self", user: alice, targetID: alice.id, want: http.StatusBadRequest},
+		{name: "Unknown user", user: alice, targetID: uuid.New(), want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, handler := range map[string]http.HandlerFunc{"Block": h.Block, "Mute": h.Mute} {
+				if code := call(handler, http.MethodPost, tt.user, tt.targetID); code != tt.want {
+					t.Errorf("%s() = %d, want %d", name, code, tt.want)
+				}
+			}
+		})
+	}
+
+	if len(store.blocks) != 0 || len(store.mutes) != 0 {
+		t.Errorf("rejected requests stored %d blocks and %d mutes", len(store.blocks), len(store.mutes))
+	}
+}
+
+func TestGetBlocks(t *testing.T) {
+	h, _, _, alice, bob := newTestHandler(t)
+	call(h.Block, http.MethodPost, alice, bob.id)
+
+	for name, handler := range map[string]http.HandlerFunc{"GetBlocks": h.GetBlocks, "GetMutes": h.GetMutes} {
+		r := httptest.NewRequest(http.MethodGet, "/api/me/blocks", nil)
+		r.Header.Set("Authorization", "Bearer "+alice.jwt)
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s() = %d, want %d", name, w.Code, http.StatusOK)
+		}
+
+		var resp []relationResponse
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		if err != nil {
+			t.Fatalf("unmarshal %s: %v", name, err)
+		}
+
+		want := 0
+		if name == "GetBlocks" {
+			want = 1
+		}
+		if len(resp) != want || (want == 1 && resp[0].UserID != bob.id.String()) {
+			t.Errorf("%s() = %+v, want %d entries", name, resp, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package blocks
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var targetErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound}
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:   "POST /api/users/{userID}/block",
+		Tag:       "blocks",
+		Summary:   "Block a user",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Blocked"}},
+		Errors:    targetErrors,
+	},
+	{
+		Pattern:   "DELETE /api/users/{userID}/block",
+		Tag:       "blocks",
+		Summary:   "Unblock a user",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Unblocked"}},
+		Errors:    targetErrors,
+	},
+	{
+		Pattern:   "POST /api/users/{userID}/mute",
+		Tag:       "blocks",
+		Summary:   "Mute a user",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Muted"}},
+		Errors:    targetErrors,
+	},
+	{
+		Pattern:   "DELETE /api/users/{userID}/mute",
+		Tag:       "blocks",
+		Summary:   "Unmute a user",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Unmuted"}},
+		Errors:    targetErrors,
+	},
+	{
+		Pattern:   "GET /api/me/blocks",
+		Tag:       "blocks",
+		Summary:   "List blocked users",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "Blocked users", Body: []relationResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "GET /api/me/mutes",
+		Tag:       "blocks",
+		Summary:   "List muted users",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "Muted users", Body: []relationResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+}
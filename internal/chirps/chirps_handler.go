@@ -1,70 +1,195 @@
 package chirps
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/chirptext"
 	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/entitlements"
+	"github.com/absurek/go-http-servers/internal/media"
+	"github.com/absurek/go-http-servers/internal/moderation"
+	"github.com/absurek/go-http-servers/internal/notifications"
+	"github.com/absurek/go-http-servers/internal/outbox"
 	"github.com/absurek/go-http-servers/internal/request"
 	"github.com/absurek/go-http-servers/internal/response"
 	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/stream"
+	"github.com/absurek/go-http-servers/internal/visibility"
 	"github.com/google/uuid"
 )
 
-var profanes = [...]string{
-	"kerfuffle",
-	"sharbert",
-	"fornax",
+const (
+	hiddenNotice = "This chirp was hidden by a moderator, only you can see it."
+
+	// How far ahead a chirp can be scheduled.
+	maxScheduleAhead = 365 * 24 * time.Hour
+
+	// How long authors can restore a chirp they deleted. The purge job keeps
+	// deleted rows at least this long.
+	RestoreGracePeriod = 30 * 24 * time.Hour
+)
+
+var (
+	ErrChirpTooLong       = errors.New("Chirp is too long")
+	ErrTooManyAttachments = errors.New("too many attachments")
+	ErrInvalidAttachment  = errors.New("invalid attachment id")
+	ErrChirpRejected      = errors.New("Chirp was rejected by moderation")
+	ErrUserSuspended      = errors.New("account is suspended")
+	ErrInvalidPublishAt   = errors.New("publish_at must be in the future and within a year")
+	ErrEditWindowClosed   = errors.New("the chirp can no longer be edited")
+)
+
+// ErrorCode is the problem code for one of the errors above.
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrChirpTooLong):
+		return "chirp_too_long"
+	case errors.Is(err, ErrTooManyAttachments):
+		return "too_many_attachments"
+	case errors.Is(err, ErrInvalidAttachment):
+		return "invalid_attachment"
+	case errors.Is(err, ErrChirpRejected):
+		return "chirp_rejected"
+	case errors.Is(err, ErrUserSuspended):
+		return "account_suspended"
+	case errors.Is(err, ErrInvalidPublishAt):
+		return "invalid_publish_at"
+	case errors.Is(err, ErrEditWindowClosed):
+		return "edit_window_closed"
+	default:
+		return response.CodeBadRequest
+	}
 }
 
 type createChirpRequest struct {
-	UserID string `json:"user_id"`
-	Body   string `json:"body"`
+	Body          string     `json:"body"`
+	AttachmentIDs []string   `json:"attachment_ids" validate:"uuid"`
+	PublishAt     *time.Time `json:"publish_at"`
 }
 
+type updateChirpRequest struct {
+	Body string `json:"body"`
+}
+
+type attachmentResponse struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int32  `json:"width"`
+	Height       int32  `json:"height"`
+}
+
+// ChirpResponse is a chirp as the API returns it, for packages passing on
+// what PostChirp returns.
+type ChirpResponse = chirpResponse
+
 type chirpResponse struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          string               `json:"id"`
+	UserID      string               `json:"user_id"`
+	Body        string               `json:"body"`
+	Attachments []attachmentResponse `json:"attachments"`
+	Hidden      bool                 `json:"hidden,omitempty"`
+	Notice      string               `json:"notice,omitempty"`
+	PublishAt   *time.Time           `json:"publish_at,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
 }
 
 type ChirpsHandler struct {
-	settings  settings.Settings
-	db        *sql.DB
-	dbQueries *database.Queries
-	logger    *log.Logger
+	settings     settings.Settings
+	db           *sql.DB
+	dbQueries    *database.Queries
+	blobStore    media.BlobStore
+	publisher    *stream.Publisher
+	notifier     *notifications.Notifier
+	filter       *visibility.Filter
+	moderator    *moderation.Moderator
+	entitlements *entitlements.Service
+	logger       *log.Logger
 }
 
-func NewChirpsHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, logger *log.Logger) *ChirpsHandler {
+func NewChirpsHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, blobStore media.BlobStore, publisher *stream.Publisher, notifier *notifications.Notifier, filter *visibility.Filter, moderator *moderation.Moderator, entitlementsService *entitlements.Service, logger *log.Logger) *ChirpsHandler {
 	return &ChirpsHandler{
-		settings:  s,
-		db:        db,
-		dbQueries: dbQueries,
-		logger:    logger,
+		settings:     s,
+		db:           db,
+		dbQueries:    dbQueries,
+		blobStore:    blobStore,
+		publisher:    publisher,
+		notifier:     notifier,
+		filter:       filter,
+		moderator:    moderator,
+		entitlements: entitlementsService,
+		logger:       logger,
 	}
 }
 
-func cleanBody(body string) string {
-	words := strings.Split(body, " ")
-	for i, word := range words {
-		lower := strings.ToLower(word)
-		for _, profane := range profanes {
-			if lower == profane {
-				words[i] = "****"
-			}
-		}
+func newChirpResponse(chirp database.Chirp, attachments []database.Attachment) chirpResponse {
+	resp := chirpResponse{
+		ID:          chirp.ID.String(),
+		UserID:      chirp.UserID.String(),
+		Body:        chirp.Body,
+		Attachments: []attachmentResponse{},
+		CreatedAt:   chirp.CreatedAt.Time,
+		UpdatedAt:   chirp.UpdatedAt.Time,
 	}
 
-	return strings.Join(words, " ")
+	// Only the author gets to see hidden chirps, with an explanation.
+	if chirp.HiddenAt.Valid {
+		resp.Hidden = true
+		resp.Notice = hiddenNotice
+	}
+
+	// Only the author gets to see scheduled chirps as well.
+	if chirp.PublishAt.Valid {
+		resp.PublishAt = &chirp.PublishAt.Time
+	}
+
+	for _, attachment := range attachments {
+		resp.Attachments = append(resp.Attachments, attachmentResponse{
+			ID:           attachment.ID.String(),
+			URL:          media.URL(attachment.StorageKey),
+			ThumbnailURL: media.URL(attachment.ThumbnailKey),
+			ContentType:  attachment.ContentType,
+			Width:        attachment.Width,
+			Height:       attachment.Height,
+		})
+	}
+
+	return resp
+}
+
+func (h *ChirpsHandler) getAttachments(ctx context.Context, chirps ...database.Chirp) (map[uuid.UUID][]database.Attachment, error) {
+	return attachmentsByChirp(ctx, h.dbQueries, chirps...)
+}
+
+// attachmentsByChirp is getAttachments for a transaction.
+func attachmentsByChirp(ctx context.Context, q *database.Queries, chirps ...database.Chirp) (map[uuid.UUID][]database.Attachment, error) {
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	attachments, err := q.GetAttachmentsByChirpIDs(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byChirp := make(map[uuid.UUID][]database.Attachment)
+	for _, attachment := range attachments {
+		byChirp[attachment.ChirpID.UUID] = append(byChirp[attachment.ChirpID.UUID], attachment)
+	}
+
+	return byChirp, nil
 }
 
 func (h *ChirpsHandler) CreateChirp(w http.ResponseWriter, r *http.Request) {
@@ -82,39 +207,278 @@ func (h *ChirpsHandler) CreateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req createChirpRequest
-	err = json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		response.InvalidRequestBody(w)
+	req, ok := request.DecodeJSON[createChirpRequest](w, r)
+	if !ok {
 		return
 	}
 
-	if len(req.Body) > 140 {
-		response.BadRequest(w, "Chirp is too long")
+	attachmentIDs := make([]uuid.UUID, 0, len(req.AttachmentIDs))
+	for _, idString := range req.AttachmentIDs {
+		// Checked by the uuid rule.
+		attachmentIDs = append(attachmentIDs, uuid.MustParse(idString))
+	}
+
+	var publishAt time.Time
+	if req.PublishAt != nil {
+		publishAt = *req.PublishAt
+	}
+
+	resp, err := h.PostChirp(r.Context(), userID, req.Body, attachmentIDs, publishAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrChirpTooLong), errors.Is(err, ErrTooManyAttachments), errors.Is(err, ErrInvalidAttachment), errors.Is(err, ErrChirpRejected), errors.Is(err, ErrInvalidPublishAt):
+			response.Error(w, http.StatusBadRequest, ErrorCode(err), err.Error())
+		case errors.Is(err, ErrUserSuspended):
+			response.Error(w, http.StatusForbidden, ErrorCode(err), err.Error())
+		default:
+			h.logger.Printf("ERROR(CreateChirp): post chirp (user_id=%s): %v", userID, err)
+			response.InternalServerError(w)
+		}
+
 		return
 	}
 
-	cleanedBody := cleanBody(req.Body)
-	chirp, err := h.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
-		UserID: userID,
-		Body:   cleanedBody,
+	response.JSON(w, http.StatusCreated, resp)
+}
+
+// ValidateChirp checks a chirp against the author's limits, drafts use it to
+// refuse what could never be published.
+func ValidateChirp(e entitlements.Entitlements, body string, attachmentIDs []uuid.UUID) error {
+	if len(body) > e.MaxChirpLength {
+		return ErrChirpTooLong
+	}
+
+	if len(attachmentIDs) > e.MaxAttachments {
+		return ErrTooManyAttachments
+	}
+
+	return nil
+}
+
+// ValidatePublishAt checks when a chirp is scheduled for, which must be in
+// the future but no further than maxScheduleAhead.
+func ValidatePublishAt(publishAt, now time.Time) error {
+	if !publishAt.After(now) || publishAt.After(now.Add(maxScheduleAhead)) {
+		return ErrInvalidPublishAt
+	}
+
+	return nil
+}
+
+// PostChirp validates, stores and publishes a new chirp. It is shared by the
+// REST and the WebSocket API, validation failures are reported with the Err*
+// values of this package. A non-zero publishAt schedules the chirp, it is
+// announced when the Scheduler releases it.
+func (h *ChirpsHandler) PostChirp(ctx context.Context, userID uuid.UUID, body string, attachmentIDs []uuid.UUID, publishAt time.Time) (chirpResponse, error) {
+	limits, err := h.entitlements.ForUser(ctx, userID)
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("entitlements: %w", err)
+	}
+
+	err = ValidateChirp(limits, body, attachmentIDs)
+	if err != nil {
+		return chirpResponse{}, err
+	}
+
+	var scheduled sql.NullTime
+	if !publishAt.IsZero() {
+		err = ValidatePublishAt(publishAt, time.Now())
+		if err != nil {
+			return chirpResponse{}, err
+		}
+
+		scheduled = sql.NullTime{Time: publishAt, Valid: true}
+	}
+
+	suspended, err := h.dbQueries.IsUserSuspended(ctx, userID)
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("db is user suspended: %w", err)
+	}
+
+	if suspended {
+		return chirpResponse{}, ErrUserSuspended
+	}
+
+	moderated, err := h.moderator.Check(body)
+	if err != nil {
+		if errors.Is(err, moderation.ErrRejected) {
+			return chirpResponse{}, ErrChirpRejected
+		}
+		return chirpResponse{}, fmt.Errorf("moderate: %w", err)
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	chirp, err := qtx.CreateChirp(ctx, database.CreateChirpParams{
+		UserID:    userID,
+		Body:      moderated.Text,
+		PublishAt: scheduled,
 	})
 	if err != nil {
-		h.logger.Printf("ERROR(CreateChirp): db create chirp: %v", err)
-		response.InternalServerError(w)
-		return
+		return chirpResponse{}, fmt.Errorf("db create chirp: %w", err)
+	}
+
+	for _, rule := range moderated.Flagged {
+		err = qtx.FlagChirp(ctx, database.FlagChirpParams{
+			ChirpID: chirp.ID,
+			RuleID:  rule.ID,
+		})
+		if err != nil {
+			return chirpResponse{}, fmt.Errorf("db flag chirp (rule_id=%s): %w", rule.ID, err)
+		}
+	}
+
+	for i, attachmentID := range attachmentIDs {
+		rowsAffected, err := qtx.AttachToChirp(ctx, database.AttachToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: int32(i),
+			ID:       attachmentID,
+			UserID:   userID,
+		})
+		if err != nil {
+			return chirpResponse{}, fmt.Errorf("db attach to chirp (attachment_id=%s): %w", attachmentID, err)
+		}
+
+		// Unknown, foreign or already used attachments all end up here.
+		if rowsAffected == 0 {
+			return chirpResponse{}, ErrInvalidAttachment
+		}
+	}
+
+	attachments, err := qtx.GetAttachmentsByChirpIDs(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("db get attachments (chirp_id=%s): %w", chirp.ID, err)
+	}
+
+	// A scheduled chirp's event is written when it goes out.
+	resp := newChirpResponse(chirp, attachments)
+	if !chirp.PublishAt.Valid {
+		err = writeEvent(ctx, qtx, outbox.EventChirpCreated, chirp, &resp)
+		if err != nil {
+			return chirpResponse{}, err
+		}
 	}
 
-	response.JSON(w, http.StatusCreated, chirpResponse{
-		ID:        chirp.ID.String(),
-		UserID:    chirp.UserID.String(),
-		Body:      chirp.Body,
-		CreatedAt: chirp.CreatedAt.Time,
-		UpdatedAt: chirp.UpdatedAt.Time,
+	err = tx.Commit()
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	if !chirp.PublishAt.Valid {
+		h.announce(ctx, chirp, resp)
+	}
+
+	return resp, nil
+}
+
+// announce tells the streams and the mentioned users about a chirp that just
+// became visible.
+func (h *ChirpsHandler) announce(ctx context.Context, chirp database.Chirp, resp chirpResponse) {
+	mentions := h.resolveMentions(ctx, chirp)
+	h.publish(ctx, stream.EventChirpCreated, chirp, mentions, resp)
+
+	for _, mentionedID := range mentions {
+		h.notifier.Notify(notifications.Notification{
+			Type:        notifications.TypeMention,
+			RecipientID: mentionedID,
+			ActorID:     chirp.UserID,
+			ChirpID:     uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		})
+	}
+}
+
+// resolveMentions looks up the users mentioned in a chirp, leaving out those
+// the author can't reach because of a block. Failing here only costs
+// notifications, so errors are logged and swallowed.
+func (h *ChirpsHandler) resolveMentions(ctx context.Context, chirp database.Chirp) []uuid.UUID {
+	emails := chirptext.Mentions(chirp.Body)
+	if len(emails) == 0 {
+		return nil
+	}
+
+	userIDs, err := h.dbQueries.GetUserIDsByEmails(ctx, emails)
+	if err != nil {
+		h.logger.Printf("Error(resolveMentions): db get users by emails (chirp_id=%s): %v", chirp.ID, err)
+		return nil
+	}
+
+	var mentions []uuid.UUID
+	for _, userID := range userIDs {
+		if userID == chirp.UserID {
+			continue
+		}
+
+		canInteract, err := h.filter.CanInteract(ctx, chirp.UserID, userID)
+		if err != nil {
+			h.logger.Printf("Error(resolveMentions): check block (chirp_id=%s, user_id=%s): %v", chirp.ID, userID, err)
+			continue
+		}
+
+		if canInteract {
+			mentions = append(mentions, userID)
+		}
+	}
+
+	return mentions
+}
+
+// chirpEventData is the payload of chirp events in the outbox. Chirp is left
+// out for deletions.
+type chirpEventData struct {
+	ChirpID  uuid.UUID      `json:"chirp_id"`
+	AuthorID uuid.UUID      `json:"author_id"`
+	Chirp    *chirpResponse `json:"chirp,omitempty"`
+}
+
+// writeEvent records a chirp event in the outbox as part of qtx. Unlike
+// publish it can't get lost, whatever has to happen reliably (webhooks)
+// subscribes to these.
+func writeEvent(ctx context.Context, qtx *database.Queries, event string, chirp database.Chirp, resp *chirpResponse) error {
+	return outbox.Write(ctx, qtx, outbox.AggregateChirp, chirp.ID, event, chirpEventData{
+		ChirpID:  chirp.ID,
+		AuthorID: chirp.UserID,
+		Chirp:    resp,
 	})
 }
 
+// publish is best effort, the chirp is already stored and streams are only a
+// convenience on top of GET /api/chirps.
+func (h *ChirpsHandler) publish(ctx context.Context, eventType string, chirp database.Chirp, mentions []uuid.UUID, resp any) {
+	event := stream.Event{
+		Type:     eventType,
+		ChirpID:  chirp.ID,
+		AuthorID: chirp.UserID,
+		Hashtags: chirptext.Hashtags(chirp.Body),
+		Mentions: mentions,
+	}
+
+	if resp != nil {
+		payload, err := json.Marshal(resp)
+		if err != nil {
+			h.logger.Printf("Error(publish): marshal chirp (chirp_id=%s): %v", chirp.ID, err)
+			return
+		}
+		event.Chirp = payload
+	}
+
+	err := h.publisher.Publish(ctx, event)
+	if err != nil {
+		h.logger.Printf("Error(publish): publish %s (chirp_id=%s): %v", eventType, chirp.ID, err)
+	}
+}
+
 func (h *ChirpsHandler) GetAllChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := auth.GetOptionalUserID(r.Header, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
 	userIDString := r.URL.Query().Get("author_id")
 	userID := request.ParseOptionalUUID(userIDString)
 
@@ -123,22 +487,32 @@ func (h *ChirpsHandler) GetAllChirps(w http.ResponseWriter, r *http.Request) {
 		sortOrder = "asc"
 	}
 
-	chirps, err := h.dbQueries.GetAllChirps(r.Context(), userID)
+	rules, err := h.filter.ForViewer(r.Context(), viewerID)
+	if err != nil {
+		h.logger.Printf("ERROR(GetAllChirps): visibility rules: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	allChirps, err := h.dbQueries.GetAllChirps(r.Context(), userID)
 	if err != nil {
 		h.logger.Printf("ERROR(GetAllChirps): db get all chirps: %v", err)
 		response.InternalServerError(w)
 		return
 	}
 
+	chirps := visibleChirps(allChirps, viewerID, userID, rules)
+
+	attachments, err := h.getAttachments(r.Context(), chirps...)
+	if err != nil {
+		h.logger.Printf("ERROR(GetAllChirps): db get attachments: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
 	var resp []chirpResponse
 	for _, chirp := range chirps {
-		resp = append(resp, chirpResponse{
-			ID:        chirp.ID.String(),
-			UserID:    chirp.UserID.String(),
-			Body:      chirp.Body,
-			CreatedAt: chirp.CreatedAt.Time,
-			UpdatedAt: chirp.UpdatedAt.Time,
-		})
+		resp = append(resp, newChirpResponse(chirp, attachments[chirp.ID]))
 	}
 
 	if sortOrder != "asc" {
@@ -151,11 +525,37 @@ func (h *ChirpsHandler) GetAllChirps(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ChirpsHandler) GetChirp(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := h.viewChirp(w, r, "GetChirp")
+	if !ok {
+		return
+	}
+
+	attachments, err := h.getAttachments(r.Context(), chirp)
+	if err != nil {
+		h.logger.Printf("Error(GetChirp): db get attachments (chirp_id=%s): %v", chirp.ID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, newChirpResponse(chirp, attachments[chirp.ID]))
+}
+
+// viewChirp looks up the chirp of the path for the viewer, writing the
+// error response if they can't see it. Blocked either way, hidden by a
+// moderator or not published yet looks the same as a chirp that doesn't
+// exist, except to the author.
+func (h *ChirpsHandler) viewChirp(w http.ResponseWriter, r *http.Request, caller string) (database.Chirp, bool) {
+	viewerID, err := auth.GetOptionalUserID(r.Header, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return database.Chirp{}, false
+	}
+
 	pathChirpID := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(pathChirpID)
 	if err != nil {
-		response.BadRequest(w, "invalid chirp id")
-		return
+		response.Invalid(w, "chirpID", response.FieldInvalid, "invalid chirp id")
+		return database.Chirp{}, false
 	}
 
 	chirp, err := h.dbQueries.GetChirpByID(r.Context(), chirpID)
@@ -164,20 +564,57 @@ func (h *ChirpsHandler) GetChirp(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, sql.ErrNoRows):
 			response.NotFound(w)
 		default:
-			h.logger.Printf("Error(GetChirp): db get chirp by id: %v", err)
+			h.logger.Printf("Error(%s): db get chirp by id: %v", caller, err)
 			response.InternalServerError(w)
 		}
 
-		return
+		return database.Chirp{}, false
 	}
 
-	response.JSON(w, http.StatusOK, chirpResponse{
-		ID:        chirp.ID.String(),
-		UserID:    chirp.UserID.String(),
-		Body:      chirp.Body,
-		CreatedAt: chirp.CreatedAt.Time,
-		UpdatedAt: chirp.UpdatedAt.Time,
-	})
+	rules, err := h.filter.ForViewer(r.Context(), viewerID)
+	if err != nil {
+		h.logger.Printf("Error(%s): visibility rules: %v", caller, err)
+		response.InternalServerError(w)
+		return database.Chirp{}, false
+	}
+
+	if !rules.CanSee(chirp.UserID) || !unpublishedVisible(chirp, viewerID) {
+		response.NotFound(w)
+		return database.Chirp{}, false
+	}
+
+	return chirp, true
+}
+
+// unpublishedVisible reports whether the viewer may see chirp as far as
+// moderation and scheduling go: hidden and scheduled chirps only show to
+// their author.
+func unpublishedVisible(chirp database.Chirp, viewerID uuid.NullUUID) bool {
+	isAuthor := viewerID.Valid && viewerID.UUID == chirp.UserID
+	return isAuthor || !(chirp.HiddenAt.Valid || chirp.PublishAt.Valid)
+}
+
+// listed reports whether a listing, narrowed down to authorID if valid,
+// shows chirp to the viewer. Asking for one author on purpose still shows
+// muted users.
+func listed(chirp database.Chirp, viewerID, authorID uuid.NullUUID, rules *visibility.Rules) bool {
+	if !unpublishedVisible(chirp, viewerID) {
+		return false
+	}
+
+	return (authorID.Valid && rules.CanSee(chirp.UserID)) || rules.InTimeline(chirp.UserID)
+}
+
+// visibleChirps leaves out the chirps a listing doesn't show the viewer.
+func visibleChirps(chirps []database.Chirp, viewerID, authorID uuid.NullUUID, rules *visibility.Rules) []database.Chirp {
+	var visible []database.Chirp
+	for _, chirp := range chirps {
+		if listed(chirp, viewerID, authorID, rules) {
+			visible = append(visible, chirp)
+		}
+	}
+
+	return visible
 }
 
 func (h *ChirpsHandler) DeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -196,7 +633,7 @@ func (h *ChirpsHandler) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 	pathChirpID := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(pathChirpID)
 	if err != nil {
-		response.BadRequest(w, "invalid chirp id")
+		response.Invalid(w, "chirpID", response.FieldInvalid, "invalid chirp id")
 		return
 	}
 
@@ -215,20 +652,369 @@ func (h *ChirpsHandler) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rowsAffected, err := h.dbQueries.DeleteChirp(r.Context(), database.DeleteChirpParams{
-		ID:     chirpID,
-		UserID: userID,
-	})
+	chirp, err := h.dbQueries.GetChirpByID(r.Context(), chirpID)
 	if err != nil {
-		h.logger.Printf("Error(DeleteChirp): delete chirp (user_id=%s chirp_id=%s): %v", userID, chirpID, err)
+		h.logger.Printf("Error(DeleteChirp): get chirp (chirp_id=%s): %v", chirpID, err)
 		response.InternalServerError(w)
 		return
 	}
 
-	if rowsAffected == 0 {
+	deleted, err := h.RemoveChirp(r.Context(), chirp, userID)
+	if err != nil {
+		h.logger.Printf("Error(DeleteChirp): remove chirp (user_id=%s chirp_id=%s): %v", userID, chirpID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !deleted {
 		response.NotFound(w)
 		return
 	}
 
 	response.NoContent(w)
 }
+
+// RemoveChirp soft deletes a chirp and tells the streams. It is shared by
+// authors deleting their own chirps and by moderators, only the former can
+// restore it. The row and its attachments stay until the purge job. It
+// reports false if the chirp was already gone.
+func (h *ChirpsHandler) RemoveChirp(ctx context.Context, chirp database.Chirp, deletedBy uuid.UUID) (bool, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	rowsAffected, err := qtx.DeleteChirp(ctx, database.DeleteChirpParams{
+		ID:        chirp.ID,
+		DeletedBy: uuid.NullUUID{UUID: deletedBy, Valid: true},
+	})
+	if err != nil {
+		return false, fmt.Errorf("db delete chirp: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	// Nobody heard of a scheduled chirp yet.
+	if !chirp.PublishAt.Valid {
+		err = writeEvent(ctx, qtx, outbox.EventChirpDeleted, chirp, nil)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("commit tx: %w", err)
+	}
+
+	if !chirp.PublishAt.Valid {
+		h.publish(ctx, stream.EventChirpDeleted, chirp, h.resolveMentions(ctx, chirp), nil)
+	}
+
+	return true, nil
+}
+
+// HideChirp takes a chirp out of public reads, its author still sees it. To
+// live clients it looks like a deletion. It reports false if the chirp
+// doesn't exist or is hidden already.
+func (h *ChirpsHandler) HideChirp(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	chirp, err := qtx.HideChirp(ctx, chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("db hide chirp: %w", err)
+	}
+
+	if !chirp.PublishAt.Valid {
+		err = writeEvent(ctx, qtx, outbox.EventChirpDeleted, chirp, nil)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("commit tx: %w", err)
+	}
+
+	if !chirp.PublishAt.Valid {
+		h.publish(ctx, stream.EventChirpDeleted, chirp, nil, nil)
+	}
+
+	return true, nil
+}
+
+// UnhideChirp undoes HideChirp, to live clients the chirp looks new again.
+// It reports false if the chirp doesn't exist or isn't hidden.
+func (h *ChirpsHandler) UnhideChirp(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	chirp, err := qtx.UnhideChirp(ctx, chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("db unhide chirp: %w", err)
+	}
+
+	attachments, err := attachmentsByChirp(ctx, qtx, chirp)
+	if err != nil {
+		return false, fmt.Errorf("db get attachments: %w", err)
+	}
+
+	resp := newChirpResponse(chirp, attachments[chirp.ID])
+	if !chirp.PublishAt.Valid {
+		err = writeEvent(ctx, qtx, outbox.EventChirpCreated, chirp, &resp)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("commit tx: %w", err)
+	}
+
+	if !chirp.PublishAt.Valid {
+		h.publish(ctx, stream.EventChirpCreated, chirp, nil, resp)
+	}
+
+	return true, nil
+}
+
+// RestoreChirp brings back a chirp its author deleted within the grace
+// period. Chirps deleted by a moderator can't be restored.
+func (h *ChirpsHandler) RestoreChirp(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		response.Invalid(w, "chirpID", response.FieldInvalid, "invalid chirp id")
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.logger.Printf("Error(RestoreChirp): begin tx: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	chirp, err := qtx.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:           chirpID,
+		UserID:       userID,
+		DeletedAfter: sql.NullTime{Time: time.Now().Add(-RestoreGracePeriod), Valid: true},
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(RestoreChirp): db restore chirp (user_id=%s, chirp_id=%s): %v", userID, chirpID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	attachments, err := attachmentsByChirp(r.Context(), qtx, chirp)
+	if err != nil {
+		h.logger.Printf("Error(RestoreChirp): db get attachments (chirp_id=%s): %v", chirp.ID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	// A scheduled chirp is announced when the Scheduler gets to it.
+	resp := newChirpResponse(chirp, attachments[chirp.ID])
+	announce := !chirp.HiddenAt.Valid && !chirp.PublishAt.Valid
+	if announce {
+		err = writeEvent(r.Context(), qtx, outbox.EventChirpCreated, chirp, &resp)
+		if err != nil {
+			h.logger.Printf("Error(RestoreChirp): %v (chirp_id=%s)", err, chirp.ID)
+			response.InternalServerError(w)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		h.logger.Printf("Error(RestoreChirp): commit tx (chirp_id=%s): %v", chirp.ID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if announce {
+		h.publish(r.Context(), stream.EventChirpCreated, chirp, h.resolveMentions(r.Context(), chirp), resp)
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// UpdateChirp lets the author change the body of a chirp within the edit
+// window of their tier. Scheduled chirps can be edited until they go out.
+func (h *ChirpsHandler) UpdateChirp(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		response.Invalid(w, "chirpID", response.FieldInvalid, "invalid chirp id")
+		return
+	}
+
+	req, ok := request.DecodeJSON[updateChirpRequest](w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.editChirp(r.Context(), userID, chirpID, req.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		case errors.Is(err, ErrChirpTooLong), errors.Is(err, ErrChirpRejected):
+			response.Error(w, http.StatusBadRequest, ErrorCode(err), err.Error())
+		case errors.Is(err, ErrEditWindowClosed), errors.Is(err, ErrUserSuspended):
+			response.Error(w, http.StatusForbidden, ErrorCode(err), err.Error())
+		default:
+			h.logger.Printf("Error(UpdateChirp): edit chirp (user_id=%s, chirp_id=%s): %v", userID, chirpID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+func (h *ChirpsHandler) editChirp(ctx context.Context, userID, chirpID uuid.UUID, body string) (chirpResponse, error) {
+	chirp, err := h.dbQueries.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		return chirpResponse{}, err
+	}
+
+	// Other people's chirps look like they don't exist.
+	if chirp.UserID != userID {
+		return chirpResponse{}, sql.ErrNoRows
+	}
+
+	limits, err := h.entitlements.ForUser(ctx, userID)
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("entitlements: %w", err)
+	}
+
+	if !chirp.PublishAt.Valid && !limits.CanEdit(chirp.CreatedAt.Time, time.Now()) {
+		return chirpResponse{}, ErrEditWindowClosed
+	}
+
+	err = ValidateChirp(limits, body, nil)
+	if err != nil {
+		return chirpResponse{}, err
+	}
+
+	suspended, err := h.dbQueries.IsUserSuspended(ctx, userID)
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("db is user suspended: %w", err)
+	}
+
+	if suspended {
+		return chirpResponse{}, ErrUserSuspended
+	}
+
+	moderated, err := h.moderator.Check(body)
+	if err != nil {
+		if errors.Is(err, moderation.ErrRejected) {
+			return chirpResponse{}, ErrChirpRejected
+		}
+		return chirpResponse{}, fmt.Errorf("moderate: %w", err)
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	chirp, err = qtx.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{
+		ID:     chirpID,
+		UserID: userID,
+		Body:   moderated.Text,
+	})
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("db update chirp body: %w", err)
+	}
+
+	for _, rule := range moderated.Flagged {
+		err = qtx.FlagChirp(ctx, database.FlagChirpParams{
+			ChirpID: chirp.ID,
+			RuleID:  rule.ID,
+		})
+		if err != nil {
+			return chirpResponse{}, fmt.Errorf("db flag chirp (rule_id=%s): %w", rule.ID, err)
+		}
+	}
+
+	attachments, err := attachmentsByChirp(ctx, qtx, chirp)
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("db get attachments: %w", err)
+	}
+
+	resp := newChirpResponse(chirp, attachments[chirp.ID])
+	announce := !chirp.HiddenAt.Valid && !chirp.PublishAt.Valid
+	if announce {
+		err = writeEvent(ctx, qtx, outbox.EventChirpUpdated, chirp, &resp)
+		if err != nil {
+			return chirpResponse{}, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return chirpResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	if announce {
+		h.publish(ctx, stream.EventChirpUpdated, chirp, h.resolveMentions(ctx, chirp), resp)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,184 @@
+package chirps
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/google/uuid"
+)
+
+// The v2 routes read chirps like the v1 ones, they differ in shape: the
+// author is embedded instead of referenced by user_id, and lists are paged.
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type authorResponse struct {
+	ID          string `json:"id"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+}
+
+type chirpV2Response struct {
+	ID          string               `json:"id"`
+	Author      authorResponse       `json:"author"`
+	Body        string               `json:"body"`
+	Attachments []attachmentResponse `json:"attachments"`
+	Hidden      bool                 `json:"hidden,omitempty"`
+	Notice      string               `json:"notice,omitempty"`
+	PublishAt   *time.Time           `json:"publish_at,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+type chirpsPageResponse struct {
+	Chirps     []chirpV2Response `json:"chirps"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+func newChirpV2Response(chirp database.Chirp, attachments []database.Attachment, author database.User) chirpV2Response {
+	v1 := newChirpResponse(chirp, attachments)
+
+	return chirpV2Response{
+		ID: v1.ID,
+		Author: authorResponse{
+			ID:          author.ID.String(),
+			IsChirpyRed: author.IsChirpyRed.Bool,
+		},
+		Body:        v1.Body,
+		Attachments: v1.Attachments,
+		Hidden:      v1.Hidden,
+		Notice:      v1.Notice,
+		PublishAt:   v1.PublishAt,
+		CreatedAt:   v1.CreatedAt,
+		UpdatedAt:   v1.UpdatedAt,
+	}
+}
+
+// getAuthors loads the authors of chirps by ID.
+func (h *ChirpsHandler) getAuthors(ctx context.Context, chirps ...database.Chirp) (map[uuid.UUID]database.User, error) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.UserID)
+	}
+
+	users, err := h.dbQueries.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	authors := make(map[uuid.UUID]database.User, len(users))
+	for _, user := range users {
+		authors[user.ID] = user
+	}
+
+	return authors, nil
+}
+
+// GetChirpsV2 pages through chirps, newest first. The cursor is the
+// next_cursor of the previous page. A page holds up to limit chirps the
+// viewer can see, chirps left out for them don't count. A page can be short
+// when many chirps are left out, only the one without a next_cursor is the
+// last.
+func (h *ChirpsHandler) GetChirpsV2(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := auth.GetOptionalUserID(r.Header, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	query := r.URL.Query()
+	userID := request.ParseOptionalUUID(query.Get("author_id"))
+
+	limit := defaultPageSize
+	if s := query.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			response.Invalid(w, "limit", response.FieldInvalid, "invalid limit")
+			return
+		}
+	}
+
+	var after *pageCursor
+	if s := query.Get("cursor"); s != "" {
+		cursor, err := parsePageCursor(s)
+		if err != nil {
+			response.Invalid(w, "cursor", response.FieldInvalid, "invalid cursor")
+			return
+		}
+		after = &cursor
+	}
+
+	rules, err := h.filter.ForViewer(r.Context(), viewerID)
+	if err != nil {
+		h.logger.Printf("Error(GetChirpsV2): visibility rules: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	chirps, next, err := readPage(r.Context(), h.dbQueries, userID, after, limit, func(chirp database.Chirp) bool {
+		return listed(chirp, viewerID, userID, rules)
+	})
+	if err != nil {
+		h.logger.Printf("Error(GetChirpsV2): db get chirps page: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	attachments, err := h.getAttachments(r.Context(), chirps...)
+	if err != nil {
+		h.logger.Printf("Error(GetChirpsV2): db get attachments: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	authors, err := h.getAuthors(r.Context(), chirps...)
+	if err != nil {
+		h.logger.Printf("Error(GetChirpsV2): db get authors: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := chirpsPageResponse{
+		Chirps: []chirpV2Response{},
+	}
+	for _, chirp := range chirps {
+		resp.Chirps = append(resp.Chirps, newChirpV2Response(chirp, attachments[chirp.ID], authors[chirp.UserID]))
+	}
+
+	if next != nil {
+		resp.NextCursor = next.String()
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+func (h *ChirpsHandler) GetChirpV2(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := h.viewChirp(w, r, "GetChirpV2")
+	if !ok {
+		return
+	}
+
+	attachments, err := h.getAttachments(r.Context(), chirp)
+	if err != nil {
+		h.logger.Printf("Error(GetChirpV2): db get attachments (chirp_id=%s): %v", chirp.ID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	authors, err := h.getAuthors(r.Context(), chirp)
+	if err != nil {
+		h.logger.Printf("Error(GetChirpV2): db get author (chirp_id=%s): %v", chirp.ID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, newChirpV2Response(chirp, attachments[chirp.ID], authors[chirp.UserID]))
+}
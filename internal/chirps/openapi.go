@@ -0,0 +1,93 @@
+package chirps
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+// ChirpBody documents chirps returned by other packages' routes.
+var ChirpBody any = chirpResponse{}
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:     "GET /api/chirps",
+		Tag:         "chirps",
+		Summary:     "List chirps",
+		Description: "Signed in, chirps of blocked and muted users are left out unless asked for by author_id.",
+		Auth:        openapi.AuthOptional,
+		Params: []openapi.Param{
+			openapi.Query("author_id", "Only chirps by this user."),
+			openapi.Query("sort", "asc (default) or desc by creation time."),
+		},
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The chirps", Body: []chirpResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		Pattern:     "POST /api/chirps",
+		Tag:         "chirps",
+		Summary:     "Post a chirp",
+		Description: "A publish_at in the future schedules the chirp.",
+		Auth:        openapi.AuthBearer,
+		Request:     createChirpRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusCreated, Description: "The new chirp", Body: chirpResponse{}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		Pattern:   "GET /api/chirps/{chirpID}",
+		Tag:       "chirps",
+		Summary:   "Get a chirp",
+		Auth:      openapi.AuthOptional,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The chirp", Body: chirpResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:     "PUT /api/chirps/{chirpID}",
+		Tag:         "chirps",
+		Summary:     "Edit a chirp",
+		Description: "Only within the edit window of the author's tier. Scheduled chirps can be edited until they go out.",
+		Auth:        openapi.AuthBearer,
+		Request:     updateChirpRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusOK, Description: "The edited chirp", Body: chirpResponse{}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Pattern:   "DELETE /api/chirps/{chirpID}",
+		Tag:       "chirps",
+		Summary:   "Delete a chirp",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Deleted, restorable during the grace period"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Pattern:     "POST /api/chirps/{chirpID}/restore",
+		Tag:         "chirps",
+		Summary:     "Restore a deleted chirp",
+		Description: "Chirps deleted by a moderator can't be restored.",
+		Auth:        openapi.AuthBearer,
+		Responses:   []openapi.Response{{Status: http.StatusOK, Description: "The restored chirp", Body: chirpResponse{}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:     "GET /api/v2/chirps",
+		Tag:         "chirps",
+		Summary:     "List chirps with their authors",
+		Description: "Newest first, a page at a time. Signed in, chirps of blocked and muted users are left out unless asked for by author_id.",
+		Auth:        openapi.AuthOptional,
+		Params: []openapi.Param{
+			openapi.Query("author_id", "Only chirps by this user."),
+			openapi.Query("limit", "Chirps per page, 1 to 100, 20 by default. Pages can have fewer when many chirps are left out, the last page is the one without a next_cursor."),
+			openapi.Query("cursor", "next_cursor of the previous page."),
+		},
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "A page of chirps", Body: chirpsPageResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "GET /api/v2/chirps/{chirpID}",
+		Tag:       "chirps",
+		Summary:   "Get a chirp with its author",
+		Auth:      openapi.AuthOptional,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The chirp", Body: chirpV2Response{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+}
@@ -0,0 +1,108 @@
+package chirps
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/google/uuid"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// maxPageBatches caps the queries of one page. A viewer who blocks or mutes
+// most authors would otherwise scan the whole table for a single page.
+const maxPageBatches = 5
+
+// pageCursor is the position of the last chirp of a page. Chirps created
+// in the same transaction share created_at, so the ID breaks ties.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// String encodes the cursor for clients, who should treat it as opaque.
+func (c pageCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.Format(time.RFC3339Nano) + "," + c.ID.String()))
+}
+
+func parsePageCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	var c pageCursor
+	c.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	c.ID, err = uuid.Parse(id)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	return c, nil
+}
+
+// pageStore is the part of database.Queries paging needs.
+type pageStore interface {
+	GetChirpsPage(ctx context.Context, arg database.GetChirpsPageParams) ([]database.Chirp, error)
+}
+
+// readPage reads the limit chirps after the cursor that pass visible,
+// newest first. It reads on until it has them, runs out of chirps or has
+// read maxPageBatches batches. In the last case the page is short and the
+// next cursor is where the scan stopped. A nil cursor starts from the newest
+// chirp. The next cursor is nil on the last page.
+func readPage(ctx context.Context, store pageStore, authorID uuid.NullUUID, after *pageCursor, limit int, visible func(database.Chirp) bool) ([]database.Chirp, *pageCursor, error) {
+	// One more than a page, to tell whether another one follows.
+	arg := database.GetChirpsPageParams{
+		UserID:    authorID,
+		MaxChirps: int32(limit + 1),
+	}
+
+	var page []database.Chirp
+	for range maxPageBatches {
+		if after != nil {
+			arg.BeforeAt = sql.NullTime{Time: after.CreatedAt, Valid: true}
+			arg.BeforeID = uuid.NullUUID{UUID: after.ID, Valid: true}
+		}
+
+		batch, err := store.GetChirpsPage(ctx, arg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, chirp := range batch {
+			if !visible(chirp) {
+				continue
+			}
+
+			if len(page) == limit {
+				last := page[len(page)-1]
+				return page, &pageCursor{CreatedAt: last.CreatedAt.Time, ID: last.ID}, nil
+			}
+			page = append(page, chirp)
+		}
+
+		if len(batch) < int(arg.MaxChirps) {
+			return page, nil, nil
+		}
+
+		last := batch[len(batch)-1]
+		after = &pageCursor{CreatedAt: last.CreatedAt.Time, ID: last.ID}
+	}
+
+	return page, after, nil
+}
@@ -0,0 +1,231 @@
+package chirps
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/google/uuid"
+)
+
+// memStore implements pageStore with the semantics of the query.
+type memStore struct {
+	chirps  []database.Chirp
+	queries int
+}
+
+// before is the (created_at, id) row comparison of the query.
+func before(chirp database.Chirp, at time.Time, id uuid.UUID) bool {
+	if !chirp.CreatedAt.Time.Equal(at) {
+		return chirp.CreatedAt.Time.Before(at)
+	}
+
+	return bytes.Compare(chirp.ID[:], id[:]) < 0
+}
+
+func (s *memStore) GetChirpsPage(ctx context.Context, arg database.GetChirpsPageParams) ([]database.Chirp, error) {
+	s.queries++
+	chirps := slices.Clone(s.chirps)
+	slices.SortFunc(chirps, func(a, b database.Chirp) int {
+		if before(a, b.CreatedAt.Time, b.ID) {
+			return 1
+		}
+		return -1
+	})
+
+	var page []database.Chirp
+	for _, chirp := range chirps {
+		if arg.UserID.Valid && chirp.UserID != arg.UserID.UUID {
+			continue
+		}
+
+		if arg.BeforeAt.Valid && !before(chirp, arg.BeforeAt.Time, arg.BeforeID.UUID) {
+			continue
+		}
+
+		if len(page) == int(arg.MaxChirps) {
+			break
+		}
+		page = append(page, chirp)
+	}
+
+	return page, nil
+}
+
+func newChirp(createdAt time.Time) database.Chirp {
+	return database.Chirp{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		CreatedAt: sql.NullTime{Time: createdAt, Valid: true},
+	}
+}
+
+func all(database.Chirp) bool {
+	return true
+}
+
+// readAll pages through the store, checking the cursor survives encoding
+// and that pages are never longer than limit.
+func readAll(t *testing.T, store pageStore, limit int, visible func(database.Chirp) bool) []database.Chirp {
+	t.Helper()
+
+	var chirps []database.Chirp
+	var after *pageCursor
+	for range 100 {
+		page, next, err := readPage(context.Background(), store, uuid.NullUUID{}, after, limit, visible)
+		if err != nil {
+			t.Fatalf("readPage() error = %v", err)
+		}
+		chirps = append(chirps, page...)
+
+		if next == nil {
+			return chirps
+		}
+
+		if len(page) > limit {
+			t.Fatalf("readPage() = %d chirps, want at most %d", len(page), limit)
+		}
+
+		cursor, err := parsePageCursor(next.String())
+		if err != nil {
+			t.Fatalf("parsePageCursor() error = %v", err)
+		}
+		after = &cursor
+	}
+
+	t.Fatal("paging doesn't end")
+	return nil
+}
+
+func TestReadPageEqualTimestamps(t *testing.T) {
+	now := time.Now().UTC()
+
+	// Five chirps inserted in one transaction straddle the boundary of the
+	// first page.
+	store := &memStore{}
+	for range 5 {
+		store.chirps = append(store.chirps, newChirp(now))
+	}
+	store.chirps = append(store.chirps, newChirp(now.Add(time.Second)), newChirp(now.Add(-time.Second)))
+
+	for _, limit := range []int{1, 2, 3, 7, 10} {
+		seen := make(map[uuid.UUID]int)
+		for _, chirp := range readAll(t, store, limit, all) {
+			seen[chirp.ID]++
+		}
+
+		for _, chirp := range store.chirps {
+			if seen[chirp.ID] != 1 {
+				t.Errorf("limit %d: chirp %s read %d times, want once", limit, chirp.ID, seen[chirp.ID])
+			}
+		}
+	}
+}
+
+func TestReadPageLeavesOutInvisible(t *testing.T) {
+	now := time.Now().UTC()
+
+	// Every third chirp is by someone the viewer muted.
+	muted := uuid.New()
+	store := &memStore{}
+	for i := range 30 {
+		chirp := newChirp(now.Add(-time.Duration(i) * time.Second))
+		if i%3 != 0 {
+			chirp.UserID = muted
+		}
+		store.chirps = append(store.chirps, chirp)
+	}
+	visible := func(chirp database.Chirp) bool {
+		return chirp.UserID != muted
+	}
+
+	for _, limit := range []int{1, 3, 4, 10, 20} {
+		chirps := readAll(t, store, limit, visible)
+		if len(chirps) != 10 {
+			t.Errorf("limit %d: read %d chirps, want 10", limit, len(chirps))
+		}
+
+		for _, chirp := range chirps {
+			if !visible(chirp) {
+				t.Errorf("limit %d: read a chirp the viewer can't see", limit)
+			}
+		}
+	}
+
+	// Nothing visible within the scan is one empty, last page.
+	page, next, err := readPage(context.Background(), store, uuid.NullUUID{}, nil, 10, func(database.Chirp) bool { return false })
+	if err != nil {
+		t.Fatalf("readPage() error = %v", err)
+	}
+
+	if len(page) != 0 || next != nil {
+		t.Errorf("readPage() = %d chirps, next %v, want none and no next cursor", len(page), next)
+	}
+}
+
+func TestReadPageCapsScan(t *testing.T) {
+	now := time.Now().UTC()
+
+	// The viewer muted everyone but the author of the oldest chirp.
+	muted := uuid.New()
+	store := &memStore{}
+	for i := range 500 {
+		chirp := newChirp(now.Add(-time.Duration(i) * time.Second))
+		if i != 499 {
+			chirp.UserID = muted
+		}
+		store.chirps = append(store.chirps, chirp)
+	}
+	visible := func(chirp database.Chirp) bool {
+		return chirp.UserID != muted
+	}
+
+	page, next, err := readPage(context.Background(), store, uuid.NullUUID{}, nil, 10, visible)
+	if err != nil {
+		t.Fatalf("readPage() error = %v", err)
+	}
+
+	if store.queries != maxPageBatches {
+		t.Errorf("readPage() ran %d queries, want %d", store.queries, maxPageBatches)
+	}
+
+	// A short page, the cursor is where the scan stopped.
+	if len(page) != 0 || next == nil {
+		t.Fatalf("readPage() = %d chirps, next %v, want none and a next cursor", len(page), next)
+	}
+
+	scanned := store.chirps[maxPageBatches*11-1]
+	if !next.CreatedAt.Equal(scanned.CreatedAt.Time) || next.ID != scanned.ID {
+		t.Errorf("next cursor = %v, want the last chirp scanned", next)
+	}
+
+	// Paging on still gets there.
+	chirps := readAll(t, store, 10, visible)
+	if len(chirps) != 1 || chirps[0].ID != store.chirps[499].ID {
+		t.Errorf("read %d chirps, want the oldest one", len(chirps))
+	}
+}
+
+func TestParsePageCursor(t *testing.T) {
+	want := pageCursor{CreatedAt: time.Date(2026, 10, 19, 12, 0, 0, 123456000, time.UTC), ID: uuid.New()}
+
+	got, err := parsePageCursor(want.String())
+	if err != nil {
+		t.Fatalf("parsePageCursor() error = %v", err)
+	}
+
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("parsePageCursor() = %v, want %v", got, want)
+	}
+
+	for _, s := range []string{"", "not base64!", "bm8gY29tbWE", want.CreatedAt.Format(time.RFC3339Nano)} {
+		_, err := parsePageCursor(s)
+		if err == nil {
+			t.Errorf("parsePageCursor(%q) error = nil, want one", s)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package chirps
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/outbox"
+	"github.com/google/uuid"
+)
+
+const (
+	releaseInterval  = 10 * time.Second
+	releaseBatchSize = 100
+	releaseTimeout   = 30 * time.Second
+)
+
+// Scheduler releases scheduled chirps once their time comes. Every instance
+// runs one, the release query skips rows another instance is working on.
+type Scheduler struct {
+	chirpsHandler *ChirpsHandler
+	logger        *log.Logger
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+func NewScheduler(chirpsHandler *ChirpsHandler, logger *log.Logger) *Scheduler {
+	s := &Scheduler{
+		chirpsHandler: chirpsHandler,
+		logger:        logger,
+		done:          make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(releaseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+			released, err := s.chirpsHandler.ReleaseScheduled(ctx, releaseBatchSize)
+			cancel()
+
+			if err != nil {
+				s.logger.Printf("Error(Scheduler): release scheduled chirps: %v", err)
+				break
+			}
+
+			if released < releaseBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// Close stops the scheduler, waiting for a batch in flight to be announced.
+func (s *Scheduler) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// ReleaseScheduled publishes up to maxChirps due chirps and announces them
+// like freshly posted ones. It reports how many it released.
+func (h *ChirpsHandler) ReleaseScheduled(ctx context.Context, maxChirps int) (int, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	chirps, err := qtx.ReleaseScheduledChirps(ctx, int32(maxChirps))
+	if err != nil {
+		return 0, fmt.Errorf("db release scheduled chirps: %w", err)
+	}
+
+	if len(chirps) == 0 {
+		return 0, nil
+	}
+
+	attachments, err := attachmentsByChirp(ctx, qtx, chirps...)
+	if err != nil {
+		return 0, fmt.Errorf("db get attachments: %w", err)
+	}
+
+	responses := make(map[uuid.UUID]chirpResponse, len(chirps))
+	for _, chirp := range chirps {
+		if chirp.HiddenAt.Valid {
+			continue
+		}
+
+		resp := newChirpResponse(chirp, attachments[chirp.ID])
+		err = writeEvent(ctx, qtx, outbox.EventChirpCreated, chirp, &resp)
+		if err != nil {
+			return 0, err
+		}
+
+		responses[chirp.ID] = resp
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+
+	for _, chirp := range chirps {
+		resp, ok := responses[chirp.ID]
+		if !ok {
+			continue
+		}
+
+		h.announce(ctx, chirp, resp)
+	}
+
+	return len(chirps), nil
+}
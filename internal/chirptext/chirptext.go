@@ -0,0 +1,54 @@
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#])#([\p{L}\p{N}_]{1,64})`)
+
+	// Users don't have handles, a mention is '@' followed by the email.
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.%+-]+@[\w-]+(?:\.[\w-]+)+)`)
+)
+
+// Hashtags returns the distinct, lower cased tags of a chirp body in order of
+// first appearance, without the leading '#'.
+func Hashtags(body string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// NormalizeHashtag turns user input like "#Go" into the stored form "go".
+func NormalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// Mentions returns the distinct, lower cased emails mentioned in a chirp body
+// as "@someone@example.com".
+func Mentions(body string) []string {
+	var emails []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(strings.TrimRight(match[1], "."))
+		if seen[email] {
+			continue
+		}
+
+		seen[email] = true
+		emails = append(emails, email)
+	}
+
+	return emails
+}
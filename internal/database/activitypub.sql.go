@@ -0,0 +1,449 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: activitypub.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const acceptRemoteFollow = `-- name: AcceptRemoteFollow :execrows
+UPDATE remote_follows SET accepted_at = CURRENT_TIMESTAMP
+WHERE follow_id = $1 AND actor_id = $2 AND accepted_at IS NULL
+`
+
+type AcceptRemoteFollowParams struct {
+	FollowID string
+	ActorID  string
+}
+
+func (q *Queries) AcceptRemoteFollow(ctx context.Context, arg AcceptRemoteFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acceptRemoteFollow, arg.FollowID, arg.ActorID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const addRemoteFollower = `-- name: AddRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_id, follow_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET follow_id = EXCLUDED.follow_id
+`
+
+type AddRemoteFollowerParams struct {
+	UserID   uuid.UUID
+	ActorID  string
+	FollowID string
+}
+
+func (q *Queries) AddRemoteFollower(ctx context.Context, arg AddRemoteFollowerParams) error {
+	_, err := q.db.ExecContext(ctx, addRemoteFollower, arg.UserID, arg.ActorID, arg.FollowID)
+	return err
+}
+
+const countRemoteFollowers = `-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM remote_followers WHERE user_id = $1
+`
+
+func (q *Queries) CountRemoteFollowers(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRemoteFollowers, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createActorKey = `-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, public_key_pem, private_key_pem)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO NOTHING
+`
+
+type CreateActorKeyParams struct {
+	UserID        uuid.UUID
+	PublicKeyPem  string
+	PrivateKeyPem string
+}
+
+// Two requests may race to create a key, the first one wins.
+func (q *Queries) CreateActorKey(ctx context.Context, arg CreateActorKeyParams) error {
+	_, err := q.db.ExecContext(ctx, createActorKey, arg.UserID, arg.PublicKeyPem, arg.PrivateKeyPem)
+	return err
+}
+
+const createRemoteFollow = `-- name: CreateRemoteFollow :execrows
+INSERT INTO remote_follows (user_id, actor_id, follow_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, actor_id) DO NOTHING
+`
+
+type CreateRemoteFollowParams struct {
+	UserID   uuid.UUID
+	ActorID  string
+	FollowID string
+}
+
+func (q *Queries) CreateRemoteFollow(ctx context.Context, arg CreateRemoteFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createRemoteFollow, arg.UserID, arg.ActorID, arg.FollowID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createRemoteLike = `-- name: CreateRemoteLike :exec
+INSERT INTO remote_likes (id, actor_id, chirp_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type CreateRemoteLikeParams struct {
+	ID      string
+	ActorID string
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) CreateRemoteLike(ctx context.Context, arg CreateRemoteLikeParams) error {
+	_, err := q.db.ExecContext(ctx, createRemoteLike, arg.ID, arg.ActorID, arg.ChirpID)
+	return err
+}
+
+const createRemoteNote = `-- name: CreateRemoteNote :exec
+INSERT INTO remote_notes (id, actor_id, content, in_reply_to, published_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (id) DO NOTHING
+`
+
+type CreateRemoteNoteParams struct {
+	ID          string
+	ActorID     string
+	Content     string
+	InReplyTo   sql.NullString
+	PublishedAt time.Time
+}
+
+func (q *Queries) CreateRemoteNote(ctx context.Context, arg CreateRemoteNoteParams) error {
+	_, err := q.db.ExecContext(ctx, createRemoteNote,
+		arg.ID,
+		arg.ActorID,
+		arg.Content,
+		arg.InReplyTo,
+		arg.PublishedAt,
+	)
+	return err
+}
+
+const deleteRemoteActor = `-- name: DeleteRemoteActor :execrows
+DELETE FROM remote_actors WHERE id = $1
+`
+
+func (q *Queries) DeleteRemoteActor(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRemoteActor, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRemoteFollow = `-- name: DeleteRemoteFollow :one
+DELETE FROM remote_follows WHERE user_id = $1 AND actor_id = $2
+RETURNING follow_id
+`
+
+type DeleteRemoteFollowParams struct {
+	UserID  uuid.UUID
+	ActorID string
+}
+
+func (q *Queries) DeleteRemoteFollow(ctx context.Context, arg DeleteRemoteFollowParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, deleteRemoteFollow, arg.UserID, arg.ActorID)
+	var follow_id string
+	err := row.Scan(&follow_id)
+	return follow_id, err
+}
+
+const deleteRemoteLike = `-- name: DeleteRemoteLike :execrows
+DELETE FROM remote_likes WHERE id = $1 AND actor_id = $2
+`
+
+type DeleteRemoteLikeParams struct {
+	ID      string
+	ActorID string
+}
+
+func (q *Queries) DeleteRemoteLike(ctx context.Context, arg DeleteRemoteLikeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRemoteLike, arg.ID, arg.ActorID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRemoteNote = `-- name: DeleteRemoteNote :execrows
+DELETE FROM remote_notes WHERE id = $1 AND actor_id = $2
+`
+
+type DeleteRemoteNoteParams struct {
+	ID      string
+	ActorID string
+}
+
+func (q *Queries) DeleteRemoteNote(ctx context.Context, arg DeleteRemoteNoteParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRemoteNote, arg.ID, arg.ActorID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getActorKey = `-- name: GetActorKey :one
+SELECT user_id, public_key_pem, private_key_pem, created_at FROM actor_keys WHERE user_id = $1
+`
+
+func (q *Queries) GetActorKey(ctx context.Context, userID uuid.UUID) (ActorKey, error) {
+	row := q.db.QueryRowContext(ctx, getActorKey, userID)
+	var i ActorKey
+	err := row.Scan(
+		&i.UserID,
+		&i.PublicKeyPem,
+		&i.PrivateKeyPem,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getFollowerInboxes = `-- name: GetFollowerInboxes :many
+SELECT DISTINCT COALESCE(remote_actors.shared_inbox, remote_actors.inbox)::text AS inbox
+FROM remote_followers
+JOIN remote_actors ON remote_actors.id = remote_followers.actor_id
+WHERE remote_followers.user_id = $1
+`
+
+// Followers on the same server share an inbox if it has one, they get one
+// delivery between them.
+func (q *Queries) GetFollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowerInboxes, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var inbox string
+		if err := rows.Scan(&inbox); err != nil {
+			return nil, err
+		}
+		items = append(items, inbox)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRemoteActor = `-- name: GetRemoteActor :one
+SELECT id, inbox, shared_inbox, public_key_id, public_key_pem, fetched_at FROM remote_actors WHERE id = $1
+`
+
+func (q *Queries) GetRemoteActor(ctx context.Context, id string) (RemoteActor, error) {
+	row := q.db.QueryRowContext(ctx, getRemoteActor, id)
+	var i RemoteActor
+	err := row.Scan(
+		&i.ID,
+		&i.Inbox,
+		&i.SharedInbox,
+		&i.PublicKeyID,
+		&i.PublicKeyPem,
+		&i.FetchedAt,
+	)
+	return i, err
+}
+
+const getRemoteActorByKeyID = `-- name: GetRemoteActorByKeyID :one
+SELECT id, inbox, shared_inbox, public_key_id, public_key_pem, fetched_at FROM remote_actors WHERE public_key_id = $1
+`
+
+func (q *Queries) GetRemoteActorByKeyID(ctx context.Context, publicKeyID string) (RemoteActor, error) {
+	row := q.db.QueryRowContext(ctx, getRemoteActorByKeyID, publicKeyID)
+	var i RemoteActor
+	err := row.Scan(
+		&i.ID,
+		&i.Inbox,
+		&i.SharedInbox,
+		&i.PublicKeyID,
+		&i.PublicKeyPem,
+		&i.FetchedAt,
+	)
+	return i, err
+}
+
+const getRemoteFollows = `-- name: GetRemoteFollows :many
+SELECT user_id, actor_id, follow_id, accepted_at, created_at FROM remote_follows WHERE user_id = $1 ORDER BY created_at DESC
+`
+
+func (q *Queries) GetRemoteFollows(ctx context.Context, userID uuid.UUID) ([]RemoteFollow, error) {
+	rows, err := q.db.QueryContext(ctx, getRemoteFollows, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RemoteFollow
+	for rows.Next() {
+		var i RemoteFollow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ActorID,
+			&i.FollowID,
+			&i.AcceptedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRemoteNotes = `-- name: GetRemoteNotes :many
+SELECT remote_notes.id, remote_notes.actor_id, remote_notes.content, remote_notes.in_reply_to, remote_notes.published_at, remote_notes.received_at FROM remote_notes
+JOIN remote_follows ON remote_follows.actor_id = remote_notes.actor_id
+WHERE remote_follows.user_id = $1 AND remote_follows.accepted_at IS NOT NULL
+ORDER BY remote_notes.published_at DESC
+LIMIT $2
+`
+
+type GetRemoteNotesParams struct {
+	UserID   uuid.UUID
+	MaxNotes int32
+}
+
+// Notes by the actors a user follows, newest first.
+func (q *Queries) GetRemoteNotes(ctx context.Context, arg GetRemoteNotesParams) ([]RemoteNote, error) {
+	rows, err := q.db.QueryContext(ctx, getRemoteNotes, arg.UserID, arg.MaxNotes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RemoteNote
+	for rows.Next() {
+		var i RemoteNote
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorID,
+			&i.Content,
+			&i.InReplyTo,
+			&i.PublishedAt,
+			&i.ReceivedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isRemoteActorFollowed = `-- name: IsRemoteActorFollowed :one
+SELECT EXISTS (SELECT 1 FROM remote_follows WHERE actor_id = $1)
+`
+
+func (q *Queries) IsRemoteActorFollowed(ctx context.Context, actorID string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isRemoteActorFollowed, actorID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const rejectRemoteFollow = `-- name: RejectRemoteFollow :execrows
+DELETE FROM remote_follows WHERE follow_id = $1 AND actor_id = $2
+`
+
+type RejectRemoteFollowParams struct {
+	FollowID string
+	ActorID  string
+}
+
+func (q *Queries) RejectRemoteFollow(ctx context.Context, arg RejectRemoteFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rejectRemoteFollow, arg.FollowID, arg.ActorID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeRemoteFollower = `-- name: RemoveRemoteFollower :execrows
+DELETE FROM remote_followers WHERE actor_id = $1 AND follow_id = $2
+`
+
+type RemoveRemoteFollowerParams struct {
+	ActorID  string
+	FollowID string
+}
+
+func (q *Queries) RemoveRemoteFollower(ctx context.Context, arg RemoveRemoteFollowerParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeRemoteFollower, arg.ActorID, arg.FollowID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertRemoteActor = `-- name: UpsertRemoteActor :one
+INSERT INTO remote_actors (id, inbox, shared_inbox, public_key_id, public_key_pem)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (id) DO UPDATE
+SET inbox = EXCLUDED.inbox,
+    shared_inbox = EXCLUDED.shared_inbox,
+    public_key_id = EXCLUDED.public_key_id,
+    public_key_pem = EXCLUDED.public_key_pem,
+    fetched_at = CURRENT_TIMESTAMP
+RETURNING id, inbox, shared_inbox, public_key_id, public_key_pem, fetched_at
+`
+
+type UpsertRemoteActorParams struct {
+	ID           string
+	Inbox        string
+	SharedInbox  sql.NullString
+	PublicKeyID  string
+	PublicKeyPem string
+}
+
+func (q *Queries) UpsertRemoteActor(ctx context.Context, arg UpsertRemoteActorParams) (RemoteActor, error) {
+	row := q.db.QueryRowContext(ctx, upsertRemoteActor,
+		arg.ID,
+		arg.Inbox,
+		arg.SharedInbox,
+		arg.PublicKeyID,
+		arg.PublicKeyPem,
+	)
+	var i RemoteActor
+	err := row.Scan(
+		&i.ID,
+		&i.Inbox,
+		&i.SharedInbox,
+		&i.PublicKeyID,
+		&i.PublicKeyPem,
+		&i.FetchedAt,
+	)
+	return i, err
+}
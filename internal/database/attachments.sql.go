@@ -0,0 +1,196 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: attachments.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const attachToChirp = `-- name: AttachToChirp :execrows
+UPDATE attachments
+SET chirp_id = $1, position = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL
+`
+
+type AttachToChirpParams struct {
+	ChirpID  uuid.NullUUID
+	Position int32
+	ID       uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) AttachToChirp(ctx context.Context, arg AttachToChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, attachToChirp,
+		arg.ChirpID,
+		arg.Position,
+		arg.ID,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createAttachment = `-- name: CreateAttachment :one
+INSERT INTO attachments (id, user_id, chirp_id, position, content_type, size_bytes, width, height, storage_key, thumbnail_key, created_at, updated_at)
+VALUES ($1, $2, NULL, 0, $3, $4, $5, $6, $7, $8, DEFAULT, DEFAULT)
+RETURNING id, user_id, chirp_id, position, content_type, size_bytes, width, height, storage_key, thumbnail_key, created_at, updated_at
+`
+
+type CreateAttachmentParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ContentType  string
+	SizeBytes    int64
+	Width        int32
+	Height       int32
+	StorageKey   string
+	ThumbnailKey string
+}
+
+func (q *Queries) CreateAttachment(ctx context.Context, arg CreateAttachmentParams) (Attachment, error) {
+	row := q.db.QueryRowContext(ctx, createAttachment,
+		arg.ID,
+		arg.UserID,
+		arg.ContentType,
+		arg.SizeBytes,
+		arg.Width,
+		arg.Height,
+		arg.StorageKey,
+		arg.ThumbnailKey,
+	)
+	var i Attachment
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Position,
+		&i.ContentType,
+		&i.SizeBytes,
+		&i.Width,
+		&i.Height,
+		&i.StorageKey,
+		&i.ThumbnailKey,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteAttachments = `-- name: DeleteAttachments :execrows
+DELETE FROM attachments WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) DeleteAttachments(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAttachments, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAttachmentsByChirpIDs = `-- name: GetAttachmentsByChirpIDs :many
+SELECT id, user_id, chirp_id, position, content_type, size_bytes, width, height, storage_key, thumbnail_key, created_at, updated_at FROM attachments
+WHERE chirp_id = ANY($1::uuid[])
+ORDER BY chirp_id, position
+`
+
+func (q *Queries) GetAttachmentsByChirpIDs(ctx context.Context, chirpIds []uuid.UUID) ([]Attachment, error) {
+	rows, err := q.db.QueryContext(ctx, getAttachmentsByChirpIDs, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Attachment
+	for rows.Next() {
+		var i Attachment
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Position,
+			&i.ContentType,
+			&i.SizeBytes,
+			&i.Width,
+			&i.Height,
+			&i.StorageKey,
+			&i.ThumbnailKey,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPurgeableAttachments = `-- name: GetPurgeableAttachments :many
+SELECT attachments.id, attachments.user_id, attachments.chirp_id, attachments.position, attachments.content_type, attachments.size_bytes, attachments.width, attachments.height, attachments.storage_key, attachments.thumbnail_key, attachments.created_at, attachments.updated_at FROM attachments
+LEFT JOIN chirps ON chirps.id = attachments.chirp_id
+JOIN users ON users.id = attachments.user_id
+WHERE chirps.deleted_at < $1
+   OR users.deleted_at < $1
+   OR (attachments.chirp_id IS NULL
+       AND attachments.created_at < $2
+       AND NOT EXISTS (SELECT 1 FROM drafts WHERE attachments.id = ANY(drafts.attachment_ids)))
+LIMIT $3
+`
+
+type GetPurgeableAttachmentsParams struct {
+	DeletedBefore  sql.NullTime
+	OrphanedBefore sql.NullTime
+	MaxAttachments int32
+}
+
+// Files of chirps and users past retention, and uploads that never made it
+// into a chirp or a draft.
+func (q *Queries) GetPurgeableAttachments(ctx context.Context, arg GetPurgeableAttachmentsParams) ([]Attachment, error) {
+	rows, err := q.db.QueryContext(ctx, getPurgeableAttachments, arg.DeletedBefore, arg.OrphanedBefore, arg.MaxAttachments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Attachment
+	for rows.Next() {
+		var i Attachment
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Position,
+			&i.ContentType,
+			&i.SizeBytes,
+			&i.Width,
+			&i.Height,
+			&i.StorageKey,
+			&i.ThumbnailKey,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
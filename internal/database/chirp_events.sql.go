@@ -0,0 +1,30 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: chirp_events.sql
+
+package database
+
+import (
+	"context"
+)
+
+const nextChirpEventID = `-- name: NextChirpEventID :one
+SELECT nextval('chirp_event_ids')::bigint
+`
+
+func (q *Queries) NextChirpEventID(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, nextChirpEventID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const notifyChirpEvent = `-- name: NotifyChirpEvent :exec
+SELECT pg_notify('chirp_events', $1::text)
+`
+
+func (q *Queries) NotifyChirpEvent(ctx context.Context, payload string) error {
+	_, err := q.db.ExecContext(ctx, notifyChirpEvent, payload)
+	return err
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -15,7 +16,7 @@ const checkChirpAccess = `-- name: CheckChirpAccess :one
 SELECT EXISTS (
     SELECT 1
     FROM chirps
-    WHERE id = $1 AND user_id = $2
+    WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
 )
 `
 
@@ -32,18 +33,19 @@ func (q *Queries) CheckChirpAccess(ctx context.Context, arg CheckChirpAccessPara
 }
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, user_id, body, created_at, updated_at)
-VALUES (gen_random_uuid(), $1, $2, DEFAULT, DEFAULT)
-RETURNING id, user_id, body, created_at, updated_at
+INSERT INTO chirps (id, user_id, body, publish_at, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, DEFAULT, DEFAULT)
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at
 `
 
 type CreateChirpParams struct {
-	UserID uuid.UUID
-	Body   string
+	UserID    uuid.UUID
+	Body      string
+	PublishAt sql.NullTime
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.UserID, arg.Body)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.UserID, arg.Body, arg.PublishAt)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -51,21 +53,27 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PublishAt,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :execrows
-DELETE FROM chirps WHERE id = $1 AND user_id = $2
+UPDATE chirps
+SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $1
+WHERE id = $2 AND deleted_at IS NULL
 `
 
 type DeleteChirpParams struct {
-	ID     uuid.UUID
-	UserID uuid.UUID
+	DeletedBy uuid.NullUUID
+	ID        uuid.UUID
 }
 
 func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (int64, error) {
-	result, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.UserID)
+	result, err := q.db.ExecContext(ctx, deleteChirp, arg.DeletedBy, arg.ID)
 	if err != nil {
 		return 0, err
 	}
@@ -73,8 +81,10 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (int64
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, user_id, body, created_at, updated_at FROM chirps 
-WHERE ($1::uuid IS NULL OR user_id = $1)
+SELECT id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at FROM chirps 
+WHERE ($1::uuid IS NULL OR chirps.user_id = $1)
+  AND chirps.deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.deleted_at IS NULL)
 ORDER BY created_at
 `
 
@@ -93,6 +103,10 @@ func (q *Queries) GetAllChirps(ctx context.Context, userID uuid.NullUUID) ([]Chi
 			&i.Body,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PublishAt,
 		); err != nil {
 			return nil, err
 		}
@@ -108,7 +122,10 @@ func (q *Queries) GetAllChirps(ctx context.Context, userID uuid.NullUUID) ([]Chi
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, user_id, body, created_at, updated_at FROM chirps WHERE id = $1
+SELECT id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at FROM chirps
+WHERE chirps.id = $1
+  AND chirps.deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.deleted_at IS NULL)
 `
 
 func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -120,6 +137,340 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const getChirpsByUser = `-- name: GetChirpsByUser :many
+SELECT id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPage = `-- name: GetChirpsPage :many
+SELECT id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at FROM chirps
+WHERE ($1::uuid IS NULL OR chirps.user_id = $1)
+  AND ($2::timestamptz IS NULL
+       OR (chirps.created_at, chirps.id) < ($2::timestamptz, $3::uuid))
+  AND chirps.deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.deleted_at IS NULL)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetChirpsPageParams struct {
+	UserID    uuid.NullUUID
+	BeforeAt  sql.NullTime
+	BeforeID  uuid.NullUUID
+	MaxChirps int32
+}
+
+func (q *Queries) GetChirpsPage(ctx context.Context, arg GetChirpsPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPage,
+		arg.UserID,
+		arg.BeforeAt,
+		arg.BeforeID,
+		arg.MaxChirps,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedChirps = `-- name: GetFeedChirps :many
+SELECT id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at FROM chirps
+WHERE ($1::uuid IS NULL OR chirps.user_id = $1)
+  AND ($2::text IS NULL OR chirps.body ~* ('(^|[^[:alnum:]_#])#' || $2::text || '($|[^[:alnum:]_])'))
+  AND chirps.deleted_at IS NULL
+  AND chirps.hidden_at IS NULL
+  AND chirps.publish_at IS NULL
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.deleted_at IS NULL)
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type GetFeedChirpsParams struct {
+	UserID    uuid.NullUUID
+	Hashtag   sql.NullString
+	MaxChirps int32
+}
+
+// The newest chirps anyone may see, by author or hashtag. The tag pattern
+// mirrors chirptext.Hashtags, tags are letters, digits and underscores so
+// they need no escaping.
+func (q *Queries) GetFeedChirps(ctx context.Context, arg GetFeedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedChirps, arg.UserID, arg.Hashtag, arg.MaxChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :one
+UPDATE chirps SET hidden_at = CURRENT_TIMESTAMP WHERE id = $1 AND hidden_at IS NULL AND deleted_at IS NULL
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, hideChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const purgeChirps = `-- name: PurgeChirps :execrows
+DELETE FROM chirps
+WHERE id IN (
+    SELECT id FROM chirps AS purged
+    WHERE purged.deleted_at < $1
+    LIMIT $2
+)
+`
+
+type PurgeChirpsParams struct {
+	DeletedBefore sql.NullTime
+	MaxChirps     int32
+}
+
+func (q *Queries) PurgeChirps(ctx context.Context, arg PurgeChirpsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeChirps, arg.DeletedBefore, arg.MaxChirps)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const releaseScheduledChirps = `-- name: ReleaseScheduledChirps :many
+UPDATE chirps
+SET publish_at = NULL, created_at = chirps.publish_at, updated_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT id FROM chirps AS due
+    WHERE due.publish_at <= CURRENT_TIMESTAMP AND due.deleted_at IS NULL
+      AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = due.user_id AND users.deleted_at IS NOT NULL)
+    ORDER BY due.publish_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at
+`
+
+// Rows locked by another instance are skipped, so every due chirp is
+// released exactly once. created_at becomes the publish time so the chirp
+// lands where readers expect it in timelines. Chirps of deleted accounts
+// wait, they are released if the account is restored and purged with it
+// otherwise.
+func (q *Queries) ReleaseScheduledChirps(ctx context.Context, maxChirps int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, releaseScheduledChirps, maxChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = $1
+  AND user_id = $2
+  AND deleted_by = $2
+  AND deleted_at > $3
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter sql.NullTime
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const unhideChirp = `-- name: UnhideChirp :one
+UPDATE chirps SET hidden_at = NULL WHERE id = $1 AND hidden_at IS NOT NULL AND deleted_at IS NULL
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at
+`
+
+func (q *Queries) UnhideChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, unhideChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, deleted_at, deleted_by, publish_at
+`
+
+type UpdateChirpBodyParams struct {
+	Body   string
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PublishAt,
 	)
 	return i, err
 }
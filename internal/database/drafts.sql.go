@@ -0,0 +1,148 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: drafts.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createDraft = `-- name: CreateDraft :one
+INSERT INTO drafts (id, user_id, body, attachment_ids)
+VALUES (gen_random_uuid(), $1, $2, $3::uuid[])
+RETURNING id, user_id, body, attachment_ids, created_at, updated_at
+`
+
+type CreateDraftParams struct {
+	UserID        uuid.UUID
+	Body          string
+	AttachmentIds []uuid.UUID
+}
+
+func (q *Queries) CreateDraft(ctx context.Context, arg CreateDraftParams) (Draft, error) {
+	row := q.db.QueryRowContext(ctx, createDraft, arg.UserID, arg.Body, pq.Array(arg.AttachmentIds))
+	var i Draft
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		pq.Array(&i.AttachmentIds),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteDraft = `-- name: DeleteDraft :execrows
+DELETE FROM drafts WHERE id = $1 AND user_id = $2
+`
+
+type DeleteDraftParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteDraft(ctx context.Context, arg DeleteDraftParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteDraft, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getDraft = `-- name: GetDraft :one
+SELECT id, user_id, body, attachment_ids, created_at, updated_at FROM drafts WHERE id = $1 AND user_id = $2
+`
+
+type GetDraftParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetDraft(ctx context.Context, arg GetDraftParams) (Draft, error) {
+	row := q.db.QueryRowContext(ctx, getDraft, arg.ID, arg.UserID)
+	var i Draft
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		pq.Array(&i.AttachmentIds),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getDrafts = `-- name: GetDrafts :many
+SELECT id, user_id, body, attachment_ids, created_at, updated_at FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC
+`
+
+func (q *Queries) GetDrafts(ctx context.Context, userID uuid.UUID) ([]Draft, error) {
+	rows, err := q.db.QueryContext(ctx, getDrafts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Draft
+	for rows.Next() {
+		var i Draft
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			pq.Array(&i.AttachmentIds),
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateDraft = `-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $1, attachment_ids = $2::uuid[], updated_at = CURRENT_TIMESTAMP
+WHERE id = $3 AND user_id = $4
+RETURNING id, user_id, body, attachment_ids, created_at, updated_at
+`
+
+type UpdateDraftParams struct {
+	Body          string
+	AttachmentIds []uuid.UUID
+	ID            uuid.UUID
+	UserID        uuid.UUID
+}
+
+func (q *Queries) UpdateDraft(ctx context.Context, arg UpdateDraftParams) (Draft, error) {
+	row := q.db.QueryRowContext(ctx, updateDraft,
+		arg.Body,
+		pq.Array(arg.AttachmentIds),
+		arg.ID,
+		arg.UserID,
+	)
+	var i Draft
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		pq.Array(&i.AttachmentIds),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,183 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: exports.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const completeExport = `-- name: CompleteExport :execrows
+UPDATE exports
+SET status = 'ready',
+    storage_key = $1,
+    size_bytes = $2,
+    completed_at = CURRENT_TIMESTAMP,
+    expires_at = $3
+WHERE id = $4 AND status = 'pending'
+`
+
+type CompleteExportParams struct {
+	StorageKey sql.NullString
+	SizeBytes  sql.NullInt64
+	ExpiresAt  sql.NullTime
+	ID         uuid.UUID
+}
+
+func (q *Queries) CompleteExport(ctx context.Context, arg CompleteExportParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, completeExport,
+		arg.StorageKey,
+		arg.SizeBytes,
+		arg.ExpiresAt,
+		arg.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createExport = `-- name: CreateExport :one
+INSERT INTO exports (id, user_id)
+VALUES (gen_random_uuid(), $1)
+ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+RETURNING id, user_id, status, storage_key, size_bytes, created_at, completed_at, expires_at
+`
+
+func (q *Queries) CreateExport(ctx context.Context, userID uuid.UUID) (Export, error) {
+	row := q.db.QueryRowContext(ctx, createExport, userID)
+	var i Export
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.StorageKey,
+		&i.SizeBytes,
+		&i.CreatedAt,
+		&i.CompletedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const deleteExports = `-- name: DeleteExports :execrows
+DELETE FROM exports WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) DeleteExports(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExports, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const failExport = `-- name: FailExport :execrows
+UPDATE exports
+SET status = 'failed', completed_at = CURRENT_TIMESTAMP, expires_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'pending'
+`
+
+// Failed exports have nothing to download, they expire right away.
+func (q *Queries) FailExport(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, failExport, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getExpiredExports = `-- name: GetExpiredExports :many
+SELECT exports.id, exports.user_id, exports.status, exports.storage_key, exports.size_bytes, exports.created_at, exports.completed_at, exports.expires_at FROM exports
+JOIN users ON users.id = exports.user_id
+WHERE exports.expires_at < $1
+   OR (users.deleted_at IS NOT NULL AND exports.status <> 'pending')
+LIMIT $2
+`
+
+type GetExpiredExportsParams struct {
+	ExpiredBefore sql.NullTime
+	MaxExports    int32
+}
+
+// Archives past their expiry, and any archive of a deleted account.
+func (q *Queries) GetExpiredExports(ctx context.Context, arg GetExpiredExportsParams) ([]Export, error) {
+	rows, err := q.db.QueryContext(ctx, getExpiredExports, arg.ExpiredBefore, arg.MaxExports)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Export
+	for rows.Next() {
+		var i Export
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.StorageKey,
+			&i.SizeBytes,
+			&i.CreatedAt,
+			&i.CompletedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExportByID = `-- name: GetExportByID :one
+SELECT id, user_id, status, storage_key, size_bytes, created_at, completed_at, expires_at FROM exports WHERE id = $1
+`
+
+func (q *Queries) GetExportByID(ctx context.Context, id uuid.UUID) (Export, error) {
+	row := q.db.QueryRowContext(ctx, getExportByID, id)
+	var i Export
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.StorageKey,
+		&i.SizeBytes,
+		&i.CreatedAt,
+		&i.CompletedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getLatestExport = `-- name: GetLatestExport :one
+SELECT id, user_id, status, storage_key, size_bytes, created_at, completed_at, expires_at FROM exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestExport(ctx context.Context, userID uuid.UUID) (Export, error) {
+	row := q.db.QueryRowContext(ctx, getLatestExport, userID)
+	var i Export
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.StorageKey,
+		&i.SizeBytes,
+		&i.CreatedAt,
+		&i.CompletedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
@@ -0,0 +1,102 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: follows.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const followUser = `-- name: FollowUser :execrows
+INSERT INTO user_follows (follower_id, followed_id, created_at)
+VALUES ($1, $2, DEFAULT)
+ON CONFLICT DO NOTHING
+`
+
+type FollowUserParams struct {
+	FollowerID uuid.UUID
+	FollowedID uuid.UUID
+}
+
+func (q *Queries) FollowUser(ctx context.Context, arg FollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, followUser, arg.FollowerID, arg.FollowedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFollowedUserIDs = `-- name: GetFollowedUserIDs :many
+SELECT followed_id FROM user_follows WHERE follower_id = $1
+`
+
+func (q *Queries) GetFollowedUserIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedUserIDs, followerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var followed_id uuid.UUID
+		if err := rows.Scan(&followed_id); err != nil {
+			return nil, err
+		}
+		items = append(items, followed_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFollowedUsers = `-- name: GetFollowedUsers :many
+SELECT follower_id, followed_id, created_at FROM user_follows WHERE follower_id = $1 ORDER BY created_at DESC
+`
+
+func (q *Queries) GetFollowedUsers(ctx context.Context, followerID uuid.UUID) ([]UserFollow, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedUsers, followerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserFollow
+	for rows.Next() {
+		var i UserFollow
+		if err := rows.Scan(&i.FollowerID, &i.FollowedID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const unfollowUser = `-- name: UnfollowUser :execrows
+DELETE FROM user_follows WHERE follower_id = $1 AND followed_id = $2
+`
+
+type UnfollowUserParams struct {
+	FollowerID uuid.UUID
+	FollowedID uuid.UUID
+}
+
+func (q *Queries) UnfollowUser(ctx context.Context, arg UnfollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unfollowUser, arg.FollowerID, arg.FollowedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,139 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: idempotency_keys.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimIdempotencyKey = `-- name: ClaimIdempotencyKey :execrows
+INSERT INTO idempotency_keys (user_id, key, fingerprint)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint,
+    status = NULL,
+    headers = '{}',
+    body = '',
+    created_at = CURRENT_TIMESTAMP
+WHERE idempotency_keys.created_at < $4
+   OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at < $5)
+`
+
+type ClaimIdempotencyKeyParams struct {
+	UserID          uuid.UUID
+	Key             string
+	Fingerprint     []byte
+	ExpiredBefore   time.Time
+	AbandonedBefore time.Time
+}
+
+// Takes the key for a request. A key already taken is only taken over once
+// it expired, or when its request was abandoned without a response.
+func (q *Queries) ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimIdempotencyKey,
+		arg.UserID,
+		arg.Key,
+		arg.Fingerprint,
+		arg.ExpiredBefore,
+		arg.AbandonedBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const completeIdempotencyKey = `-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status = $3, headers = $4, body = $5
+WHERE user_id = $1 AND key = $2
+`
+
+type CompleteIdempotencyKeyParams struct {
+	UserID  uuid.UUID
+	Key     string
+	Status  sql.NullInt32
+	Headers json.RawMessage
+	Body    []byte
+}
+
+func (q *Queries) CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error {
+	_, err := q.db.ExecContext(ctx, completeIdempotencyKey,
+		arg.UserID,
+		arg.Key,
+		arg.Status,
+		arg.Headers,
+		arg.Body,
+	)
+	return err
+}
+
+const deleteIdempotencyKey = `-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE user_id = $1 AND key = $2
+`
+
+type DeleteIdempotencyKeyParams struct {
+	UserID uuid.UUID
+	Key    string
+}
+
+func (q *Queries) DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error {
+	_, err := q.db.ExecContext(ctx, deleteIdempotencyKey, arg.UserID, arg.Key)
+	return err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT user_id, key, fingerprint, status, headers, body, created_at FROM idempotency_keys
+WHERE user_id = $1 AND key = $2
+`
+
+type GetIdempotencyKeyParams struct {
+	UserID uuid.UUID
+	Key    string
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, getIdempotencyKey, arg.UserID, arg.Key)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.UserID,
+		&i.Key,
+		&i.Fingerprint,
+		&i.Status,
+		&i.Headers,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const purgeIdempotencyKeys = `-- name: PurgeIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE (user_id, key) IN (
+    SELECT user_id, key FROM idempotency_keys AS purged
+    WHERE purged.created_at < $1
+    LIMIT $2
+)
+`
+
+type PurgeIdempotencyKeysParams struct {
+	CreatedBefore time.Time
+	MaxKeys       int32
+}
+
+func (q *Queries) PurgeIdempotencyKeys(ctx context.Context, arg PurgeIdempotencyKeysParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeIdempotencyKeys, arg.CreatedBefore, arg.MaxKeys)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
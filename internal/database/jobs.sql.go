@@ -0,0 +1,352 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: jobs.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimJobs = `-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_until = $1::timestamptz, updated_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT due.id FROM jobs AS due
+    WHERE (due.status = 'queued' AND due.run_at <= CURRENT_TIMESTAMP)
+    OR (due.status = 'running' AND due.locked_until <= CURRENT_TIMESTAMP)
+    ORDER BY due.run_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, payload, status, unique_key, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at, finished_at
+`
+
+type ClaimJobsParams struct {
+	LeaseUntil time.Time
+	MaxJobs    int32
+}
+
+func (q *Queries) ClaimJobs(ctx context.Context, arg ClaimJobsParams) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx, claimJobs, arg.LeaseUntil, arg.MaxJobs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Payload,
+			&i.Status,
+			&i.UniqueKey,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAt,
+			&i.LockedUntil,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeJob = `-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_until = NULL, last_error = NULL, updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+func (q *Queries) CompleteJob(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, completeJob, id)
+	return err
+}
+
+const enqueueJob = `-- name: EnqueueJob :execrows
+INSERT INTO jobs (id, kind, payload, unique_key, max_attempts, run_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5)
+ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('queued', 'running') DO NOTHING
+`
+
+type EnqueueJobParams struct {
+	Kind        string
+	Payload     json.RawMessage
+	UniqueKey   sql.NullString
+	MaxAttempts int32
+	RunAt       time.Time
+}
+
+func (q *Queries) EnqueueJob(ctx context.Context, arg EnqueueJobParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enqueueJob,
+		arg.Kind,
+		arg.Payload,
+		arg.UniqueKey,
+		arg.MaxAttempts,
+		arg.RunAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getDueJobSchedules = `-- name: GetDueJobSchedules :many
+SELECT name, spec, next_run_at, last_run_at FROM job_schedules
+WHERE next_run_at <= CURRENT_TIMESTAMP
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) GetDueJobSchedules(ctx context.Context) ([]JobSchedule, error) {
+	rows, err := q.db.QueryContext(ctx, getDueJobSchedules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []JobSchedule
+	for rows.Next() {
+		var i JobSchedule
+		if err := rows.Scan(
+			&i.Name,
+			&i.Spec,
+			&i.NextRunAt,
+			&i.LastRunAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getJobSchedules = `-- name: GetJobSchedules :many
+SELECT name, spec, next_run_at, last_run_at FROM job_schedules ORDER BY name
+`
+
+func (q *Queries) GetJobSchedules(ctx context.Context) ([]JobSchedule, error) {
+	rows, err := q.db.QueryContext(ctx, getJobSchedules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []JobSchedule
+	for rows.Next() {
+		var i JobSchedule
+		if err := rows.Scan(
+			&i.Name,
+			&i.Spec,
+			&i.NextRunAt,
+			&i.LastRunAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getJobStats = `-- name: GetJobStats :many
+SELECT kind, status, COUNT(*) AS count
+FROM jobs
+GROUP BY kind, status
+ORDER BY kind, status
+`
+
+type GetJobStatsRow struct {
+	Kind   string
+	Status string
+	Count  int64
+}
+
+func (q *Queries) GetJobStats(ctx context.Context) ([]GetJobStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getJobStats)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetJobStatsRow
+	for rows.Next() {
+		var i GetJobStatsRow
+		if err := rows.Scan(&i.Kind, &i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getJobs = `-- name: GetJobs :many
+SELECT id, kind, payload, status, unique_key, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at, finished_at FROM jobs
+WHERE $1::text IS NULL OR status = $1::text
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetJobsParams struct {
+	Status  sql.NullString
+	MaxJobs int32
+}
+
+func (q *Queries) GetJobs(ctx context.Context, arg GetJobsParams) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx, getJobs, arg.Status, arg.MaxJobs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Payload,
+			&i.Status,
+			&i.UniqueKey,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAt,
+			&i.LockedUntil,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const killJob = `-- name: KillJob :exec
+UPDATE jobs
+SET status = 'dead', locked_until = NULL, last_error = $1, updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+WHERE id = $2
+`
+
+type KillJobParams struct {
+	LastError sql.NullString
+	ID        uuid.UUID
+}
+
+func (q *Queries) KillJob(ctx context.Context, arg KillJobParams) error {
+	_, err := q.db.ExecContext(ctx, killJob, arg.LastError, arg.ID)
+	return err
+}
+
+const purgeJobs = `-- name: PurgeJobs :execrows
+DELETE FROM jobs
+WHERE id IN (
+    SELECT id FROM jobs AS purged
+    WHERE purged.finished_at < $1
+    LIMIT $2
+)
+`
+
+type PurgeJobsParams struct {
+	FinishedBefore sql.NullTime
+	MaxJobs        int32
+}
+
+func (q *Queries) PurgeJobs(ctx context.Context, arg PurgeJobsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeJobs, arg.FinishedBefore, arg.MaxJobs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryJob = `-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'queued', locked_until = NULL, run_at = $1, last_error = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3
+`
+
+type RetryJobParams struct {
+	RunAt     time.Time
+	LastError sql.NullString
+	ID        uuid.UUID
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) error {
+	_, err := q.db.ExecContext(ctx, retryJob, arg.RunAt, arg.LastError, arg.ID)
+	return err
+}
+
+const setJobScheduleNextRun = `-- name: SetJobScheduleNextRun :exec
+UPDATE job_schedules
+SET next_run_at = $2, last_run_at = CURRENT_TIMESTAMP
+WHERE name = $1
+`
+
+type SetJobScheduleNextRunParams struct {
+	Name      string
+	NextRunAt time.Time
+}
+
+func (q *Queries) SetJobScheduleNextRun(ctx context.Context, arg SetJobScheduleNextRunParams) error {
+	_, err := q.db.ExecContext(ctx, setJobScheduleNextRun, arg.Name, arg.NextRunAt)
+	return err
+}
+
+const upsertJobSchedule = `-- name: UpsertJobSchedule :exec
+INSERT INTO job_schedules (name, spec, next_run_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE
+SET spec = EXCLUDED.spec,
+    next_run_at = CASE WHEN job_schedules.spec = EXCLUDED.spec THEN job_schedules.next_run_at ELSE EXCLUDED.next_run_at END
+`
+
+type UpsertJobScheduleParams struct {
+	Name      string
+	Spec      string
+	NextRunAt time.Time
+}
+
+// A changed spec starts over from the new next run.
+func (q *Queries) UpsertJobSchedule(ctx context.Context, arg UpsertJobScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, upsertJobSchedule, arg.Name, arg.Spec, arg.NextRunAt)
+	return err
+}
@@ -0,0 +1,343 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: messages.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addConversationParticipant = `-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, last_read_at, joined_at)
+VALUES ($1, $2, NULL, DEFAULT)
+`
+
+type AddConversationParticipantParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) AddConversationParticipant(ctx context.Context, arg AddConversationParticipantParams) error {
+	_, err := q.db.ExecContext(ctx, addConversationParticipant, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const createConversation = `-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (gen_random_uuid(), DEFAULT, DEFAULT)
+RETURNING id, created_at, updated_at
+`
+
+func (q *Queries) CreateConversation(ctx context.Context) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createConversation)
+	var i Conversation
+	err := row.Scan(&i.ID, &i.CreatedAt, &i.UpdatedAt)
+	return i, err
+}
+
+const createMessage = `-- name: CreateMessage :one
+INSERT INTO messages (id, conversation_id, sender_id, key_id, nonce, ciphertext, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, DEFAULT)
+RETURNING id, conversation_id, sender_id, key_id, nonce, ciphertext, created_at
+`
+
+type CreateMessageParams struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	KeyID          string
+	Nonce          []byte
+	Ciphertext     []byte
+}
+
+func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, createMessage,
+		arg.ID,
+		arg.ConversationID,
+		arg.SenderID,
+		arg.KeyID,
+		arg.Nonce,
+		arg.Ciphertext,
+	)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.KeyID,
+		&i.Nonce,
+		&i.Ciphertext,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getConversationByParticipants = `-- name: GetConversationByParticipants :one
+SELECT conversation_id
+FROM conversation_participants
+GROUP BY conversation_id
+HAVING COUNT(*) = cardinality($1::uuid[])
+   AND bool_and(user_id = ANY($1::uuid[]))
+LIMIT 1
+`
+
+func (q *Queries) GetConversationByParticipants(ctx context.Context, userIds []uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getConversationByParticipants, pq.Array(userIds))
+	var conversation_id uuid.UUID
+	err := row.Scan(&conversation_id)
+	return conversation_id, err
+}
+
+const getConversationParticipants = `-- name: GetConversationParticipants :many
+SELECT conversation_id, user_id, last_read_at, joined_at FROM conversation_participants
+WHERE conversation_id = ANY($1::uuid[])
+ORDER BY joined_at
+`
+
+func (q *Queries) GetConversationParticipants(ctx context.Context, conversationIds []uuid.UUID) ([]ConversationParticipant, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationParticipants, pq.Array(conversationIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ConversationParticipant
+	for rows.Next() {
+		var i ConversationParticipant
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.UserID,
+			&i.LastReadAt,
+			&i.JoinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getConversationsForUser = `-- name: GetConversationsForUser :many
+SELECT
+    conversations.id, conversations.created_at, conversations.updated_at,
+    (
+        SELECT COUNT(*)
+        FROM messages
+        WHERE messages.conversation_id = conversations.id
+          AND messages.sender_id <> conversation_participants.user_id
+          AND (conversation_participants.last_read_at IS NULL OR messages.created_at > conversation_participants.last_read_at)
+    )::int AS unread_count
+FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversation_participants.user_id = $1
+ORDER BY conversations.updated_at DESC
+`
+
+type GetConversationsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UnreadCount int32
+}
+
+func (q *Queries) GetConversationsForUser(ctx context.Context, userID uuid.UUID) ([]GetConversationsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetConversationsForUserRow
+	for rows.Next() {
+		var i GetConversationsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessages = `-- name: GetMessages :many
+SELECT id, conversation_id, sender_id, key_id, nonce, ciphertext, created_at FROM messages
+WHERE conversation_id = $1
+  AND ($2::timestamptz IS NULL OR created_at < $2)
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type GetMessagesParams struct {
+	ConversationID uuid.UUID
+	Before         sql.NullTime
+	MaxMessages    int32
+}
+
+func (q *Queries) GetMessages(ctx context.Context, arg GetMessagesParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, getMessages, arg.ConversationID, arg.Before, arg.MaxMessages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.KeyID,
+			&i.Nonce,
+			&i.Ciphertext,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessagesNotSealedWith = `-- name: GetMessagesNotSealedWith :many
+SELECT id, conversation_id, sender_id, key_id, nonce, ciphertext, created_at FROM messages
+WHERE key_id <> $1
+  AND NOT (id = ANY($2::uuid[]))
+LIMIT $3
+`
+
+type GetMessagesNotSealedWithParams struct {
+	KeyID       string
+	SkipIds     []uuid.UUID
+	MaxMessages int32
+}
+
+func (q *Queries) GetMessagesNotSealedWith(ctx context.Context, arg GetMessagesNotSealedWithParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, getMessagesNotSealedWith, arg.KeyID, pq.Array(arg.SkipIds), arg.MaxMessages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.KeyID,
+			&i.Nonce,
+			&i.Ciphertext,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isConversationParticipant = `-- name: IsConversationParticipant :one
+SELECT EXISTS (
+    SELECT 1
+    FROM conversation_participants
+    WHERE conversation_id = $1 AND user_id = $2
+)
+`
+
+type IsConversationParticipantParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) IsConversationParticipant(ctx context.Context, arg IsConversationParticipantParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isConversationParticipant, arg.ConversationID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const markConversationRead = `-- name: MarkConversationRead :exec
+UPDATE conversation_participants
+SET last_read_at = CURRENT_TIMESTAMP
+WHERE conversation_id = $1 AND user_id = $2
+`
+
+type MarkConversationReadParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) error {
+	_, err := q.db.ExecContext(ctx, markConversationRead, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const resealMessage = `-- name: ResealMessage :execrows
+UPDATE messages
+SET key_id = $1, nonce = $2, ciphertext = $3
+WHERE id = $4 AND key_id = $5
+`
+
+type ResealMessageParams struct {
+	KeyID      string
+	Nonce      []byte
+	Ciphertext []byte
+	ID         uuid.UUID
+	OldKeyID   string
+}
+
+func (q *Queries) ResealMessage(ctx context.Context, arg ResealMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resealMessage,
+		arg.KeyID,
+		arg.Nonce,
+		arg.Ciphertext,
+		arg.ID,
+		arg.OldKeyID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const touchConversation = `-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = CURRENT_TIMESTAMP WHERE id = $1
+`
+
+func (q *Queries) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchConversation, id)
+	return err
+}
@@ -6,17 +6,171 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ActorKey struct {
+	UserID        uuid.UUID
+	PublicKeyPem  string
+	PrivateKeyPem string
+	CreatedAt     time.Time
+}
+
+type Attachment struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	SizeBytes    int64
+	Width        int32
+	Height       int32
+	StorageKey   string
+	ThumbnailKey string
+	CreatedAt    sql.NullTime
+	UpdatedAt    sql.NullTime
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
 	Body      string
 	CreatedAt sql.NullTime
 	UpdatedAt sql.NullTime
+	HiddenAt  sql.NullTime
+	DeletedAt sql.NullTime
+	DeletedBy uuid.NullUUID
+	PublishAt sql.NullTime
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	LastReadAt     sql.NullTime
+	JoinedAt       time.Time
+}
+
+type Draft struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	Body          string
+	AttachmentIds []uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type Export struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Status      string
+	StorageKey  sql.NullString
+	SizeBytes   sql.NullInt64
+	CreatedAt   time.Time
+	CompletedAt sql.NullTime
+	ExpiresAt   sql.NullTime
+}
+
+type IdempotencyKey struct {
+	UserID      uuid.UUID
+	Key         string
+	Fingerprint []byte
+	Status      sql.NullInt32
+	Headers     json.RawMessage
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     json.RawMessage
+	Status      string
+	UniqueKey   sql.NullString
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedUntil sql.NullTime
+	LastError   sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  sql.NullTime
+}
+
+type JobSchedule struct {
+	Name      string
+	Spec      string
+	NextRunAt time.Time
+	LastRunAt sql.NullTime
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	KeyID          string
+	Nonce          []byte
+	Ciphertext     []byte
+	CreatedAt      time.Time
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID
+	ModeratorID uuid.NullUUID
+	Action      string
+	ReportID    uuid.NullUUID
+	UserID      uuid.UUID
+	ChirpID     uuid.NullUUID
+	Reason      string
+	CreatedAt   time.Time
+}
+
+type ModerationRule struct {
+	ID        uuid.UUID
+	Kind      string
+	Pattern   string
+	Action    string
+	Position  int32
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Type      string
+	Muted     bool
+	UpdatedAt sql.NullTime
+}
+
+type Outbox struct {
+	ID            int64
+	AggregateType string
+	AggregateID   uuid.UUID
+	Event         string
+	Payload       json.RawMessage
+	Attempts      int32
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	PublishedAt   sql.NullTime
 }
 
 type RefreshToken struct {
@@ -28,6 +182,78 @@ type RefreshToken struct {
 	UpdatedAt sql.NullTime
 }
 
+type RemoteActor struct {
+	ID           string
+	Inbox        string
+	SharedInbox  sql.NullString
+	PublicKeyID  string
+	PublicKeyPem string
+	FetchedAt    time.Time
+}
+
+type RemoteFollow struct {
+	UserID     uuid.UUID
+	ActorID    string
+	FollowID   string
+	AcceptedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+type RemoteFollower struct {
+	UserID    uuid.UUID
+	ActorID   string
+	FollowID  string
+	CreatedAt time.Time
+}
+
+type RemoteLike struct {
+	ID        string
+	ActorID   string
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type RemoteNote struct {
+	ID          string
+	ActorID     string
+	Content     string
+	InReplyTo   sql.NullString
+	PublishedAt time.Time
+	ReceivedAt  time.Time
+}
+
+type Report struct {
+	ID         uuid.UUID
+	ReporterID uuid.NullUUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	ChirpBody  sql.NullString
+	Reason     string
+	Details    string
+	Status     string
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+type Subscription struct {
+	UserID            uuid.UUID
+	Status            string
+	CurrentPeriodEnd  time.Time
+	CancelAtPeriodEnd bool
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+type SubscriptionEvent struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Event     string
+	Status    string
+	PeriodEnd time.Time
+	CreatedAt time.Time
+}
+
 type User struct {
 	ID             uuid.UUID
 	Email          string
@@ -35,4 +261,70 @@ type User struct {
 	UpdatedAt      sql.NullTime
 	HashedPassword string
 	IsChirpyRed    sql.NullBool
+	IsModerator    bool
+	SuspendedAt    sql.NullTime
+	DeletedAt      sql.NullTime
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt sql.NullTime
+}
+
+type UserFollow struct {
+	FollowerID uuid.UUID
+	FollowedID uuid.UUID
+	CreatedAt  sql.NullTime
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt sql.NullTime
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	WebhookID     uuid.UUID
+	Event         string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	CompletedAt   sql.NullTime
+	OutboxID      sql.NullInt64
+}
+
+type WebhookDeliveryAttempt struct {
+	ID         uuid.UUID
+	DeliveryID uuid.UUID
+	Attempt    int32
+	StatusCode sql.NullInt32
+	Error      sql.NullString
+	LatencyMs  int32
+	CreatedAt  time.Time
+}
+
+type WebhookEvent struct {
+	Source     string
+	EventID    string
+	Event      string
+	ReceivedAt time.Time
+}
+
+type WsTicket struct {
+	TicketHash []byte
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
 }
@@ -0,0 +1,137 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: moderation_rules.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createModerationRule = `-- name: CreateModerationRule :one
+INSERT INTO moderation_rules (id, kind, pattern, action, position, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, DEFAULT, DEFAULT)
+RETURNING id, kind, pattern, action, position, created_at, updated_at
+`
+
+type CreateModerationRuleParams struct {
+	Kind     string
+	Pattern  string
+	Action   string
+	Position int32
+}
+
+func (q *Queries) CreateModerationRule(ctx context.Context, arg CreateModerationRuleParams) (ModerationRule, error) {
+	row := q.db.QueryRowContext(ctx, createModerationRule,
+		arg.Kind,
+		arg.Pattern,
+		arg.Action,
+		arg.Position,
+	)
+	var i ModerationRule
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Pattern,
+		&i.Action,
+		&i.Position,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteModerationRule = `-- name: DeleteModerationRule :execrows
+DELETE FROM moderation_rules WHERE id = $1
+`
+
+func (q *Queries) DeleteModerationRule(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteModerationRule, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getModerationRules = `-- name: GetModerationRules :many
+SELECT id, kind, pattern, action, position, created_at, updated_at FROM moderation_rules ORDER BY position, created_at
+`
+
+func (q *Queries) GetModerationRules(ctx context.Context) ([]ModerationRule, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationRules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationRule
+	for rows.Next() {
+		var i ModerationRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Pattern,
+			&i.Action,
+			&i.Position,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const notifyModerationRulesChanged = `-- name: NotifyModerationRulesChanged :exec
+SELECT pg_notify('moderation_rules', '')
+`
+
+func (q *Queries) NotifyModerationRulesChanged(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, notifyModerationRulesChanged)
+	return err
+}
+
+const updateModerationRule = `-- name: UpdateModerationRule :one
+UPDATE moderation_rules
+SET kind = $2, pattern = $3, action = $4, position = $5, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, kind, pattern, action, position, created_at, updated_at
+`
+
+type UpdateModerationRuleParams struct {
+	ID       uuid.UUID
+	Kind     string
+	Pattern  string
+	Action   string
+	Position int32
+}
+
+func (q *Queries) UpdateModerationRule(ctx context.Context, arg UpdateModerationRuleParams) (ModerationRule, error) {
+	row := q.db.QueryRowContext(ctx, updateModerationRule,
+		arg.ID,
+		arg.Kind,
+		arg.Pattern,
+		arg.Action,
+		arg.Position,
+	)
+	var i ModerationRule
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Pattern,
+		&i.Action,
+		&i.Position,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,221 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: notifications.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countUnreadNotifications = `-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+`
+
+func (q *Queries) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadNotifications, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createNotification = `-- name: CreateNotification :execrows
+INSERT INTO notifications (id, user_id, actor_id, type, chirp_id, read_at, created_at)
+SELECT gen_random_uuid(), $1, $2, $3, $4, NULL, DEFAULT
+WHERE NOT EXISTS (
+    SELECT 1
+    FROM notification_preferences
+    WHERE notification_preferences.user_id = $1
+      AND notification_preferences.type = $3
+      AND notification_preferences.muted
+)
+`
+
+type CreateNotificationParams struct {
+	UserID  uuid.UUID
+	ActorID uuid.UUID
+	Type    string
+	ChirpID uuid.NullUUID
+}
+
+func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createNotification,
+		arg.UserID,
+		arg.ActorID,
+		arg.Type,
+		arg.ChirpID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getNotificationGroups = `-- name: GetNotificationGroups :many
+WITH groups AS (
+    SELECT
+        type,
+        chirp_id,
+        COUNT(*)::int AS count,
+        COUNT(DISTINCT actor_id)::int AS actor_count,
+        (array_agg(actor_id ORDER BY created_at DESC))[1:3]::uuid[] AS recent_actor_ids,
+        (array_agg(id ORDER BY created_at DESC))[1:$5::int]::uuid[] AS notification_ids,
+        bool_and(read_at IS NOT NULL) AS read,
+        MAX(created_at)::timestamptz AS latest_at
+    FROM notifications
+    WHERE user_id = $6
+    GROUP BY type, chirp_id, date_trunc('day', created_at AT TIME ZONE 'UTC')
+    HAVING NOT $7::bool OR bool_or(read_at IS NULL)
+)
+SELECT type, chirp_id, count, actor_count, recent_actor_ids, notification_ids, read, latest_at FROM groups
+WHERE $1::timestamptz IS NULL
+   OR (latest_at, type, COALESCE(chirp_id, '00000000-0000-0000-0000-000000000000'))
+    < ($1::timestamptz, $2::text, $3::uuid)
+ORDER BY latest_at DESC, type DESC, COALESCE(chirp_id, '00000000-0000-0000-0000-000000000000') DESC
+LIMIT $4
+`
+
+type GetNotificationGroupsParams struct {
+	BeforeAt      sql.NullTime
+	BeforeType    sql.NullString
+	BeforeChirpID uuid.NullUUID
+	MaxGroups     int32
+	MaxIds        int32
+	UserID        uuid.UUID
+	UnreadOnly    bool
+}
+
+type GetNotificationGroupsRow struct {
+	Type            string
+	ChirpID         uuid.NullUUID
+	Count           int32
+	ActorCount      int32
+	RecentActorIds  []uuid.UUID
+	NotificationIds []uuid.UUID
+	Read            bool
+	LatestAt        time.Time
+}
+
+// Notifications of one type about one chirp are grouped per UTC day, so a
+// group only changes on the day it is about and follows, which have no
+// chirp, don't all end up in one group. Only the latest max_ids
+// notification IDs of a group are returned. Pages are keyed on
+// (latest_at, type, chirp_id), with a missing chirp sorting as the nil UUID.
+func (q *Queries) GetNotificationGroups(ctx context.Context, arg GetNotificationGroupsParams) ([]GetNotificationGroupsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNotificationGroups,
+		arg.BeforeAt,
+		arg.BeforeType,
+		arg.BeforeChirpID,
+		arg.MaxGroups,
+		arg.MaxIds,
+		arg.UserID,
+		arg.UnreadOnly,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetNotificationGroupsRow
+	for rows.Next() {
+		var i GetNotificationGroupsRow
+		if err := rows.Scan(
+			&i.Type,
+			&i.ChirpID,
+			&i.Count,
+			&i.ActorCount,
+			pq.Array(&i.RecentActorIds),
+			pq.Array(&i.NotificationIds),
+			&i.Read,
+			&i.LatestAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNotificationPreferences = `-- name: GetNotificationPreferences :many
+SELECT user_id, type, muted, updated_at FROM notification_preferences WHERE user_id = $1
+`
+
+func (q *Queries) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]NotificationPreference, error) {
+	rows, err := q.db.QueryContext(ctx, getNotificationPreferences, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NotificationPreference
+	for rows.Next() {
+		var i NotificationPreference
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Type,
+			&i.Muted,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markNotificationsRead = `-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = CURRENT_TIMESTAMP
+WHERE user_id = $1
+  AND read_at IS NULL
+  AND ($2::bool OR id = ANY($3::uuid[]))
+`
+
+type MarkNotificationsReadParams struct {
+	UserID uuid.UUID
+	All    bool
+	Ids    []uuid.UUID
+}
+
+func (q *Queries) MarkNotificationsRead(ctx context.Context, arg MarkNotificationsReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markNotificationsRead, arg.UserID, arg.All, pq.Array(arg.Ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertNotificationPreferences = `-- name: UpsertNotificationPreferences :exec
+INSERT INTO notification_preferences (user_id, type, muted)
+SELECT $1, unnest($2::text[]), unnest($3::bool[])
+ON CONFLICT (user_id, type) DO UPDATE
+SET muted = EXCLUDED.muted, updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertNotificationPreferencesParams struct {
+	UserID uuid.UUID
+	Types  []string
+	Muted  []bool
+}
+
+// types and muted are parallel arrays, one statement sets them all.
+func (q *Queries) UpsertNotificationPreferences(ctx context.Context, arg UpsertNotificationPreferencesParams) error {
+	_, err := q.db.ExecContext(ctx, upsertNotificationPreferences, arg.UserID, pq.Array(arg.Types), pq.Array(arg.Muted))
+	return err
+}
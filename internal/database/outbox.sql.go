@@ -0,0 +1,169 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outbox.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createOutboxEvent = `-- name: CreateOutboxEvent :exec
+INSERT INTO outbox (aggregate_type, aggregate_id, event, payload)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateOutboxEventParams struct {
+	AggregateType string
+	AggregateID   uuid.UUID
+	Event         string
+	Payload       json.RawMessage
+}
+
+func (q *Queries) CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) error {
+	_, err := q.db.ExecContext(ctx, createOutboxEvent,
+		arg.AggregateType,
+		arg.AggregateID,
+		arg.Event,
+		arg.Payload,
+	)
+	return err
+}
+
+const getDueOutboxEvents = `-- name: GetDueOutboxEvents :many
+SELECT id, aggregate_type, aggregate_id, event, payload, attempts, last_error, next_attempt_at, created_at, published_at FROM outbox AS o
+WHERE o.published_at IS NULL
+AND o.next_attempt_at <= CURRENT_TIMESTAMP
+AND NOT EXISTS (
+    SELECT 1 FROM outbox AS earlier
+    WHERE earlier.published_at IS NULL
+    AND earlier.aggregate_type = o.aggregate_type
+    AND earlier.aggregate_id = o.aggregate_id
+    AND earlier.id < o.id
+    AND earlier.next_attempt_at > CURRENT_TIMESTAMP
+)
+ORDER BY o.id
+LIMIT $1
+`
+
+// An event waits while an earlier one of its aggregate backs off, so
+// subscribers see every aggregate's events in order.
+func (q *Queries) GetDueOutboxEvents(ctx context.Context, maxEvents int32) ([]Outbox, error) {
+	rows, err := q.db.QueryContext(ctx, getDueOutboxEvents, maxEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateType,
+			&i.AggregateID,
+			&i.Event,
+			&i.Payload,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+			&i.PublishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOutboxLag = `-- name: GetOutboxLag :one
+SELECT COUNT(*) AS pending, COALESCE(MIN(created_at), CURRENT_TIMESTAMP)::timestamptz AS oldest
+FROM outbox
+WHERE published_at IS NULL
+`
+
+type GetOutboxLagRow struct {
+	Pending int64
+	Oldest  time.Time
+}
+
+func (q *Queries) GetOutboxLag(ctx context.Context) (GetOutboxLagRow, error) {
+	row := q.db.QueryRowContext(ctx, getOutboxLag)
+	var i GetOutboxLagRow
+	err := row.Scan(&i.Pending, &i.Oldest)
+	return i, err
+}
+
+const markOutboxEventsPublished = `-- name: MarkOutboxEventsPublished :exec
+UPDATE outbox
+SET published_at = CURRENT_TIMESTAMP
+WHERE id = ANY($1::bigint[])
+`
+
+func (q *Queries) MarkOutboxEventsPublished(ctx context.Context, ids []int64) error {
+	_, err := q.db.ExecContext(ctx, markOutboxEventsPublished, pq.Array(ids))
+	return err
+}
+
+const purgeOutboxEvents = `-- name: PurgeOutboxEvents :execrows
+DELETE FROM outbox
+WHERE id IN (
+    SELECT id FROM outbox AS purged
+    WHERE purged.published_at < $1
+    LIMIT $2
+)
+`
+
+type PurgeOutboxEventsParams struct {
+	PublishedBefore sql.NullTime
+	MaxEvents       int32
+}
+
+func (q *Queries) PurgeOutboxEvents(ctx context.Context, arg PurgeOutboxEventsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeOutboxEvents, arg.PublishedBefore, arg.MaxEvents)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryOutboxEvent = `-- name: RetryOutboxEvent :exec
+UPDATE outbox
+SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2
+WHERE id = $3
+`
+
+type RetryOutboxEventParams struct {
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+	ID            int64
+}
+
+func (q *Queries) RetryOutboxEvent(ctx context.Context, arg RetryOutboxEventParams) error {
+	_, err := q.db.ExecContext(ctx, retryOutboxEvent, arg.LastError, arg.NextAttemptAt, arg.ID)
+	return err
+}
+
+const tryAdvisoryLock = `-- name: TryAdvisoryLock :one
+SELECT pg_try_advisory_lock($1::bigint)
+`
+
+func (q *Queries) TryAdvisoryLock(ctx context.Context, key int64) (bool, error) {
+	row := q.db.QueryRowContext(ctx, tryAdvisoryLock, key)
+	var pg_try_advisory_lock bool
+	err := row.Scan(&pg_try_advisory_lock)
+	return pg_try_advisory_lock, err
+}
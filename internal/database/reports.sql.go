@@ -0,0 +1,273 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: reports.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createModerationAction = `-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, moderator_id, action, report_id, user_id, chirp_id, reason, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, DEFAULT)
+RETURNING id, moderator_id, action, report_id, user_id, chirp_id, reason, created_at
+`
+
+type CreateModerationActionParams struct {
+	ModeratorID uuid.NullUUID
+	Action      string
+	ReportID    uuid.NullUUID
+	UserID      uuid.UUID
+	ChirpID     uuid.NullUUID
+	Reason      string
+}
+
+func (q *Queries) CreateModerationAction(ctx context.Context, arg CreateModerationActionParams) (ModerationAction, error) {
+	row := q.db.QueryRowContext(ctx, createModerationAction,
+		arg.ModeratorID,
+		arg.Action,
+		arg.ReportID,
+		arg.UserID,
+		arg.ChirpID,
+		arg.Reason,
+	)
+	var i ModerationAction
+	err := row.Scan(
+		&i.ID,
+		&i.ModeratorID,
+		&i.Action,
+		&i.ReportID,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createReport = `-- name: CreateReport :execrows
+INSERT INTO reports (id, reporter_id, user_id, chirp_id, chirp_body, reason, details, status, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, 'open', DEFAULT)
+ON CONFLICT DO NOTHING
+`
+
+type CreateReportParams struct {
+	ReporterID uuid.NullUUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	ChirpBody  sql.NullString
+	Reason     string
+	Details    string
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createReport,
+		arg.ReporterID,
+		arg.UserID,
+		arg.ChirpID,
+		arg.ChirpBody,
+		arg.Reason,
+		arg.Details,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const flagChirp = `-- name: FlagChirp :exec
+INSERT INTO reports (id, reporter_id, user_id, chirp_id, chirp_body, reason, details, status, created_at)
+SELECT gen_random_uuid(), NULL, chirps.user_id, chirps.id, chirps.body, 'flagged', 'Matched moderation rule ' || $1::uuid, 'open', DEFAULT
+FROM chirps
+WHERE chirps.id = $2
+`
+
+type FlagChirpParams struct {
+	RuleID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) FlagChirp(ctx context.Context, arg FlagChirpParams) error {
+	_, err := q.db.ExecContext(ctx, flagChirp, arg.RuleID, arg.ChirpID)
+	return err
+}
+
+const getModerationActions = `-- name: GetModerationActions :many
+SELECT id, moderator_id, action, report_id, user_id, chirp_id, reason, created_at FROM moderation_actions
+WHERE ($1::timestamptz IS NULL OR created_at < $1)
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetModerationActionsParams struct {
+	Before     sql.NullTime
+	MaxActions int32
+}
+
+func (q *Queries) GetModerationActions(ctx context.Context, arg GetModerationActionsParams) ([]ModerationAction, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationActions, arg.Before, arg.MaxActions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationAction
+	for rows.Next() {
+		var i ModerationAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.ModeratorID,
+			&i.Action,
+			&i.ReportID,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReportByID = `-- name: GetReportByID :one
+SELECT id, reporter_id, user_id, chirp_id, chirp_body, reason, details, status, resolved_by, resolved_at, created_at FROM reports WHERE id = $1
+`
+
+func (q *Queries) GetReportByID(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, getReportByID, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ReporterID,
+		&i.UserID,
+		&i.ChirpID,
+		&i.ChirpBody,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ResolvedBy,
+		&i.ResolvedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getReports = `-- name: GetReports :many
+SELECT id, reporter_id, user_id, chirp_id, chirp_body, reason, details, status, resolved_by, resolved_at, created_at FROM reports
+WHERE status = $1
+  AND ($2::timestamptz IS NULL OR created_at > $2)
+ORDER BY created_at
+LIMIT $3
+`
+
+type GetReportsParams struct {
+	Status     string
+	After      sql.NullTime
+	MaxReports int32
+}
+
+func (q *Queries) GetReports(ctx context.Context, arg GetReportsParams) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, getReports, arg.Status, arg.After, arg.MaxReports)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReporterID,
+			&i.UserID,
+			&i.ChirpID,
+			&i.ChirpBody,
+			&i.Reason,
+			&i.Details,
+			&i.Status,
+			&i.ResolvedBy,
+			&i.ResolvedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resolveChirpReports = `-- name: ResolveChirpReports :execrows
+UPDATE reports
+SET status = $1, resolved_by = $2, resolved_at = CURRENT_TIMESTAMP
+WHERE chirp_id = $3 AND status = 'open'
+`
+
+type ResolveChirpReportsParams struct {
+	Status     string
+	ResolvedBy uuid.NullUUID
+	ChirpID    uuid.NullUUID
+}
+
+func (q *Queries) ResolveChirpReports(ctx context.Context, arg ResolveChirpReportsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resolveChirpReports, arg.Status, arg.ResolvedBy, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resolveReport = `-- name: ResolveReport :execrows
+UPDATE reports
+SET status = $1, resolved_by = $2, resolved_at = CURRENT_TIMESTAMP
+WHERE id = $3 AND status = 'open'
+`
+
+type ResolveReportParams struct {
+	Status     string
+	ResolvedBy uuid.NullUUID
+	ID         uuid.UUID
+}
+
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resolveReport, arg.Status, arg.ResolvedBy, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resolveUserReports = `-- name: ResolveUserReports :execrows
+UPDATE reports
+SET status = 'actioned', resolved_by = $1, resolved_at = CURRENT_TIMESTAMP
+WHERE user_id = $2 AND status = 'open'
+`
+
+type ResolveUserReportsParams struct {
+	ResolvedBy uuid.NullUUID
+	UserID     uuid.UUID
+}
+
+func (q *Queries) ResolveUserReports(ctx context.Context, arg ResolveUserReportsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resolveUserReports, arg.ResolvedBy, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,192 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: subscriptions.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createSubscriptionEvent = `-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (id, user_id, event, status, period_end)
+VALUES (gen_random_uuid(), $1, $2, $3, $4)
+`
+
+type CreateSubscriptionEventParams struct {
+	UserID    uuid.UUID
+	Event     string
+	Status    string
+	PeriodEnd time.Time
+}
+
+func (q *Queries) CreateSubscriptionEvent(ctx context.Context, arg CreateSubscriptionEventParams) error {
+	_, err := q.db.ExecContext(ctx, createSubscriptionEvent,
+		arg.UserID,
+		arg.Event,
+		arg.Status,
+		arg.PeriodEnd,
+	)
+	return err
+}
+
+const expireSubscriptions = `-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', cancel_at_period_end = false, updated_at = CURRENT_TIMESTAMP
+WHERE user_id IN (
+    SELECT user_id FROM subscriptions AS due
+    WHERE due.status <> 'expired' AND due.current_period_end <= CURRENT_TIMESTAMP
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING user_id, status, current_period_end, cancel_at_period_end, created_at, updated_at
+`
+
+func (q *Queries) ExpireSubscriptions(ctx context.Context, maxSubscriptions int32) ([]Subscription, error) {
+	rows, err := q.db.QueryContext(ctx, expireSubscriptions, maxSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Status,
+			&i.CurrentPeriodEnd,
+			&i.CancelAtPeriodEnd,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSubscription = `-- name: GetSubscription :one
+SELECT user_id, status, current_period_end, cancel_at_period_end, created_at, updated_at FROM subscriptions WHERE user_id = $1
+`
+
+func (q *Queries) GetSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getSubscription, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.UserID,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CancelAtPeriodEnd,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getSubscriptionEvents = `-- name: GetSubscriptionEvents :many
+SELECT id, user_id, event, status, period_end, created_at FROM subscription_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetSubscriptionEventsParams struct {
+	UserID    uuid.UUID
+	MaxEvents int32
+}
+
+func (q *Queries) GetSubscriptionEvents(ctx context.Context, arg GetSubscriptionEventsParams) ([]SubscriptionEvent, error) {
+	rows, err := q.db.QueryContext(ctx, getSubscriptionEvents, arg.UserID, arg.MaxEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SubscriptionEvent
+	for rows.Next() {
+		var i SubscriptionEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Event,
+			&i.Status,
+			&i.PeriodEnd,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSubscriptionForUpdate = `-- name: GetSubscriptionForUpdate :one
+SELECT user_id, status, current_period_end, cancel_at_period_end, created_at, updated_at FROM subscriptions WHERE user_id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetSubscriptionForUpdate(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getSubscriptionForUpdate, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.UserID,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CancelAtPeriodEnd,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertSubscription = `-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, status, current_period_end, cancel_at_period_end)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    cancel_at_period_end = EXCLUDED.cancel_at_period_end,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING user_id, status, current_period_end, cancel_at_period_end, created_at, updated_at
+`
+
+type UpsertSubscriptionParams struct {
+	UserID            uuid.UUID
+	Status            string
+	CurrentPeriodEnd  time.Time
+	CancelAtPeriodEnd bool
+}
+
+func (q *Queries) UpsertSubscription(ctx context.Context, arg UpsertSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, upsertSubscription,
+		arg.UserID,
+		arg.Status,
+		arg.CurrentPeriodEnd,
+		arg.CancelAtPeriodEnd,
+	)
+	var i Subscription
+	err := row.Scan(
+		&i.UserID,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CancelAtPeriodEnd,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -7,14 +7,16 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, email, hashed_password, created_at, updated_at)
 VALUES (gen_random_uuid(), $1, $2, DEFAULT, DEFAULT)
-RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red
+RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red, is_moderator, suspended_at, deleted_at
 `
 
 type CreateUserParams struct {
@@ -32,6 +34,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -45,8 +50,37 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const getDeletedUserByEmail = `-- name: GetDeletedUserByEmail :one
+SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red, is_moderator, suspended_at, deleted_at FROM users
+WHERE email = $1 AND deleted_at > $2
+ORDER BY deleted_at DESC
+LIMIT 1
+`
+
+type GetDeletedUserByEmailParams struct {
+	Email        string
+	DeletedAfter sql.NullTime
+}
+
+func (q *Queries) GetDeletedUserByEmail(ctx context.Context, arg GetDeletedUserByEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedUserByEmail, arg.Email, arg.DeletedAfter)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red, is_moderator, suspended_at, deleted_at FROM users WHERE email = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -59,15 +93,229 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red, is_moderator, suspended_at, deleted_at FROM users WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserIDsByEmails = `-- name: GetUserIDsByEmails :many
+SELECT id FROM users WHERE lower(email) = ANY($1::text[]) AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserIDsByEmails(ctx context.Context, emails []string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getUserIDsByEmails, pq.Array(emails))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersByIDs = `-- name: GetUsersByIDs :many
+SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red, is_moderator, suspended_at, deleted_at FROM users WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.IsModerator,
+			&i.SuspendedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isChirpyRed = `-- name: IsChirpyRed :one
+SELECT COALESCE(is_chirpy_red, false)::bool AS is_chirpy_red FROM users WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) IsChirpyRed(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isChirpyRed, id)
+	var is_chirpy_red bool
+	err := row.Scan(&is_chirpy_red)
+	return is_chirpy_red, err
+}
+
+const isModerator = `-- name: IsModerator :one
+SELECT is_moderator FROM users WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) IsModerator(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isModerator, id)
+	var is_moderator bool
+	err := row.Scan(&is_moderator)
+	return is_moderator, err
+}
+
+const isUserSuspended = `-- name: IsUserSuspended :one
+SELECT (suspended_at IS NOT NULL OR deleted_at IS NOT NULL)::bool AS suspended FROM users WHERE id = $1
+`
+
+// A deleted account counts as suspended until it is restored.
+func (q *Queries) IsUserSuspended(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isUserSuspended, id)
+	var suspended bool
+	err := row.Scan(&suspended)
+	return suspended, err
+}
+
+const purgeUsers = `-- name: PurgeUsers :execrows
+DELETE FROM users
+WHERE id IN (
+    SELECT id FROM users AS purged
+    WHERE purged.deleted_at < $1
+    LIMIT $2
+)
+`
+
+type PurgeUsersParams struct {
+	DeletedBefore sql.NullTime
+	MaxUsers      int32
+}
+
+func (q *Queries) PurgeUsers(ctx context.Context, arg PurgeUsersParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeUsers, arg.DeletedBefore, arg.MaxUsers)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at > $2
+RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red, is_moderator, suspended_at, deleted_at
+`
+
+type RestoreUserParams struct {
+	ID           uuid.UUID
+	DeletedAfter sql.NullTime
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, arg.ID, arg.DeletedAfter)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const setChirpyRed = `-- name: SetChirpyRed :execrows
+UPDATE users SET is_chirpy_red = $1::bool WHERE id = $2 AND deleted_at IS NULL
+`
+
+type SetChirpyRedParams struct {
+	IsChirpyRed bool
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetChirpyRed(ctx context.Context, arg SetChirpyRedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setChirpyRed, arg.IsChirpyRed, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :execrows
+UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const suspendUser = `-- name: SuspendUser :execrows
+UPDATE users SET suspended_at = CURRENT_TIMESTAMP WHERE id = $1 AND suspended_at IS NULL
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, suspendUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET email = $1, hashed_password = $2, updated_at = CURRENT_TIMESTAMP
-WHERE id = $3
-RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red
+WHERE id = $3 AND deleted_at IS NULL
+RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red, is_moderator, suspended_at, deleted_at
 `
 
 type UpdateUserParams struct {
@@ -86,15 +334,20 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const upgradeUserToChirpyRed = `-- name: UpgradeUserToChirpyRed :exec
-UPDATE users SET is_chirpy_red = true WHERE id = $1
+const userExists = `-- name: UserExists :one
+SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)
 `
 
-func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserToChirpyRed, id)
-	return err
+func (q *Queries) UserExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, userExists, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
 }
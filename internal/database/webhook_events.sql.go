@@ -0,0 +1,53 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhook_events.sql
+
+package database
+
+import (
+	"context"
+	"time"
+)
+
+const purgeWebhookEvents = `-- name: PurgeWebhookEvents :execrows
+DELETE FROM webhook_events
+WHERE (source, event_id) IN (
+    SELECT source, event_id FROM webhook_events AS purged
+    WHERE purged.received_at < $1
+    LIMIT $2
+)
+`
+
+type PurgeWebhookEventsParams struct {
+	ReceivedBefore time.Time
+	MaxEvents      int32
+}
+
+func (q *Queries) PurgeWebhookEvents(ctx context.Context, arg PurgeWebhookEventsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeWebhookEvents, arg.ReceivedBefore, arg.MaxEvents)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordWebhookEvent = `-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (source, event_id, event)
+VALUES ($1, $2, $3)
+ON CONFLICT (source, event_id) DO NOTHING
+`
+
+type RecordWebhookEventParams struct {
+	Source  string
+	EventID string
+	Event   string
+}
+
+func (q *Queries) RecordWebhookEvent(ctx context.Context, arg RecordWebhookEventParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recordWebhookEvent, arg.Source, arg.EventID, arg.Event)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,416 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET attempts = webhook_deliveries.attempts + 1, next_attempt_at = $1
+FROM webhooks
+WHERE webhooks.id = webhook_deliveries.webhook_id
+AND webhook_deliveries.id IN (
+    SELECT due.id FROM webhook_deliveries AS due
+    WHERE due.status = 'pending' AND due.next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY due.next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING webhook_deliveries.id, webhook_deliveries.event, webhook_deliveries.payload, webhook_deliveries.attempts, webhook_deliveries.created_at, webhooks.url, webhooks.secret
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil    time.Time
+	MaxDeliveries int32
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	ID        uuid.UUID
+	Event     string
+	Payload   json.RawMessage
+	Attempts  int32
+	CreatedAt time.Time
+	Url       string
+	Secret    string
+}
+
+// Counts the attempt up front and pushes the delivery back by a lease, so a
+// crashed sender's deliveries are retried once the lease runs out.
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Event,
+			&i.Payload,
+			&i.Attempts,
+			&i.CreatedAt,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeWebhookDelivery = `-- name: CompleteWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', completed_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+func (q *Queries) CompleteWebhookDelivery(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, completeWebhookDelivery, id)
+	return err
+}
+
+const countWebhooks = `-- name: CountWebhooks :one
+SELECT COUNT(*) FROM webhooks WHERE user_id = $1
+`
+
+func (q *Queries) CountWebhooks(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countWebhooks, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (id, user_id, url, secret, events)
+VALUES (gen_random_uuid(), $1, $2, $3, $4::text[])
+RETURNING id, user_id, url, secret, events, created_at
+`
+
+type CreateWebhookParams struct {
+	UserID uuid.UUID
+	Url    string
+	Secret string
+	Events []string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		pq.Array(arg.Events),
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createWebhookDeliveryAttempt = `-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempt, status_code, error, latency_ms)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5)
+`
+
+type CreateWebhookDeliveryAttemptParams struct {
+	DeliveryID uuid.UUID
+	Attempt    int32
+	StatusCode sql.NullInt32
+	Error      sql.NullString
+	LatencyMs  int32
+}
+
+func (q *Queries) CreateWebhookDeliveryAttempt(ctx context.Context, arg CreateWebhookDeliveryAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookDeliveryAttempt,
+		arg.DeliveryID,
+		arg.Attempt,
+		arg.StatusCode,
+		arg.Error,
+		arg.LatencyMs,
+	)
+	return err
+}
+
+const deadLetterWebhookDelivery = `-- name: DeadLetterWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'dead', completed_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+func (q *Queries) DeadLetterWebhookDelivery(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deadLetterWebhookDelivery, id)
+	return err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2
+`
+
+type DeleteWebhookParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhook, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, webhook_id, event, payload, outbox_id)
+SELECT gen_random_uuid(), webhooks.id, $1::text, $2::jsonb, $3::bigint
+FROM webhooks
+JOIN users ON users.id = webhooks.user_id
+WHERE $1::text = ANY(webhooks.events)
+AND users.deleted_at IS NULL
+AND ($4::uuid IS NULL OR webhooks.user_id = $4::uuid)
+ON CONFLICT (webhook_id, outbox_id) DO NOTHING
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	Event    string
+	Payload  json.RawMessage
+	OutboxID int64
+	OwnerID  uuid.NullUUID
+}
+
+// Owner-only events (owner_id set) go to the owner's webhooks, the rest to
+// every webhook subscribed to the event. An outbox event already enqueued is
+// skipped.
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enqueueWebhookDeliveries,
+		arg.Event,
+		arg.Payload,
+		arg.OutboxID,
+		arg.OwnerID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, user_id, url, secret, events, created_at FROM webhooks WHERE id = $1 AND user_id = $2
+`
+
+type GetWebhookParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetWebhook(ctx context.Context, arg GetWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, arg.ID, arg.UserID)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, created_at, completed_at, outbox_id FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetWebhookDeliveriesParams struct {
+	WebhookID     uuid.UUID
+	MaxDeliveries int32
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries, arg.WebhookID, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+			&i.CompletedAt,
+			&i.OutboxID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDeliveryAttempts = `-- name: GetWebhookDeliveryAttempts :many
+SELECT id, delivery_id, attempt, status_code, error, latency_ms, created_at FROM webhook_delivery_attempts
+WHERE delivery_id = ANY($1::uuid[])
+ORDER BY attempt
+`
+
+func (q *Queries) GetWebhookDeliveryAttempts(ctx context.Context, deliveryIds []uuid.UUID) ([]WebhookDeliveryAttempt, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveryAttempts, pq.Array(deliveryIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDeliveryAttempt
+	for rows.Next() {
+		var i WebhookDeliveryAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.Attempt,
+			&i.StatusCode,
+			&i.Error,
+			&i.LatencyMs,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooks = `-- name: GetWebhooks :many
+SELECT id, user_id, url, secret, events, created_at FROM webhooks WHERE user_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) GetWebhooks(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooks, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeWebhookDeliveries = `-- name: PurgeWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE id IN (
+    SELECT id FROM webhook_deliveries AS purged
+    WHERE purged.completed_at < $1
+    LIMIT $2
+)
+`
+
+type PurgeWebhookDeliveriesParams struct {
+	CompletedBefore sql.NullTime
+	MaxDeliveries   int32
+}
+
+func (q *Queries) PurgeWebhookDeliveries(ctx context.Context, arg PurgeWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeWebhookDeliveries, arg.CompletedBefore, arg.MaxDeliveries)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const redeliverWebhookDelivery = `-- name: RedeliverWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, completed_at = NULL
+WHERE id = $1 AND webhook_id = $2 AND status = 'dead'
+`
+
+type RedeliverWebhookDeliveryParams struct {
+	ID        uuid.UUID
+	WebhookID uuid.UUID
+}
+
+func (q *Queries) RedeliverWebhookDelivery(ctx context.Context, arg RedeliverWebhookDeliveryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, redeliverWebhookDelivery, arg.ID, arg.WebhookID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryWebhookDelivery = `-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET next_attempt_at = $1
+WHERE id = $2
+`
+
+type RetryWebhookDeliveryParams struct {
+	NextAttemptAt time.Time
+	ID            uuid.UUID
+}
+
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, retryWebhookDelivery, arg.NextAttemptAt, arg.ID)
+	return err
+}
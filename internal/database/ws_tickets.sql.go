@@ -0,0 +1,64 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: ws_tickets.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const consumeWSTicket = `-- name: ConsumeWSTicket :one
+DELETE FROM ws_tickets
+WHERE ticket_hash = $1 AND expires_at > CURRENT_TIMESTAMP
+RETURNING user_id
+`
+
+func (q *Queries) ConsumeWSTicket(ctx context.Context, ticketHash []byte) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, consumeWSTicket, ticketHash)
+	var user_id uuid.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const createWSTicket = `-- name: CreateWSTicket :exec
+INSERT INTO ws_tickets (ticket_hash, user_id, expires_at)
+VALUES ($1, $2, $3)
+`
+
+type CreateWSTicketParams struct {
+	TicketHash []byte
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+}
+
+func (q *Queries) CreateWSTicket(ctx context.Context, arg CreateWSTicketParams) error {
+	_, err := q.db.ExecContext(ctx, createWSTicket, arg.TicketHash, arg.UserID, arg.ExpiresAt)
+	return err
+}
+
+const purgeWSTickets = `-- name: PurgeWSTickets :execrows
+DELETE FROM ws_tickets
+WHERE ticket_hash IN (
+    SELECT ticket_hash FROM ws_tickets AS purged
+    WHERE purged.expires_at < $1
+    LIMIT $2
+)
+`
+
+type PurgeWSTicketsParams struct {
+	ExpiredBefore time.Time
+	MaxTickets    int32
+}
+
+func (q *Queries) PurgeWSTickets(ctx context.Context, arg PurgeWSTicketsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeWSTickets, arg.ExpiredBefore, arg.MaxTickets)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,354 @@
+package drafts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/chirps"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/entitlements"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+type draftRequest struct {
+	Body          string   `json:"body"`
+	AttachmentIDs []string `json:"attachment_ids" validate:"uuid"`
+}
+
+type publishDraftRequest struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+type draftResponse struct {
+	ID            string    `json:"id"`
+	Body          string    `json:"body"`
+	AttachmentIDs []string  `json:"attachment_ids"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// store is the part of database.Queries the handler needs.
+type store interface {
+	GetDrafts(ctx context.Context, userID uuid.UUID) ([]database.Draft, error)
+	GetDraft(ctx context.Context, arg database.GetDraftParams) (database.Draft, error)
+	CreateDraft(ctx context.Context, arg database.CreateDraftParams) (database.Draft, error)
+	UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Draft, error)
+	DeleteDraft(ctx context.Context, arg database.DeleteDraftParams) (int64, error)
+}
+
+// poster is the part of chirps.ChirpsHandler publishing drafts needs.
+type poster interface {
+	PostChirp(ctx context.Context, userID uuid.UUID, body string, attachmentIDs []uuid.UUID, publishAt time.Time) (chirps.ChirpResponse, error)
+}
+
+type DraftsHandler struct {
+	settings      settings.Settings
+	db            *sql.DB
+	store         store
+	chirpsHandler poster
+	entitlements  *entitlements.Service
+	logger        *log.Logger
+}
+
+func NewDraftsHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, chirpsHandler *chirps.ChirpsHandler, entitlementsService *entitlements.Service, logger *log.Logger) *DraftsHandler {
+	return &DraftsHandler{
+		settings:      s,
+		db:            db,
+		store:         dbQueries,
+		chirpsHandler: chirpsHandler,
+		entitlements:  entitlementsService,
+		logger:        logger,
+	}
+}
+
+func newDraftResponse(draft database.Draft) draftResponse {
+	resp := draftResponse{
+		ID:            draft.ID.String(),
+		Body:          draft.Body,
+		AttachmentIDs: []string{},
+		CreatedAt:     draft.CreatedAt,
+		UpdatedAt:     draft.UpdatedAt,
+	}
+
+	for _, attachmentID := range draft.AttachmentIds {
+		resp.AttachmentIDs = append(resp.AttachmentIDs, attachmentID.String())
+	}
+
+	return resp
+}
+
+// userID authenticates the request. It writes the error response itself and
+// reports ok=false in that case.
+func (h *DraftsHandler) userID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// decode reads a draft from the body. Drafts may be empty, but never hold
+// what the user couldn't publish.
+func (h *DraftsHandler) decode(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (string, []uuid.UUID, bool) {
+	req, ok := request.DecodeJSON[draftRequest](w, r)
+	if !ok {
+		return "", nil, false
+	}
+
+	attachmentIDs := make([]uuid.UUID, 0, len(req.AttachmentIDs))
+	for _, idString := range req.AttachmentIDs {
+		// Checked by the uuid rule.
+		attachmentIDs = append(attachmentIDs, uuid.MustParse(idString))
+	}
+
+	limits, err := h.entitlements.ForUser(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(decode): entitlements (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return "", nil, false
+	}
+
+	err = chirps.ValidateChirp(limits, req.Body, attachmentIDs)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, chirps.ErrorCode(err), err.Error())
+		return "", nil, false
+	}
+
+	return req.Body, attachmentIDs, true
+}
+
+func (h *DraftsHandler) GetDrafts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	drafts, err := h.store.GetDrafts(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetDrafts): db get drafts (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := []draftResponse{}
+	for _, draft := range drafts {
+		resp = append(resp, newDraftResponse(draft))
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+func (h *DraftsHandler) CreateDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	body, attachmentIDs, ok := h.decode(w, r, userID)
+	if !ok {
+		return
+	}
+
+	draft, err := h.store.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID:        userID,
+		Body:          body,
+		AttachmentIds: attachmentIDs,
+	})
+	if err != nil {
+		h.logger.Printf("Error(CreateDraft): db create draft (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, newDraftResponse(draft))
+}
+
+func (h *DraftsHandler) GetDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		response.Invalid(w, "draftID", response.FieldInvalid, "invalid draft id")
+		return
+	}
+
+	draft, err := h.store.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(GetDraft): db get draft (draft_id=%s): %v", draftID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	response.JSON(w, http.StatusOK, newDraftResponse(draft))
+}
+
+func (h *DraftsHandler) UpdateDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		response.Invalid(w, "draftID", response.FieldInvalid, "invalid draft id")
+		return
+	}
+
+	body, attachmentIDs, ok := h.decode(w, r, userID)
+	if !ok {
+		return
+	}
+
+	draft, err := h.store.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:            draftID,
+		UserID:        userID,
+		Body:          body,
+		AttachmentIds: attachmentIDs,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(UpdateDraft): db update draft (draft_id=%s): %v", draftID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	response.JSON(w, http.StatusOK, newDraftResponse(draft))
+}
+
+func (h *DraftsHandler) DeleteDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		response.Invalid(w, "draftID", response.FieldInvalid, "invalid draft id")
+		return
+	}
+
+	rowsAffected, err := h.store.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(DeleteDraft): db delete draft (draft_id=%s): %v", draftID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if rowsAffected == 0 {
+		response.NotFound(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// PublishDraft turns a draft into a chirp, right away or at publish_at. The
+// draft is gone once the chirp is stored.
+func (h *DraftsHandler) PublishDraft(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		response.Invalid(w, "draftID", response.FieldInvalid, "invalid draft id")
+		return
+	}
+
+	// The body is optional, an empty one publishes right away.
+	req, ok := request.DecodeOptionalJSON[publishDraftRequest](w, r)
+	if !ok {
+		return
+	}
+
+	// PostChirp checks this too, a bad schedule shouldn't get that far.
+	var publishAt time.Time
+	if req.PublishAt != nil {
+		publishAt = *req.PublishAt
+
+		err = chirps.ValidatePublishAt(publishAt, time.Now())
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, chirps.ErrorCode(err), err.Error())
+			return
+		}
+	}
+
+	draft, err := h.store.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(PublishDraft): db get draft (draft_id=%s): %v", draftID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	resp, err := h.chirpsHandler.PostChirp(r.Context(), userID, draft.Body, draft.AttachmentIds, publishAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, chirps.ErrChirpTooLong), errors.Is(err, chirps.ErrTooManyAttachments), errors.Is(err, chirps.ErrInvalidAttachment), errors.Is(err, chirps.ErrChirpRejected), errors.Is(err, chirps.ErrInvalidPublishAt):
+			response.Error(w, http.StatusBadRequest, chirps.ErrorCode(err), err.Error())
+		case errors.Is(err, chirps.ErrUserSuspended):
+			response.Error(w, http.StatusForbidden, chirps.ErrorCode(err), err.Error())
+		default:
+			h.logger.Printf("Error(PublishDraft): post chirp (draft_id=%s): %v", draftID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	// The chirp is out, a leftover draft is only an annoyance.
+	_, err = h.store.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(PublishDraft): db delete draft (draft_id=%s): %v", draftID, err)
+	}
+
+	response.JSON(w, http.StatusCreated, resp)
+}
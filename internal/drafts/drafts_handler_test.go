@@ -0,0 +1,308 @@
+package drafts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/chirps"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/entitlements"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+// memStore implements store and entitlements.Store with the semantics of the
+// queries.
+type memStore struct {
+	red    map[uuid.UUID]bool
+	drafts map[uuid.UUID]database.Draft
+}
+
+func (s *memStore) IsChirpyRed(ctx context.Context, id uuid.UUID) (bool, error) {
+	return s.red[id], nil
+}
+
+func (s *memStore) GetDrafts(ctx context.Context, userID uuid.UUID) ([]database.Draft, error) {
+	var drafts []database.Draft
+	for _, draft := range s.drafts {
+		if draft.UserID == userID {
+			drafts = append(drafts, draft)
+		}
+	}
+	return drafts, nil
+}
+
+func (s *memStore) GetDraft(ctx context.Context, arg database.GetDraftParams) (database.Draft, error) {
+	draft, ok := s.drafts[arg.ID]
+	if !ok || draft.UserID != arg.UserID {
+		return database.Draft{}, sql.ErrNoRows
+	}
+	return draft, nil
+}
+
+func (s *memStore) CreateDraft(ctx context.Context, arg database.CreateDraftParams) (database.Draft, error) {
+	draft := database.Draft{
+		ID:            uuid.New(),
+		UserID:        arg.UserID,
+		Body:          arg.Body,
+		AttachmentIds: arg.AttachmentIds,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	s.drafts[draft.ID] = draft
+	return draft, nil
+}
+
+func (s *memStore) UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Draft, error) {
+	draft, err := s.GetDraft(ctx, database.GetDraftParams{ID: arg.ID, UserID: arg.UserID})
+	if err != nil {
+		return database.Draft{}, err
+	}
+
+	draft.Body = arg.Body
+	draft.AttachmentIds = arg.AttachmentIds
+	draft.UpdatedAt = time.Now()
+	s.drafts[draft.ID] = draft
+	return draft, nil
+}
+
+func (s *memStore) DeleteDraft(ctx context.Context, arg database.DeleteDraftParams) (int64, error) {
+	if _, err := s.GetDraft(ctx, database.GetDraftParams(arg)); err != nil {
+		return 0, nil
+	}
+
+	delete(s.drafts, arg.ID)
+	return 1, nil
+}
+
+// memPoster implements poster, recording what it posted.
+type memPoster struct {
+	posted []chirps.ChirpResponse
+}
+
+func (p *memPoster) PostChirp(ctx context.Context, userID uuid.UUID, body string, attachmentIDs []uuid.UUID, publishAt time.Time) (chirps.ChirpResponse, error) {
+	resp := chirps.ChirpResponse{
+		ID:        uuid.NewString(),
+		UserID:    userID.String(),
+		Body:      body,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if !publishAt.IsZero() {
+		resp.PublishAt = &publishAt
+	}
+
+	p.posted = append(p.posted, resp)
+	return resp, nil
+}
+
+type testUser struct {
+	id  uuid.UUID
+	jwt string
+}
+
+func newTestHandler(t *testing.T) (*DraftsHandler, *memStore, *memPoster, testUser, testUser) {
+	t.Helper()
+
+	store := &memStore{
+		red:    make(map[uuid.UUID]bool),
+		drafts: make(map[uuid.UUID]database.Draft),
+	}
+	poster := &memPoster{}
+
+	var users []testUser
+	for range 2 {
+		user := testUser{id: uuid.New()}
+		jwt, err := auth.MakeJWT(user.id, "secret", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.jwt = jwt
+
+		users = append(users, user)
+	}
+
+	return &DraftsHandler{
+		settings:      settings.Settings{JWTSecret: "secret"},
+		store:         store,
+		chirpsHandler: poster,
+		entitlements:  entitlements.NewService(store),
+		logger:        log.New(io.Discard, "", 0),
+	}, store, poster, users[0], users[1]
+}
+
+// call runs a handler on a draft, draftID is left out when it's uuid.Nil.
+func call(handler http.HandlerFunc, method string, user testUser, draftID uuid.UUID, body string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, "/api/drafts", nil)
+	} else {
+		r = httptest.NewRequest(method, "/api/drafts", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if draftID != uuid.Nil {
+		r.SetPathValue("draftID", draftID.String())
+	}
+	r.Header.Set("Authorization", "Bearer "+user.jwt)
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func decodeDraft(t *testing.T, w *httptest.ResponseRecorder) draftResponse {
+	t.Helper()
+
+	var resp draftResponse
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatalf("unmarshal draft: %v", err)
+	}
+	return resp
+}
+
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var resp response.Problem
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	return resp.Code
+}
+
+func TestDrafts(t *testing.T) {
+	h, store, _, alice, bob := newTestHandler(t)
+
+	// Drafts may be empty.
+	w := call(h.CreateDraft, http.MethodPost, alice, uuid.Nil, `{"body": ""}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateDraft() = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	draftID := uuid.MustParse(decodeDraft(t, w).ID)
+
+	w = call(h.UpdateDraft, http.MethodPut, alice, draftID, `{"body": "Almost ready"}`)
+	if w.Code != http.StatusOK || decodeDraft(t, w).Body != "Almost ready" {
+		t.Fatalf("UpdateDraft() = %d %s, want the new body", w.Code, w.Body)
+	}
+
+	w = call(h.GetDraft, http.MethodGet, alice, draftID, "")
+	if draft := decodeDraft(t, w); w.Code != http.StatusOK || draft.Body != "Almost ready" || len(draft.AttachmentIDs) != 0 {
+		t.Fatalf("GetDraft() = %d %s, want the updated draft", w.Code, w.Body)
+	}
+
+	w = call(h.GetDrafts, http.MethodGet, alice, uuid.Nil, "")
+	var drafts []draftResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &drafts); err != nil || len(drafts) != 1 {
+		t.Fatalf("GetDrafts() = %s, want the one draft", w.Body)
+	}
+
+	// Other users' drafts don't exist for them.
+	for name, handler := range map[string]http.HandlerFunc{"GetDraft": h.GetDraft, "DeleteDraft": h.DeleteDraft, "PublishDraft": h.PublishDraft} {
+		if w := call(handler, http.MethodGet, bob, draftID, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s() by another user = %d, want %d", name, w.Code, http.StatusNotFound)
+		}
+	}
+	if w := call(h.UpdateDraft, http.MethodPut, bob, draftID, `{"body": "Mine now"}`); w.Code != http.StatusNotFound {
+		t.Errorf("UpdateDraft() by another user = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	if w := call(h.DeleteDraft, http.MethodDelete, alice, draftID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DeleteDraft() = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	if len(store.drafts) != 0 {
+		t.Errorf("stored %d drafts after the delete, want none", len(store.drafts))
+	}
+
+	if w := call(h.DeleteDraft, http.MethodDelete, alice, draftID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DeleteDraft() = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestDraftLimits(t *testing.T) {
+	h, store, _, alice, red := newTestHandler(t)
+	store.red[red.id] = true
+
+	long := `{"body": "` + strings.Repeat("a", entitlements.Free.MaxChirpLength+1) + `"}`
+
+	attachmentIDs := make([]string, entitlements.Free.MaxAttachments+1)
+	for i := range attachmentIDs {
+		attachmentIDs[i] = `"` + uuid.NewString() + `"`
+	}
+	attachments := `{"body": "Look", "attachment_ids": [` + strings.Join(attachmentIDs, ", ") + `]}`
+
+	// Drafts never hold what the user couldn't publish.
+	limited := map[string]string{long: "chirp_too_long", attachments: "too_many_attachments"}
+	for body, want := range limited {
+		w := call(h.CreateDraft, http.MethodPost, alice, uuid.Nil, body)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != want {
+			t.Errorf("CreateDraft() = %d %s, want %d %s", w.Code, w.Body, http.StatusBadRequest, want)
+		}
+	}
+
+	if len(store.drafts) != 0 {
+		t.Fatalf("stored %d drafts over the limits, want none", len(store.drafts))
+	}
+
+	// Chirpy Red raises them.
+	for body := range limited {
+		if w := call(h.CreateDraft, http.MethodPost, red, uuid.Nil, body); w.Code != http.StatusCreated {
+			t.Errorf("CreateDraft() for Chirpy Red = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+		}
+	}
+}
+
+func TestPublishDraft(t *testing.T) {
+	h, store, poster, alice, _ := newTestHandler(t)
+
+	w := call(h.CreateDraft, http.MethodPost, alice, uuid.Nil, `{"body": "Ready"}`)
+	draftID := uuid.MustParse(decodeDraft(t, w).ID)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "In the past", body: `{"publish_at": "` + time.Now().Add(-time.Minute).Format(time.RFC3339) + `"}`},
+		{name: "Over a year ahead", body: `{"publish_at": "` + time.Now().AddDate(1, 0, 1).Format(time.RFC3339) + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := call(h.PublishDraft, http.MethodPost, alice, draftID, tt.body)
+			if w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_publish_at" {
+				t.Errorf("PublishDraft() = %d %s, want %d invalid_publish_at", w.Code, w.Body, http.StatusBadRequest)
+			}
+		})
+	}
+
+	if len(poster.posted) != 0 || len(store.drafts) != 1 {
+		t.Fatalf("a bad schedule posted %d chirps, left %d drafts", len(poster.posted), len(store.drafts))
+	}
+
+	publishAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	w = call(h.PublishDraft, http.MethodPost, alice, draftID, `{"publish_at": "`+publishAt.Format(time.RFC3339)+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("PublishDraft() = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	if len(poster.posted) != 1 || poster.posted[0].Body != "Ready" || !poster.posted[0].PublishAt.Equal(publishAt) {
+		t.Fatalf("posted %+v, want the draft scheduled at %v", poster.posted, publishAt)
+	}
+
+	// The draft is gone once the chirp is stored.
+	if len(store.drafts) != 0 {
+		t.Errorf("stored %d drafts after the publish, want none", len(store.drafts))
+	}
+}
@@ -0,0 +1,63 @@
+package drafts
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/chirps"
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:   "GET /api/drafts",
+		Tag:       "drafts",
+		Summary:   "List drafts",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The drafts", Body: []draftResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "POST /api/drafts",
+		Tag:       "drafts",
+		Summary:   "Save a draft",
+		Auth:      openapi.AuthBearer,
+		Request:   draftRequest{},
+		Responses: []openapi.Response{{Status: http.StatusCreated, Description: "The new draft", Body: draftResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "GET /api/drafts/{draftID}",
+		Tag:       "drafts",
+		Summary:   "Get a draft",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The draft", Body: draftResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:   "PUT /api/drafts/{draftID}",
+		Tag:       "drafts",
+		Summary:   "Update a draft",
+		Auth:      openapi.AuthBearer,
+		Request:   draftRequest{},
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The updated draft", Body: draftResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:   "DELETE /api/drafts/{draftID}",
+		Tag:       "drafts",
+		Summary:   "Delete a draft",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Deleted"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:     "POST /api/drafts/{draftID}/publish",
+		Tag:         "drafts",
+		Summary:     "Publish a draft",
+		Description: "Right away, or at publish_at. The body is optional. The draft is deleted once the chirp is stored.",
+		Auth:        openapi.AuthBearer,
+		Request:     publishDraftRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusCreated, Description: "The new chirp", Body: chirps.ChirpBody}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+}
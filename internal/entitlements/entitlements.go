@@ -0,0 +1,132 @@
+package entitlements
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TierFree      = "free"
+	TierChirpyRed = "chirpy_red"
+
+	// Upgrades arrive through the Polka webhook, possibly on another
+	// instance, so cached tiers are only trusted for a short while.
+	cacheTTL = 1 * time.Minute
+)
+
+// Entitlements are the limits that come with a subscription tier.
+type Entitlements struct {
+	Tier              string
+	MaxChirpLength    int
+	MaxAttachments    int
+	EditWindow        time.Duration
+	RequestsPerMinute int
+}
+
+var (
+	Free = Entitlements{
+		Tier:              TierFree,
+		MaxChirpLength:    140,
+		MaxAttachments:    4,
+		EditWindow:        0,
+		RequestsPerMinute: 120,
+	}
+
+	ChirpyRed = Entitlements{
+		Tier:              TierChirpyRed,
+		MaxChirpLength:    1000,
+		MaxAttachments:    10,
+		EditWindow:        30 * time.Minute,
+		RequestsPerMinute: 600,
+	}
+)
+
+// CanEdit reports whether a chirp published at publishedAt can still be
+// edited at now.
+func (e Entitlements) CanEdit(publishedAt, now time.Time) bool {
+	return now.Before(publishedAt.Add(e.EditWindow))
+}
+
+type cacheEntry struct {
+	entitlements Entitlements
+	expiresAt    time.Time
+}
+
+// Store is the part of database.Queries a Service needs.
+type Store interface {
+	IsChirpyRed(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+// Service turns users into their entitlements. It is the only place that
+// reads is_chirpy_red.
+type Service struct {
+	store Store
+	now   func() time.Time
+
+	mu        sync.Mutex
+	cache     map[uuid.UUID]cacheEntry
+	lastSweep time.Time
+}
+
+func NewService(store Store) *Service {
+	return &Service{
+		store: store,
+		now:   time.Now,
+		cache: make(map[uuid.UUID]cacheEntry),
+	}
+}
+
+func (s *Service) ForUser(ctx context.Context, userID uuid.UUID) (Entitlements, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	entry, ok := s.cache[userID]
+	s.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.entitlements, nil
+	}
+
+	// Unknown users (deleted since the token was issued) get the free tier,
+	// the handlers turn them away on their own.
+	isChirpyRed, err := s.store.IsChirpyRed(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Entitlements{}, fmt.Errorf("db is chirpy red: %w", err)
+	}
+
+	entitlements := Free
+	if isChirpyRed {
+		entitlements = ChirpyRed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop what ran out now and then, the map would only grow otherwise.
+	if now.Sub(s.lastSweep) > cacheTTL {
+		for id, entry := range s.cache {
+			if now.After(entry.expiresAt) {
+				delete(s.cache, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.cache[userID] = cacheEntry{entitlements: entitlements, expiresAt: now.Add(cacheTTL)}
+
+	return entitlements, nil
+}
+
+// Invalidate forgets the cached tier of a user, for changes made by this
+// instance.
+func (s *Service) Invalidate(userID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.cache, userID)
+}
@@ -0,0 +1,63 @@
+package entitlements
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+)
+
+type entitlementsResponse struct {
+	Tier              string `json:"tier"`
+	MaxChirpLength    int    `json:"max_chirp_length"`
+	MaxAttachments    int    `json:"max_attachments"`
+	EditWindowSeconds int    `json:"edit_window_seconds"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+}
+
+type EntitlementsHandler struct {
+	settings settings.Settings
+	service  *Service
+	logger   *log.Logger
+}
+
+func NewEntitlementsHandler(s settings.Settings, service *Service, logger *log.Logger) *EntitlementsHandler {
+	return &EntitlementsHandler{
+		settings: s,
+		service:  service,
+		logger:   logger,
+	}
+}
+
+// GetEntitlements tells clients which limits apply to the user, so they can
+// e.g. size the compose box.
+func (h *EntitlementsHandler) GetEntitlements(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	e, err := h.service.ForUser(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetEntitlements): %v (user_id=%s)", err, userID)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, entitlementsResponse{
+		Tier:              e.Tier,
+		MaxChirpLength:    e.MaxChirpLength,
+		MaxAttachments:    e.MaxAttachments,
+		EditWindowSeconds: int(e.EditWindow.Seconds()),
+		RequestsPerMinute: e.RequestsPerMinute,
+	})
+}
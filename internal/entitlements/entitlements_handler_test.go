@@ -0,0 +1,73 @@
+package entitlements
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+func TestGetEntitlements(t *testing.T) {
+	s, store, advance := newTestService()
+	h := &EntitlementsHandler{
+		settings: settings.Settings{JWTSecret: "secret"},
+		service:  s,
+		logger:   log.New(io.Discard, "", 0),
+	}
+
+	userID := uuid.New()
+	store.red[userID] = false
+	jwt, err := auth.MakeJWT(userID, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() entitlementsResponse {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodGet, "/api/me/entitlements", nil)
+		r.Header.Set("Authorization", "Bearer "+jwt)
+		w := httptest.NewRecorder()
+		h.GetEntitlements(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("GetEntitlements() = %d, want %d", w.Code, http.StatusOK)
+		}
+
+		var resp entitlementsResponse
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		if err != nil {
+			t.Fatalf("unmarshal entitlements: %v", err)
+		}
+		return resp
+	}
+
+	want := entitlementsResponse{Tier: TierFree, MaxChirpLength: 140, MaxAttachments: 4, EditWindowSeconds: 0, RequestsPerMinute: 120}
+	if got := get(); got != want {
+		t.Errorf("GetEntitlements() = %+v, want %+v", got, want)
+	}
+
+	// The upgrade shows once the cached tier runs out.
+	store.red[userID] = true
+	advance(cacheTTL + time.Second)
+
+	want = entitlementsResponse{Tier: TierChirpyRed, MaxChirpLength: 1000, MaxAttachments: 10, EditWindowSeconds: 1800, RequestsPerMinute: 600}
+	if got := get(); got != want {
+		t.Errorf("GetEntitlements() = %+v, want %+v", got, want)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/me/entitlements", nil)
+	w := httptest.NewRecorder()
+	h.GetEntitlements(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GetEntitlements() without a token = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,143 @@
+package entitlements
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memStore implements Store, users missing from red are unknown.
+type memStore struct {
+	red     map[uuid.UUID]bool
+	queries int
+}
+
+func (s *memStore) IsChirpyRed(ctx context.Context, id uuid.UUID) (bool, error) {
+	s.queries++
+
+	isChirpyRed, ok := s.red[id]
+	if !ok {
+		return false, sql.ErrNoRows
+	}
+	return isChirpyRed, nil
+}
+
+// newTestService returns a Service on a clock the test moves with advance.
+func newTestService() (*Service, *memStore, func(time.Duration)) {
+	store := &memStore{red: make(map[uuid.UUID]bool)}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s := NewService(store)
+	s.now = func() time.Time { return now }
+
+	return s, store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestForUser(t *testing.T) {
+	s, store, _ := newTestService()
+	free, red := uuid.New(), uuid.New()
+	store.red[free] = false
+	store.red[red] = true
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		want   Entitlements
+	}{
+		{name: "Free", userID: free, want: Free},
+		{name: "Chirpy Red", userID: red, want: ChirpyRed},
+		{name: "Unknown user", userID: uuid.New(), want: Free},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ForUser(context.Background(), tt.userID)
+			if err != nil {
+				t.Fatalf("ForUser() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ForUser() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForUserCache(t *testing.T) {
+	s, store, advance := newTestService()
+	userID := uuid.New()
+	store.red[userID] = false
+
+	get := func() Entitlements {
+		t.Helper()
+
+		e, err := s.ForUser(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("ForUser() error = %v", err)
+		}
+		return e
+	}
+
+	get()
+
+	// An upgrade on another instance isn't seen until the entry runs out.
+	store.red[userID] = true
+	advance(cacheTTL - time.Second)
+	if e := get(); e.Tier != TierFree || store.queries != 1 {
+		t.Fatalf("ForUser() = %s after %d queries, want the cached free tier", e.Tier, store.queries)
+	}
+
+	advance(2 * time.Second)
+	if e := get(); e.Tier != TierChirpyRed || store.queries != 2 {
+		t.Fatalf("ForUser() = %s after %d queries, want chirpy_red read again", e.Tier, store.queries)
+	}
+
+	// Changes made by this instance apply right away.
+	store.red[userID] = false
+	s.Invalidate(userID)
+	if e := get(); e.Tier != TierFree {
+		t.Errorf("ForUser() = %s after Invalidate(), want free", e.Tier)
+	}
+}
+
+func TestForUserSweep(t *testing.T) {
+	s, store, advance := newTestService()
+	for range 10 {
+		userID := uuid.New()
+		store.red[userID] = false
+		s.ForUser(context.Background(), userID)
+	}
+
+	advance(2 * cacheTTL)
+	s.ForUser(context.Background(), uuid.New())
+
+	if len(s.cache) != 1 {
+		t.Errorf("cache holds %d entries, want the expired ones dropped", len(s.cache))
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+	publishedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		e    Entitlements
+		now  time.Time
+		want bool
+	}{
+		{name: "Free right away", e: Free, now: publishedAt, want: false},
+		{name: "Chirpy Red within the window", e: ChirpyRed, now: publishedAt.Add(29 * time.Minute), want: true},
+		{name: "Chirpy Red after the window", e: ChirpyRed, now: publishedAt.Add(30 * time.Minute), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.CanEdit(publishedAt, tt.now); got != tt.want {
+				t.Errorf("CanEdit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package entitlements
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:   "GET /api/me/entitlements",
+		Tag:       "subscriptions",
+		Summary:   "Get the limits of the user's tier",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The user's limits", Body: entitlementsResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+}
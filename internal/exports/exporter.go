@@ -0,0 +1,311 @@
+package exports
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/jobs"
+	"github.com/absurek/go-http-servers/internal/media"
+	"github.com/google/uuid"
+)
+
+const (
+	buildTimeout  = 10 * time.Minute
+	buildAttempts = 3
+
+	// How long a finished archive can be downloaded.
+	archiveLifetime = 24 * time.Hour
+)
+
+type profileExport struct {
+	ID          string    `json:"id"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+type attachmentExport struct {
+	ID          string    `json:"id"`
+	File        string    `json:"file"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Width       int32     `json:"width"`
+	Height      int32     `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type chirpExport struct {
+	ID          string             `json:"id"`
+	Body        string             `json:"body"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	Hidden      bool               `json:"hidden"`
+	Attachments []attachmentExport `json:"attachments"`
+}
+
+// Sessions are the refresh tokens, without the tokens themselves.
+type sessionExport struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// BuildJob builds the archive of an export, it is enqueued with the export.
+var BuildJob = jobs.Kind[BuildPayload]{Name: "exports.build", Timeout: buildTimeout}
+
+type BuildPayload struct {
+	ExportID uuid.UUID `json:"export_id"`
+}
+
+// enqueueBuild enqueues the build of a new export. dbQueries should be bound
+// to the transaction creating it.
+func enqueueBuild(ctx context.Context, dbQueries *database.Queries, exportID uuid.UUID) error {
+	_, err := jobs.Enqueue(ctx, dbQueries, BuildJob, BuildPayload{ExportID: exportID}, jobs.Options{
+		UniqueKey:   exportID.String(),
+		MaxAttempts: buildAttempts,
+	})
+	return err
+}
+
+// Exporter runs BuildJob: it builds a zip with the profile, chirps and
+// sessions as JSON plus the original attachment files.
+type Exporter struct {
+	dbQueries *database.Queries
+	blobStore media.BlobStore
+	logger    *log.Logger
+}
+
+func NewExporter(dbQueries *database.Queries, blobStore media.BlobStore, logger *log.Logger) *Exporter {
+	return &Exporter{
+		dbQueries: dbQueries,
+		blobStore: blobStore,
+		logger:    logger,
+	}
+}
+
+// Run builds the archive. A failed attempt is retried, an export still
+// pending after the last one goes stale and is started over on the next
+// poll.
+func (e *Exporter) Run(ctx context.Context, payload BuildPayload) error {
+	err := e.build(ctx, payload.ExportID)
+	if err != nil {
+		return fmt.Errorf("build export %s: %w", payload.ExportID, err)
+	}
+
+	return nil
+}
+
+func (e *Exporter) build(ctx context.Context, exportID uuid.UUID) error {
+	export, err := e.dbQueries.GetExportByID(ctx, exportID)
+	if err != nil {
+		return fmt.Errorf("db get export: %w", err)
+	}
+
+	// Went stale, or an earlier attempt finished it after all.
+	if export.Status != statusPending {
+		return nil
+	}
+
+	// Archives carry every attachment of the account, they are put together
+	// on disk rather than in memory.
+	archive, err := os.CreateTemp("", "chirpy-export-*.zip")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	err = e.archive(ctx, archive, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	size, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seek archive: %w", err)
+	}
+
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("seek archive: %w", err)
+	}
+
+	storageKey := "exports/" + export.UserID.String() + "/" + export.ID.String() + ".zip"
+	err = e.blobStore.Put(ctx, storageKey, "application/zip", archive)
+	if err != nil {
+		return fmt.Errorf("store archive: %w", err)
+	}
+
+	rowsAffected, err := e.dbQueries.CompleteExport(ctx, database.CompleteExportParams{
+		ID:         export.ID,
+		StorageKey: sql.NullString{String: storageKey, Valid: true},
+		SizeBytes:  sql.NullInt64{Int64: size, Valid: true},
+		ExpiresAt:  sql.NullTime{Time: time.Now().Add(archiveLifetime), Valid: true},
+	})
+	if err != nil || rowsAffected == 0 {
+		// The export went stale in the meantime, nothing points at the
+		// archive so it would never be cleaned up.
+		if delErr := e.blobStore.Delete(ctx, storageKey); delErr != nil {
+			e.logger.Printf("Error(Exporter): delete archive (key=%s): %v", storageKey, delErr)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("db complete export: %w", err)
+	}
+
+	return nil
+}
+
+// archive writes the zip to w.
+func (e *Exporter) archive(ctx context.Context, w io.Writer, userID uuid.UUID) error {
+	user, err := e.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("db get user: %w", err)
+	}
+
+	chirps, err := e.dbQueries.GetChirpsByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("db get chirps: %w", err)
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	attachments, err := e.dbQueries.GetAttachmentsByChirpIDs(ctx, chirpIDs)
+	if err != nil {
+		return fmt.Errorf("db get attachments: %w", err)
+	}
+
+	tokens, err := e.dbQueries.GetRefreshTokensByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("db get refresh tokens: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+
+	err = writeJSON(zw, "profile.json", profileExport{
+		ID:          user.ID.String(),
+		Email:       user.Email,
+		CreatedAt:   user.CreatedAt.Time,
+		UpdatedAt:   user.UpdatedAt.Time,
+		IsChirpyRed: user.IsChirpyRed.Bool,
+	})
+	if err != nil {
+		return err
+	}
+
+	byChirp := make(map[uuid.UUID][]attachmentExport)
+	for _, attachment := range attachments {
+		file := "media/" + path.Base(attachment.StorageKey)
+		err = e.copyBlob(ctx, zw, file, attachment.StorageKey)
+		if err != nil {
+			return err
+		}
+
+		byChirp[attachment.ChirpID.UUID] = append(byChirp[attachment.ChirpID.UUID], attachmentExport{
+			ID:          attachment.ID.String(),
+			File:        file,
+			ContentType: attachment.ContentType,
+			SizeBytes:   attachment.SizeBytes,
+			Width:       attachment.Width,
+			Height:      attachment.Height,
+			CreatedAt:   attachment.CreatedAt.Time,
+		})
+	}
+
+	chirpsExport := make([]chirpExport, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpAttachments := byChirp[chirp.ID]
+		if chirpAttachments == nil {
+			chirpAttachments = []attachmentExport{}
+		}
+
+		chirpsExport = append(chirpsExport, chirpExport{
+			ID:          chirp.ID.String(),
+			Body:        chirp.Body,
+			CreatedAt:   chirp.CreatedAt.Time,
+			UpdatedAt:   chirp.UpdatedAt.Time,
+			Hidden:      chirp.HiddenAt.Valid,
+			Attachments: chirpAttachments,
+		})
+	}
+
+	err = writeJSON(zw, "chirps.json", chirpsExport)
+	if err != nil {
+		return err
+	}
+
+	sessions := make([]sessionExport, 0, len(tokens))
+	for _, token := range tokens {
+		session := sessionExport{
+			CreatedAt: token.CreatedAt.Time,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if token.RevokedAt.Valid {
+			session.RevokedAt = &token.RevokedAt.Time
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	err = writeJSON(zw, "sessions.json", sessions)
+	if err != nil {
+		return err
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return fmt.Errorf("close zip: %w", err)
+	}
+
+	return nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(v)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (e *Exporter) copyBlob(ctx context.Context, zw *zip.Writer, name, key string) error {
+	obj, err := e.blobStore.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get blob %s: %w", key, err)
+	}
+	defer obj.Body.Close()
+
+	// Images are compressed already.
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: obj.ModTime})
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+
+	_, err = io.Copy(f, obj.Body)
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", key, err)
+	}
+
+	return nil
+}
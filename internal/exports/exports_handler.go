@@ -0,0 +1,249 @@
+package exports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/media"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+const (
+	statusPending = "pending"
+	statusReady   = "ready"
+	statusFailed  = "failed"
+
+	// How long a download link works, a new one is handed out on every poll.
+	linkLifetime = 1 * time.Hour
+
+	// A pending export this old failed every build attempt, it is given
+	// up on. The retries back off for well under a minute in between.
+	staleAfter = buildAttempts*buildTimeout + 5*time.Minute
+
+	retryAfterSeconds = 5
+)
+
+type exportResponse struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	SizeBytes   *int64     `json:"size_bytes,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type ExportsHandler struct {
+	settings  settings.Settings
+	db        *sql.DB
+	dbQueries *database.Queries
+	blobStore media.BlobStore
+	signer    *Signer
+	logger    *log.Logger
+}
+
+// NewExportsHandler signs download links with EXPORT_LINK_SECRET. Without
+// it the key is derived from JWT_SECRET, so rotating the JWT secret also
+// breaks the links handed out.
+func NewExportsHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, blobStore media.BlobStore, logger *log.Logger) *ExportsHandler {
+	linkSecret := s.ExportLinkSecret
+	if linkSecret == "" {
+		linkSecret = s.JWTSecret
+	}
+
+	return &ExportsHandler{
+		settings:  s,
+		db:        db,
+		dbQueries: dbQueries,
+		blobStore: blobStore,
+		signer:    NewSigner(linkSecret),
+		logger:    logger,
+	}
+}
+
+// GetExport starts an export of the user's data, or reports on the one in the
+// works. Clients poll it until the archive is ready, it then comes with a
+// signed download link.
+func (h *ExportsHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	export, err := h.currentExport(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetExport): %v (user_id=%s)", err, userID)
+		response.InternalServerError(w)
+		return
+	}
+
+	if export.Status == statusReady {
+		expiresAt := time.Now().Add(linkLifetime)
+		if export.ExpiresAt.Time.Before(expiresAt) {
+			expiresAt = export.ExpiresAt.Time
+		}
+
+		resp := newExportResponse(export)
+		resp.URL = h.signer.URL(export.ID, expiresAt)
+		resp.ExpiresAt = &expiresAt
+
+		response.JSON(w, http.StatusOK, resp)
+		return
+	}
+
+	if export.Status == statusFailed {
+		response.ServiceUnavailable(w)
+		return
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	response.JSON(w, http.StatusAccepted, newExportResponse(export))
+}
+
+// currentExport returns the latest usable export of the user, starting a new
+// one if there is none.
+func (h *ExportsHandler) currentExport(ctx context.Context, userID uuid.UUID) (database.Export, error) {
+	export, err := h.dbQueries.GetLatestExport(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.Export{}, fmt.Errorf("db get latest export: %w", err)
+	}
+
+	if err == nil {
+		switch {
+		case export.Status == statusReady && export.ExpiresAt.Time.After(time.Now()):
+			return export, nil
+		case export.Status == statusPending && time.Since(export.CreatedAt) < staleAfter:
+			return export, nil
+		case export.Status == statusPending:
+			_, err = h.dbQueries.FailExport(ctx, export.ID)
+			if err != nil {
+				return database.Export{}, fmt.Errorf("db fail stale export: %w", err)
+			}
+		}
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Export{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	export, err = qtx.CreateExport(ctx, userID)
+	if err != nil {
+		// Someone else started one at the same time.
+		if errors.Is(err, sql.ErrNoRows) {
+			tx.Rollback()
+			return h.currentExport(ctx, userID)
+		}
+
+		return database.Export{}, fmt.Errorf("db create export: %w", err)
+	}
+
+	err = enqueueBuild(ctx, qtx, export.ID)
+	if err != nil {
+		return database.Export{}, fmt.Errorf("enqueue build: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return database.Export{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return export, nil
+}
+
+// Download serves an archive through a signed link, no token needed.
+func (h *ExportsHandler) Download(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		response.NotFound(w)
+		return
+	}
+
+	err = h.signer.Verify(exportID, r.URL.Query(), time.Now())
+	if err != nil {
+		response.Forbidden(w)
+		return
+	}
+
+	export, err := h.dbQueries.GetExportByID(r.Context(), exportID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(Download): db get export (export_id=%s): %v", exportID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	if export.Status != statusReady || !export.ExpiresAt.Time.After(time.Now()) {
+		response.NotFound(w)
+		return
+	}
+
+	obj, err := h.blobStore.Get(r.Context(), export.StorageKey.String)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrNotFound):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(Download): get archive (export_id=%s): %v", exportID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+	defer obj.Body.Close()
+
+	filename := "chirpy-export-" + export.CreatedAt.UTC().Format("2006-01-02") + ".zip"
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	if obj.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, obj.Body)
+}
+
+func newExportResponse(export database.Export) exportResponse {
+	resp := exportResponse{
+		ID:        export.ID.String(),
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt,
+	}
+
+	if export.CompletedAt.Valid {
+		resp.CompletedAt = &export.CompletedAt.Time
+	}
+
+	if export.SizeBytes.Valid {
+		resp.SizeBytes = &export.SizeBytes.Int64
+	}
+
+	return resp
+}
@@ -0,0 +1,34 @@
+package exports
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:     "GET /api/me/export",
+		Tag:         "users",
+		Summary:     "Export the user's data",
+		Description: "Starts an export if there is no current one. Poll until it is ready, its url downloads the archive.",
+		Auth:        openapi.AuthBearer,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "The export is ready", Body: exportResponse{}},
+			{Status: http.StatusAccepted, Description: "The export is in progress, see Retry-After", Body: exportResponse{}},
+		},
+		Errors: []int{http.StatusUnauthorized, http.StatusServiceUnavailable},
+	},
+	{
+		Pattern:     "GET " + DownloadPathPrefix + "{exportID}",
+		Tag:         "users",
+		Summary:     "Download an export",
+		Description: "Through the signed url of GET /api/me/export, no token needed.",
+		Params: []openapi.Param{
+			{Name: "expires", In: "query", Required: true},
+			{Name: "signature", In: "query", Required: true},
+		},
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The archive", ContentType: "application/zip", Body: openapi.Schema{"type": "string", "contentMediaType": "application/zip"}}},
+		Errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+}
@@ -0,0 +1,62 @@
+package exports
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func query(t *testing.T, link string) url.Values {
+	t.Helper()
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	return u.Query()
+}
+
+func TestSignerVerify(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	exportID := uuid.New()
+	signer := NewSigner("secret")
+	link := signer.URL(exportID, now.Add(time.Hour))
+
+	if !strings.HasPrefix(link, DownloadPathPrefix+exportID.String()+"?") {
+		t.Fatalf("URL() = %q, want the download path of the export", link)
+	}
+
+	tampered := query(t, link)
+	tampered.Set("expires", "9999999999")
+
+	tests := []struct {
+		name     string
+		signer   *Signer
+		exportID uuid.UUID
+		query    url.Values
+		now      time.Time
+		wantErr  error
+	}{
+		{name: "Valid", signer: signer, exportID: exportID, query: query(t, link), now: now},
+		{name: "Expired", signer: signer, exportID: exportID, query: query(t, link), now: now.Add(time.Hour), wantErr: ErrLinkExpired},
+		{name: "Other export", signer: signer, exportID: uuid.New(), query: query(t, link), now: now, wantErr: ErrInvalidSignature},
+		{name: "Other secret", signer: NewSigner("other"), exportID: exportID, query: query(t, link), now: now, wantErr: ErrInvalidSignature},
+		{name: "Extended expiry", signer: signer, exportID: exportID, query: tampered, now: now, wantErr: ErrInvalidSignature},
+		{name: "Missing signature", signer: signer, exportID: exportID, query: url.Values{"expires": {"9999999999"}}, now: now, wantErr: ErrInvalidSignature},
+		{name: "Empty", signer: signer, exportID: exportID, query: url.Values{}, now: now, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signer.Verify(tt.exportID, tt.query, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package feeds
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/chirptext"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+const (
+	feedLength = 50
+	// Readers poll, a minute of staleness takes most of them off the
+	// database.
+	cacheControl = "public, max-age=60"
+)
+
+type format struct {
+	ext         string
+	contentType string
+	render      func(feed) ([]byte, error)
+}
+
+var formats = []format{
+	{"rss", "application/rss+xml; charset=utf-8", renderRSS},
+	{"atom", "application/atom+xml; charset=utf-8", renderAtom},
+	{"json", "application/feed+json; charset=utf-8", renderJSON},
+}
+
+// Feeds serves public chirps by user and by hashtag as RSS, Atom and JSON
+// Feed. They are anonymous, hidden and scheduled chirps never show up.
+type Feeds struct {
+	settings  settings.Settings
+	dbQueries *database.Queries
+	logger    *log.Logger
+}
+
+func NewFeeds(s settings.Settings, dbQueries *database.Queries, logger *log.Logger) *Feeds {
+	return &Feeds{
+		settings:  s,
+		dbQueries: dbQueries,
+		logger:    logger,
+	}
+}
+
+func (f *Feeds) SetupRoutes(mux *http.ServeMux) {
+	for _, format := range formats {
+		mux.HandleFunc("GET /users/{userID}/feed."+format.ext, f.userFeed(format))
+		mux.HandleFunc("GET /tags/{tag}/feed."+format.ext, f.tagFeed(format))
+	}
+}
+
+func (f *Feeds) userFeed(format format) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("userID"))
+		if err != nil {
+			response.NotFound(w)
+			return
+		}
+
+		exists, err := f.dbQueries.UserExists(r.Context(), userID)
+		if err != nil {
+			f.logger.Printf("Error(userFeed): db user exists (user_id=%s): %v", userID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		if !exists {
+			response.NotFound(w)
+			return
+		}
+
+		baseURL := request.BaseURL(r, f.settings.BaseURL)
+		items, err := f.items(r.Context(), baseURL, database.GetFeedChirpsParams{
+			UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+			MaxChirps: feedLength,
+		}, "")
+		if err != nil {
+			f.logger.Printf("Error(userFeed): feed items (user_id=%s): %v", userID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		f.serve(w, r, format, feed{
+			Title:       fmt.Sprintf("Chirps by %s", userID),
+			Description: fmt.Sprintf("The latest chirps by %s on Chirpy.", userID),
+			HomeURL:     baseURL + "/api/chirps?author_id=" + userID.String(),
+			FeedURL:     baseURL + r.URL.Path,
+			Items:       items,
+		})
+	}
+}
+
+func (f *Feeds) tagFeed(format format) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag := chirptext.NormalizeHashtag(r.PathValue("tag"))
+
+		// Anything Hashtags wouldn't find in a chirp can't have chirps.
+		if !slices.Equal(chirptext.Hashtags("#"+tag), []string{tag}) {
+			response.NotFound(w)
+			return
+		}
+
+		baseURL := request.BaseURL(r, f.settings.BaseURL)
+		items, err := f.items(r.Context(), baseURL, database.GetFeedChirpsParams{
+			Hashtag:   sql.NullString{String: tag, Valid: true},
+			MaxChirps: feedLength,
+		}, tag)
+		if err != nil {
+			f.logger.Printf("Error(tagFeed): feed items (tag=%s): %v", tag, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		f.serve(w, r, format, feed{
+			Title:       "#" + tag,
+			Description: fmt.Sprintf("The latest chirps tagged #%s on Chirpy.", tag),
+			HomeURL:     baseURL + "/",
+			FeedURL:     baseURL + r.URL.Path,
+			Items:       items,
+		})
+	}
+}
+
+// items loads the newest public chirps. With a tag, chirps the database
+// matched loosely are checked against chirptext.Hashtags.
+func (f *Feeds) items(ctx context.Context, baseURL string, params database.GetFeedChirpsParams, tag string) ([]item, error) {
+	chirps, err := f.dbQueries.GetFeedChirps(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("db get feed chirps: %w", err)
+	}
+
+	items := []item{}
+	for _, chirp := range chirps {
+		if tag != "" && !slices.Contains(chirptext.Hashtags(chirp.Body), tag) {
+			continue
+		}
+
+		items = append(items, item{
+			URL:       baseURL + "/api/chirps/" + chirp.ID.String(),
+			Body:      chirp.Body,
+			Published: chirp.CreatedAt.Time,
+			Updated:   chirp.UpdatedAt.Time,
+		})
+	}
+
+	return items, nil
+}
+
+// serve renders the feed and answers conditional requests. The ETag is a
+// hash of the document, so a deleted or edited chirp changes it even when
+// Last-Modified can't tell.
+func (f *Feeds) serve(w http.ResponseWriter, r *http.Request, format format, fd feed) {
+	for _, i := range fd.Items {
+		if i.Updated.After(fd.Updated) {
+			fd.Updated = i.Updated
+		}
+	}
+
+	body, err := format.render(fd)
+	if err != nil {
+		f.logger.Printf("Error(serve): render %s feed (path=%s): %v", format.ext, r.URL.Path, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	writeFeed(w, r, format.contentType, body, fd.Updated)
+}
+
+// writeFeed leaves If-None-Match and If-Modified-Since to
+// http.ServeContent.
+func writeFeed(w http.ResponseWriter, r *http.Request, contentType string, body []byte, lastModified time.Time) {
+	sum := sha256.Sum256(body)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(body))
+}
@@ -0,0 +1,173 @@
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() feed {
+	published := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	return feed{
+		Title:       "#go",
+		Description: "The latest chirps tagged #go on Chirpy.",
+		HomeURL:     "https://chirpy.example/",
+		FeedURL:     "https://chirpy.example/tags/go/feed.rss",
+		Updated:     published.Add(time.Hour),
+		Items: []item{
+			{
+				URL:       "https://chirpy.example/api/chirps/1",
+				Body:      "Generics in #go <3 & more",
+				Published: published,
+				Updated:   published.Add(time.Hour),
+			},
+		},
+	}
+}
+
+func TestRenderRSS(t *testing.T) {
+	data, err := renderRSS(testFeed())
+	if err != nil {
+		t.Fatalf("renderRSS() error = %v", err)
+	}
+
+	var doc struct {
+		Channel struct {
+			Items []struct {
+				Link        string `xml:"link"`
+				Description string `xml:"description"`
+				PubDate     string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	err = xml.Unmarshal(data, &doc)
+	if err != nil {
+		t.Fatalf("RSS doesn't parse: %v", err)
+	}
+
+	if len(doc.Channel.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(doc.Channel.Items))
+	}
+
+	got := doc.Channel.Items[0]
+	if got.Description != "Generics in #go <3 & more" {
+		t.Errorf("description = %q, want the chirp body", got.Description)
+	}
+
+	if got.Link != "https://chirpy.example/api/chirps/1" {
+		t.Errorf("link = %q, want the permalink", got.Link)
+	}
+
+	if got.PubDate != "Thu, 01 Jan 2026 12:00:00 +0000" {
+		t.Errorf("pubDate = %q", got.PubDate)
+	}
+}
+
+func TestRenderAtom(t *testing.T) {
+	data, err := renderAtom(testFeed())
+	if err != nil {
+		t.Fatalf("renderAtom() error = %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID        string `xml:"id"`
+			Published string `xml:"published"`
+			Content   string `xml:"content"`
+		} `xml:"entry"`
+	}
+	err = xml.Unmarshal(data, &doc)
+	if err != nil {
+		t.Fatalf("Atom doesn't parse: %v", err)
+	}
+
+	if doc.Updated != "2026-01-01T13:00:00Z" {
+		t.Errorf("updated = %q", doc.Updated)
+	}
+
+	if len(doc.Entries) != 1 || doc.Entries[0].Content != "Generics in #go <3 & more" || doc.Entries[0].Published != "2026-01-01T12:00:00Z" {
+		t.Errorf("entries = %+v", doc.Entries)
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	data, err := renderJSON(testFeed())
+	if err != nil {
+		t.Fatalf("renderJSON() error = %v", err)
+	}
+
+	var doc jsonFeedDocument
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		t.Fatalf("JSON Feed doesn't parse: %v", err)
+	}
+
+	if doc.Version != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("version = %q", doc.Version)
+	}
+
+	if len(doc.Items) != 1 || doc.Items[0].URL != "https://chirpy.example/api/chirps/1" || doc.Items[0].ContentText != "Generics in #go <3 & more" {
+		t.Errorf("items = %+v", doc.Items)
+	}
+}
+
+func TestItemTitle(t *testing.T) {
+	short := item{Body: "hello\n  world"}
+	if got := short.title(); got != "hello world" {
+		t.Errorf("title() = %q, want %q", got, "hello world")
+	}
+
+	long := item{Body: strings.Repeat("é", 100)}
+	got := long.title()
+	if !strings.HasSuffix(got, "…") || len([]rune(got)) != titleLength {
+		t.Errorf("title() = %q, want %d runes ending in …", got, titleLength)
+	}
+}
+
+func TestWriteFeedConditional(t *testing.T) {
+	lastModified := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte("<rss/>")
+
+	first := httptest.NewRecorder()
+	writeFeed(first, httptest.NewRequest(http.MethodGet, "/tags/go/feed.rss", nil), "application/rss+xml", body, lastModified)
+
+	if first.Code != http.StatusOK || first.Body.String() != "<rss/>" {
+		t.Fatalf("GET = %d %q, want 200 with the feed", first.Code, first.Body.String())
+	}
+
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("no ETag")
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"same etag", "If-None-Match", etag, http.StatusNotModified},
+		{"other etag", "If-None-Match", `"stale"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", lastModified.Format(http.TimeFormat), http.StatusNotModified},
+		{"modified since", "If-Modified-Since", lastModified.Add(-time.Minute).Format(http.TimeFormat), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/tags/go/feed.rss", nil)
+		r.Header.Set(tt.header, tt.value)
+
+		w := httptest.NewRecorder()
+		writeFeed(w, r, "application/rss+xml", body, lastModified)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
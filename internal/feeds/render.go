@@ -0,0 +1,205 @@
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Item titles are the start of the chirp, readers without one show the
+// feed's name instead.
+const titleLength = 60
+
+// feed is what the RSS, Atom and JSON Feed renderers have in common.
+type feed struct {
+	Title       string
+	Description string
+	HomeURL     string
+	// Where this very document is served, differs per format.
+	FeedURL string
+	Updated time.Time
+	Items   []item
+}
+
+type item struct {
+	URL       string
+	Body      string
+	Published time.Time
+	Updated   time.Time
+}
+
+func (i item) title() string {
+	title := strings.Join(strings.Fields(i.Body), " ")
+	if utf8.RuneCountInString(title) <= titleLength {
+		return title
+	}
+
+	runes := []rune(title)
+	return strings.TrimSpace(string(runes[:titleLength-1])) + "…"
+}
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomXMLNS string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+func renderRSS(f feed) ([]byte, error) {
+	doc := rssDocument{
+		Version:   "2.0",
+		AtomXMLNS: "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.HomeURL,
+			Description: f.Description,
+			AtomLink:    atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"},
+			Items:       []rssItem{},
+		},
+	}
+
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, i := range f.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       i.title(),
+			Link:        i.URL,
+			GUID:        rssGUID{IsPermaLink: true, Value: i.URL},
+			Description: i.Body,
+			PubDate:     i.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	return marshalXML(doc)
+}
+
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Link      atomLink `xml:"link"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+	Content   atomText `xml:"content"`
+}
+
+func renderAtom(f feed) ([]byte, error) {
+	doc := atomDocument{
+		ID:      f.FeedURL,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.HomeURL, Rel: "alternate"},
+		},
+	}
+
+	for _, i := range f.Items {
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:        i.URL,
+			Title:     i.title(),
+			Link:      atomLink{Href: i.URL, Rel: "alternate"},
+			Published: i.Published.UTC().Format(time.RFC3339),
+			Updated:   i.Updated.UTC().Format(time.RFC3339),
+			Content:   atomText{Type: "text", Value: i.Body},
+		})
+	}
+
+	return marshalXML(doc)
+}
+
+func marshalXML(doc any) ([]byte, error) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+type jsonFeedDocument struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string    `json:"id"`
+	URL           string    `json:"url"`
+	Title         string    `json:"title"`
+	ContentText   string    `json:"content_text"`
+	DatePublished time.Time `json:"date_published"`
+	DateModified  time.Time `json:"date_modified"`
+}
+
+func renderJSON(f feed) ([]byte, error) {
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		Description: f.Description,
+		HomePageURL: f.HomeURL,
+		FeedURL:     f.FeedURL,
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, i := range f.Items {
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            i.URL,
+			URL:           i.URL,
+			Title:         i.title(),
+			ContentText:   i.Body,
+			DatePublished: i.Published.UTC(),
+			DateModified:  i.Updated.UTC(),
+		})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,169 @@
+package follows
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/notifications"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+type followResponse struct {
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FollowsHandler struct {
+	settings  settings.Settings
+	dbQueries *database.Queries
+	filter    *visibility.Filter
+	notifier  *notifications.Notifier
+	logger    *log.Logger
+}
+
+func NewFollowsHandler(s settings.Settings, dbQueries *database.Queries, filter *visibility.Filter, notifier *notifications.Notifier, logger *log.Logger) *FollowsHandler {
+	return &FollowsHandler{
+		settings:  s,
+		dbQueries: dbQueries,
+		filter:    filter,
+		notifier:  notifier,
+		logger:    logger,
+	}
+}
+
+// target authenticates the request and resolves the {userID} path value. It
+// writes the error response itself and reports ok=false in that case.
+func (h *FollowsHandler) target(w http.ResponseWriter, r *http.Request) (userID, targetID uuid.UUID, ok bool) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	userID, err = auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	targetID, err = uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.Invalid(w, "userID", response.FieldInvalid, "invalid user id")
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if targetID == userID {
+		response.Error(w, http.StatusBadRequest, "self_target", "can't target yourself")
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	exists, err := h.dbQueries.UserExists(r.Context(), targetID)
+	if err != nil {
+		h.logger.Printf("Error(FollowsHandler): db user exists (user_id=%s): %v", targetID, err)
+		response.InternalServerError(w)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if !exists {
+		response.NotFound(w)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	return userID, targetID, true
+}
+
+func (h *FollowsHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+
+	canInteract, err := h.filter.CanInteract(r.Context(), userID, targetID)
+	if err != nil {
+		h.logger.Printf("Error(Follow): check block (user_id=%s, target_id=%s): %v", userID, targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !canInteract {
+		response.Forbidden(w)
+		return
+	}
+
+	followed, err := h.dbQueries.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FollowedID: targetID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(Follow): db follow user (user_id=%s, target_id=%s): %v", userID, targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	// Following again is a no-op, it doesn't notify twice.
+	if followed > 0 {
+		h.notifier.Notify(notifications.Notification{
+			Type:        notifications.TypeFollow,
+			RecipientID: targetID,
+			ActorID:     userID,
+		})
+	}
+
+	response.NoContent(w)
+}
+
+func (h *FollowsHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := h.dbQueries.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FollowedID: targetID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(Unfollow): db unfollow user (user_id=%s, target_id=%s): %v", userID, targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *FollowsHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	follows, err := h.dbQueries.GetFollowedUsers(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetFollowing): db get followed users (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := []followResponse{}
+	for _, follow := range follows {
+		resp = append(resp, followResponse{
+			UserID:    follow.FollowedID.String(),
+			CreatedAt: follow.CreatedAt.Time,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
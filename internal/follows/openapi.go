@@ -0,0 +1,37 @@
+package follows
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var targetErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound}
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:     "POST /api/users/{userID}/follow",
+		Tag:         "follows",
+		Summary:     "Follow a user",
+		Description: "Not possible while either user blocks the other. The followed user is notified the first time.",
+		Auth:        openapi.AuthBearer,
+		Responses:   []openapi.Response{{Status: http.StatusNoContent, Description: "Followed"}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Pattern:   "DELETE /api/users/{userID}/follow",
+		Tag:       "follows",
+		Summary:   "Unfollow a user",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Unfollowed"}},
+		Errors:    targetErrors,
+	},
+	{
+		Pattern:   "GET /api/me/following",
+		Tag:       "follows",
+		Summary:   "List followed users",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "Followed users", Body: []followResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+}
@@ -0,0 +1,263 @@
+// Package idempotency lets clients retry mutating requests safely. The
+// first response to a request with an Idempotency-Key is stored and
+// replayed to retries with the same key, instead of running them again.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+const (
+	Header = "Idempotency-Key"
+	// Set to "true" on replayed responses.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// How long the first response to a key is replayed.
+	Retention = 24 * time.Hour
+	// A request still running after this is taken to be lost along with the
+	// instance that ran it, its key can be used again.
+	abandonAfter = 5 * time.Minute
+
+	maxKeyLength = 255
+	// Above the biggest upload, handlers enforce their own limits.
+	maxBodySize = 8 << 20
+)
+
+// Methods a key is honored for.
+var Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// Headers about the exchange rather than the response, a replay gets its
+// own.
+var exchangeHeaders = []string{
+	http.CanonicalHeaderKey(response.RequestIDHeader),
+	"X-Ratelimit-Limit",
+	"X-Ratelimit-Remaining",
+	"Date",
+}
+
+// store is the part of database.Queries keys need.
+type store interface {
+	ClaimIdempotencyKey(ctx context.Context, arg database.ClaimIdempotencyKeyParams) (int64, error)
+	GetIdempotencyKey(ctx context.Context, arg database.GetIdempotencyKeyParams) (database.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, arg database.CompleteIdempotencyKeyParams) error
+	DeleteIdempotencyKey(ctx context.Context, arg database.DeleteIdempotencyKeyParams) error
+}
+
+// Keys are per user, requests without a valid access token are served as
+// if they had no key.
+type Keys struct {
+	settings settings.Settings
+	store    store
+	logger   *log.Logger
+}
+
+func NewKeys(s settings.Settings, dbQueries *database.Queries, logger *log.Logger) *Keys {
+	return &Keys{
+		settings: s,
+		store:    dbQueries,
+		logger:   logger,
+	}
+}
+
+// Middleware replays the stored response to a retry. A retry with another
+// method, target or body is answered 422, one arriving while the first
+// request is still running 409. Server errors aren't stored, the request
+// can be retried with the same key.
+func (k *Keys) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || !slices.Contains(Methods, r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := auth.GetOptionalUserID(r.Header, k.settings.JWTSecret)
+		if err != nil || !userID.Valid {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !validKey(key) {
+			response.Invalid(w, Header, response.FieldInvalid, "Idempotency-Key must be 1 to 255 printable ASCII characters")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				response.PayloadTooLarge(w)
+			} else {
+				response.InvalidRequestBody(w)
+			}
+
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := fingerprint(r, body)
+		now := time.Now()
+		claimed, err := k.store.ClaimIdempotencyKey(r.Context(), database.ClaimIdempotencyKeyParams{
+			UserID:          userID.UUID,
+			Key:             key,
+			Fingerprint:     fingerprint,
+			ExpiredBefore:   now.Add(-Retention),
+			AbandonedBefore: now.Add(-abandonAfter),
+		})
+		if err != nil {
+			k.logger.Printf("Error(idempotency): db claim key (user_id=%s): %v", userID.UUID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		if claimed == 0 {
+			k.replay(w, r, userID.UUID, key, fingerprint)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			// Also reached when the handler panics.
+			if !completed {
+				k.release(r, userID.UUID, key)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		completed = k.complete(r, userID.UUID, key, rec)
+	})
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+
+	for i := range len(key) {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fingerprint identifies the request a key was first used for.
+func fingerprint(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+func (k *Keys) replay(w http.ResponseWriter, r *http.Request, userID uuid.UUID, key string, fingerprint []byte) {
+	stored, err := k.store.GetIdempotencyKey(r.Context(), database.GetIdempotencyKeyParams{
+		UserID: userID,
+		Key:    key,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Released after a server error just now.
+			response.Error(w, http.StatusConflict, "idempotency_key_in_use", "a request with this Idempotency-Key just failed, retry it")
+			return
+		}
+
+		k.logger.Printf("Error(idempotency): db get key (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !bytes.Equal(stored.Fingerprint, fingerprint) {
+		response.Error(w, http.StatusUnprocessableEntity, "idempotency_key_reused", "the Idempotency-Key was used for a different request")
+		return
+	}
+
+	if !stored.Status.Valid {
+		w.Header().Set("Retry-After", "1")
+		response.Error(w, http.StatusConflict, "idempotency_key_in_use", "a request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	var header http.Header
+	err = json.Unmarshal(stored.Headers, &header)
+	if err != nil {
+		k.logger.Printf("Error(idempotency): unmarshal headers (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+
+	w.WriteHeader(int(stored.Status.Int32))
+	w.Write(stored.Body)
+}
+
+// complete stores the response. The client may be gone by now, which is
+// why it would retry, so it doesn't go by the request's context.
+func (k *Keys) complete(r *http.Request, userID uuid.UUID, key string, rec *recorder) bool {
+	header := rec.header
+	if header == nil {
+		header = rec.Header()
+	}
+
+	header = header.Clone()
+	for _, name := range exchangeHeaders {
+		delete(header, name)
+	}
+
+	headers, err := json.Marshal(header)
+	if err != nil {
+		k.logger.Printf("Error(idempotency): marshal headers (user_id=%s): %v", userID, err)
+		return false
+	}
+
+	err = k.store.CompleteIdempotencyKey(context.WithoutCancel(r.Context()), database.CompleteIdempotencyKeyParams{
+		UserID:  userID,
+		Key:     key,
+		Status:  sql.NullInt32{Int32: int32(rec.statusCode()), Valid: true},
+		Headers: headers,
+		Body:    rec.body.Bytes(),
+	})
+	if err != nil {
+		k.logger.Printf("Error(idempotency): db complete key (user_id=%s): %v", userID, err)
+		return false
+	}
+
+	return true
+}
+
+// release gives up the key when there is no response worth replaying.
+func (k *Keys) release(r *http.Request, userID uuid.UUID, key string) {
+	err := k.store.DeleteIdempotencyKey(context.WithoutCancel(r.Context()), database.DeleteIdempotencyKeyParams{
+		UserID: userID,
+		Key:    key,
+	})
+	if err != nil {
+		k.logger.Printf("Error(idempotency): db delete key (user_id=%s): %v", userID, err)
+	}
+}
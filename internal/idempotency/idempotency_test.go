@@ -0,0 +1,263 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+type storeKey struct {
+	userID uuid.UUID
+	key    string
+}
+
+// memStore implements store with the semantics of the queries.
+type memStore struct {
+	mu   sync.Mutex
+	keys map[storeKey]database.IdempotencyKey
+}
+
+func (s *memStore) ClaimIdempotencyKey(ctx context.Context, arg database.ClaimIdempotencyKeyParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := storeKey{arg.UserID, arg.Key}
+	if existing, ok := s.keys[k]; ok {
+		expired := existing.CreatedAt.Before(arg.ExpiredBefore)
+		abandoned := !existing.Status.Valid && existing.CreatedAt.Before(arg.AbandonedBefore)
+		if !expired && !abandoned {
+			return 0, nil
+		}
+	}
+
+	s.keys[k] = database.IdempotencyKey{
+		UserID:      arg.UserID,
+		Key:         arg.Key,
+		Fingerprint: arg.Fingerprint,
+		Headers:     []byte("{}"),
+		CreatedAt:   time.Now(),
+	}
+	return 1, nil
+}
+
+func (s *memStore) GetIdempotencyKey(ctx context.Context, arg database.GetIdempotencyKeyParams) (database.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[storeKey{arg.UserID, arg.Key}]
+	if !ok {
+		return database.IdempotencyKey{}, sql.ErrNoRows
+	}
+	return key, nil
+}
+
+func (s *memStore) CompleteIdempotencyKey(ctx context.Context, arg database.CompleteIdempotencyKeyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := storeKey{arg.UserID, arg.Key}
+	key := s.keys[k]
+	key.Status, key.Headers, key.Body = arg.Status, arg.Headers, arg.Body
+	s.keys[k] = key
+	return nil
+}
+
+func (s *memStore) DeleteIdempotencyKey(ctx context.Context, arg database.DeleteIdempotencyKeyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, storeKey{arg.UserID, arg.Key})
+	return nil
+}
+
+type testServer struct {
+	store   *memStore
+	handler http.Handler
+	calls   int
+	status  int
+	userID  uuid.UUID
+	jwt     string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	userID := uuid.New()
+	jwt, err := auth.MakeJWT(userID, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := &testServer{
+		store:  &memStore{keys: make(map[storeKey]database.IdempotencyKey)},
+		status: http.StatusCreated,
+		userID: userID,
+		jwt:    jwt,
+	}
+
+	keys := &Keys{
+		settings: settings.Settings{JWTSecret: "secret"},
+		store:    ts.store,
+		logger:   log.New(io.Discard, "", 0),
+	}
+
+	ts.handler = keys.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.calls++
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Location", "/api/chirps/1")
+		w.Header().Set("X-Ratelimit-Remaining", "9")
+		w.WriteHeader(ts.status)
+		w.Write(body)
+	}))
+
+	return ts
+}
+
+func (ts *testServer) do(method, key, body string, authenticated bool) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/chirps", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	if authenticated {
+		r.Header.Set("Authorization", "Bearer "+ts.jwt)
+	}
+
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestReplay(t *testing.T) {
+	ts := newTestServer(t)
+
+	first := ts.do(http.MethodPost, "k1", `{"body":"hi"}`, true)
+	retry := ts.do(http.MethodPost, "k1", `{"body":"hi"}`, true)
+
+	if ts.calls != 1 {
+		t.Fatalf("handler ran %d times, want once", ts.calls)
+	}
+
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+
+	if retry.Header().Get("Location") != "/api/chirps/1" {
+		t.Errorf("Location = %q, want it replayed", retry.Header().Get("Location"))
+	}
+
+	if retry.Header().Get("X-Ratelimit-Remaining") != "" {
+		t.Error("rate limit headers of the first request were replayed")
+	}
+
+	if retry.Header().Get(ReplayedHeader) != "true" || first.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("%s = %q on the retry, %q on the first", ReplayedHeader, retry.Header().Get(ReplayedHeader), first.Header().Get(ReplayedHeader))
+	}
+}
+
+func TestKeyReusedForOtherRequest(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.do(http.MethodPost, "k1", `{"body":"hi"}`, true)
+	w := ts.do(http.MethodPost, "k1", `{"body":"bye"}`, true)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	if ts.calls != 1 {
+		t.Errorf("handler ran %d times, want once", ts.calls)
+	}
+}
+
+func TestKeyInUse(t *testing.T) {
+	ts := newTestServer(t)
+
+	body := `{"body":"hi"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(body))
+	ts.store.ClaimIdempotencyKey(context.Background(), database.ClaimIdempotencyKeyParams{
+		UserID:      ts.userID,
+		Key:         "k1",
+		Fingerprint: fingerprint(r, []byte(body)),
+	})
+
+	w := ts.do(http.MethodPost, "k1", body, true)
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	// The instance running it died.
+	k := storeKey{ts.userID, "k1"}
+	abandoned := ts.store.keys[k]
+	abandoned.CreatedAt = time.Now().Add(-2 * abandonAfter)
+	ts.store.keys[k] = abandoned
+
+	w = ts.do(http.MethodPost, "k1", body, true)
+	if w.Code != http.StatusCreated || ts.calls != 1 {
+		t.Errorf("status = %d after %d calls, want the abandoned key taken over", w.Code, ts.calls)
+	}
+}
+
+func TestServerErrorsArentStored(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.status = http.StatusServiceUnavailable
+	ts.do(http.MethodPost, "k1", `{"body":"hi"}`, true)
+
+	ts.status = http.StatusCreated
+	w := ts.do(http.MethodPost, "k1", `{"body":"hi"}`, true)
+
+	if w.Code != http.StatusCreated || ts.calls != 2 {
+		t.Errorf("status = %d after %d calls, want the retry to run", w.Code, ts.calls)
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		key           string
+		authenticated bool
+	}{
+		{name: "no key", method: http.MethodPost, authenticated: true},
+		{name: "anonymous", method: http.MethodPost, key: "k1"},
+		{name: "safe method", method: http.MethodGet, key: "k1", authenticated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+
+			ts.do(tt.method, tt.key, `{}`, tt.authenticated)
+			ts.do(tt.method, tt.key, `{}`, tt.authenticated)
+
+			if ts.calls != 2 {
+				t.Errorf("handler ran %d times, want twice", ts.calls)
+			}
+		})
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, key := range []string{strings.Repeat("k", maxKeyLength+1), "k\x7f"} {
+		w := ts.do(http.MethodPost, key, `{}`, true)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("key %q: status = %d, want %d", key, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package idempotency
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// recorder keeps a copy of the response on its way to the client.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	// The headers as they were sent.
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = rec.Header().Clone()
+	}
+
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// statusCode is the status sent, handlers that write nothing send 200.
+func (rec *recorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+
+	return rec.status
+}
@@ -0,0 +1,417 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+)
+
+const (
+	pollInterval = 1 * time.Second
+	// A claimed job's lease outlives its timeout by this much, so a job is
+	// only picked up again if its worker died.
+	leaseSlack = 1 * time.Minute
+
+	DefaultTimeout     = 5 * time.Minute
+	DefaultMaxAttempts = 5
+
+	baseBackoff = 10 * time.Second
+	maxBackoff  = 1 * time.Hour
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+var Statuses = []string{StatusQueued, StatusRunning, StatusSucceeded, StatusDead}
+
+var ErrNoHandler = errors.New("no handler for job kind")
+
+// Kind names a type of job with payload T. Name is what is stored, it must
+// not change once jobs of the kind were enqueued.
+type Kind[T any] struct {
+	Name string
+	// How long a single attempt may take, DefaultTimeout if zero.
+	Timeout time.Duration
+}
+
+func (k Kind[T]) timeout() time.Duration {
+	if k.Timeout == 0 {
+		return DefaultTimeout
+	}
+
+	return k.Timeout
+}
+
+// Options change how a job is enqueued, the zero value runs it right away.
+type Options struct {
+	// Delays the job until then.
+	RunAt time.Time
+	// While a job with the same kind and key is queued or running, another
+	// one is not enqueued.
+	UniqueKey string
+	// DefaultMaxAttempts if zero.
+	MaxAttempts int
+}
+
+// Enqueue stores a job. dbQueries may be bound to a transaction, the job is
+// then only enqueued if the transaction commits. It reports false if a job
+// with the same unique key is already waiting.
+func Enqueue[T any](ctx context.Context, dbQueries *database.Queries, kind Kind[T], payload T, opts Options) (bool, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("marshal %s payload: %w", kind.Name, err)
+	}
+
+	runAt := opts.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	enqueued, err := dbQueries.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        kind.Name,
+		Payload:     data,
+		UniqueKey:   sql.NullString{String: opts.UniqueKey, Valid: opts.UniqueKey != ""},
+		MaxAttempts: int32(maxAttempts),
+		RunAt:       runAt,
+	})
+	if err != nil {
+		return false, fmt.Errorf("db enqueue %s job: %w", kind.Name, err)
+	}
+
+	return enqueued == 1, nil
+}
+
+type handler struct {
+	run     func(ctx context.Context, payload json.RawMessage) error
+	timeout time.Duration
+}
+
+type schedule struct {
+	name    string
+	spec    string
+	cron    Cron
+	enqueue func(ctx context.Context, qtx *database.Queries) error
+}
+
+// backoff is the wait after the given failed attempt, doubling each time.
+func backoff(attempt int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return wait
+}
+
+// Queue runs jobs from the jobs table on a pool of workers and enqueues
+// recurring jobs when they are due. Every instance runs one, claiming skips
+// jobs and schedules another instance has locked. Handlers and schedules
+// must be added before Start.
+type Queue struct {
+	db        *sql.DB
+	dbQueries *database.Queries
+	workers   int
+	logger    *log.Logger
+
+	handlers  map[string]handler
+	schedules []schedule
+
+	done    chan struct{}
+	wg      sync.WaitGroup
+	running sync.WaitGroup
+	slots   chan struct{}
+
+	// Jobs run under this context, it is canceled if draining takes too
+	// long.
+	jobCtx    context.Context
+	cancelJob context.CancelFunc
+}
+
+func NewQueue(db *sql.DB, dbQueries *database.Queries, workers int, logger *log.Logger) *Queue {
+	jobCtx, cancelJob := context.WithCancel(context.Background())
+
+	return &Queue{
+		db:        db,
+		dbQueries: dbQueries,
+		workers:   workers,
+		logger:    logger,
+		handlers:  make(map[string]handler),
+		done:      make(chan struct{}),
+		slots:     make(chan struct{}, workers),
+		jobCtx:    jobCtx,
+		cancelJob: cancelJob,
+	}
+}
+
+// Register sets the handler for jobs of kind. A handler returning an error
+// is retried with backoff until the job runs out of attempts, so handlers
+// must cope with running more than once.
+func Register[T any](q *Queue, kind Kind[T], fn func(ctx context.Context, payload T) error) {
+	q.handlers[kind.Name] = handler{
+		run: func(ctx context.Context, data json.RawMessage) error {
+			var payload T
+			err := json.Unmarshal(data, &payload)
+			if err != nil {
+				return fmt.Errorf("unmarshal payload: %w", err)
+			}
+
+			return fn(ctx, payload)
+		},
+		timeout: kind.timeout(),
+	}
+}
+
+// Schedule enqueues a job of kind whenever the cron spec matches. A run is
+// skipped while the previous one is still queued or running.
+func Schedule[T any](q *Queue, name, spec string, kind Kind[T], payload T) error {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	q.schedules = append(q.schedules, schedule{
+		name: name,
+		spec: spec,
+		cron: cron,
+		enqueue: func(ctx context.Context, qtx *database.Queries) error {
+			_, err := Enqueue(ctx, qtx, kind, payload, Options{UniqueKey: "schedule:" + name})
+			return err
+		},
+	})
+
+	return nil
+}
+
+// Start stores the schedules and starts claiming jobs.
+func (q *Queue) Start(ctx context.Context) error {
+	now := time.Now()
+	for _, s := range q.schedules {
+		err := q.dbQueries.UpsertJobSchedule(ctx, database.UpsertJobScheduleParams{
+			Name:      s.name,
+			Spec:      s.spec,
+			NextRunAt: s.cron.Next(now),
+		})
+		if err != nil {
+			return fmt.Errorf("db upsert job schedule %s: %w", s.name, err)
+		}
+	}
+
+	q.wg.Add(1)
+	go q.run()
+
+	return nil
+}
+
+func (q *Queue) run() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+		}
+
+		err := q.enqueueScheduled()
+		if err != nil {
+			q.logger.Printf("Error(Queue): enqueue scheduled jobs: %v", err)
+		}
+
+		q.claim()
+	}
+}
+
+// enqueueScheduled enqueues the jobs of due schedules and moves them on to
+// their next run, in one transaction so a schedule fires once.
+func (q *Queue) enqueueScheduled() error {
+	if len(q.schedules) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := q.dbQueries.WithTx(tx)
+	due, err := qtx.GetDueJobSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("db get due job schedules: %w", err)
+	}
+
+	if len(due) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, row := range due {
+		var s *schedule
+		for i := range q.schedules {
+			if q.schedules[i].name == row.Name {
+				s = &q.schedules[i]
+			}
+		}
+
+		// Dropped from the code, or added by a newer version running next
+		// to this one.
+		if s == nil {
+			continue
+		}
+
+		err = s.enqueue(ctx, qtx)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", s.name, err)
+		}
+
+		err = qtx.SetJobScheduleNextRun(ctx, database.SetJobScheduleNextRunParams{
+			Name:      s.name,
+			NextRunAt: s.cron.Next(now),
+		})
+		if err != nil {
+			return fmt.Errorf("db set job schedule next run %s: %w", s.name, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// claim takes as many due jobs as there are idle workers and starts them.
+func (q *Queue) claim() {
+	idle := q.workers - len(q.slots)
+	if idle == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The lease has to cover the slowest kind, the job's own timeout is
+	// only known after claiming it.
+	var longest time.Duration
+	for _, h := range q.handlers {
+		longest = max(longest, h.timeout)
+	}
+	longest = max(longest, DefaultTimeout)
+
+	claimed, err := q.dbQueries.ClaimJobs(ctx, database.ClaimJobsParams{
+		LeaseUntil: time.Now().Add(longest + leaseSlack),
+		MaxJobs:    int32(idle),
+	})
+	if err != nil {
+		q.logger.Printf("Error(Queue): db claim jobs: %v", err)
+		return
+	}
+
+	for _, job := range claimed {
+		q.slots <- struct{}{}
+		q.running.Add(1)
+		go func() {
+			defer q.running.Done()
+			defer func() { <-q.slots }()
+
+			q.execute(job)
+		}()
+	}
+}
+
+func (q *Queue) execute(job database.Job) {
+	err := q.runHandler(job)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	switch {
+	case err == nil:
+		err = q.dbQueries.CompleteJob(ctx, job.ID)
+	case job.Attempts >= job.MaxAttempts:
+		q.logger.Printf("Error(Queue): job %s failed for good (job_id=%s, attempt=%d): %v", job.Kind, job.ID, job.Attempts, err)
+		err = q.dbQueries.KillJob(ctx, database.KillJobParams{
+			ID:        job.ID,
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+	default:
+		q.logger.Printf("Error(Queue): job %s failed (job_id=%s, attempt=%d): %v", job.Kind, job.ID, job.Attempts, err)
+		err = q.dbQueries.RetryJob(ctx, database.RetryJobParams{
+			ID:        job.ID,
+			RunAt:     time.Now().Add(backoff(int(job.Attempts))),
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+	}
+
+	// The lease runs out and the job is retried.
+	if err != nil {
+		q.logger.Printf("Error(Queue): db update job (job_id=%s): %v", job.ID, err)
+	}
+}
+
+func (q *Queue) runHandler(job database.Job) (err error) {
+	h, ok := q.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrNoHandler, job.Kind)
+	}
+
+	ctx, cancel := context.WithTimeout(q.jobCtx, h.timeout)
+	defer cancel()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	return h.run(ctx, job.Payload)
+}
+
+// Close stops claiming jobs and waits for the running ones to finish. Jobs
+// still running when ctx is done are canceled, they are retried later.
+func (q *Queue) Close(ctx context.Context) {
+	close(q.done)
+	q.wg.Wait()
+
+	drained := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		q.logger.Printf("Queue: canceling running jobs")
+		q.cancelJob()
+		<-drained
+	}
+
+	q.cancelJob()
+}
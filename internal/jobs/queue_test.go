@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{5, 160 * time.Second},
+		{9, 2560 * time.Second},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRunHandler(t *testing.T) {
+	type greeting struct {
+		Name string `json:"name"`
+	}
+
+	greet := Kind[greeting]{Name: "greet"}
+	crash := Kind[struct{}]{Name: "crash"}
+
+	q := NewQueue(nil, nil, 1, log.New(io.Discard, "", 0))
+	defer q.cancelJob()
+
+	var got string
+	Register(q, greet, func(ctx context.Context, g greeting) error {
+		got = g.Name
+		return nil
+	})
+	Register(q, crash, func(ctx context.Context, _ struct{}) error {
+		panic("boom")
+	})
+
+	payload, _ := json.Marshal(greeting{Name: "alice"})
+	err := q.runHandler(database.Job{Kind: greet.Name, Payload: payload})
+	if err != nil {
+		t.Fatalf("runHandler() error = %v", err)
+	}
+
+	if got != "alice" {
+		t.Errorf("handler got %q, want alice", got)
+	}
+
+	err = q.runHandler(database.Job{Kind: greet.Name, Payload: json.RawMessage(`"alice"`)})
+	if err == nil {
+		t.Errorf("runHandler() accepted a malformed payload")
+	}
+
+	err = q.runHandler(database.Job{Kind: crash.Name, Payload: json.RawMessage(`{}`)})
+	if err == nil {
+		t.Errorf("runHandler() didn't turn a panic into an error")
+	}
+
+	err = q.runHandler(database.Job{Kind: "unknown", Payload: json.RawMessage(`{}`)})
+	if !errors.Is(err, ErrNoHandler) {
+		t.Errorf("runHandler() error = %v, want %v", err, ErrNoHandler)
+	}
+}
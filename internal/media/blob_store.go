@@ -0,0 +1,70 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/settings"
+)
+
+const defaultMediaDir = "media"
+
+var ErrNotFound = errors.New("blob not found")
+
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// BlobStore keeps uploaded media. Keys are slash separated paths generated by
+// the server, objects are never overwritten once written. Put reads the body
+// from its current offset to the end and may read it more than once, so big
+// objects can be stored from a file without holding them in memory.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, body io.ReadSeeker) error
+	Get(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func NewBlobStore(s settings.Settings) (BlobStore, error) {
+	switch s.MediaStore {
+	case "", "local":
+		dir := s.MediaDir
+		if dir == "" {
+			dir = defaultMediaDir
+		}
+
+		return NewLocalStore(dir), nil
+	case "s3":
+		store, err := NewS3Store(s.S3Endpoint, s.S3Bucket, s.S3Region, s.S3AccessKey, s.S3SecretKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown media store: %s", s.MediaStore)
+	}
+}
+
+// DeleteAttachments removes the stored files of the given attachments. It
+// keeps going on failure and reports every error at the end.
+func DeleteAttachments(ctx context.Context, store BlobStore, attachments []database.Attachment) error {
+	var errs []error
+	for _, attachment := range attachments {
+		for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+			err := store.Delete(ctx, key)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("delete %s: %w", key, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const URLPrefix = "/app/media/"
+
+// Only attachments are public, other objects (data exports) are served by
+// their own handlers.
+const publicKeyPrefix = "attachments/"
+
+// Stored objects are never overwritten, so they can be cached forever.
+const cacheControl = "public, max-age=31536000, immutable"
+
+func URL(key string) string {
+	return URLPrefix + key
+}
+
+type FileServer struct {
+	store  BlobStore
+	logger *log.Logger
+}
+
+// NewFileServer serves objects from the store by key. It expects the URL
+// prefix to be stripped already.
+func NewFileServer(store BlobStore, logger *log.Logger) *FileServer {
+	return &FileServer{
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (fs *FileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if !strings.HasPrefix(key, publicKeyPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	obj, err := fs.store.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			http.NotFound(w, r)
+		default:
+			fs.logger.Printf("Error(FileServer): get blob (key=%s): %v", key, err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+
+		return
+	}
+	defer obj.Body.Close()
+
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+
+	if seeker, ok := obj.Body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, key, obj.ModTime, seeker)
+		return
+	}
+
+	if !obj.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", obj.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	if obj.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		io.Copy(w, obj.Body)
+	}
+}
@@ -0,0 +1,150 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	MaxUploadSize  = 5 << 20
+	maxImagePixels = 40_000_000
+	thumbnailSize  = 320
+	jpegQuality    = 90
+)
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+type imageFormat struct {
+	contentType string
+	ext         string
+}
+
+var allowedFormats = map[string]imageFormat{
+	"image/jpeg": {contentType: "image/jpeg", ext: ".jpg"},
+	"image/png":  {contentType: "image/png", ext: ".png"},
+	"image/gif":  {contentType: "image/gif", ext: ".gif"},
+}
+
+type processedImage struct {
+	format          imageFormat
+	data            []byte
+	thumbnailFormat imageFormat
+	thumbnail       []byte
+	width           int
+	height          int
+}
+
+// processImage checks the real type of an upload and re-encodes it. Decoding
+// and encoding again drops everything that is not pixel data, which is how
+// EXIF (and other metadata chunks) get stripped.
+func processImage(data []byte) (processedImage, error) {
+	format, ok := allowedFormats[http.DetectContentType(data)]
+	if !ok {
+		return processedImage{}, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return processedImage{}, fmt.Errorf("decode config: %w", err)
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return processedImage{}, fmt.Errorf("invalid image dimensions %dx%d", cfg.Width, cfg.Height)
+	}
+
+	var encoded bytes.Buffer
+	var first image.Image
+	switch format.contentType {
+	case "image/gif":
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return processedImage{}, fmt.Errorf("decode gif: %w", err)
+		}
+
+		err = gif.EncodeAll(&encoded, g)
+		if err != nil {
+			return processedImage{}, fmt.Errorf("encode gif: %w", err)
+		}
+
+		first = g.Image[0]
+	default:
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return processedImage{}, fmt.Errorf("decode image: %w", err)
+		}
+
+		err = encodeImage(&encoded, format, img)
+		if err != nil {
+			return processedImage{}, err
+		}
+
+		first = img
+	}
+
+	// Thumbnails are always a single still frame.
+	var thumb bytes.Buffer
+	thumbFormat := format
+	if thumbFormat.contentType == "image/gif" {
+		thumbFormat = allowedFormats["image/png"]
+	}
+
+	err = encodeImage(&thumb, thumbFormat, thumbnail(first))
+	if err != nil {
+		return processedImage{}, err
+	}
+
+	return processedImage{
+		format:          format,
+		data:            encoded.Bytes(),
+		thumbnailFormat: thumbFormat,
+		thumbnail:       thumb.Bytes(),
+		width:           cfg.Width,
+		height:          cfg.Height,
+	}, nil
+}
+
+func encodeImage(buf *bytes.Buffer, format imageFormat, img image.Image) error {
+	var err error
+	switch format.contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		err = png.Encode(buf, img)
+	default:
+		err = ErrUnsupportedType
+	}
+
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", format.contentType, err)
+	}
+
+	return nil
+}
+
+func thumbnail(src image.Image) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= thumbnailSize && h <= thumbnailSize {
+		return src
+	}
+
+	if w >= h {
+		h = max(1, h*thumbnailSize/w)
+		w = thumbnailSize
+	} else {
+		w = max(1, w*thumbnailSize/h)
+		h = thumbnailSize
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
@@ -0,0 +1,110 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	return img
+}
+
+// withExif splices an APP1 Exif segment right after the JPEG SOI marker.
+func withExif(t *testing.T, jpg []byte) []byte {
+	t.Helper()
+
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS 52.5200 N 13.4050 E")...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcessImage(t *testing.T) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, testImage(800, 400), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var pngBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, testImage(40, 30)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantErr     error
+		wantType    string
+		wantThumbW  int
+		wantThumbH  int
+		wantNoBytes []byte
+	}{
+		{
+			name:        "jpeg with exif",
+			data:        withExif(t, jpg.Bytes()),
+			wantType:    "image/jpeg",
+			wantThumbW:  320,
+			wantThumbH:  160,
+			wantNoBytes: []byte("Exif"),
+		},
+		{
+			name:       "small png",
+			data:       pngBuf.Bytes(),
+			wantType:   "image/png",
+			wantThumbW: 40,
+			wantThumbH: 30,
+		},
+		{
+			name:    "html pretending to be an image",
+			data:    []byte("<html><body>hi</body></html>"),
+			wantErr: ErrUnsupportedType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := processImage(tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("processImage() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("processImage() error = %v", err)
+			}
+
+			if img.format.contentType != tt.wantType {
+				t.Errorf("processImage() type = %s, want %s", img.format.contentType, tt.wantType)
+			}
+
+			if tt.wantNoBytes != nil && bytes.Contains(img.data, tt.wantNoBytes) {
+				t.Errorf("processImage() kept %q in the output", tt.wantNoBytes)
+			}
+
+			thumb, _, err := image.DecodeConfig(bytes.NewReader(img.thumbnail))
+			if err != nil {
+				t.Fatalf("decode thumbnail: %v", err)
+			}
+
+			if thumb.Width != tt.wantThumbW || thumb.Height != tt.wantThumbH {
+				t.Errorf("thumbnail = %dx%d, want %dx%d", thumb.Width, thumb.Height, tt.wantThumbW, tt.wantThumbH)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package media
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+// Room for the multipart envelope around the file itself.
+const multipartOverhead = 64 << 10
+
+type attachmentResponse struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type MediaHandler struct {
+	settings  settings.Settings
+	db        *sql.DB
+	dbQueries *database.Queries
+	store     BlobStore
+	logger    *log.Logger
+}
+
+func NewMediaHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, store BlobStore, logger *log.Logger) *MediaHandler {
+	return &MediaHandler{
+		settings:  s,
+		db:        db,
+		dbQueries: dbQueries,
+		store:     store,
+		logger:    logger,
+	}
+}
+
+func (h *MediaHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize+multipartOverhead)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			response.PayloadTooLarge(w)
+		default:
+			response.Invalid(w, "file", response.FieldRequired, "missing file")
+		}
+
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxUploadSize+1))
+	if err != nil {
+		response.Invalid(w, "file", response.FieldInvalid, "invalid file")
+		return
+	}
+
+	if len(data) > MaxUploadSize {
+		response.PayloadTooLarge(w)
+		return
+	}
+
+	img, err := processImage(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnsupportedType):
+			response.UnsupportedMediaType(w)
+		default:
+			response.Invalid(w, "file", response.FieldInvalid, "invalid image")
+		}
+
+		return
+	}
+
+	attachmentID := uuid.New()
+	storageKey := "attachments/" + attachmentID.String() + img.format.ext
+	thumbnailKey := "attachments/" + attachmentID.String() + "_thumb" + img.thumbnailFormat.ext
+
+	err = h.store.Put(r.Context(), storageKey, img.format.contentType, bytes.NewReader(img.data))
+	if err != nil {
+		h.logger.Printf("Error(UploadAttachment): store image (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	err = h.store.Put(r.Context(), thumbnailKey, img.thumbnailFormat.contentType, bytes.NewReader(img.thumbnail))
+	if err != nil {
+		h.logger.Printf("Error(UploadAttachment): store thumbnail (user_id=%s): %v", userID, err)
+		h.deleteBlobs(r, storageKey)
+		response.InternalServerError(w)
+		return
+	}
+
+	attachment, err := h.dbQueries.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+		ID:           attachmentID,
+		UserID:       userID,
+		ContentType:  img.format.contentType,
+		SizeBytes:    int64(len(img.data)),
+		Width:        int32(img.width),
+		Height:       int32(img.height),
+		StorageKey:   storageKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		h.logger.Printf("Error(UploadAttachment): db create attachment (user_id=%s): %v", userID, err)
+		h.deleteBlobs(r, storageKey, thumbnailKey)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, attachmentResponse{
+		ID:           attachment.ID.String(),
+		URL:          URL(attachment.StorageKey),
+		ThumbnailURL: URL(attachment.ThumbnailKey),
+		ContentType:  attachment.ContentType,
+		SizeBytes:    attachment.SizeBytes,
+		Width:        attachment.Width,
+		Height:       attachment.Height,
+		CreatedAt:    attachment.CreatedAt.Time,
+	})
+}
+
+func (h *MediaHandler) deleteBlobs(r *http.Request, keys ...string) {
+	for _, key := range keys {
+		err := h.store.Delete(r.Context(), key)
+		if err != nil {
+			h.logger.Printf("Error(MediaHandler): delete blob (key=%s): %v", key, err)
+		}
+	}
+}
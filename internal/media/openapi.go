@@ -0,0 +1,27 @@
+package media
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:     "POST /api/attachments",
+		Tag:         "chirps",
+		Summary:     "Upload an image",
+		Description: "JPEG, PNG or GIF. The attachment is added to a chirp by its ID.",
+		Auth:        openapi.AuthBearer,
+		Request: openapi.Schema{
+			"type":     "object",
+			"required": []string{"file"},
+			"properties": map[string]any{
+				"file": map[string]any{"type": "string", "contentMediaType": "application/octet-stream"},
+			},
+		},
+		RequestContentType: "multipart/form-data",
+		Responses:          []openapi.Response{{Status: http.StatusCreated, Description: "The attachment", Body: attachmentResponse{}}},
+		Errors:             []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType},
+	},
+}
@@ -0,0 +1,138 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// minioStub is a tiny in-memory stand-in for an S3 compatible server. It
+// re-signs every request with the shared secret to check the signature.
+type minioStub struct {
+	t       *testing.T
+	store   *S3Store
+	mu      sync.Mutex
+	objects map[string]stubObject
+}
+
+type stubObject struct {
+	contentType string
+	data        []byte
+}
+
+func (m *minioStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	got := r.Header.Get("Authorization")
+	check := r.Clone(context.Background())
+	check.URL.Host = r.Host
+	check.Header.Del("Authorization")
+	payloadHash := sha256.Sum256(body)
+	m.store.sign(check, hex.EncodeToString(payloadHash[:]))
+	if want := check.Header.Get("Authorization"); got != want {
+		m.t.Errorf("signature mismatch\n got: %s\nwant: %s", got, want)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		m.objects[r.URL.Path] = stubObject{contentType: r.Header.Get("Content-Type"), data: body}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		obj, ok := m.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+	case http.MethodDelete:
+		delete(m.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	stub := &minioStub{t: t, objects: make(map[string]stubObject)}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	store, err := NewS3Store(server.URL, "chirpy", "", "minioadmin", "minioadmin")
+	if err != nil {
+		t.Fatalf("NewS3Store() error = %v", err)
+	}
+	store.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	stub.store = store
+
+	ctx := context.Background()
+	data := []byte("not really a png")
+
+	err = store.Put(ctx, "attachments/a.png", "image/png", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	if _, ok := stub.objects["/chirpy/attachments/a.png"]; !ok {
+		t.Fatalf("Put() did not use path style addressing, objects = %v", stub.objects)
+	}
+
+	obj, err := store.Get(ctx, "attachments/a.png")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	got, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+
+	if !bytes.Equal(got, data) || obj.ContentType != "image/png" {
+		t.Errorf("Get() = %q (%s), want %q (image/png)", got, obj.ContentType, data)
+	}
+
+	err = store.Delete(ctx, "attachments/a.png")
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	_, err = store.Get(ctx, "attachments/a.png")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3StoreAuthorizationHeader(t *testing.T) {
+	store, err := NewS3Store("http://localhost:9000", "chirpy", "eu-west-1", "AKID", "secret")
+	if err != nil {
+		t.Fatalf("NewS3Store() error = %v", err)
+	}
+
+	req, err := store.newRequest(context.Background(), http.MethodPut, "a.jpg", "image/jpeg", bytes.NewReader([]byte("x")))
+	if err != nil {
+		t.Fatalf("newRequest() error = %v", err)
+	}
+
+	authHeader := req.Header.Get("Authorization")
+	for _, want := range []string{
+		"AWS4-HMAC-SHA256 Credential=AKID/",
+		"/eu-west-1/s3/aws4_request",
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date",
+	} {
+		if !strings.Contains(authHeader, want) {
+			t.Errorf("Authorization = %q, want it to contain %q", authHeader, want)
+		}
+	}
+}
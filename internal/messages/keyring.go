@@ -0,0 +1,108 @@
+package messages
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownKey = errors.New("unknown message key")
+
+// Keyring encrypts message bodies at rest with AES-256-GCM. New messages are
+// sealed with the primary key, the other keys are only kept around to open
+// messages sealed before a rotation.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring parses MESSAGE_KEYS: comma separated "<key id>:<base64 key>"
+// pairs, the first one is the primary key. Rotating means putting a new key
+// in front and keeping the old ones until Reseal is done with them.
+func NewKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{
+		keys: make(map[string]cipher.AEAD),
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid key entry %q, want <id>:<base64 key>", entry)
+		}
+
+		if _, ok := k.keys[id]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decode key %q: %w", id, err)
+		}
+
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q is %d bytes, want 32", id, len(key))
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+
+		if k.primary == "" {
+			k.primary = id
+		}
+		k.keys[id] = aead
+	}
+
+	if k.primary == "" {
+		return nil, errors.New("no message keys configured")
+	}
+
+	return k, nil
+}
+
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// Seal encrypts plaintext with the primary key. The additional data (the
+// message ID) binds the ciphertext to its row, so it can't be moved to
+// another message.
+func (k *Keyring) Seal(plaintext, additionalData []byte) (keyID string, nonce, ciphertext []byte, err error) {
+	aead := k.keys[k.primary]
+
+	nonce = make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	return k.primary, nonce, aead.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+func (k *Keyring) Open(keyID string, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
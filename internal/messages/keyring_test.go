@@ -0,0 +1,87 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		wantPrimary string
+		wantErr     bool
+	}{
+		{name: "Single key", spec: "k1:" + testKey(1), wantPrimary: "k1"},
+		{name: "First key is primary", spec: "k2:" + testKey(2) + ", k1:" + testKey(1), wantPrimary: "k2"},
+		{name: "Empty", spec: "", wantErr: true},
+		{name: "Missing id", spec: testKey(1), wantErr: true},
+		{name: "Short key", spec: "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "Duplicate id", spec: "k1:" + testKey(1) + ",k1:" + testKey(2), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeyring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && k.PrimaryKeyID() != tt.wantPrimary {
+				t.Errorf("PrimaryKeyID() = %q, want %q", k.PrimaryKeyID(), tt.wantPrimary)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, err := NewKeyring("k1:" + testKey(1))
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	keyID, nonce, ciphertext, err := old.Seal([]byte("hello"), []byte("message-1"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	if bytes.Contains(ciphertext, []byte("hello")) {
+		t.Fatalf("Seal() left the plaintext readable")
+	}
+
+	rotated, err := NewKeyring("k2:" + testKey(2) + ",k1:" + testKey(1))
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	plaintext, err := rotated.Open(keyID, nonce, ciphertext, []byte("message-1"))
+	if err != nil || string(plaintext) != "hello" {
+		t.Fatalf("Open() after rotation = %q, %v, want hello", plaintext, err)
+	}
+
+	if _, err := rotated.Open(keyID, nonce, ciphertext, []byte("message-2")); err == nil {
+		t.Errorf("Open() with other additional data succeeded")
+	}
+
+	newKeyID, _, _, err := rotated.Seal([]byte("hello"), nil)
+	if err != nil || newKeyID != "k2" {
+		t.Errorf("Seal() after rotation used %q (%v), want k2", newKeyID, err)
+	}
+
+	retired, err := NewKeyring("k2:" + testKey(2))
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	_, err = retired.Open(keyID, nonce, ciphertext, []byte("message-1"))
+	if !errors.Is(err, ErrUnknownKey) || !strings.Contains(err.Error(), "k1") {
+		t.Errorf("Open() with a retired key error = %v, want ErrUnknownKey", err)
+	}
+}
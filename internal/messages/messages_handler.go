@@ -0,0 +1,554 @@
+package messages
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/moderation"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+const (
+	maxParticipants = 8
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+type createConversationRequest struct {
+	ParticipantIDs []string `json:"participant_ids" validate:"required,uuid"`
+}
+
+type sendMessageRequest struct {
+	Body string `json:"body" validate:"required,max=1000"`
+}
+
+type participantResponse struct {
+	UserID     string     `json:"user_id"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type conversationResponse struct {
+	ID           string                `json:"id"`
+	Participants []participantResponse `json:"participants"`
+	UnreadCount  int32                 `json:"unread_count"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+}
+
+type messageResponse struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	SenderID       string    `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type messagesResponse struct {
+	Messages   []messageResponse `json:"messages"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type MessagesHandler struct {
+	settings  settings.Settings
+	db        *sql.DB
+	dbQueries *database.Queries
+	keyring   *Keyring
+	filter    *visibility.Filter
+	moderator *moderation.Moderator
+	logger    *log.Logger
+}
+
+func NewMessagesHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, keyring *Keyring, filter *visibility.Filter, moderator *moderation.Moderator, logger *log.Logger) *MessagesHandler {
+	return &MessagesHandler{
+		settings:  s,
+		db:        db,
+		dbQueries: dbQueries,
+		keyring:   keyring,
+		filter:    filter,
+		moderator: moderator,
+		logger:    logger,
+	}
+}
+
+func newParticipantResponses(participants []database.ConversationParticipant) []participantResponse {
+	resp := []participantResponse{}
+	for _, participant := range participants {
+		var lastReadAt *time.Time
+		if participant.LastReadAt.Valid {
+			lastReadAt = &participant.LastReadAt.Time
+		}
+
+		resp = append(resp, participantResponse{
+			UserID:     participant.UserID.String(),
+			LastReadAt: lastReadAt,
+		})
+	}
+
+	return resp
+}
+
+func (h *MessagesHandler) userID(r *http.Request) (uuid.UUID, error) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return auth.ValidateJWT(jwt, h.settings.JWTSecret)
+}
+
+// conversation resolves the {conversationID} path value for a participant.
+// Conversations the user isn't part of are reported as not found. It writes
+// the error response itself and reports ok=false in that case.
+func (h *MessagesHandler) conversation(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (uuid.UUID, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		response.Invalid(w, "conversationID", response.FieldInvalid, "invalid conversation id")
+		return uuid.UUID{}, false
+	}
+
+	isParticipant, err := h.dbQueries.IsConversationParticipant(r.Context(), database.IsConversationParticipantParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(MessagesHandler): db is participant (conversation_id=%s, user_id=%s): %v", conversationID, userID, err)
+		response.InternalServerError(w)
+		return uuid.UUID{}, false
+	}
+
+	if !isParticipant {
+		response.NotFound(w)
+		return uuid.UUID{}, false
+	}
+
+	return conversationID, true
+}
+
+func (h *MessagesHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.userID(r)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	conversations, err := h.dbQueries.GetConversationsForUser(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetConversations): db get conversations (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	conversationIDs := make([]uuid.UUID, 0, len(conversations))
+	for _, conversation := range conversations {
+		conversationIDs = append(conversationIDs, conversation.ID)
+	}
+
+	participants, err := h.dbQueries.GetConversationParticipants(r.Context(), conversationIDs)
+	if err != nil {
+		h.logger.Printf("Error(GetConversations): db get participants (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	byConversation := make(map[uuid.UUID][]database.ConversationParticipant)
+	for _, participant := range participants {
+		byConversation[participant.ConversationID] = append(byConversation[participant.ConversationID], participant)
+	}
+
+	resp := []conversationResponse{}
+	for _, conversation := range conversations {
+		resp = append(resp, conversationResponse{
+			ID:           conversation.ID.String(),
+			Participants: newParticipantResponses(byConversation[conversation.ID]),
+			UnreadCount:  conversation.UnreadCount,
+			CreatedAt:    conversation.CreatedAt,
+			UpdatedAt:    conversation.UpdatedAt,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// CreateConversation starts a conversation with the given users, or returns
+// the existing one if there already is a conversation with exactly them.
+func (h *MessagesHandler) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.userID(r)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	req, ok := request.DecodeJSON[createConversationRequest](w, r)
+	if !ok {
+		return
+	}
+
+	userIDs := []uuid.UUID{userID}
+	seen := map[uuid.UUID]bool{userID: true}
+	for _, s := range req.ParticipantIDs {
+		// Checked by the uuid rule.
+		participantID := uuid.MustParse(s)
+
+		if seen[participantID] {
+			continue
+		}
+		seen[participantID] = true
+		userIDs = append(userIDs, participantID)
+	}
+
+	if len(userIDs) < 2 {
+		response.Invalid(w, "participant_ids", response.FieldRequired, "at least one other participant is required")
+		return
+	}
+
+	if len(userIDs) > maxParticipants {
+		response.Invalid(w, "participant_ids", response.FieldTooMany, "too many participants")
+		return
+	}
+
+	for _, participantID := range userIDs[1:] {
+		exists, err := h.dbQueries.UserExists(r.Context(), participantID)
+		if err != nil {
+			h.logger.Printf("Error(CreateConversation): db user exists (user_id=%s): %v", participantID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		if !exists {
+			response.Invalid(w, "participant_ids", response.FieldUnknown, "unknown participant: "+participantID.String())
+			return
+		}
+
+		canInteract, err := h.filter.CanInteract(r.Context(), userID, participantID)
+		if err != nil {
+			h.logger.Printf("Error(CreateConversation): check block (user_id=%s, participant_id=%s): %v", userID, participantID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		if !canInteract {
+			response.Forbidden(w)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	conversationID, err := h.dbQueries.GetConversationByParticipants(r.Context(), userIDs)
+	if errors.Is(err, sql.ErrNoRows) {
+		status = http.StatusCreated
+		conversationID, err = h.createConversation(r.Context(), userIDs)
+	}
+	if err != nil {
+		h.logger.Printf("Error(CreateConversation): create conversation (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	conversations, err := h.dbQueries.GetConversationsForUser(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(CreateConversation): db get conversations (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	participants, err := h.dbQueries.GetConversationParticipants(r.Context(), []uuid.UUID{conversationID})
+	if err != nil {
+		h.logger.Printf("Error(CreateConversation): db get participants (conversation_id=%s): %v", conversationID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	for _, conversation := range conversations {
+		if conversation.ID == conversationID {
+			response.JSON(w, status, conversationResponse{
+				ID:           conversation.ID.String(),
+				Participants: newParticipantResponses(participants),
+				UnreadCount:  conversation.UnreadCount,
+				CreatedAt:    conversation.CreatedAt,
+				UpdatedAt:    conversation.UpdatedAt,
+			})
+			return
+		}
+	}
+
+	h.logger.Printf("Error(CreateConversation): conversation %s not listed for user %s", conversationID, userID)
+	response.InternalServerError(w)
+}
+
+func (h *MessagesHandler) createConversation(ctx context.Context, userIDs []uuid.UUID) (uuid.UUID, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	conversation, err := qtx.CreateConversation(ctx)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	for _, userID := range userIDs {
+		err = qtx.AddConversationParticipant(ctx, database.AddConversationParticipantParams{
+			ConversationID: conversation.ID,
+			UserID:         userID,
+		})
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+	}
+
+	return conversation.ID, tx.Commit()
+}
+
+func (h *MessagesHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.userID(r)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	conversationID, ok := h.conversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	req, ok := request.DecodeJSON[sendMessageRequest](w, r)
+	if !ok {
+		return
+	}
+
+	participants, err := h.dbQueries.GetConversationParticipants(r.Context(), []uuid.UUID{conversationID})
+	if err != nil {
+		h.logger.Printf("Error(SendMessage): db get participants (conversation_id=%s): %v", conversationID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	// Participants who blocked the sender (or got blocked) don't see the
+	// message, GetMessages filters it. There is no point in sending when
+	// nobody would.
+	reachable := false
+	for _, participant := range participants {
+		if participant.UserID == userID {
+			continue
+		}
+
+		canInteract, err := h.filter.CanInteract(r.Context(), userID, participant.UserID)
+		if err != nil {
+			h.logger.Printf("Error(SendMessage): check block (user_id=%s, participant_id=%s): %v", userID, participant.UserID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		if canInteract {
+			reachable = true
+			break
+		}
+	}
+
+	if !reachable {
+		response.Forbidden(w)
+		return
+	}
+
+	// Flags are for public content, nobody reviews private messages.
+	moderated, err := h.moderator.Check(req.Body)
+	if err != nil {
+		if errors.Is(err, moderation.ErrRejected) {
+			response.Error(w, http.StatusBadRequest, "message_rejected", "Message was rejected by moderation")
+			return
+		}
+
+		h.logger.Printf("Error(SendMessage): moderate (conversation_id=%s): %v", conversationID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	body := moderated.Text
+	messageID := uuid.New()
+	keyID, nonce, ciphertext, err := h.keyring.Seal([]byte(body), messageID[:])
+	if err != nil {
+		h.logger.Printf("Error(SendMessage): seal message (conversation_id=%s): %v", conversationID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.logger.Printf("Error(SendMessage): begin tx: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+	message, err := qtx.CreateMessage(r.Context(), database.CreateMessageParams{
+		ID:             messageID,
+		ConversationID: conversationID,
+		SenderID:       userID,
+		KeyID:          keyID,
+		Nonce:          nonce,
+		Ciphertext:     ciphertext,
+	})
+	if err != nil {
+		h.logger.Printf("Error(SendMessage): db create message (conversation_id=%s): %v", conversationID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	err = qtx.TouchConversation(r.Context(), conversationID)
+	if err != nil {
+		h.logger.Printf("Error(SendMessage): db touch conversation (conversation_id=%s): %v", conversationID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	// Whoever writes has read everything before.
+	err = qtx.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(SendMessage): db mark read (conversation_id=%s): %v", conversationID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		h.logger.Printf("Error(SendMessage): commit tx: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, messageResponse{
+		ID:             message.ID.String(),
+		ConversationID: message.ConversationID.String(),
+		SenderID:       message.SenderID.String(),
+		Body:           body,
+		CreatedAt:      message.CreatedAt,
+	})
+}
+
+// GetMessages pages through a conversation, newest first. The cursor is the
+// creation time of the last message of the previous page.
+func (h *MessagesHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.userID(r)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	conversationID, ok := h.conversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultPageSize
+	if s := query.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			response.Invalid(w, "limit", response.FieldInvalid, "invalid limit")
+			return
+		}
+	}
+
+	var before sql.NullTime
+	if s := query.Get("cursor"); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			response.Invalid(w, "cursor", response.FieldInvalid, "invalid cursor")
+			return
+		}
+		before = sql.NullTime{Time: t, Valid: true}
+	}
+
+	rules, err := h.filter.ForViewer(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		h.logger.Printf("Error(GetMessages): visibility rules (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	messages, err := h.dbQueries.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: conversationID,
+		Before:         before,
+		MaxMessages:    int32(limit),
+	})
+	if err != nil {
+		h.logger.Printf("Error(GetMessages): db get messages (conversation_id=%s): %v", conversationID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := messagesResponse{
+		Messages: []messageResponse{},
+	}
+	for _, message := range messages {
+		if !rules.CanSee(message.SenderID) {
+			continue
+		}
+
+		body, err := h.keyring.Open(message.KeyID, message.Nonce, message.Ciphertext, message.ID[:])
+		if err != nil {
+			h.logger.Printf("Error(GetMessages): open message (message_id=%s, key_id=%s): %v", message.ID, message.KeyID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		resp.Messages = append(resp.Messages, messageResponse{
+			ID:             message.ID.String(),
+			ConversationID: message.ConversationID.String(),
+			SenderID:       message.SenderID.String(),
+			Body:           string(body),
+			CreatedAt:      message.CreatedAt,
+		})
+	}
+
+	// Paging goes by what was read, not by what was shown.
+	if len(messages) == limit {
+		resp.NextCursor = messages[len(messages)-1].CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// MarkRead moves the caller's read receipt to now, other participants see it
+// as last_read_at.
+func (h *MessagesHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.userID(r)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	conversationID, ok := h.conversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	err = h.dbQueries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(MarkRead): db mark read (conversation_id=%s, user_id=%s): %v", conversationID, userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
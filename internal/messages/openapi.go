@@ -0,0 +1,62 @@
+package messages
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var pageParams = []openapi.Param{
+	openapi.Query("limit", "Page size, 50 by default and 100 at most."),
+	openapi.Query("cursor", "next_cursor of the previous page."),
+}
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:   "GET /api/conversations",
+		Tag:       "messages",
+		Summary:   "List conversations",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The conversations, latest activity first", Body: []conversationResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		Pattern:     "POST /api/conversations",
+		Tag:         "messages",
+		Summary:     "Start a conversation",
+		Description: "Returns the existing conversation if there is one with exactly these participants.",
+		Auth:        openapi.AuthBearer,
+		Request:     createConversationRequest{},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "The existing conversation", Body: conversationResponse{}},
+			{Status: http.StatusCreated, Description: "The new conversation", Body: conversationResponse{}},
+		},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		Pattern:   "GET /api/conversations/{conversationID}/messages",
+		Tag:       "messages",
+		Summary:   "List messages",
+		Auth:      openapi.AuthBearer,
+		Params:    pageParams,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "A page of messages, newest first", Body: messagesResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:   "POST /api/conversations/{conversationID}/messages",
+		Tag:       "messages",
+		Summary:   "Send a message",
+		Auth:      openapi.AuthBearer,
+		Request:   sendMessageRequest{},
+		Responses: []openapi.Response{{Status: http.StatusCreated, Description: "The message", Body: messageResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Pattern:   "POST /api/conversations/{conversationID}/read",
+		Tag:       "messages",
+		Summary:   "Mark a conversation read",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Read up to now"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+}
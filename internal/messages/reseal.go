@@ -0,0 +1,115 @@
+package messages
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/jobs"
+	"github.com/google/uuid"
+)
+
+const resealBatchSize = 100
+
+// ResealJob reseals messages under the primary key in its payload. It is
+// enqueued on startup with the key ID as unique key, so instances starting
+// together share one run.
+var ResealJob = jobs.Kind[ResealPayload]{Name: "messages.reseal", Timeout: 30 * time.Minute}
+
+type ResealPayload struct {
+	KeyID string `json:"key_id"`
+}
+
+// Resealer runs ResealJob.
+type Resealer struct {
+	dbQueries *database.Queries
+	keyring   *Keyring
+	logger    *log.Logger
+}
+
+func NewResealer(dbQueries *database.Queries, keyring *Keyring, logger *log.Logger) *Resealer {
+	return &Resealer{
+		dbQueries: dbQueries,
+		keyring:   keyring,
+		logger:    logger,
+	}
+}
+
+// EnqueueReseal asks for messages to be moved off retired keys. Without a
+// rotation the job finds nothing to do.
+func EnqueueReseal(ctx context.Context, dbQueries *database.Queries, keyring *Keyring) error {
+	keyID := keyring.PrimaryKeyID()
+	_, err := jobs.Enqueue(ctx, dbQueries, ResealJob, ResealPayload{KeyID: keyID}, jobs.Options{UniqueKey: keyID})
+	return err
+}
+
+// Run reseals with the primary key of the payload. During a rolling deploy
+// an instance still on the old keys may claim the job, it fails the
+// attempt instead of sealing with its own primary key, and the job is
+// retried later.
+func (r *Resealer) Run(ctx context.Context, payload ResealPayload) error {
+	if payload.KeyID != r.keyring.PrimaryKeyID() {
+		return fmt.Errorf("primary key is %q, the job is for %q", r.keyring.PrimaryKeyID(), payload.KeyID)
+	}
+
+	resealed, err := Reseal(ctx, r.dbQueries, r.keyring, r.logger)
+	if resealed > 0 {
+		r.logger.Printf("Resealed %d messages with key %s", resealed, payload.KeyID)
+	}
+
+	return err
+}
+
+// Reseal re-encrypts messages sealed with an older key under the primary key,
+// after which the old key can be dropped from MESSAGE_KEYS. Messages whose
+// key is no longer configured are logged and left alone.
+func Reseal(ctx context.Context, dbQueries *database.Queries, keyring *Keyring, logger *log.Logger) (int, error) {
+	resealed := 0
+	skipIDs := []uuid.UUID{}
+
+	for {
+		batch, err := dbQueries.GetMessagesNotSealedWith(ctx, database.GetMessagesNotSealedWithParams{
+			KeyID:       keyring.PrimaryKeyID(),
+			SkipIds:     skipIDs,
+			MaxMessages: resealBatchSize,
+		})
+		if err != nil {
+			return resealed, fmt.Errorf("get messages: %w", err)
+		}
+
+		if len(batch) == 0 {
+			return resealed, nil
+		}
+
+		for _, message := range batch {
+			plaintext, err := keyring.Open(message.KeyID, message.Nonce, message.Ciphertext, message.ID[:])
+			if err != nil {
+				logger.Printf("Error(Reseal): open message (message_id=%s, key_id=%s): %v", message.ID, message.KeyID, err)
+				skipIDs = append(skipIDs, message.ID)
+				continue
+			}
+
+			keyID, nonce, ciphertext, err := keyring.Seal(plaintext, message.ID[:])
+			if err != nil {
+				return resealed, fmt.Errorf("seal message (message_id=%s): %w", message.ID, err)
+			}
+
+			// Matching on the old key keeps a concurrent reseal from
+			// overwriting a row twice.
+			rowsAffected, err := dbQueries.ResealMessage(ctx, database.ResealMessageParams{
+				ID:         message.ID,
+				OldKeyID:   message.KeyID,
+				KeyID:      keyID,
+				Nonce:      nonce,
+				Ciphertext: ciphertext,
+			})
+			if err != nil {
+				return resealed, fmt.Errorf("update message (message_id=%s): %w", message.ID, err)
+			}
+
+			resealed += int(rowsAffected)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package messages
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestResealerOtherPrimaryKey(t *testing.T) {
+	// An instance not rotated yet, it must not touch the database.
+	old, err := NewKeyring("k1:" + testKey(1))
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	r := NewResealer(nil, old, log.New(io.Discard, "", 0))
+	err = r.Run(context.Background(), ResealPayload{KeyID: "k2"})
+	if err == nil {
+		t.Error("Run() error = nil, want the attempt failed for a retry")
+	}
+}
@@ -3,8 +3,13 @@ package metrics
 import (
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/response"
 )
 
 const metricsTemplate = `
@@ -18,12 +23,43 @@ const metricsTemplate = `
 
 type Metrics struct {
 	fileServerHits atomic.Int32
-	logger         *log.Logger
+
+	outboxPending   atomic.Int64
+	outboxLag       atomic.Int64
+	outboxPublished atomic.Int64
+
+	refreshTokensPurged atomic.Int64
+	refreshTokensLastGC atomic.Int64
+
+	apiMu sync.Mutex
+	// Requests by API version, and to deprecated routes by pattern.
+	apiRequests        map[string]int64
+	deprecatedRequests map[string]int64
+
+	logger *log.Logger
+}
+
+type outboxMetricsResponse struct {
+	Pending    int64   `json:"pending"`
+	LagSeconds float64 `json:"lag_seconds"`
+	Published  int64   `json:"published"`
+}
+
+type refreshTokenMetricsResponse struct {
+	Purged    int64      `json:"purged"`
+	LastRunAt *time.Time `json:"last_run_at"`
+}
+
+type apiVersionMetricsResponse struct {
+	Requests   map[string]int64 `json:"requests"`
+	Deprecated map[string]int64 `json:"deprecated"`
 }
 
 func NewMetrics(logger *log.Logger) *Metrics {
 	return &Metrics{
-		logger: logger,
+		apiRequests:        make(map[string]int64),
+		deprecatedRequests: make(map[string]int64),
+		logger:             logger,
 	}
 }
 
@@ -45,3 +81,75 @@ func (m *Metrics) ServerHitCounter(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// SetOutboxLag records how many outbox events wait to be relayed and how
+// long the oldest of them has.
+func (m *Metrics) SetOutboxLag(pending int64, lag time.Duration) {
+	if pending == 0 {
+		lag = 0
+	}
+
+	m.outboxPending.Store(pending)
+	m.outboxLag.Store(int64(lag))
+}
+
+// AddOutboxPublished counts events this instance relayed.
+func (m *Metrics) AddOutboxPublished(n int) {
+	m.outboxPublished.Add(int64(n))
+}
+
+func (m *Metrics) GetOutboxMetrics(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, outboxMetricsResponse{
+		Pending:    m.outboxPending.Load(),
+		LagSeconds: time.Duration(m.outboxLag.Load()).Seconds(),
+		Published:  m.outboxPublished.Load(),
+	})
+}
+
+// AddRefreshTokensPurged counts refresh tokens a garbage collection run on
+// this instance removed, n may be 0.
+func (m *Metrics) AddRefreshTokensPurged(n int64, at time.Time) {
+	m.refreshTokensPurged.Add(n)
+	m.refreshTokensLastGC.Store(at.UnixNano())
+}
+
+func (m *Metrics) GetRefreshTokenMetrics(w http.ResponseWriter, r *http.Request) {
+	resp := refreshTokenMetricsResponse{
+		Purged: m.refreshTokensPurged.Load(),
+	}
+
+	if lastRun := m.refreshTokensLastGC.Load(); lastRun != 0 {
+		t := time.Unix(0, lastRun).UTC()
+		resp.LastRunAt = &t
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// AddAPIRequest counts a request to a route of the API version.
+func (m *Metrics) AddAPIRequest(version string) {
+	m.apiMu.Lock()
+	defer m.apiMu.Unlock()
+
+	m.apiRequests[version]++
+}
+
+// AddDeprecatedRequest counts a request to a deprecated route, to tell who
+// still needs it before the sunset.
+func (m *Metrics) AddDeprecatedRequest(pattern string) {
+	m.apiMu.Lock()
+	defer m.apiMu.Unlock()
+
+	m.deprecatedRequests[pattern]++
+}
+
+func (m *Metrics) GetAPIVersionMetrics(w http.ResponseWriter, r *http.Request) {
+	m.apiMu.Lock()
+	resp := apiVersionMetricsResponse{
+		Requests:   maps.Clone(m.apiRequests),
+		Deprecated: maps.Clone(m.deprecatedRequests),
+	}
+	m.apiMu.Unlock()
+
+	response.JSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	notifyChannel        = "moderation_rules"
+	minReconnectInterval = 1 * time.Second
+	maxReconnectInterval = 1 * time.Minute
+	listenerPingInterval = 90 * time.Second
+	reloadTimeout        = 10 * time.Second
+)
+
+// Listener reloads the rules whenever any instance changes them, so edits
+// apply everywhere without a restart.
+type Listener struct {
+	listener  *pq.Listener
+	moderator *Moderator
+	logger    *log.Logger
+	done      chan struct{}
+}
+
+func NewListener(dbURL string, moderator *Moderator, logger *log.Logger) (*Listener, error) {
+	l := &Listener{
+		moderator: moderator,
+		logger:    logger,
+		done:      make(chan struct{}),
+	}
+
+	l.listener = pq.NewListener(dbURL, minReconnectInterval, maxReconnectInterval, l.reportProblem)
+	err := l.listener.Listen(notifyChannel)
+	if err != nil {
+		l.listener.Close()
+		return nil, fmt.Errorf("listen %s: %w", notifyChannel, err)
+	}
+
+	go l.run()
+	return l, nil
+}
+
+func (l *Listener) run() {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case _, ok := <-l.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// A nil notification means the connection was re-established
+			// and a change may have been missed, reloading covers both.
+			l.reload()
+		case <-ticker.C:
+			go l.listener.Ping()
+		}
+	}
+}
+
+func (l *Listener) reload() {
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+
+	err := l.moderator.Reload(ctx)
+	if err != nil {
+		l.logger.Printf("Error(Listener): reload moderation rules: %v", err)
+	}
+}
+
+func (l *Listener) reportProblem(ev pq.ListenerEventType, err error) {
+	if err != nil {
+		l.logger.Printf("Error(Listener): %v", err)
+	}
+}
+
+func (l *Listener) Close() error {
+	close(l.done)
+	return l.listener.Close()
+}
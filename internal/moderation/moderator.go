@@ -0,0 +1,57 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/absurek/go-http-servers/internal/database"
+)
+
+// Moderator holds the pipeline built from the rules in the database. Reload
+// swaps it atomically, checks in flight finish with the rules they started
+// with.
+type Moderator struct {
+	dbQueries *database.Queries
+	pipeline  atomic.Pointer[Pipeline]
+}
+
+func NewModerator(dbQueries *database.Queries) *Moderator {
+	m := &Moderator{
+		dbQueries: dbQueries,
+	}
+	m.pipeline.Store(NewPipeline())
+
+	return m
+}
+
+func (m *Moderator) Reload(ctx context.Context) error {
+	rows, err := m.dbQueries.GetModerationRules(ctx)
+	if err != nil {
+		return fmt.Errorf("get moderation rules: %w", err)
+	}
+
+	rules := make([]Rule, 0, len(rows))
+	for _, row := range rows {
+		rules = append(rules, Rule{
+			ID:      row.ID,
+			Kind:    row.Kind,
+			Pattern: row.Pattern,
+			Action:  row.Action,
+		})
+	}
+
+	pipeline, err := NewRulePipeline(rules)
+	if err != nil {
+		return err
+	}
+
+	m.pipeline.Store(pipeline)
+	return nil
+}
+
+// Check moderates a text with the current rules. Rejected texts are reported
+// with ErrRejected.
+func (m *Moderator) Check(text string) (Result, error) {
+	return m.pipeline.Load().Check(text)
+}
@@ -0,0 +1,138 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables folds look-alike letters from other scripts onto Latin ones.
+// It only covers the lower case letters people actually use to dodge word
+// filters, not the full Unicode confusables table.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'ë', 'к': 'k', 'м': 'm', 'н': 'h',
+	'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's',
+	'і': 'i', 'ј': 'j', 'ԁ': 'd', 'ɡ': 'g', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+	// Latin variants NFKC leaves alone
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ħ': 'h', 'ŧ': 't',
+}
+
+// leetspeak folds digits and symbols used in place of letters.
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b',
+	'@': 'a', '$': 's', '|': 'l', '€': 'e',
+}
+
+// Text is a body in normalized form that remembers where every normalized
+// byte came from, so matches on the normalized text can be masked in the
+// original.
+type Text struct {
+	Original   string
+	Normalized string
+
+	// starts[i] and ends[i] is the span of Original that produced byte i of
+	// Normalized.
+	starts []int
+	ends   []int
+}
+
+// fold normalizes one segment, a character with its combining marks:
+// compatibility composition (NFKC, so "ｋ" and "ﬁ" become "k" and "fi" and
+// "e" with a combining acute "é"), lower cased, then confusables and
+// leetspeak folded. Folding can leave a Latin letter before marks that were
+// on a look-alike, so the segment is composed again. Accented letters stay
+// accented, "café" and "cafe" are different words. Marks NFKC can't compose
+// onto their letter are dropped, stacking them is how zalgo text dodges
+// word filters.
+func fold(segment string) string {
+	folded := []rune(norm.NFKC.String(segment))
+	for i, r := range folded {
+		r = unicode.ToLower(r)
+		if c, ok := confusables[r]; ok {
+			r = c
+		} else if c, ok := leetspeak[r]; ok {
+			r = c
+		}
+
+		folded[i] = r
+	}
+
+	var b strings.Builder
+	for _, r := range norm.NFKC.String(string(folded)) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+func Normalize(s string) *Text {
+	t := &Text{Original: s}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		size := norm.NFKC.NextBoundaryInString(s[i:], true)
+		if size <= 0 {
+			size = len(s) - i
+		}
+
+		folded := fold(s[i : i+size])
+		if folded == "" {
+			// Stray combining marks belong to the previous character.
+			if len(t.ends) > 0 {
+				t.ends[len(t.ends)-1] = i + size
+			}
+		} else {
+			b.WriteString(folded)
+			for range len(folded) {
+				t.starts = append(t.starts, i)
+				t.ends = append(t.ends, i+size)
+			}
+		}
+
+		i += size
+	}
+	t.Normalized = b.String()
+
+	return t
+}
+
+// span maps a byte range of Normalized back to Original.
+func (t *Text) span(start, end int) (int, int) {
+	return t.starts[start], t.ends[end-1]
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// words returns the byte ranges of the words in Normalized.
+func (t *Text) words() [][2]int {
+	var words [][2]int
+	start := -1
+	for i, r := range t.Normalized {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 {
+			words = append(words, [2]int{start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, [2]int{start, len(t.Normalized)})
+	}
+
+	return words
+}
@@ -0,0 +1,268 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	KindWord  = "word"
+	KindRegex = "regex"
+
+	ActionMask   = "mask"
+	ActionReject = "reject"
+	ActionFlag   = "flag"
+
+	mask = "****"
+)
+
+var (
+	Kinds   = []string{KindWord, KindRegex}
+	Actions = []string{ActionMask, ActionReject, ActionFlag}
+
+	ErrRejected    = errors.New("rejected by moderation")
+	ErrInvalidRule = errors.New("invalid moderation rule")
+)
+
+type Rule struct {
+	ID      uuid.UUID
+	Kind    string
+	Pattern string
+	Action  string
+}
+
+// Match is a rule hit, Start and End are byte offsets into the original text.
+type Match struct {
+	Rule  Rule
+	Start int
+	End   int
+}
+
+// Filter is one step of the pipeline. It looks at the normalized text and
+// reports what it found, acting on it is up to the pipeline.
+type Filter interface {
+	Matches(t *Text) []Match
+}
+
+// WordFilter matches whole words of the normalized text against a list, so
+// "Kerfuffle!", "kеrfufflе" (Cyrillic e) and "k3rfuffl3" are all caught.
+type WordFilter struct {
+	words map[string]Rule
+}
+
+func NewWordFilter(rules []Rule) *WordFilter {
+	f := &WordFilter{
+		words: make(map[string]Rule),
+	}
+
+	for _, rule := range rules {
+		word := Normalize(rule.Pattern).Normalized
+		if existing, ok := f.words[word]; ok && severity(existing.Action) >= severity(rule.Action) {
+			continue
+		}
+		f.words[word] = rule
+	}
+
+	return f
+}
+
+func (f *WordFilter) Matches(t *Text) []Match {
+	var matches []Match
+	for _, word := range t.words() {
+		rule, ok := f.words[t.Normalized[word[0]:word[1]]]
+		if !ok {
+			continue
+		}
+
+		start, end := t.span(word[0], word[1])
+		matches = append(matches, Match{Rule: rule, Start: start, End: end})
+	}
+
+	return matches
+}
+
+type regexRule struct {
+	rule    Rule
+	pattern *regexp.Regexp
+}
+
+// RegexFilter runs regular expressions against the normalized text, which is
+// lower case and folded, so patterns should be written for that form.
+type RegexFilter struct {
+	rules []regexRule
+}
+
+func NewRegexFilter(rules []Rule) (*RegexFilter, error) {
+	f := &RegexFilter{}
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %s: %v", ErrInvalidRule, rule.ID, err)
+		}
+
+		f.rules = append(f.rules, regexRule{rule: rule, pattern: pattern})
+	}
+
+	return f, nil
+}
+
+func (f *RegexFilter) Matches(t *Text) []Match {
+	var matches []Match
+	for _, r := range f.rules {
+		for _, loc := range r.pattern.FindAllStringIndex(t.Normalized, -1) {
+			// Empty matches have nothing to mask or report.
+			if loc[0] == loc[1] {
+				continue
+			}
+
+			start, end := t.span(loc[0], loc[1])
+			matches = append(matches, Match{Rule: r.rule, Start: start, End: end})
+		}
+	}
+
+	return matches
+}
+
+// Result is what is left of a text after moderation.
+type Result struct {
+	Text    string
+	Flagged []Rule
+}
+
+// Pipeline runs its filters in order over the normalized text. A reject
+// anywhere wins, otherwise masks are applied and flags are collected.
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{
+		filters: filters,
+	}
+}
+
+// NewRulePipeline builds the standard pipeline from stored rules: the word
+// list first, then the regular expressions.
+func NewRulePipeline(rules []Rule) (*Pipeline, error) {
+	var words, regexes []Rule
+	for _, rule := range rules {
+		err := ValidateRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+
+		switch rule.Kind {
+		case KindWord:
+			words = append(words, rule)
+		case KindRegex:
+			regexes = append(regexes, rule)
+		}
+	}
+
+	regexFilter, err := NewRegexFilter(regexes)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPipeline(NewWordFilter(words), regexFilter), nil
+}
+
+// ValidateRule checks a rule before it is stored, a broken rule would
+// otherwise keep every instance from loading the rules.
+func ValidateRule(rule Rule) error {
+	switch rule.Action {
+	case ActionMask, ActionReject, ActionFlag:
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidRule, rule.Action)
+	}
+
+	switch rule.Kind {
+	case KindWord:
+		word := Normalize(rule.Pattern).Normalized
+		if word == "" || strings.IndexFunc(word, func(r rune) bool { return !isWordRune(r) }) >= 0 {
+			return fmt.Errorf("%w: a word must be a single word", ErrInvalidRule)
+		}
+	case KindRegex:
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+
+		if pattern.MatchString("") {
+			return fmt.Errorf("%w: the pattern matches empty text", ErrInvalidRule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidRule, rule.Kind)
+	}
+
+	return nil
+}
+
+func severity(action string) int {
+	switch action {
+	case ActionReject:
+		return 2
+	case ActionMask:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (p *Pipeline) Check(text string) (Result, error) {
+	t := Normalize(text)
+
+	var masks []Match
+	var flagged []Rule
+	seenFlags := make(map[uuid.UUID]bool)
+	for _, filter := range p.filters {
+		for _, match := range filter.Matches(t) {
+			switch match.Rule.Action {
+			case ActionReject:
+				return Result{}, fmt.Errorf("%w: rule %s", ErrRejected, match.Rule.ID)
+			case ActionMask:
+				masks = append(masks, match)
+			case ActionFlag:
+				if !seenFlags[match.Rule.ID] {
+					seenFlags[match.Rule.ID] = true
+					flagged = append(flagged, match.Rule)
+				}
+			}
+		}
+	}
+
+	return Result{Text: applyMasks(text, masks), Flagged: flagged}, nil
+}
+
+// applyMasks replaces every masked span with "****", overlapping spans are
+// merged first.
+func applyMasks(text string, masks []Match) string {
+	if len(masks) == 0 {
+		return text
+	}
+
+	sort.Slice(masks, func(i, j int) bool {
+		return masks[i].Start < masks[j].Start
+	})
+
+	var b strings.Builder
+	pos := 0
+	for i := 0; i < len(masks); {
+		start, end := masks[i].Start, masks[i].End
+		for i++; i < len(masks) && masks[i].Start < end; i++ {
+			end = max(end, masks[i].End)
+		}
+
+		b.WriteString(text[pos:start])
+		b.WriteString(mask)
+		pos = end
+	}
+	b.WriteString(text[pos:])
+
+	return b.String()
+}
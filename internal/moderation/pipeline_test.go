@@ -0,0 +1,160 @@
+package moderation
+
+import (
+	"errors"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var testRules = []Rule{
+	{ID: uuid.New(), Kind: KindWord, Pattern: "kerfuffle", Action: ActionMask},
+	{ID: uuid.New(), Kind: KindWord, Pattern: "sharbert", Action: ActionMask},
+	{ID: uuid.New(), Kind: KindWord, Pattern: "fornax", Action: ActionMask},
+	{ID: uuid.New(), Kind: KindWord, Pattern: "spam", Action: ActionReject},
+	{ID: uuid.New(), Kind: KindWord, Pattern: "déjà", Action: ActionMask},
+	{ID: uuid.New(), Kind: KindRegex, Pattern: `buy\s+now`, Action: ActionFlag},
+}
+
+func testPipeline(t testing.TB) *Pipeline {
+	t.Helper()
+
+	p, err := NewRulePipeline(testRules)
+	if err != nil {
+		t.Fatalf("NewRulePipeline() error = %v", err)
+	}
+
+	return p
+}
+
+func TestPipelineCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		want        string
+		wantFlagged int
+		wantErr     error
+	}{
+		{name: "Clean", text: "I had something interesting for breakfast", want: "I had something interesting for breakfast"},
+		{name: "Plain word", text: "What a kerfuffle today", want: "What a **** today"},
+		{name: "Upper case", text: "What a Kerfuffle today", want: "What a **** today"},
+		{name: "Punctuation", text: "What a kerfuffle! Sharbert, fornax.", want: "What a ****! ****, ****."},
+		{name: "Leetspeak", text: "k3rfuffl3 and f0rn4x", want: "**** and ****"},
+		{name: "Cyrillic look-alike", text: "kеrfufflе", want: "****"},
+		{name: "Fullwidth", text: "ｋｅｒｆｕｆｆｌｅ", want: "****"},
+		{name: "Accents", text: "kérfüfflé time", want: "kérfüfflé time"},
+		{name: "Accented word", text: "DÉJÀ vu, deja vu", want: "**** vu, deja vu"},
+		{name: "Decomposed accents", text: "de\u0301ja\u0300 vu", want: "**** vu"},
+		{name: "Cyrillic look-alike with a mark", text: "dе\u0301jà vu", want: "**** vu"},
+		{name: "Combining marks", text: "k\u0334e\u0334rfuffle", want: "****"},
+		{name: "Longer word", text: "kerfuffles", want: "kerfuffles"},
+		{name: "Reject", text: "this is sp4m", wantErr: ErrRejected},
+		{name: "Flag", text: "Buy   NOW cheap", want: "Buy   NOW cheap", wantFlagged: 1},
+		{name: "Flag and mask", text: "buy now, kerfuffle", want: "buy now, ****", wantFlagged: 1},
+	}
+
+	p := testPipeline(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Check(tt.text)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got.Text != tt.want {
+				t.Errorf("Check() = %q, want %q", got.Text, tt.want)
+			}
+
+			if len(got.Flagged) != tt.wantFlagged {
+				t.Errorf("Check() flagged %d rules, want %d", len(got.Flagged), tt.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{name: "Word", rule: Rule{Kind: KindWord, Pattern: "fornax", Action: ActionMask}},
+		{name: "Regex", rule: Rule{Kind: KindRegex, Pattern: `fo+`, Action: ActionFlag}},
+		{name: "Two words", rule: Rule{Kind: KindWord, Pattern: "two words", Action: ActionMask}, wantErr: true},
+		{name: "Empty word", rule: Rule{Kind: KindWord, Pattern: "", Action: ActionMask}, wantErr: true},
+		{name: "Broken regex", rule: Rule{Kind: KindRegex, Pattern: `(`, Action: ActionMask}, wantErr: true},
+		{name: "Regex matching everything", rule: Rule{Kind: KindRegex, Pattern: `x*`, Action: ActionMask}, wantErr: true},
+		{name: "Unknown action", rule: Rule{Kind: KindWord, Pattern: "fornax", Action: "ban"}, wantErr: true},
+		{name: "Unknown kind", rule: Rule{Kind: "phrase", Pattern: "fornax", Action: ActionMask}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func FuzzNormalize(f *testing.F) {
+	for _, seed := range []string{"", "kerfuffle!", "ｋｅｒｆｕｆｆｌｅ", "kérfuffle", "́abc", "\xff\xfe", "ﬁne"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		n := Normalize(s)
+		if len(n.starts) != len(n.Normalized) || len(n.ends) != len(n.Normalized) {
+			t.Fatalf("offsets cover %d/%d bytes of %d", len(n.starts), len(n.ends), len(n.Normalized))
+		}
+
+		for i := range n.starts {
+			if n.starts[i] < 0 || n.starts[i] >= n.ends[i] || n.ends[i] > len(s) {
+				t.Fatalf("byte %d maps to invalid span [%d, %d) of %d", i, n.starts[i], n.ends[i], len(s))
+			}
+
+			if i > 0 && n.starts[i] < n.starts[i-1] {
+				t.Fatalf("spans go backwards at byte %d", i)
+			}
+		}
+	})
+}
+
+func FuzzPipelineCheck(f *testing.F) {
+	for _, seed := range []string{"", "kerfuffle", "What a k3rfuffl3!", "kеrfufflе fornax", "buy now", "\xffkerfuffle\xff", "kérfuffle kérfuffle"} {
+		f.Add(seed)
+	}
+
+	p := testPipeline(f)
+	f.Fuzz(func(t *testing.T, s string) {
+		result, err := p.Check(s)
+		if errors.Is(err, ErrRejected) {
+			return
+		}
+
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+
+		if utf8.ValidString(s) && !utf8.ValidString(result.Text) {
+			t.Fatalf("Check(%q) = %q, not valid UTF-8", s, result.Text)
+		}
+
+		// Masking must not leave a masked word behind, running the result
+		// through again changes nothing.
+		again, err := p.Check(result.Text)
+		if err != nil {
+			t.Fatalf("Check() of the result error = %v", err)
+		}
+
+		if again.Text != result.Text {
+			t.Fatalf("Check(%q) = %q, checking again gives %q", s, result.Text, again.Text)
+		}
+	})
+}
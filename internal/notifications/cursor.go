@@ -0,0 +1,59 @@
+package notifications
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// groupCursor is the position of the last group of a page. Groups of
+// different types or chirps can have the same latest_at, the type and chirp
+// break ties. ChirpID is the nil UUID for groups without a chirp.
+type groupCursor struct {
+	LatestAt time.Time
+	Type     string
+	ChirpID  uuid.UUID
+}
+
+// String encodes the cursor for clients, who should treat it as opaque.
+func (c groupCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.LatestAt.Format(time.RFC3339Nano) + "," + c.Type + "," + c.ChirpID.String()))
+}
+
+func parseGroupCursor(s string) (groupCursor, error) {
+	// Before cursors were opaque they were the latest_at of the last
+	// group. An empty type sorts before all others, so groups at exactly
+	// that time are left out as they used to be.
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return groupCursor{LatestAt: t}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return groupCursor{}, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ",")
+	if len(parts) != 3 {
+		return groupCursor{}, errInvalidCursor
+	}
+
+	var c groupCursor
+	c.LatestAt, err = time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return groupCursor{}, errInvalidCursor
+	}
+
+	c.Type = parts[1]
+	c.ChirpID, err = uuid.Parse(parts[2])
+	if err != nil {
+		return groupCursor{}, errInvalidCursor
+	}
+
+	return c, nil
+}
@@ -0,0 +1,331 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// A group lists the IDs of its latest notifications only, count has
+	// the total.
+	maxGroupIDs = 50
+)
+
+type notificationGroupResponse struct {
+	Type            string    `json:"type"`
+	ChirpID         *string   `json:"chirp_id"`
+	Summary         string    `json:"summary"`
+	Count           int32     `json:"count"`
+	ActorCount      int32     `json:"actor_count"`
+	RecentActorIDs  []string  `json:"recent_actor_ids"`
+	NotificationIDs []string  `json:"notification_ids"`
+	Read            bool      `json:"read"`
+	LatestAt        time.Time `json:"latest_at"`
+}
+
+type notificationsResponse struct {
+	Notifications []notificationGroupResponse `json:"notifications"`
+	UnreadCount   int64                       `json:"unread_count"`
+	NextCursor    string                      `json:"next_cursor,omitempty"`
+}
+
+type markReadRequest struct {
+	IDs []string `json:"ids" validate:"uuid"`
+	All bool     `json:"all"`
+}
+
+func (req markReadRequest) Validate() []response.FieldError {
+	if !req.All && len(req.IDs) == 0 {
+		return []response.FieldError{{Field: "ids", Code: response.FieldRequired, Message: "ids or all is required"}}
+	}
+
+	return nil
+}
+
+// preferences maps a notification type to whether it is muted.
+type preferences map[string]bool
+
+func (p preferences) Validate() []response.FieldError {
+	var errs []response.FieldError
+	for notificationType := range p {
+		if !slices.Contains(Types, notificationType) {
+			errs = append(errs, response.FieldError{
+				Field:   notificationType,
+				Code:    response.FieldUnknown,
+				Message: "unknown notification type: " + notificationType,
+			})
+		}
+	}
+
+	return errs
+}
+
+// store is the part of database.Queries the handler needs.
+type store interface {
+	GetNotificationGroups(ctx context.Context, arg database.GetNotificationGroupsParams) ([]database.GetNotificationGroupsRow, error)
+	CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error)
+	MarkNotificationsRead(ctx context.Context, arg database.MarkNotificationsReadParams) (int64, error)
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error)
+	UpsertNotificationPreferences(ctx context.Context, arg database.UpsertNotificationPreferencesParams) error
+}
+
+type NotificationsHandler struct {
+	settings settings.Settings
+	store    store
+	logger   *log.Logger
+}
+
+func NewNotificationsHandler(s settings.Settings, dbQueries *database.Queries, logger *log.Logger) *NotificationsHandler {
+	return &NotificationsHandler{
+		settings: s,
+		store:    dbQueries,
+		logger:   logger,
+	}
+}
+
+func summary(notificationType string, actorCount int32) string {
+	who := "Someone"
+	if actorCount > 1 {
+		who = fmt.Sprintf("%d people", actorCount)
+	}
+
+	switch notificationType {
+	case TypeMention:
+		return who + " mentioned you"
+	case TypeReply:
+		return who + " replied to your chirp"
+	case TypeLike:
+		return who + " liked your chirp"
+	case TypeRechirp:
+		return who + " rechirped your chirp"
+	case TypeFollow:
+		return who + " followed you"
+	default:
+		return who + " interacted with you"
+	}
+}
+
+// GetNotifications lists the user's notifications grouped by type, chirp and
+// UTC day, the most recently active group first.
+func (h *NotificationsHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultPageSize
+	if s := query.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			response.Invalid(w, "limit", response.FieldInvalid, "invalid limit")
+			return
+		}
+	}
+
+	arg := database.GetNotificationGroupsParams{
+		UserID:     userID,
+		UnreadOnly: query.Get("unread") == "true",
+		MaxGroups:  int32(limit),
+		MaxIds:     maxGroupIDs,
+	}
+	if s := query.Get("cursor"); s != "" {
+		cursor, err := parseGroupCursor(s)
+		if err != nil {
+			response.Invalid(w, "cursor", response.FieldInvalid, "invalid cursor")
+			return
+		}
+
+		arg.BeforeAt = sql.NullTime{Time: cursor.LatestAt, Valid: true}
+		arg.BeforeType = sql.NullString{String: cursor.Type, Valid: true}
+		arg.BeforeChirpID = uuid.NullUUID{UUID: cursor.ChirpID, Valid: true}
+	}
+
+	groups, err := h.store.GetNotificationGroups(r.Context(), arg)
+	if err != nil {
+		h.logger.Printf("Error(GetNotifications): db get notification groups (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	unreadCount, err := h.store.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetNotifications): db count unread (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := notificationsResponse{
+		Notifications: []notificationGroupResponse{},
+		UnreadCount:   unreadCount,
+	}
+	for _, group := range groups {
+		var chirpID *string
+		if group.ChirpID.Valid {
+			s := group.ChirpID.UUID.String()
+			chirpID = &s
+		}
+
+		resp.Notifications = append(resp.Notifications, notificationGroupResponse{
+			Type:            group.Type,
+			ChirpID:         chirpID,
+			Summary:         summary(group.Type, group.ActorCount),
+			Count:           group.Count,
+			ActorCount:      group.ActorCount,
+			RecentActorIDs:  uuidStrings(group.RecentActorIds),
+			NotificationIDs: uuidStrings(group.NotificationIds),
+			Read:            group.Read,
+			LatestAt:        group.LatestAt,
+		})
+	}
+
+	if len(groups) == limit {
+		last := groups[len(groups)-1]
+		resp.NextCursor = groupCursor{LatestAt: last.LatestAt, Type: last.Type, ChirpID: last.ChirpID.UUID}.String()
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+func (h *NotificationsHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	req, ok := request.DecodeJSON[markReadRequest](w, r)
+	if !ok {
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(req.IDs))
+	for _, s := range req.IDs {
+		// Checked by the uuid rule.
+		ids = append(ids, uuid.MustParse(s))
+	}
+
+	_, err = h.store.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+		UserID: userID,
+		All:    req.All,
+		Ids:    ids,
+	})
+	if err != nil {
+		h.logger.Printf("Error(MarkRead): db mark notifications read (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *NotificationsHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	h.writePreferences(w, r, userID)
+}
+
+func (h *NotificationsHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	req, ok := request.DecodeJSON[preferences](w, r)
+	if !ok {
+		return
+	}
+
+	arg := database.UpsertNotificationPreferencesParams{
+		UserID: userID,
+		Types:  make([]string, 0, len(req)),
+		Muted:  make([]bool, 0, len(req)),
+	}
+	for notificationType, muted := range req {
+		arg.Types = append(arg.Types, notificationType)
+		arg.Muted = append(arg.Muted, muted)
+	}
+
+	err = h.store.UpsertNotificationPreferences(r.Context(), arg)
+	if err != nil {
+		h.logger.Printf("Error(UpdatePreferences): db upsert preferences (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	h.writePreferences(w, r, userID)
+}
+
+func (h *NotificationsHandler) writePreferences(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	stored, err := h.store.GetNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(writePreferences): db get preferences (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := make(preferences, len(Types))
+	for _, notificationType := range Types {
+		resp[notificationType] = false
+	}
+	for _, preference := range stored {
+		resp[preference.Type] = preference.Muted
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, id.String())
+	}
+
+	return strs
+}
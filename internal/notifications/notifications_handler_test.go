@@ -0,0 +1,404 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+// memStore implements store with the semantics of the queries.
+type memStore struct {
+	notifications []database.Notification
+	preferences   map[string]bool
+}
+
+type groupKey struct {
+	notificationType string
+	chirpID          uuid.UUID
+	day              time.Time
+}
+
+// compareGroups orders groups like the query, by (latest_at, type, chirp_id).
+func compareGroups(a, b groupCursor) int {
+	if c := a.LatestAt.Compare(b.LatestAt); c != 0 {
+		return c
+	}
+
+	if c := strings.Compare(a.Type, b.Type); c != 0 {
+		return c
+	}
+
+	return bytes.Compare(a.ChirpID[:], b.ChirpID[:])
+}
+
+func (s *memStore) GetNotificationGroups(ctx context.Context, arg database.GetNotificationGroupsParams) ([]database.GetNotificationGroupsRow, error) {
+	notifications := slices.Clone(s.notifications)
+	slices.SortFunc(notifications, func(a, b database.Notification) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	groups := make(map[groupKey]*database.GetNotificationGroupsRow)
+	actors := make(map[groupKey]map[uuid.UUID]bool)
+	unread := make(map[groupKey]bool)
+	for _, n := range notifications {
+		if n.UserID != arg.UserID {
+			continue
+		}
+
+		key := groupKey{n.Type, n.ChirpID.UUID, n.CreatedAt.UTC().Truncate(24 * time.Hour)}
+		group, ok := groups[key]
+		if !ok {
+			group = &database.GetNotificationGroupsRow{Type: n.Type, ChirpID: n.ChirpID, Read: true, LatestAt: n.CreatedAt}
+			groups[key] = group
+			actors[key] = make(map[uuid.UUID]bool)
+		}
+
+		group.Count++
+		if !actors[key][n.ActorID] {
+			actors[key][n.ActorID] = true
+			group.ActorCount++
+		}
+		if len(group.RecentActorIds) < 3 {
+			group.RecentActorIds = append(group.RecentActorIds, n.ActorID)
+		}
+		if len(group.NotificationIds) < int(arg.MaxIds) {
+			group.NotificationIds = append(group.NotificationIds, n.ID)
+		}
+		if !n.ReadAt.Valid {
+			group.Read = false
+			unread[key] = true
+		}
+	}
+
+	cursor := func(group *database.GetNotificationGroupsRow) groupCursor {
+		return groupCursor{LatestAt: group.LatestAt, Type: group.Type, ChirpID: group.ChirpID.UUID}
+	}
+
+	var rows []database.GetNotificationGroupsRow
+	for key, group := range groups {
+		if arg.UnreadOnly && !unread[key] {
+			continue
+		}
+
+		before := groupCursor{LatestAt: arg.BeforeAt.Time, Type: arg.BeforeType.String, ChirpID: arg.BeforeChirpID.UUID}
+		if arg.BeforeAt.Valid && compareGroups(cursor(group), before) >= 0 {
+			continue
+		}
+
+		rows = append(rows, *group)
+	}
+
+	slices.SortFunc(rows, func(a, b database.GetNotificationGroupsRow) int {
+		return compareGroups(cursor(&b), cursor(&a))
+	})
+
+	return rows[:min(len(rows), int(arg.MaxGroups))], nil
+}
+
+func (s *memStore) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	for _, n := range s.notifications {
+		if n.UserID == userID && !n.ReadAt.Valid {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *memStore) MarkNotificationsRead(ctx context.Context, arg database.MarkNotificationsReadParams) (int64, error) {
+	var marked int64
+	for i, n := range s.notifications {
+		if n.UserID == arg.UserID && !n.ReadAt.Valid && (arg.All || slices.Contains(arg.Ids, n.ID)) {
+			s.notifications[i].ReadAt.Time, s.notifications[i].ReadAt.Valid = time.Now(), true
+			marked++
+		}
+	}
+
+	return marked, nil
+}
+
+func (s *memStore) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	var preferences []database.NotificationPreference
+	for notificationType, muted := range s.preferences {
+		preferences = append(preferences, database.NotificationPreference{UserID: userID, Type: notificationType, Muted: muted})
+	}
+
+	return preferences, nil
+}
+
+func (s *memStore) UpsertNotificationPreferences(ctx context.Context, arg database.UpsertNotificationPreferencesParams) error {
+	for i, notificationType := range arg.Types {
+		s.preferences[notificationType] = arg.Muted[i]
+	}
+
+	return nil
+}
+
+type testUser struct {
+	id  uuid.UUID
+	jwt string
+}
+
+func newTestHandler(t *testing.T) (*NotificationsHandler, *memStore, testUser) {
+	t.Helper()
+
+	user := testUser{id: uuid.New()}
+	jwt, err := auth.MakeJWT(user.id, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.jwt = jwt
+
+	store := &memStore{preferences: make(map[string]bool)}
+	return &NotificationsHandler{
+		settings: settings.Settings{JWTSecret: "secret"},
+		store:    store,
+		logger:   log.New(io.Discard, "", 0),
+	}, store, user
+}
+
+func (s *memStore) add(userID uuid.UUID, notificationType string, chirpID uuid.NullUUID, createdAt time.Time) {
+	s.notifications = append(s.notifications, database.Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		ActorID:   uuid.New(),
+		Type:      notificationType,
+		ChirpID:   chirpID,
+		CreatedAt: createdAt,
+	})
+}
+
+func getNotifications(t *testing.T, h *NotificationsHandler, user testUser, query string) (notificationsResponse, int) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/api/notifications?"+query, nil)
+	r.Header.Set("Authorization", "Bearer "+user.jwt)
+	w := httptest.NewRecorder()
+	h.GetNotifications(w, r)
+
+	var resp notificationsResponse
+	if w.Code == http.StatusOK {
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		if err != nil {
+			t.Fatalf("unmarshal notifications: %v", err)
+		}
+	}
+
+	return resp, w.Code
+}
+
+func TestGetNotificationsGroups(t *testing.T) {
+	h, store, user := newTestHandler(t)
+
+	today := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	yesterday := today.Add(-24 * time.Hour)
+	chirp := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	other := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+
+	for i := range 60 {
+		store.add(user.id, TypeLike, chirp, today.Add(-time.Duration(i)*time.Minute))
+	}
+	store.add(user.id, TypeLike, chirp, yesterday)
+	store.add(user.id, TypeReply, chirp, today)
+	store.add(user.id, TypeLike, other, today)
+	store.add(user.id, TypeFollow, uuid.NullUUID{}, today)
+	store.add(user.id, TypeFollow, uuid.NullUUID{}, today.Add(-time.Hour))
+	store.add(user.id, TypeFollow, uuid.NullUUID{}, yesterday)
+	store.add(uuid.New(), TypeLike, chirp, today)
+
+	resp, status := getNotifications(t, h, user, "")
+	if status != http.StatusOK {
+		t.Fatalf("GetNotifications() status = %d, want %d", status, http.StatusOK)
+	}
+
+	type group struct {
+		notificationType string
+		chirpID          string
+		day              int
+	}
+	counts := make(map[group]int32)
+	for _, n := range resp.Notifications {
+		chirpID := ""
+		if n.ChirpID != nil {
+			chirpID = *n.ChirpID
+		}
+
+		key := group{n.Type, chirpID, n.LatestAt.Day()}
+		if _, ok := counts[key]; ok {
+			t.Errorf("group %v listed twice", key)
+		}
+		counts[key] = n.Count
+
+		if len(n.NotificationIDs) > maxGroupIDs {
+			t.Errorf("group %v has %d notification IDs, want at most %d", key, len(n.NotificationIDs), maxGroupIDs)
+		}
+	}
+
+	want := map[group]int32{
+		{TypeLike, chirp.UUID.String(), 19}:  60,
+		{TypeLike, chirp.UUID.String(), 18}:  1,
+		{TypeReply, chirp.UUID.String(), 19}: 1,
+		{TypeLike, other.UUID.String(), 19}:  1,
+		{TypeFollow, "", 19}:                 2,
+		{TypeFollow, "", 18}:                 1,
+	}
+	if len(counts) != len(want) {
+		t.Errorf("GetNotifications() groups = %v, want %v", counts, want)
+	}
+	for key, count := range want {
+		if counts[key] != count {
+			t.Errorf("group %v count = %d, want %d", key, counts[key], count)
+		}
+	}
+
+	if resp.UnreadCount != 66 {
+		t.Errorf("unread_count = %d, want 66", resp.UnreadCount)
+	}
+}
+
+func TestGetNotificationsPages(t *testing.T) {
+	h, store, user := newTestHandler(t)
+
+	// Groups active at the same time straddle page boundaries.
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	for i := range 4 {
+		for _, notificationType := range []string{TypeLike, TypeReply, TypeRechirp} {
+			store.add(user.id, notificationType, uuid.NullUUID{UUID: uuid.New(), Valid: true}, now.Add(-time.Duration(i)*time.Hour))
+		}
+		store.add(user.id, TypeFollow, uuid.NullUUID{}, now.Add(-time.Duration(i)*24*time.Hour))
+	}
+	total := 16
+
+	for _, limit := range []int{1, 2, 3, 5, 16, 20} {
+		seen := make(map[string]bool)
+		query := "limit=" + strconv.Itoa(limit)
+		for range total + 1 {
+			resp, status := getNotifications(t, h, user, query)
+			if status != http.StatusOK {
+				t.Fatalf("limit %d: GetNotifications() status = %d", limit, status)
+			}
+
+			for _, n := range resp.Notifications {
+				key := n.Type + " " + n.LatestAt.String()
+				if n.ChirpID != nil {
+					key += " " + *n.ChirpID
+				}
+				if seen[key] {
+					t.Errorf("limit %d: group %s listed twice", limit, key)
+				}
+				seen[key] = true
+			}
+
+			if resp.NextCursor == "" {
+				break
+			}
+			query = "limit=" + strconv.Itoa(limit) + "&cursor=" + resp.NextCursor
+		}
+
+		if len(seen) != total {
+			t.Errorf("limit %d: listed %d groups, want %d", limit, len(seen), total)
+		}
+	}
+
+	// Marking a group read leaves it out of the unread pages.
+	resp, _ := getNotifications(t, h, user, "limit=1")
+	ids := []uuid.UUID{}
+	for _, id := range resp.Notifications[0].NotificationIDs {
+		ids = append(ids, uuid.MustParse(id))
+	}
+	store.MarkNotificationsRead(context.Background(), database.MarkNotificationsReadParams{UserID: user.id, Ids: ids})
+
+	unread, _ := getNotifications(t, h, user, "unread=true&limit=100")
+	if len(unread.Notifications) != total-1 || unread.UnreadCount != int64(total-1) {
+		t.Errorf("unread = %d groups, unread_count %d, want %d", len(unread.Notifications), unread.UnreadCount, total-1)
+	}
+
+	for _, cursor := range []string{"nope", "bm9wZQ", groupCursor{LatestAt: now, Type: TypeLike}.String()[:10]} {
+		if _, status := getNotifications(t, h, user, "cursor="+cursor); status != http.StatusBadRequest {
+			t.Errorf("cursor %q status = %d, want %d", cursor, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestParseGroupCursor(t *testing.T) {
+	want := groupCursor{LatestAt: time.Date(2026, 10, 19, 12, 0, 0, 123456000, time.UTC), Type: TypeLike, ChirpID: uuid.New()}
+
+	got, err := parseGroupCursor(want.String())
+	if err != nil {
+		t.Fatalf("parseGroupCursor() error = %v", err)
+	}
+
+	if compareGroups(got, want) != 0 {
+		t.Errorf("parseGroupCursor() = %v, want %v", got, want)
+	}
+
+	// The latest_at cursors handed out before.
+	got, err = parseGroupCursor(want.LatestAt.Format(time.RFC3339Nano))
+	if err != nil || !got.LatestAt.Equal(want.LatestAt) || got.Type != "" {
+		t.Errorf("parseGroupCursor(timestamp) = %v, %v, want the time and no type", got, err)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	h, store, user := newTestHandler(t)
+
+	put := func(body string) (map[string]bool, int) {
+		r := httptest.NewRequest(http.MethodPut, "/api/notifications/preferences", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+user.jwt)
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.UpdatePreferences(w, r)
+
+		var resp map[string]bool
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp, w.Code
+	}
+
+	got, status := put(`{"like": true, "follow": true}`)
+	if status != http.StatusOK {
+		t.Fatalf("UpdatePreferences() status = %d, want %d", status, http.StatusOK)
+	}
+
+	for _, notificationType := range Types {
+		want := notificationType == TypeLike || notificationType == TypeFollow
+		if muted, ok := got[notificationType]; !ok || muted != want {
+			t.Errorf("%s muted = %v (listed %v), want %v", notificationType, muted, ok, want)
+		}
+	}
+
+	// Types left out keep their setting.
+	got, _ = put(`{"like": false}`)
+	if got[TypeLike] || !got[TypeFollow] {
+		t.Errorf("after unmuting likes = %v, want only follows muted", got)
+	}
+
+	_, status = put(`{"poke": true}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("unknown type status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if _, ok := store.preferences["poke"]; ok {
+		t.Error("unknown type was stored")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/notifications/preferences", nil)
+	w := httptest.NewRecorder()
+	h.GetPreferences(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GetPreferences() without a token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
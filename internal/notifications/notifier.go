@@ -0,0 +1,127 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+const (
+	TypeMention = "mention"
+	TypeReply   = "reply"
+	TypeLike    = "like"
+	TypeRechirp = "rechirp"
+	TypeFollow  = "follow"
+
+	queueSize     = 1024
+	createTimeout = 5 * time.Second
+)
+
+var Types = []string{TypeMention, TypeReply, TypeLike, TypeRechirp, TypeFollow}
+
+type Notification struct {
+	Type        string
+	RecipientID uuid.UUID
+	ActorID     uuid.UUID
+	ChirpID     uuid.NullUUID
+}
+
+// Notifier writes notifications in the background so producers (posting a
+// chirp, ...) don't wait on it. Blocked and muted actors as well as muted
+// types are filtered when writing.
+type Notifier struct {
+	dbQueries *database.Queries
+	filter    *visibility.Filter
+	logger    *log.Logger
+	queue     chan Notification
+	wg        sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewNotifier(dbQueries *database.Queries, filter *visibility.Filter, logger *log.Logger) *Notifier {
+	n := &Notifier{
+		dbQueries: dbQueries,
+		filter:    filter,
+		logger:    logger,
+		queue:     make(chan Notification, queueSize),
+	}
+
+	n.wg.Add(1)
+	go n.run()
+
+	return n
+}
+
+// Notify queues a notification. It never blocks, when the queue is full the
+// notification is dropped and logged.
+func (n *Notifier) Notify(notification Notification) {
+	if notification.RecipientID == notification.ActorID {
+		return
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.closed {
+		return
+	}
+
+	select {
+	case n.queue <- notification:
+	default:
+		n.logger.Printf("Error(Notifier): queue full, dropping %s notification (user_id=%s)", notification.Type, notification.RecipientID)
+	}
+}
+
+// Close stops accepting notifications and waits for the queued ones to be
+// written.
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	n.wg.Wait()
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+
+	for notification := range n.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), createTimeout)
+		err := n.create(ctx, notification)
+		cancel()
+
+		if err != nil {
+			n.logger.Printf("Error(Notifier): create %s notification (user_id=%s): %v", notification.Type, notification.RecipientID, err)
+		}
+	}
+}
+
+func (n *Notifier) create(ctx context.Context, notification Notification) error {
+	rules, err := n.filter.ForViewer(ctx, uuid.NullUUID{UUID: notification.RecipientID, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	if !rules.ShouldNotify(notification.ActorID) {
+		return nil
+	}
+
+	_, err = n.dbQueries.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  notification.RecipientID,
+		ActorID: notification.ActorID,
+		Type:    notification.Type,
+		ChirpID: notification.ChirpID,
+	})
+	return err
+}
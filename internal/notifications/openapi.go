@@ -0,0 +1,51 @@
+package notifications
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern: "GET /api/notifications",
+		Tag:     "notifications",
+		Summary: "List notifications",
+		Description: "Notifications are grouped by type and chirp within a UTC day, the most recently active group first. " +
+			"notification_ids has the IDs of the latest 50 notifications of a group, count has how many there are.",
+		Auth: openapi.AuthBearer,
+		Params: []openapi.Param{
+			openapi.Query("limit", "Page size, 20 by default and 100 at most."),
+			openapi.Query("cursor", "next_cursor of the previous page."),
+		},
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "A page of grouped notifications", Body: notificationsResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "POST /api/notifications/read",
+		Tag:       "notifications",
+		Summary:   "Mark notifications read",
+		Auth:      openapi.AuthBearer,
+		Request:   markReadRequest{},
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Marked read"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "GET /api/notifications/preferences",
+		Tag:       "notifications",
+		Summary:   "Get which notification types are muted",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "Muted by notification type", Body: preferences{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		Pattern:     "PUT /api/notifications/preferences",
+		Tag:         "notifications",
+		Summary:     "Mute or unmute notification types",
+		Description: "Types left out keep their setting.",
+		Auth:        openapi.AuthBearer,
+		Request:     preferences{},
+		Responses:   []openapi.Response{{Status: http.StatusOK, Description: "Muted by notification type", Body: preferences{}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+}
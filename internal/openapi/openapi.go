@@ -0,0 +1,257 @@
+// Package openapi builds the OpenAPI 3.1 document of the API. Handler
+// packages describe their routes as Operations next to their request and
+// response types, the schemas are derived from those types.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/absurek/go-http-servers/internal/response"
+)
+
+const (
+	Version = "3.1.0"
+
+	ContentTypeJSON = "application/json"
+)
+
+type Auth int
+
+const (
+	AuthNone Auth = iota
+	// A JWT access token.
+	AuthBearer
+	// The JWT is optional, it changes what the viewer gets to see.
+	AuthOptional
+	// A refresh token in place of the JWT.
+	AuthRefreshToken
+	// Polka's HMAC signature of the body.
+	AuthPolkaSignature
+)
+
+// Schema is used as it is in place of one derived from a Go type, for
+// bodies that aren't JSON.
+type Schema map[string]any
+
+type Param struct {
+	Name string
+	// "query" or "header".
+	In          string
+	Description string
+	Required    bool
+}
+
+func Query(name, description string) Param {
+	return Param{Name: name, In: "query", Description: description}
+}
+
+func Header(name, description string) Param {
+	return Param{Name: name, In: "header", Description: description}
+}
+
+type Response struct {
+	Status      int
+	Description string
+	// A value of the body's type or a Schema, nil for no body.
+	Body any
+	// Defaults to JSON.
+	ContentType string
+}
+
+// Operation documents one route.
+type Operation struct {
+	// The route pattern as registered, like "GET /api/chirps/{chirpID}".
+	// Path parameters are documented from it.
+	Pattern     string
+	Tag         string
+	Summary     string
+	Description string
+	Auth        Auth
+	Params      []Param
+	// A value of the request body's type or a Schema, nil for no body.
+	Request            any
+	RequestContentType string
+	Responses          []Response
+	// Statuses answered with an error body. 500 goes without saying, as do
+	// 400, 413 and 415 for JSON request bodies.
+	Errors     []int
+	Deprecated bool
+}
+
+var securitySchemes = map[string]any{
+	"bearerAuth": map[string]any{
+		"type":         "http",
+		"scheme":       "bearer",
+		"bearerFormat": "JWT",
+		"description":  "An access token from POST /api/login or POST /api/refresh.",
+	},
+	"refreshToken": map[string]any{
+		"type":        "http",
+		"scheme":      "bearer",
+		"description": "A refresh token from POST /api/login.",
+	},
+	"polkaSignature": map[string]any{
+		"type":        "apiKey",
+		"in":          "header",
+		"name":        "Polka-Signature",
+		"description": "t=<unix time>,v1=<hex HMAC-SHA256 of \"<t>.<body>\">, one v1 per active secret.",
+	},
+}
+
+// Build renders the document for ops.
+func Build(title, version string, ops []Operation) ([]byte, error) {
+	g := newGenerator()
+	paths := make(map[string]map[string]any)
+
+	for _, op := range ops {
+		method, path, ok := strings.Cut(op.Pattern, " ")
+		if !ok || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("operation %q: pattern needs a method and a path", op.Pattern)
+		}
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+
+		method = strings.ToLower(method)
+		if _, ok := paths[path][method]; ok {
+			return nil, fmt.Errorf("operation %q: documented twice", op.Pattern)
+		}
+
+		paths[path][method] = g.operation(op, path)
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi": Version,
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas":         g.schemas,
+			"securitySchemes": securitySchemes,
+		},
+	}, "", "  ")
+}
+
+func (g *generator) operation(op Operation, path string) map[string]any {
+	doc := map[string]any{
+		"summary": op.Summary,
+	}
+
+	if op.Tag != "" {
+		doc["tags"] = []string{op.Tag}
+	}
+
+	if op.Description != "" {
+		doc["description"] = op.Description
+	}
+
+	if op.Deprecated {
+		doc["deprecated"] = true
+	}
+
+	switch op.Auth {
+	case AuthBearer:
+		doc["security"] = []map[string][]string{{"bearerAuth": {}}}
+	case AuthOptional:
+		doc["security"] = []map[string][]string{{}, {"bearerAuth": {}}}
+	case AuthRefreshToken:
+		doc["security"] = []map[string][]string{{"refreshToken": {}}}
+	case AuthPolkaSignature:
+		doc["security"] = []map[string][]string{{"polkaSignature": {}}}
+	}
+
+	var params []map[string]any
+	for _, name := range pathParams(path) {
+		schema := map[string]any{"type": "string"}
+		if strings.HasSuffix(name, "ID") {
+			schema["format"] = "uuid"
+		}
+
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+
+	for _, param := range op.Params {
+		params = append(params, map[string]any{
+			"name":        param.Name,
+			"in":          param.In,
+			"description": param.Description,
+			"required":    param.Required,
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+
+	if params != nil {
+		doc["parameters"] = params
+	}
+
+	statuses := slices.Concat(op.Errors, []int{http.StatusInternalServerError})
+	if op.Request != nil {
+		contentType := op.RequestContentType
+		if contentType == "" {
+			contentType = ContentTypeJSON
+			statuses = append(statuses, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType)
+		}
+
+		doc["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				contentType: map[string]any{"schema": g.schema(op.Request, true)},
+			},
+		}
+	}
+
+	responses := make(map[string]any)
+	for _, resp := range op.Responses {
+		doc := map[string]any{"description": resp.Description}
+		if resp.Body != nil {
+			contentType := resp.ContentType
+			if contentType == "" {
+				contentType = ContentTypeJSON
+			}
+
+			doc["content"] = map[string]any{
+				contentType: map[string]any{"schema": g.schema(resp.Body, false)},
+			}
+		}
+
+		responses[strconv.Itoa(resp.Status)] = doc
+	}
+
+	for _, status := range statuses {
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": http.StatusText(status),
+			"content": map[string]any{
+				response.ContentTypeProblem: map[string]any{"schema": g.schema(response.Problem{}, false)},
+			},
+		}
+	}
+
+	doc["responses"] = responses
+
+	return doc
+}
+
+// pathParams returns the {name} segments of a path.
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			names = append(names, strings.TrimSuffix(strings.TrimSuffix(name, "}"), "..."))
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	requestpkg "github.com/absurek/go-http-servers/internal/request"
+	"github.com/google/uuid"
+)
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	uuidType       = reflect.TypeFor[uuid.UUID]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// generator derives JSON schemas from Go types the way encoding/json
+// marshals them. Named structs become components, referenced by name.
+// Fields of responses are required unless they are omitempty, fields of
+// requests follow their validate tags, see request.ParseRules.
+type generator struct {
+	schemas map[string]any
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: make(map[string]any)}
+}
+
+func (g *generator) schema(v any, request bool) any {
+	if schema, ok := v.(Schema); ok {
+		return schema
+	}
+
+	return g.typeSchema(reflect.TypeOf(v), request)
+}
+
+func (g *generator) typeSchema(t reflect.Type, request bool) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.typeSchema(t.Elem(), request))
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem(), request)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem(), request)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, request)
+		}
+
+		name := componentName(t)
+		if _, ok := g.schemas[name]; !ok {
+			// Taken before recursing, for types referring to themselves.
+			g.schemas[name] = nil
+			g.schemas[name] = g.structSchema(t, request)
+		}
+
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		// Interfaces, anything goes.
+		return map[string]any{}
+	}
+}
+
+func (g *generator) structSchema(t reflect.Type, request bool) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	g.addFields(t, request, properties, &required)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if required != nil {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// addFields adds the fields of t, and of structs embedded in it, as
+// properties.
+func (g *generator) addFields(t reflect.Type, request bool, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addFields(field.Type, request, properties, required)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := g.typeSchema(field.Type, request)
+		properties[name] = schema
+
+		if !request {
+			if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+				*required = append(*required, name)
+			}
+			continue
+		}
+
+		for _, rule := range requestpkg.ParseRules(field.Tag.Get("validate")) {
+			if rule.Name == requestpkg.RuleRequired {
+				*required = append(*required, name)
+				continue
+			}
+
+			addRule(schema, rule)
+		}
+	}
+}
+
+// addRule documents a validation rule on the schema of a field.
+func addRule(schema map[string]any, rule requestpkg.Rule) {
+	array := schema["type"] == "array"
+	if array && rule.Name != requestpkg.RuleMin && rule.Name != requestpkg.RuleMax {
+		// The rule is about the items.
+		schema, _ = schema["items"].(map[string]any)
+	}
+
+	switch rule.Name {
+	case requestpkg.RuleEmail:
+		schema["format"] = "email"
+	case requestpkg.RuleHTTPS:
+		schema["format"] = "uri"
+		schema["pattern"] = "^https://"
+	case requestpkg.RuleUUID:
+		schema["format"] = "uuid"
+	case requestpkg.RuleMin:
+		if array {
+			schema["minItems"] = rule.Arg
+		} else {
+			schema["minLength"] = rule.Arg
+		}
+	case requestpkg.RuleMax:
+		if array {
+			schema["maxItems"] = rule.Arg
+		} else {
+			schema["maxLength"] = rule.Arg
+		}
+	}
+}
+
+// nullable allows null besides schema.
+func nullable(schema map[string]any) map[string]any {
+	if typ, ok := schema["type"].(string); ok {
+		schema["type"] = []string{typ, "null"}
+		return schema
+	}
+
+	return map[string]any{"oneOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+// componentName names a type after its package, chirps.chirpResponse is
+// chirps.ChirpResponse.
+func componentName(t reflect.Type) string {
+	r, size := utf8.DecodeRuneInString(t.Name())
+	return path.Base(t.PkgPath()) + "." + string(unicode.ToUpper(r)) + t.Name()[size:]
+}
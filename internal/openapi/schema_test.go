@@ -0,0 +1,145 @@
+package openapi
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type testAuthor struct {
+	ID uuid.UUID `json:"id"`
+}
+
+type testTimestamps struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type testChirp struct {
+	testTimestamps
+	Body      string          `json:"body"`
+	Author    testAuthor      `json:"author"`
+	Replies   []testChirp     `json:"replies"`
+	PublishAt *time.Time      `json:"publish_at,omitempty"`
+	Extra     json.RawMessage `json:"extra,omitempty"`
+	Ignored   string          `json:"-"`
+	internal  string
+}
+
+func TestSchema(t *testing.T) {
+	g := newGenerator()
+
+	ref := g.schema(testChirp{}, false).(map[string]any)
+	if ref["$ref"] != "#/components/schemas/openapi.TestChirp" {
+		t.Fatalf("schema = %v, want a reference", ref)
+	}
+
+	chirp := g.schemas["openapi.TestChirp"].(map[string]any)
+	properties := chirp["properties"].(map[string]any)
+
+	want := []string{"created_at", "body", "author", "replies", "publish_at", "extra"}
+	if len(properties) != len(want) {
+		t.Errorf("properties = %v, want %v", properties, want)
+	}
+
+	required := chirp["required"].([]string)
+	if !slices.Equal(required, []string{"created_at", "body", "author", "replies"}) {
+		t.Errorf("required = %v", required)
+	}
+
+	publishAt := properties["publish_at"].(map[string]any)
+	if !slices.Equal(publishAt["type"].([]string), []string{"string", "null"}) || publishAt["format"] != "date-time" {
+		t.Errorf("publish_at = %v, want a nullable date-time", publishAt)
+	}
+
+	replies := properties["replies"].(map[string]any)
+	if replies["items"].(map[string]any)["$ref"] != "#/components/schemas/openapi.TestChirp" {
+		t.Errorf("replies = %v, want references to the chirp", replies)
+	}
+
+	if g.schemas["openapi.TestAuthor"] == nil {
+		t.Error("author isn't a component")
+	}
+
+	request := newGenerator()
+	request.schema(testChirp{}, true)
+	if _, ok := request.schemas["openapi.TestChirp"].(map[string]any)["required"]; ok {
+		t.Error("request fields are required")
+	}
+}
+
+type testSignup struct {
+	Email   string   `json:"email" validate:"required,email,max=254"`
+	Website string   `json:"website" validate:"https"`
+	Friends []string `json:"friends" validate:"uuid,max=3"`
+}
+
+func TestRequestSchema(t *testing.T) {
+	g := newGenerator()
+	g.schema(testSignup{}, true)
+
+	signup := g.schemas["openapi.TestSignup"].(map[string]any)
+	if !slices.Equal(signup["required"].([]string), []string{"email"}) {
+		t.Errorf("required = %v, want the required rules", signup["required"])
+	}
+
+	properties := signup["properties"].(map[string]any)
+
+	email := properties["email"].(map[string]any)
+	if email["format"] != "email" || email["maxLength"] != 254 {
+		t.Errorf("email = %v", email)
+	}
+
+	website := properties["website"].(map[string]any)
+	if website["format"] != "uri" {
+		t.Errorf("website = %v", website)
+	}
+
+	friends := properties["friends"].(map[string]any)
+	if friends["maxItems"] != 3 || friends["items"].(map[string]any)["format"] != "uuid" {
+		t.Errorf("friends = %v, want at most 3 uuids", friends)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	_, err := Build("test", "1", []Operation{
+		{Pattern: "GET /a", Summary: "a"},
+		{Pattern: "GET /a", Summary: "again"},
+	})
+	if err == nil {
+		t.Error("Build() with a duplicate operation succeeded")
+	}
+
+	spec, err := Build("test", "1", []Operation{
+		{Pattern: "GET /chirps/{chirpID}", Summary: "chirp", Errors: []int{404}},
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name   string         `json:"name"`
+				In     string         `json:"in"`
+				Schema map[string]any `json:"schema"`
+			} `json:"parameters"`
+			Responses map[string]any `json:"responses"`
+		} `json:"paths"`
+	}
+	err = json.Unmarshal(spec, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op := doc.Paths["/chirps/{chirpID}"]["get"]
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "chirpID" || op.Parameters[0].In != "path" || op.Parameters[0].Schema["format"] != "uuid" {
+		t.Errorf("parameters = %+v, want the chirpID path parameter", op.Parameters)
+	}
+
+	if op.Responses["404"] == nil || op.Responses["500"] == nil {
+		t.Errorf("responses = %v, want 404 and 500", op.Responses)
+	}
+}
@@ -0,0 +1,52 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	AggregateChirp = "chirp"
+	AggregateUser  = "user"
+
+	EventChirpCreated = "chirp.created"
+	EventChirpUpdated = "chirp.updated"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserUpgraded = "user.upgraded"
+)
+
+// Message is an event as subscribers get it.
+type Message struct {
+	ID            int64
+	AggregateType string
+	AggregateID   uuid.UUID
+	Event         string
+	Payload       json.RawMessage
+	CreatedAt     time.Time
+}
+
+// Write stores an event in the outbox. qtx must be the transaction making
+// the change the event describes, so that both or neither are committed.
+func Write(ctx context.Context, qtx *database.Queries, aggregateType string, aggregateID uuid.UUID, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal %s payload: %w", event, err)
+	}
+
+	err = qtx.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Event:         event,
+		Payload:       payload,
+	})
+	if err != nil {
+		return fmt.Errorf("db create outbox event %s: %w", event, err)
+	}
+
+	return nil
+}
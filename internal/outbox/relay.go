@@ -0,0 +1,289 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/metrics"
+)
+
+const (
+	relayInterval  = 1 * time.Second
+	relayBatchSize = 100
+	relayTimeout   = 30 * time.Second
+
+	// Key of the advisory lock held by the instance relaying. Only one
+	// instance relays, otherwise two could hand out events of the same
+	// aggregate at once.
+	relayLockKey = 0x636869727079 // "chirpy"
+
+	baseBackoff = 1 * time.Second
+	maxBackoff  = 5 * time.Minute
+)
+
+// Handler handles one event. An error makes the relay retry the event, so
+// the same event can be seen more than once.
+type Handler func(ctx context.Context, msg Message) error
+
+// backoff is the wait after the given failed attempt, doubling each time.
+func backoff(attempt int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return wait
+}
+
+// store is the part of database.Queries the relay needs.
+type store interface {
+	GetDueOutboxEvents(ctx context.Context, maxEvents int32) ([]database.Outbox, error)
+	MarkOutboxEventsPublished(ctx context.Context, ids []int64) error
+	RetryOutboxEvent(ctx context.Context, arg database.RetryOutboxEventParams) error
+	GetOutboxLag(ctx context.Context) (database.GetOutboxLagRow, error)
+}
+
+// locker is the lock only one relay holds at a time.
+type locker interface {
+	// acquire reports whether the relay holds the lock, trying to take it
+	// if not.
+	acquire() bool
+	release()
+}
+
+// Relay hands outbox events to in-process subscribers, at least once and in
+// order per aggregate. A failing event holds back the later events of its
+// aggregate only. Every instance runs one, the one holding the lock relays.
+type Relay struct {
+	store       store
+	lock        locker
+	metrics     *metrics.Metrics
+	logger      *log.Logger
+	subscribers map[string][]Handler
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+func NewRelay(db *sql.DB, dbQueries *database.Queries, metrics *metrics.Metrics, logger *log.Logger) *Relay {
+	return &Relay{
+		store:       dbQueries,
+		lock:        &advisoryLock{db: db, logger: logger},
+		metrics:     metrics,
+		logger:      logger,
+		subscribers: make(map[string][]Handler),
+		done:        make(chan struct{}),
+	}
+}
+
+// Subscribe registers handler for event. All subscriptions must be made
+// before Start.
+func (r *Relay) Subscribe(event string, handler Handler) {
+	r.subscribers[event] = append(r.subscribers[event], handler)
+}
+
+func (r *Relay) Start() {
+	r.wg.Add(1)
+	go r.run()
+}
+
+func (r *Relay) run() {
+	defer r.wg.Done()
+	defer r.lock.release()
+
+	ticker := time.NewTicker(relayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+
+		r.relay()
+	}
+}
+
+// relay hands out the due events if this instance holds the lock.
+func (r *Relay) relay() {
+	r.reportLag()
+
+	if !r.lock.acquire() {
+		return
+	}
+
+	for {
+		relayed, err := r.relayBatch()
+		if err != nil {
+			r.logger.Printf("Error(Relay): %v", err)
+			return
+		}
+
+		if relayed < relayBatchSize {
+			return
+		}
+	}
+}
+
+// advisoryLock is a Postgres advisory lock, held by a session.
+type advisoryLock struct {
+	db     *sql.DB
+	logger *log.Logger
+
+	// The connection holding the lock, nil while another instance has it.
+	conn *sql.Conn
+}
+
+func (l *advisoryLock) acquire() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+
+	if l.conn != nil {
+		// The lock lives as long as the connection does.
+		err := l.conn.PingContext(ctx)
+		if err == nil {
+			return true
+		}
+
+		l.logger.Printf("Error(Relay): lost the lock connection: %v", err)
+		l.release()
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		l.logger.Printf("Error(Relay): db conn: %v", err)
+		return false
+	}
+
+	locked, err := database.New(conn).TryAdvisoryLock(ctx, relayLockKey)
+	if err != nil || !locked {
+		if err != nil {
+			l.logger.Printf("Error(Relay): db try advisory lock: %v", err)
+		}
+		conn.Close()
+		return false
+	}
+
+	l.conn = conn
+	return true
+}
+
+// release gives the lock up by closing its connection.
+func (l *advisoryLock) release() {
+	if l.conn == nil {
+		return
+	}
+
+	// Closing only returns the connection to the pool, it has to go for the
+	// session to end.
+	l.conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+	l.conn.Close()
+	l.conn = nil
+}
+
+// relayBatch hands out due events and reports how many it read.
+func (r *Relay) relayBatch() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+
+	events, err := r.store.GetDueOutboxEvents(ctx, relayBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("db get due outbox events: %w", err)
+	}
+
+	type aggregate struct {
+		typ string
+		id  string
+	}
+	failed := make(map[aggregate]bool)
+
+	var published []int64
+	for _, event := range events {
+		key := aggregate{event.AggregateType, event.AggregateID.String()}
+		if failed[key] {
+			continue
+		}
+
+		err := r.handle(ctx, Message{
+			ID:            event.ID,
+			AggregateType: event.AggregateType,
+			AggregateID:   event.AggregateID,
+			Event:         event.Event,
+			Payload:       event.Payload,
+			CreatedAt:     event.CreatedAt,
+		})
+		if err != nil {
+			failed[key] = true
+			r.logger.Printf("Error(Relay): handle %s (outbox_id=%d, attempt=%d): %v", event.Event, event.ID, event.Attempts+1, err)
+
+			err = r.store.RetryOutboxEvent(ctx, database.RetryOutboxEventParams{
+				ID:            event.ID,
+				LastError:     sql.NullString{String: err.Error(), Valid: true},
+				NextAttemptAt: time.Now().Add(backoff(int(event.Attempts) + 1)),
+			})
+			if err != nil {
+				return 0, fmt.Errorf("db retry outbox event (outbox_id=%d): %w", event.ID, err)
+			}
+
+			continue
+		}
+
+		published = append(published, event.ID)
+	}
+
+	if len(published) > 0 {
+		// Failing here hands the events out again, which subscribers have to
+		// cope with anyway.
+		err = r.store.MarkOutboxEventsPublished(ctx, published)
+		if err != nil {
+			return 0, fmt.Errorf("db mark outbox events published: %w", err)
+		}
+
+		r.metrics.AddOutboxPublished(len(published))
+	}
+
+	return len(events), nil
+}
+
+func (r *Relay) handle(ctx context.Context, msg Message) error {
+	for _, handler := range r.subscribers[msg.Event] {
+		err := handler(ctx, msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reportLag records how many events wait and for how long the oldest has.
+func (r *Relay) reportLag() {
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+
+	lag, err := r.store.GetOutboxLag(ctx)
+	if err != nil {
+		r.logger.Printf("Error(Relay): db get outbox lag: %v", err)
+		return
+	}
+
+	r.metrics.SetOutboxLag(lag.Pending, time.Since(lag.Oldest))
+}
+
+// Close stops the relay, waiting for a batch in flight, and gives up the
+// lock.
+func (r *Relay) Close() {
+	close(r.done)
+	r.wg.Wait()
+}
@@ -0,0 +1,317 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/metrics"
+	"github.com/google/uuid"
+)
+
+// memStore implements store with the semantics of the queries.
+type memStore struct {
+	mu          sync.Mutex
+	events      []database.Outbox
+	failPublish bool
+}
+
+func (s *memStore) add(aggregateID uuid.UUID, event string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := int64(len(s.events) + 1)
+	s.events = append(s.events, database.Outbox{
+		ID:            id,
+		AggregateType: AggregateChirp,
+		AggregateID:   aggregateID,
+		Event:         event,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	})
+	return id
+}
+
+func (s *memStore) GetDueOutboxEvents(ctx context.Context, maxEvents int32) ([]database.Outbox, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var due []database.Outbox
+	for _, o := range s.events {
+		if o.PublishedAt.Valid || o.NextAttemptAt.After(now) {
+			continue
+		}
+
+		held := slices.ContainsFunc(s.events, func(earlier database.Outbox) bool {
+			return !earlier.PublishedAt.Valid &&
+				earlier.AggregateType == o.AggregateType &&
+				earlier.AggregateID == o.AggregateID &&
+				earlier.ID < o.ID &&
+				earlier.NextAttemptAt.After(now)
+		})
+		if held {
+			continue
+		}
+
+		if len(due) == int(maxEvents) {
+			break
+		}
+		due = append(due, o)
+	}
+
+	return due, nil
+}
+
+func (s *memStore) MarkOutboxEventsPublished(ctx context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failPublish {
+		return errors.New("connection reset")
+	}
+
+	for i := range s.events {
+		if slices.Contains(ids, s.events[i].ID) {
+			s.events[i].PublishedAt.Time, s.events[i].PublishedAt.Valid = time.Now(), true
+		}
+	}
+	return nil
+}
+
+func (s *memStore) RetryOutboxEvent(ctx context.Context, arg database.RetryOutboxEventParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.events {
+		if s.events[i].ID == arg.ID {
+			s.events[i].Attempts++
+			s.events[i].LastError = arg.LastError
+			s.events[i].NextAttemptAt = arg.NextAttemptAt
+		}
+	}
+	return nil
+}
+
+func (s *memStore) GetOutboxLag(ctx context.Context) (database.GetOutboxLagRow, error) {
+	return database.GetOutboxLagRow{Oldest: time.Now()}, nil
+}
+
+// due makes the backoff of an event run out.
+func (s *memStore) due(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events[id-1].NextAttemptAt = time.Now()
+}
+
+func (s *memStore) event(id int64) database.Outbox {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.events[id-1]
+}
+
+// memLock is the advisory lock shared by the relays of a test.
+type memLock struct {
+	mu     *sync.Mutex
+	holder **memLock
+}
+
+func newMemLocks(n int) []*memLock {
+	mu := &sync.Mutex{}
+	var holder *memLock
+	locks := make([]*memLock, n)
+	for i := range locks {
+		locks[i] = &memLock{mu: mu, holder: &holder}
+	}
+
+	return locks
+}
+
+func (l *memLock) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if *l.holder == nil {
+		*l.holder = l
+	}
+	return *l.holder == l
+}
+
+func (l *memLock) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if *l.holder == l {
+		*l.holder = nil
+	}
+}
+
+// recorder records what a relay handed out, failing events in fail.
+type recorder struct {
+	mu   sync.Mutex
+	seen []int64
+	fail map[int64]bool
+}
+
+func (rec *recorder) handle(ctx context.Context, msg Message) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.seen = append(rec.seen, msg.ID)
+	if rec.fail[msg.ID] {
+		return fmt.Errorf("event %d failed", msg.ID)
+	}
+	return nil
+}
+
+func (rec *recorder) handled() []int64 {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return slices.Clone(rec.seen)
+}
+
+func newTestRelay(store *memStore, lock locker, rec *recorder) *Relay {
+	logger := log.New(io.Discard, "", 0)
+	r := &Relay{
+		store:       store,
+		lock:        lock,
+		metrics:     metrics.NewMetrics(logger),
+		logger:      logger,
+		subscribers: make(map[string][]Handler),
+		done:        make(chan struct{}),
+	}
+	r.Subscribe(EventChirpCreated, rec.handle)
+	r.Subscribe(EventChirpUpdated, rec.handle)
+
+	return r
+}
+
+func TestRelayOrderPerAggregate(t *testing.T) {
+	store := &memStore{}
+	a, b := uuid.New(), uuid.New()
+	a1 := store.add(a, EventChirpCreated)
+	b1 := store.add(b, EventChirpCreated)
+	a2 := store.add(a, EventChirpUpdated)
+
+	rec := &recorder{fail: map[int64]bool{a1: true}}
+	r := newTestRelay(store, newMemLocks(1)[0], rec)
+
+	// a1 fails, a2 waits behind it, b is unaffected.
+	r.relay()
+	if got, want := rec.handled(), []int64{a1, b1}; !slices.Equal(got, want) {
+		t.Fatalf("first relay handed out %v, want %v", got, want)
+	}
+
+	if event := store.event(a1); event.Attempts != 1 || !event.NextAttemptAt.After(time.Now()) || !event.LastError.Valid {
+		t.Errorf("failed event = %+v, want one attempt and a backoff", event)
+	}
+
+	// While a1 backs off, a2 is held back.
+	r.relay()
+	if got := rec.handled(); len(got) != 2 {
+		t.Fatalf("relay during the backoff handed out %v, want nothing more", got[2:])
+	}
+
+	// Once a1 goes through, a2 follows it.
+	delete(rec.fail, a1)
+	store.due(a1)
+	r.relay()
+	if got, want := rec.handled(), []int64{a1, b1, a1, a2}; !slices.Equal(got, want) {
+		t.Fatalf("relay after the backoff handed out %v, want %v", got, want)
+	}
+
+	for _, id := range []int64{a1, b1, a2} {
+		if !store.event(id).PublishedAt.Valid {
+			t.Errorf("event %d not published", id)
+		}
+	}
+}
+
+func TestRelayAtLeastOnce(t *testing.T) {
+	store := &memStore{failPublish: true}
+	id := store.add(uuid.New(), EventChirpCreated)
+
+	rec := &recorder{}
+	r := newTestRelay(store, newMemLocks(1)[0], rec)
+
+	// Handled, but not marked published: it is handed out again rather
+	// than lost.
+	r.relay()
+	store.mu.Lock()
+	store.failPublish = false
+	store.mu.Unlock()
+	r.relay()
+	r.relay()
+
+	if got, want := rec.handled(), []int64{id, id}; !slices.Equal(got, want) {
+		t.Errorf("handed out %v, want %v", got, want)
+	}
+
+	if !store.event(id).PublishedAt.Valid {
+		t.Error("event not published")
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: maxBackoff} {
+		if got := backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestRelayLock(t *testing.T) {
+	store := &memStore{}
+	locks := newMemLocks(2)
+	first, second := &recorder{}, &recorder{}
+	r1 := newTestRelay(store, locks[0], first)
+	r2 := newTestRelay(store, locks[1], second)
+
+	store.add(uuid.New(), EventChirpCreated)
+	r1.relay()
+	store.add(uuid.New(), EventChirpCreated)
+	r2.relay()
+
+	if len(first.handled()) != 1 || len(second.handled()) != 0 {
+		t.Fatalf("handed out %v and %v, want only the first relay to relay", first.handled(), second.handled())
+	}
+
+	// Relays run concurrently, one of them hands out each event once.
+	for range 20 {
+		store.add(uuid.New(), EventChirpCreated)
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range []*Relay{r1, r2} {
+		wg.Go(func() {
+			for range 10 {
+				r.relay()
+			}
+		})
+	}
+	wg.Wait()
+
+	if len(first.handled()) != 22 || len(second.handled()) != 0 {
+		t.Fatalf("first relay handed out %d, second %d, want 22 and 0", len(first.handled()), len(second.handled()))
+	}
+
+	// Once the holder stops, the other relay takes over.
+	r1.Start()
+	r1.Close()
+	store.add(uuid.New(), EventChirpCreated)
+	r2.relay()
+
+	if len(second.handled()) != 1 {
+		t.Errorf("second relay handed out %v after the first stopped, want 1 event", second.handled())
+	}
+}
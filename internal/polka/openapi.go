@@ -0,0 +1,20 @@
+package polka
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:     "POST /api/polka/webhooks",
+		Tag:         "subscriptions",
+		Summary:     "Receive Polka's payment events",
+		Description: "Events are applied once, redeliveries are acknowledged without effect.",
+		Auth:        openapi.AuthPolkaSignature,
+		Request:     webhooksRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusNoContent, Description: "Handled"}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusRequestEntityTooLarge},
+	},
+}
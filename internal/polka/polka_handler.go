@@ -1,57 +1,114 @@
 package polka
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/absurek/go-http-servers/internal/auth"
 	"github.com/absurek/go-http-servers/internal/database"
 	"github.com/absurek/go-http-servers/internal/response"
 	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/subscriptions"
+	"github.com/absurek/go-http-servers/internal/webhooks"
 	"github.com/google/uuid"
 )
 
+// Polka bodies are a few hundred bytes.
+const maxBodySize = 64 * 1024
+
+const SignatureHeader = "Polka-Signature"
+
+// applier is the part of subscriptions.Service the handler needs.
+type applier interface {
+	Apply(ctx context.Context, eventID string, userID uuid.UUID, event string, periodEnd time.Time) error
+}
+
 type PolkaHandler struct {
-	settings  settings.Settings
-	db        *sql.DB
-	dbQueries *database.Queries
-	logger    *log.Logger
+	settings      settings.Settings
+	db            *sql.DB
+	dbQueries     *database.Queries
+	subscriptions applier
+	secrets       []string
+	logger        *log.Logger
 }
 
-func NewPolkaHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, logger *log.Logger) *PolkaHandler {
+// NewPolkaHandler takes the signing secrets from POLKA_WEBHOOK_SECRETS, comma
+// separated so a new secret can be added before Polka switches to it. Without
+// it POLKA_KEY is the only secret.
+func NewPolkaHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, subscriptionsService *subscriptions.Service, logger *log.Logger) *PolkaHandler {
+	var secrets []string
+	for _, secret := range strings.Split(s.PolkaWebhookSecrets, ",") {
+		secret = strings.TrimSpace(secret)
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	if len(secrets) == 0 && s.PolkaKey != "" {
+		secrets = []string{s.PolkaKey}
+	}
+
 	return &PolkaHandler{
-		settings:  s,
-		db:        db,
-		dbQueries: dbQueries,
-		logger:    logger,
+		settings:      s,
+		db:            db,
+		dbQueries:     dbQueries,
+		subscriptions: subscriptionsService,
+		secrets:       secrets,
+		logger:        logger,
 	}
 }
 
 type webhooksRequest struct {
+	ID    string `json:"id"`
 	Event string `json:"event"`
 	Data  struct {
-		UserId string `json:"user_id"`
+		UserId    string     `json:"user_id"`
+		PeriodEnd *time.Time `json:"period_end"`
 	} `json:"data"`
 }
 
+// Webhooks only trusts bodies signed by Polka within the last few minutes,
+// and handles each event ID once.
 func (h *PolkaHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := auth.GetApiKey(r.Header)
-	if err != nil || apiKey != h.settings.PolkaKey {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			response.PayloadTooLarge(w)
+		default:
+			response.InvalidRequestBody(w)
+		}
+
+		return
+	}
+
+	err = webhooks.Verify(r.Header.Get(SignatureHeader), body, h.secrets, webhooks.SignatureTolerance, time.Now())
+	if err != nil {
 		response.Unauthorized(w)
 		return
 	}
 
 	var req webhooksRequest
-	err = json.NewDecoder(r.Body).Decode(&req)
+	err = json.Unmarshal(body, &req)
 	if err != nil {
 		response.InvalidRequestBody(w)
 		return
 	}
 
-	if req.Event != "user.upgraded" {
+	if req.ID == "" {
+		response.Invalid(w, "id", response.FieldRequired, "missing event id")
+		return
+	}
+
+	// Polka expects a 2xx for events we don't care about.
+	if !subscriptions.Handles(req.Event) {
 		response.NoContent(w)
 		return
 	}
@@ -62,13 +119,21 @@ func (h *PolkaHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.dbQueries.UpgradeUserToChirpyRed(r.Context(), userID)
+	var periodEnd time.Time
+	if req.Data.PeriodEnd != nil {
+		periodEnd = *req.Data.PeriodEnd
+	}
+
+	err = h.subscriptions.Apply(r.Context(), req.ID, userID, req.Event, periodEnd)
 	if err != nil {
 		switch {
-		case errors.Is(err, sql.ErrNoRows):
+		case errors.Is(err, subscriptions.ErrDuplicateEvent):
+			// Handled the first time around.
+			response.NoContent(w)
+		case errors.Is(err, subscriptions.ErrUserNotFound):
 			response.NotFound(w)
 		default:
-			h.logger.Printf("Error(Webhooks): upgrade user to chirpy red (user_id=%s): %v", userID, err)
+			h.logger.Printf("Error(Webhooks): apply subscription event (event_id=%s, event=%s, user_id=%s): %v", req.ID, req.Event, userID, err)
 			response.InternalServerError(w)
 		}
 
@@ -0,0 +1,153 @@
+package polka
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/subscriptions"
+	"github.com/absurek/go-http-servers/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// memSubscriptions implements applier with the semantics of
+// subscriptions.Service, recording the events it applied.
+type memSubscriptions struct {
+	users   map[uuid.UUID]bool
+	applied []string
+}
+
+func (s *memSubscriptions) Apply(ctx context.Context, eventID string, userID uuid.UUID, event string, periodEnd time.Time) error {
+	for _, id := range s.applied {
+		if id == eventID {
+			return subscriptions.ErrDuplicateEvent
+		}
+	}
+
+	if !s.users[userID] {
+		return subscriptions.ErrUserNotFound
+	}
+
+	s.applied = append(s.applied, eventID)
+	return nil
+}
+
+func newTestHandler() (*PolkaHandler, *memSubscriptions, uuid.UUID) {
+	userID := uuid.New()
+	subs := &memSubscriptions{users: map[uuid.UUID]bool{userID: true}}
+
+	h := NewPolkaHandler(settings.Settings{PolkaWebhookSecrets: "old, new"}, nil, nil, nil, log.New(io.Discard, "", 0))
+	h.subscriptions = subs
+
+	return h, subs, userID
+}
+
+func event(eventID, event string, userID uuid.UUID) string {
+	return `{"id": "` + eventID + `", "event": "` + event + `", "data": {"user_id": "` + userID.String() + `"}}`
+}
+
+// deliver sends body to the handler as Polka would, signed with secret at
+// signedAt. An empty secret sends it unsigned.
+func deliver(h *PolkaHandler, body, secret string, signedAt time.Time) int {
+	r := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		r.Header.Set(SignatureHeader, webhooks.Sign(secret, []byte(body), signedAt))
+	}
+
+	w := httptest.NewRecorder()
+	h.Webhooks(w, r)
+	return w.Code
+}
+
+func TestWebhooksSignature(t *testing.T) {
+	h, subs, userID := newTestHandler()
+	body := event("evt_1", subscriptions.EventUpgraded, userID)
+
+	tests := []struct {
+		name     string
+		secret   string
+		signedAt time.Time
+	}{
+		{name: "Unsigned", signedAt: time.Now()},
+		{name: "Unknown secret", secret: "other", signedAt: time.Now()},
+		{name: "Stale", secret: "new", signedAt: time.Now().Add(-webhooks.SignatureTolerance - time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := deliver(h, body, tt.secret, tt.signedAt); code != http.StatusUnauthorized {
+				t.Errorf("Webhooks() = %d, want %d", code, http.StatusUnauthorized)
+			}
+		})
+	}
+
+	// Tampering with a signed body breaks the signature.
+	r := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(event("evt_1", subscriptions.EventUpgraded, uuid.New())))
+	r.Header.Set(SignatureHeader, webhooks.Sign("new", []byte(body), time.Now()))
+	w := httptest.NewRecorder()
+	h.Webhooks(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Webhooks() with a tampered body = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if len(subs.applied) != 0 {
+		t.Fatalf("applied %v from rejected deliveries, want nothing", subs.applied)
+	}
+
+	// Either secret works while they are rotated.
+	for i, secret := range []string{"old", "new"} {
+		body := event("evt_"+secret, subscriptions.EventRenewed, userID)
+		if code := deliver(h, body, secret, time.Now()); code != http.StatusNoContent {
+			t.Errorf("Webhooks() signed with %q = %d, want %d", secret, code, http.StatusNoContent)
+		}
+		if len(subs.applied) != i+1 {
+			t.Errorf("applied %v, want the event signed with %q", subs.applied, secret)
+		}
+	}
+}
+
+func TestWebhooksDuplicateEvent(t *testing.T) {
+	h, subs, userID := newTestHandler()
+	body := event("evt_1", subscriptions.EventUpgraded, userID)
+
+	// Polka redelivers until it gets a 2xx, the redelivery is acknowledged
+	// without applying the event again.
+	for i := range 2 {
+		if code := deliver(h, body, "new", time.Now()); code != http.StatusNoContent {
+			t.Fatalf("delivery %d = %d, want %d", i+1, code, http.StatusNoContent)
+		}
+	}
+
+	if len(subs.applied) != 1 {
+		t.Errorf("applied %v, want the event once", subs.applied)
+	}
+}
+
+func TestWebhooksUnhandledEvent(t *testing.T) {
+	h, subs, userID := newTestHandler()
+
+	// Polka sends events we don't care about, they are acknowledged so it
+	// stops retrying.
+	if code := deliver(h, event("evt_1", "user.exported", userID), "new", time.Now()); code != http.StatusNoContent {
+		t.Errorf("Webhooks() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if code := deliver(h, event("", subscriptions.EventUpgraded, userID), "new", time.Now()); code != http.StatusBadRequest {
+		t.Errorf("Webhooks() without an event id = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := deliver(h, event("evt_2", subscriptions.EventUpgraded, uuid.New()), "new", time.Now()); code != http.StatusNotFound {
+		t.Errorf("Webhooks() for an unknown user = %d, want %d", code, http.StatusNotFound)
+	}
+
+	if len(subs.applied) != 0 {
+		t.Errorf("applied %v, want nothing", subs.applied)
+	}
+}
@@ -0,0 +1,250 @@
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/chirps"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/idempotency"
+	"github.com/absurek/go-http-servers/internal/jobs"
+	"github.com/absurek/go-http-servers/internal/media"
+	"github.com/absurek/go-http-servers/internal/users"
+	"github.com/google/uuid"
+)
+
+const (
+	batchSize    = 500
+	batchTimeout = 1 * time.Minute
+
+	// Uploads that were never attached to a chirp.
+	orphanRetention = 24 * time.Hour
+
+	// Senders give up on redelivering long before this.
+	webhookEventRetention = 30 * 24 * time.Hour
+	// Finished outbound deliveries stay in the delivery history this long.
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+	// Relayed outbox events are only kept for debugging.
+	outboxRetention = 7 * 24 * time.Hour
+	// Finished jobs stay visible in /admin/jobs this long.
+	jobRetention = 7 * 24 * time.Hour
+)
+
+// Job runs the purger, it is scheduled hourly.
+var Job = jobs.Kind[struct{}]{Name: "purge", Timeout: 30 * time.Minute}
+
+// store is the part of database.Queries the purger needs.
+type store interface {
+	GetPurgeableAttachments(ctx context.Context, arg database.GetPurgeableAttachmentsParams) ([]database.Attachment, error)
+	DeleteAttachments(ctx context.Context, ids []uuid.UUID) (int64, error)
+	GetExpiredExports(ctx context.Context, arg database.GetExpiredExportsParams) ([]database.Export, error)
+	DeleteExports(ctx context.Context, ids []uuid.UUID) (int64, error)
+	PurgeChirps(ctx context.Context, arg database.PurgeChirpsParams) (int64, error)
+	PurgeUsers(ctx context.Context, arg database.PurgeUsersParams) (int64, error)
+	PurgeWebhookEvents(ctx context.Context, arg database.PurgeWebhookEventsParams) (int64, error)
+	PurgeOutboxEvents(ctx context.Context, arg database.PurgeOutboxEventsParams) (int64, error)
+	PurgeWebhookDeliveries(ctx context.Context, arg database.PurgeWebhookDeliveriesParams) (int64, error)
+	PurgeJobs(ctx context.Context, arg database.PurgeJobsParams) (int64, error)
+	PurgeIdempotencyKeys(ctx context.Context, arg database.PurgeIdempotencyKeysParams) (int64, error)
+	PurgeWSTickets(ctx context.Context, arg database.PurgeWSTicketsParams) (int64, error)
+}
+
+// Purger hard deletes soft deleted chirps and users once they can no longer
+// be restored, along with their attachment files. Deleting a user cascades to
+// whatever they still own. It also removes expired data export archives and
+// forgets old webhook event IDs, deliveries, relayed outbox events,
+// finished jobs, expired idempotency keys and unused websocket tickets.
+type Purger struct {
+	store     store
+	blobStore media.BlobStore
+	logger    *log.Logger
+}
+
+func NewPurger(dbQueries *database.Queries, blobStore media.BlobStore, logger *log.Logger) *Purger {
+	return &Purger{
+		store:     dbQueries,
+		blobStore: blobStore,
+		logger:    logger,
+	}
+}
+
+// Run works in batches until there is nothing left, so a large backlog
+// doesn't hold long locks. A failed step doesn't stop the unrelated ones, the
+// job is retried once all of them ran. Deleting chirps and users cascades to
+// their attachment and export rows, so they wait for a run in which the files
+// were deleted; otherwise the rows pointing at the files would go and the
+// files would never be deleted.
+func (p *Purger) Run(ctx context.Context, _ struct{}) error {
+	now := time.Now()
+	steps := []struct {
+		name  string
+		after []string
+		run   func(ctx context.Context) (int64, error)
+	}{
+		{"attachments", nil, func(ctx context.Context) (int64, error) {
+			return p.purgeAttachments(ctx, now)
+		}},
+		{"exports", nil, func(ctx context.Context) (int64, error) {
+			return p.purgeExports(ctx, now)
+		}},
+		{"chirps", []string{"attachments"}, func(ctx context.Context) (int64, error) {
+			return p.store.PurgeChirps(ctx, database.PurgeChirpsParams{
+				DeletedBefore: sql.NullTime{Time: now.Add(-chirps.RestoreGracePeriod), Valid: true},
+				MaxChirps:     batchSize,
+			})
+		}},
+		{"users", []string{"attachments", "exports"}, func(ctx context.Context) (int64, error) {
+			return p.store.PurgeUsers(ctx, database.PurgeUsersParams{
+				DeletedBefore: sql.NullTime{Time: now.Add(-users.RestoreGracePeriod), Valid: true},
+				MaxUsers:      batchSize,
+			})
+		}},
+		{"webhook events", nil, func(ctx context.Context) (int64, error) {
+			return p.store.PurgeWebhookEvents(ctx, database.PurgeWebhookEventsParams{
+				ReceivedBefore: now.Add(-webhookEventRetention),
+				MaxEvents:      batchSize,
+			})
+		}},
+		{"outbox events", nil, func(ctx context.Context) (int64, error) {
+			return p.store.PurgeOutboxEvents(ctx, database.PurgeOutboxEventsParams{
+				PublishedBefore: sql.NullTime{Time: now.Add(-outboxRetention), Valid: true},
+				MaxEvents:       batchSize,
+			})
+		}},
+		{"webhook deliveries", nil, func(ctx context.Context) (int64, error) {
+			return p.store.PurgeWebhookDeliveries(ctx, database.PurgeWebhookDeliveriesParams{
+				CompletedBefore: sql.NullTime{Time: now.Add(-webhookDeliveryRetention), Valid: true},
+				MaxDeliveries:   batchSize,
+			})
+		}},
+		{"jobs", nil, func(ctx context.Context) (int64, error) {
+			return p.store.PurgeJobs(ctx, database.PurgeJobsParams{
+				FinishedBefore: sql.NullTime{Time: now.Add(-jobRetention), Valid: true},
+				MaxJobs:        batchSize,
+			})
+		}},
+		{"idempotency keys", nil, func(ctx context.Context) (int64, error) {
+			return p.store.PurgeIdempotencyKeys(ctx, database.PurgeIdempotencyKeysParams{
+				CreatedBefore: now.Add(-idempotency.Retention),
+				MaxKeys:       batchSize,
+			})
+		}},
+		{"websocket tickets", nil, func(ctx context.Context) (int64, error) {
+			return p.store.PurgeWSTickets(ctx, database.PurgeWSTicketsParams{
+				ExpiredBefore: now,
+				MaxTickets:    batchSize,
+			})
+		}},
+	}
+
+	var errs []error
+	failed := make(map[string]bool)
+	for _, step := range steps {
+		if slices.ContainsFunc(step.after, func(name string) bool { return failed[name] }) {
+			p.logger.Printf("Skipped purging %s, an earlier step failed", step.name)
+			continue
+		}
+
+		var total int64
+		for {
+			err := ctx.Err()
+			if err != nil {
+				return err
+			}
+
+			batchCtx, cancel := context.WithTimeout(ctx, batchTimeout)
+			purged, err := step.run(batchCtx)
+			cancel()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("purge %s: %w", step.name, err))
+				failed[step.name] = true
+				break
+			}
+
+			total += purged
+			if purged < batchSize {
+				break
+			}
+		}
+
+		if total > 0 {
+			p.logger.Printf("Purged %d %s", total, step.name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// purgeAttachments removes the files before the rows, a failed file delete
+// leaves the row in place to be retried on the next run.
+func (p *Purger) purgeAttachments(ctx context.Context, now time.Time) (int64, error) {
+	attachments, err := p.store.GetPurgeableAttachments(ctx, database.GetPurgeableAttachmentsParams{
+		DeletedBefore:  sql.NullTime{Time: now.Add(-max(chirps.RestoreGracePeriod, users.RestoreGracePeriod)), Valid: true},
+		OrphanedBefore: sql.NullTime{Time: now.Add(-orphanRetention), Valid: true},
+		MaxAttachments: batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("db get purgeable attachments: %w", err)
+	}
+
+	if len(attachments) == 0 {
+		return 0, nil
+	}
+
+	err = media.DeleteAttachments(ctx, p.blobStore, attachments)
+	if err != nil {
+		return 0, fmt.Errorf("delete attachment files: %w", err)
+	}
+
+	ids := make([]uuid.UUID, 0, len(attachments))
+	for _, attachment := range attachments {
+		ids = append(ids, attachment.ID)
+	}
+
+	purged, err := p.store.DeleteAttachments(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("db delete attachments: %w", err)
+	}
+
+	return purged, nil
+}
+
+// purgeExports removes expired archives and those of deleted accounts, files
+// first like purgeAttachments.
+func (p *Purger) purgeExports(ctx context.Context, now time.Time) (int64, error) {
+	exports, err := p.store.GetExpiredExports(ctx, database.GetExpiredExportsParams{
+		ExpiredBefore: sql.NullTime{Time: now, Valid: true},
+		MaxExports:    batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("db get expired exports: %w", err)
+	}
+
+	if len(exports) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(exports))
+	for _, export := range exports {
+		if export.StorageKey.Valid {
+			err = p.blobStore.Delete(ctx, export.StorageKey.String)
+			if err != nil {
+				return 0, fmt.Errorf("delete archive %s: %w", export.StorageKey.String, err)
+			}
+		}
+
+		ids = append(ids, export.ID)
+	}
+
+	purged, err := p.store.DeleteExports(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("db delete exports: %w", err)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,192 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"slices"
+	"testing"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/media"
+	"github.com/google/uuid"
+)
+
+// memStore implements store, it has one purgeable attachment and export
+// and records which steps ran.
+type memStore struct {
+	attachments []database.Attachment
+	exports     []database.Export
+	ran         []string
+}
+
+func (s *memStore) GetPurgeableAttachments(ctx context.Context, arg database.GetPurgeableAttachmentsParams) ([]database.Attachment, error) {
+	return s.attachments, nil
+}
+
+func (s *memStore) DeleteAttachments(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	s.ran = append(s.ran, "attachments")
+	s.attachments = slices.DeleteFunc(s.attachments, func(a database.Attachment) bool { return slices.Contains(ids, a.ID) })
+	return int64(len(ids)), nil
+}
+
+func (s *memStore) GetExpiredExports(ctx context.Context, arg database.GetExpiredExportsParams) ([]database.Export, error) {
+	return s.exports, nil
+}
+
+func (s *memStore) DeleteExports(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	s.ran = append(s.ran, "exports")
+	s.exports = slices.DeleteFunc(s.exports, func(e database.Export) bool { return slices.Contains(ids, e.ID) })
+	return int64(len(ids)), nil
+}
+
+func (s *memStore) purge(step string) (int64, error) {
+	s.ran = append(s.ran, step)
+	return 0, nil
+}
+
+func (s *memStore) PurgeChirps(ctx context.Context, arg database.PurgeChirpsParams) (int64, error) {
+	return s.purge("chirps")
+}
+
+func (s *memStore) PurgeUsers(ctx context.Context, arg database.PurgeUsersParams) (int64, error) {
+	return s.purge("users")
+}
+
+func (s *memStore) PurgeWebhookEvents(ctx context.Context, arg database.PurgeWebhookEventsParams) (int64, error) {
+	return s.purge("webhook events")
+}
+
+func (s *memStore) PurgeOutboxEvents(ctx context.Context, arg database.PurgeOutboxEventsParams) (int64, error) {
+	return s.purge("outbox events")
+}
+
+func (s *memStore) PurgeWebhookDeliveries(ctx context.Context, arg database.PurgeWebhookDeliveriesParams) (int64, error) {
+	return s.purge("webhook deliveries")
+}
+
+func (s *memStore) PurgeJobs(ctx context.Context, arg database.PurgeJobsParams) (int64, error) {
+	return s.purge("jobs")
+}
+
+func (s *memStore) PurgeIdempotencyKeys(ctx context.Context, arg database.PurgeIdempotencyKeysParams) (int64, error) {
+	return s.purge("idempotency keys")
+}
+
+func (s *memStore) PurgeWSTickets(ctx context.Context, arg database.PurgeWSTicketsParams) (int64, error) {
+	return s.purge("websocket tickets")
+}
+
+// blobStore fails to delete the keys in failing.
+type blobStore struct {
+	failing map[string]bool
+	deleted []string
+}
+
+func (b *blobStore) Put(ctx context.Context, key, contentType string, body io.ReadSeeker) error {
+	return nil
+}
+
+func (b *blobStore) Get(ctx context.Context, key string) (*media.Object, error) {
+	return nil, media.ErrNotFound
+}
+
+func (b *blobStore) Delete(ctx context.Context, key string) error {
+	if b.failing[key] {
+		return errors.New("blob store unavailable")
+	}
+
+	b.deleted = append(b.deleted, key)
+	return nil
+}
+
+func newTestPurger() (*Purger, *memStore, *blobStore) {
+	store := &memStore{
+		attachments: []database.Attachment{{ID: uuid.New(), StorageKey: "media/a", ThumbnailKey: "media/a-thumb"}},
+		exports:     []database.Export{{ID: uuid.New()}},
+	}
+	blobs := &blobStore{failing: make(map[string]bool)}
+
+	return &Purger{
+		store:     store,
+		blobStore: blobs,
+		logger:    log.New(io.Discard, "", 0),
+	}, store, blobs
+}
+
+func TestRun(t *testing.T) {
+	p, store, blobs := newTestPurger()
+
+	err := p.Run(context.Background(), struct{}{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []string{"attachments", "exports", "chirps", "users", "webhook events", "outbox events", "webhook deliveries", "jobs", "idempotency keys", "websocket tickets"}
+	if !slices.Equal(store.ran, want) {
+		t.Errorf("Run() ran %v, want %v", store.ran, want)
+	}
+
+	if len(blobs.deleted) != 2 {
+		t.Errorf("Run() deleted files %v, want the file and its thumbnail", blobs.deleted)
+	}
+}
+
+func TestRunKeepsOwnersOfUndeletedFiles(t *testing.T) {
+	p, store, blobs := newTestPurger()
+	blobs.failing["media/a-thumb"] = true
+
+	err := p.Run(context.Background(), struct{}{})
+	if err == nil {
+		t.Fatal("Run() error = nil, want the failed file delete")
+	}
+
+	// Purging chirps or users would cascade to the attachment row, the
+	// only record of the file.
+	for _, step := range store.ran {
+		if step == "attachments" || step == "chirps" || step == "users" {
+			t.Errorf("Run() ran %s after a file delete failed", step)
+		}
+	}
+
+	if len(store.attachments) != 1 {
+		t.Errorf("attachment rows = %d, want 1 left for the next run", len(store.attachments))
+	}
+
+	// The unrelated steps still ran.
+	if !slices.Contains(store.ran, "exports") || !slices.Contains(store.ran, "jobs") {
+		t.Errorf("Run() ran %v, want the unrelated steps too", store.ran)
+	}
+
+	// The next run picks it up.
+	delete(blobs.failing, "media/a-thumb")
+	store.ran = nil
+	err = p.Run(context.Background(), struct{}{})
+	if err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+
+	if len(store.attachments) != 0 || !slices.Contains(store.ran, "users") {
+		t.Errorf("second Run() ran %v with %d attachments left, want all steps and none left", store.ran, len(store.attachments))
+	}
+}
+
+func TestRunKeepsUsersWithUndeletedArchives(t *testing.T) {
+	p, store, blobs := newTestPurger()
+	store.exports[0].StorageKey.String, store.exports[0].StorageKey.Valid = "exports/x.zip", true
+	blobs.failing["exports/x.zip"] = true
+
+	err := p.Run(context.Background(), struct{}{})
+	if err == nil {
+		t.Fatal("Run() error = nil, want the failed archive delete")
+	}
+
+	if !slices.Contains(store.ran, "chirps") {
+		t.Errorf("Run() ran %v, want chirps purged, they own no archives", store.ran)
+	}
+
+	if slices.Contains(store.ran, "users") {
+		t.Errorf("Run() purged users after an archive delete failed")
+	}
+}
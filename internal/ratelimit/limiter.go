@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// How long an untouched bucket is kept around. A bucket idle this long is
+// full again anyway, forgetting it changes nothing.
+const idleAfter = 1 * time.Minute
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// Limiter is a token bucket per key, refilled continuously at limit tokens
+// per minute with room for a burst of limit requests. Limits are per
+// instance.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Result describes the bucket after a request was counted.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Allow counts a request for key against a limit of perMinute requests. The
+// limit may change between calls, e.g. after an upgrade.
+func (l *Limiter) Allow(key string, perMinute int, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(perMinute), lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	rate := float64(perMinute) / time.Minute.Seconds()
+	b.tokens = math.Min(float64(perMinute), b.tokens+now.Sub(b.lastSeen).Seconds()*rate)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return Result{Allowed: false, Limit: perMinute, Remaining: 0, RetryAfter: wait}
+	}
+
+	b.tokens--
+	return Result{Allowed: true, Limit: perMinute, Remaining: int(b.tokens)}
+}
+
+// Must be called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleAfter {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idleAfter {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
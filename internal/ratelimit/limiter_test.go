@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter()
+
+	// A full bucket allows a burst of the whole limit.
+	for i := range 3 {
+		res := l.Allow("alice", 3, now)
+		if !res.Allowed {
+			t.Fatalf("request %d: Allow() denied a request within the burst", i)
+		}
+
+		if res.Remaining != 2-i {
+			t.Errorf("request %d: Remaining = %d, want %d", i, res.Remaining, 2-i)
+		}
+	}
+
+	res := l.Allow("alice", 3, now)
+	if res.Allowed {
+		t.Fatalf("Allow() allowed a request over the limit")
+	}
+
+	if res.RetryAfter != 20*time.Second {
+		t.Errorf("RetryAfter = %v, want 20s", res.RetryAfter)
+	}
+
+	// Keys don't share buckets.
+	if !l.Allow("bob", 3, now).Allowed {
+		t.Errorf("Allow() denied another key")
+	}
+
+	// One token comes back every 20 seconds at 3 per minute.
+	if !l.Allow("alice", 3, now.Add(20*time.Second)).Allowed {
+		t.Errorf("Allow() denied a request after a refill")
+	}
+
+	if l.Allow("alice", 3, now.Add(20*time.Second)).Allowed {
+		t.Errorf("Allow() refilled more than one token")
+	}
+}
+
+func TestLimiterRaisedLimit(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter()
+
+	l.Allow("alice", 1, now)
+	if l.Allow("alice", 1, now).Allowed {
+		t.Fatalf("Allow() allowed a request over the limit")
+	}
+
+	// After an upgrade the bucket fills at the new rate, 10 per minute is
+	// one token every 6 seconds.
+	if !l.Allow("alice", 10, now.Add(6*time.Second)).Allowed {
+		t.Errorf("Allow() ignored the raised limit")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter()
+
+	l.Allow("alice", 1, now)
+	l.Allow("bob", 1, now.Add(2*idleAfter))
+
+	if _, ok := l.buckets["alice"]; ok {
+		t.Errorf("idle bucket was not swept")
+	}
+
+	if _, ok := l.buckets["bob"]; !ok {
+		t.Errorf("active bucket was swept")
+	}
+}
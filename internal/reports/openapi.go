@@ -0,0 +1,28 @@
+package reports
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:   "POST /api/chirps/{chirpID}/report",
+		Tag:       "reports",
+		Summary:   "Report a chirp",
+		Auth:      openapi.AuthBearer,
+		Request:   reportRequest{},
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Reported"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:   "POST /api/users/{userID}/report",
+		Tag:       "reports",
+		Summary:   "Report a user",
+		Auth:      openapi.AuthBearer,
+		Request:   reportRequest{},
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Reported"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+}
@@ -0,0 +1,190 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+// Reasons users can pick from. Reports created by moderation rules use
+// "flagged", which isn't offered here.
+var Reasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+type reportRequest struct {
+	Reason  string `json:"reason" validate:"required"`
+	Details string `json:"details" validate:"max=1000"`
+}
+
+func (req reportRequest) Validate() []response.FieldError {
+	if req.Reason != "" && !slices.Contains(Reasons, req.Reason) {
+		return []response.FieldError{{Field: "reason", Code: response.FieldInvalid, Message: "invalid reason"}}
+	}
+
+	return nil
+}
+
+// store is the part of database.Queries the handler needs.
+type store interface {
+	GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	UserExists(ctx context.Context, id uuid.UUID) (bool, error)
+	CreateReport(ctx context.Context, arg database.CreateReportParams) (int64, error)
+}
+
+type ReportsHandler struct {
+	settings settings.Settings
+	db       *sql.DB
+	store    store
+	filter   *visibility.Filter
+	logger   *log.Logger
+}
+
+func NewReportsHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, filter *visibility.Filter, logger *log.Logger) *ReportsHandler {
+	return &ReportsHandler{
+		settings: s,
+		db:       db,
+		store:    dbQueries,
+		filter:   filter,
+		logger:   logger,
+	}
+}
+
+// decode authenticates the reporter and reads the report. It writes the
+// error response itself and reports ok=false in that case.
+func (h *ReportsHandler) decode(w http.ResponseWriter, r *http.Request) (uuid.UUID, reportRequest, bool) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.UUID{}, reportRequest{}, false
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.UUID{}, reportRequest{}, false
+	}
+
+	req, ok := request.DecodeJSON[reportRequest](w, r)
+	if !ok {
+		return uuid.UUID{}, reportRequest{}, false
+	}
+
+	return userID, req, true
+}
+
+// ReportChirp files a report about a chirp. Reporting the same chirp again
+// while the first report is open is a no-op.
+func (h *ReportsHandler) ReportChirp(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		response.Invalid(w, "chirpID", response.FieldInvalid, "invalid chirp id")
+		return
+	}
+
+	chirp, err := h.store.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(ReportChirp): db get chirp by id (chirp_id=%s): %v", chirpID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	rules, err := h.filter.ForViewer(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		h.logger.Printf("Error(ReportChirp): visibility rules (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	// Only what the reporter can actually see can be reported.
+	if chirp.HiddenAt.Valid || chirp.PublishAt.Valid || !rules.CanSee(chirp.UserID) {
+		response.NotFound(w)
+		return
+	}
+
+	if chirp.UserID == userID {
+		response.Error(w, http.StatusBadRequest, "self_report", "can't report your own chirp")
+		return
+	}
+
+	_, err = h.store.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: userID, Valid: true},
+		UserID:     chirp.UserID,
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody:  sql.NullString{String: chirp.Body, Valid: true},
+		Reason:     req.Reason,
+		Details:    req.Details,
+	})
+	if err != nil {
+		h.logger.Printf("Error(ReportChirp): db create report (user_id=%s, chirp_id=%s): %v", userID, chirp.ID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// ReportUser files a report about an account as a whole.
+func (h *ReportsHandler) ReportUser(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.Invalid(w, "userID", response.FieldInvalid, "invalid user id")
+		return
+	}
+
+	if targetID == userID {
+		response.Error(w, http.StatusBadRequest, "self_report", "can't report yourself")
+		return
+	}
+
+	exists, err := h.store.UserExists(r.Context(), targetID)
+	if err != nil {
+		h.logger.Printf("Error(ReportUser): db user exists (user_id=%s): %v", targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !exists {
+		response.NotFound(w)
+		return
+	}
+
+	_, err = h.store.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: userID, Valid: true},
+		UserID:     targetID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+	})
+	if err != nil {
+		h.logger.Printf("Error(ReportUser): db create report (user_id=%s, target_id=%s): %v", userID, targetID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.NoContent(w)
+}
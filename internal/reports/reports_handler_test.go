@@ -0,0 +1,255 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+// memStore implements store and visibility.Store with the semantics of the
+// queries.
+type memStore struct {
+	users   map[uuid.UUID]bool
+	chirps  map[uuid.UUID]database.Chirp
+	blocks  map[[2]uuid.UUID]bool
+	reports []database.Report
+}
+
+func (s *memStore) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, ok := s.chirps[id]
+	if !ok || chirp.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (s *memStore) UserExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	return s.users[id], nil
+}
+
+// CreateReport keeps one open report per reporter and chirp, or reporter and
+// user for reports about an account, like the unique indexes.
+func (s *memStore) CreateReport(ctx context.Context, arg database.CreateReportParams) (int64, error) {
+	for _, report := range s.reports {
+		if report.Status == "open" && report.ReporterID == arg.ReporterID && report.ChirpID == arg.ChirpID &&
+			(arg.ChirpID.Valid || report.UserID == arg.UserID) {
+			return 0, nil
+		}
+	}
+
+	s.reports = append(s.reports, database.Report{
+		ID:         uuid.New(),
+		ReporterID: arg.ReporterID,
+		UserID:     arg.UserID,
+		ChirpID:    arg.ChirpID,
+		ChirpBody:  arg.ChirpBody,
+		Reason:     arg.Reason,
+		Details:    arg.Details,
+		Status:     "open",
+		CreatedAt:  time.Now(),
+	})
+	return 1, nil
+}
+
+func (s *memStore) GetVisibilityRelations(ctx context.Context, viewerID uuid.UUID) ([]database.GetVisibilityRelationsRow, error) {
+	var relations []database.GetVisibilityRelationsRow
+	for key := range s.blocks {
+		if key[0] == viewerID {
+			relations = append(relations, database.GetVisibilityRelationsRow{UserID: key[1], Relation: "blocked"})
+		}
+		if key[1] == viewerID {
+			relations = append(relations, database.GetVisibilityRelationsRow{UserID: key[0], Relation: "blocked_by"})
+		}
+	}
+	return relations, nil
+}
+
+func (s *memStore) IsBlockedEitherWay(ctx context.Context, arg database.IsBlockedEitherWayParams) (bool, error) {
+	return s.blocks[[2]uuid.UUID{arg.BlockerID, arg.BlockedID}] || s.blocks[[2]uuid.UUID{arg.BlockedID, arg.BlockerID}], nil
+}
+
+func (s *memStore) addChirp(authorID uuid.UUID) uuid.UUID {
+	chirp := database.Chirp{ID: uuid.New(), UserID: authorID, Body: "What a kerfuffle"}
+	s.chirps[chirp.ID] = chirp
+	return chirp.ID
+}
+
+type testUser struct {
+	id  uuid.UUID
+	jwt string
+}
+
+func newTestHandler(t *testing.T) (*ReportsHandler, *memStore, testUser, testUser) {
+	t.Helper()
+
+	store := &memStore{
+		users:  make(map[uuid.UUID]bool),
+		chirps: make(map[uuid.UUID]database.Chirp),
+		blocks: make(map[[2]uuid.UUID]bool),
+	}
+
+	var users []testUser
+	for range 2 {
+		user := testUser{id: uuid.New()}
+		jwt, err := auth.MakeJWT(user.id, "secret", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.jwt = jwt
+
+		store.users[user.id] = true
+		users = append(users, user)
+	}
+
+	return &ReportsHandler{
+		settings: settings.Settings{JWTSecret: "secret"},
+		store:    store,
+		filter:   visibility.NewFilter(store),
+		logger:   log.New(io.Discard, "", 0),
+	}, store, users[0], users[1]
+}
+
+func reportChirp(h *ReportsHandler, user testUser, chirpID uuid.UUID, body string) int {
+	r := httptest.NewRequest(http.MethodPost, "/api/chirps/"+chirpID.String()+"/report", strings.NewReader(body))
+	r.SetPathValue("chirpID", chirpID.String())
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+user.jwt)
+
+	w := httptest.NewRecorder()
+	h.ReportChirp(w, r)
+	return w.Code
+}
+
+func reportUser(h *ReportsHandler, user testUser, targetID uuid.UUID, body string) int {
+	r := httptest.NewRequest(http.MethodPost, "/api/users/"+targetID.String()+"/report", strings.NewReader(body))
+	r.SetPathValue("userID", targetID.String())
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+user.jwt)
+
+	w := httptest.NewRecorder()
+	h.ReportUser(w, r)
+	return w.Code
+}
+
+func TestReportChirp(t *testing.T) {
+	h, store, reporter, author := newTestHandler(t)
+	chirpID := store.addChirp(author.id)
+
+	if code := reportChirp(h, reporter, chirpID, `{"reason": "spam", "details": "again"}`); code != http.StatusNoContent {
+		t.Fatalf("ReportChirp() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if len(store.reports) != 1 {
+		t.Fatalf("stored %d reports, want 1", len(store.reports))
+	}
+
+	// The queue keeps what the chirp said when it was reported.
+	report := store.reports[0]
+	if report.UserID != author.id || report.ChirpID.UUID != chirpID || report.ChirpBody.String != "What a kerfuffle" || report.Reason != "spam" {
+		t.Errorf("report = %+v, want one about the chirp and its author", report)
+	}
+
+	// Reporting again while the report is open is a no-op.
+	if code := reportChirp(h, reporter, chirpID, `{"reason": "hate"}`); code != http.StatusNoContent {
+		t.Fatalf("second ReportChirp() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if len(store.reports) != 1 {
+		t.Errorf("stored %d reports, want the open one only", len(store.reports))
+	}
+}
+
+func TestReportChirpErrors(t *testing.T) {
+	h, store, reporter, author := newTestHandler(t)
+	chirpID := store.addChirp(author.id)
+	ownChirpID := store.addChirp(reporter.id)
+
+	hiddenID := store.addChirp(author.id)
+	hidden := store.chirps[hiddenID]
+	hidden.HiddenAt = sql.NullTime{Time: time.Now(), Valid: true}
+	store.chirps[hiddenID] = hidden
+
+	blockerID := uuid.New()
+	store.users[blockerID] = true
+	store.blocks[[2]uuid.UUID{blockerID, reporter.id}] = true
+	blockedChirpID := store.addChirp(blockerID)
+
+	tests := []struct {
+		name    string
+		chirpID uuid.UUID
+		body    string
+		want    int
+	}{
+		{name: "No reason", chirpID: chirpID, body: `{}`, want: http.StatusBadRequest},
+		{name: "Unknown reason", chirpID: chirpID, body: `{"reason": "boring"}`, want: http.StatusBadRequest},
+		{name: "Flagged is for rules", chirpID: chirpID, body: `{"reason": "flagged"}`, want: http.StatusBadRequest},
+		{name: "Own chirp", chirpID: ownChirpID, body: `{"reason": "spam"}`, want: http.StatusBadRequest},
+		{name: "Unknown chirp", chirpID: uuid.New(), body: `{"reason": "spam"}`, want: http.StatusNotFound},
+		{name: "Hidden chirp", chirpID: hiddenID, body: `{"reason": "spam"}`, want: http.StatusNotFound},
+		{name: "Blocked author", chirpID: blockedChirpID, body: `{"reason": "spam"}`, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := reportChirp(h, reporter, tt.chirpID, tt.body); code != tt.want {
+				t.Errorf("ReportChirp() = %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	if len(store.reports) != 0 {
+		t.Errorf("stored %d reports, want none", len(store.reports))
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/chirps/"+chirpID.String()+"/report", strings.NewReader(`{"reason": "spam"}`))
+	r.SetPathValue("chirpID", chirpID.String())
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ReportChirp(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("ReportChirp() without a token = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestReportUser(t *testing.T) {
+	h, store, reporter, target := newTestHandler(t)
+
+	if code := reportUser(h, reporter, target.id, `{"reason": "harassment"}`); code != http.StatusNoContent {
+		t.Fatalf("ReportUser() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if len(store.reports) != 1 || store.reports[0].UserID != target.id || store.reports[0].ChirpID.Valid {
+		t.Fatalf("reports = %+v, want one about the account", store.reports)
+	}
+
+	// A report about the account doesn't stop one about a chirp.
+	chirpID := store.addChirp(target.id)
+	if code := reportChirp(h, reporter, chirpID, `{"reason": "spam"}`); code != http.StatusNoContent {
+		t.Fatalf("ReportChirp() = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if len(store.reports) != 2 {
+		t.Errorf("stored %d reports, want 2", len(store.reports))
+	}
+
+	if code := reportUser(h, reporter, reporter.id, `{"reason": "spam"}`); code != http.StatusBadRequest {
+		t.Errorf("ReportUser() on yourself = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := reportUser(h, reporter, uuid.New(), `{"reason": "spam"}`); code != http.StatusNotFound {
+		t.Errorf("ReportUser() on an unknown user = %d, want %d", code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,25 @@
+package request
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BaseURL is the scheme and host clients reach the server at, for links
+// that have to be absolute. The configured URL wins, otherwise it is taken
+// from the request, trusting X-Forwarded-Proto from a proxy in front.
+func BaseURL(r *http.Request, configured string) string {
+	if configured != "" {
+		return strings.TrimSuffix(configured, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,140 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/absurek/go-http-servers/internal/response"
+)
+
+// MaxBodySize caps JSON bodies, bigger ones are answered 413.
+const MaxBodySize = 1 << 20
+
+// DecodeJSON reads the body into a T and validates it, see Validate. It
+// rejects bodies that aren't application/json, fields T doesn't have and
+// anything after the value. It writes the error response itself and
+// reports ok=false in that case.
+func DecodeJSON[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	return decodeJSON[T](w, r, false)
+}
+
+// DecodeOptionalJSON is DecodeJSON for bodies that may be left out, no
+// body is the zero T.
+func DecodeOptionalJSON[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	return decodeJSON[T](w, r, true)
+}
+
+func decodeJSON[T any](w http.ResponseWriter, r *http.Request, optional bool) (T, bool) {
+	var v T
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.PayloadTooLarge(w)
+		} else {
+			response.InvalidRequestBody(w)
+		}
+
+		return v, false
+	}
+
+	if optional && len(bytes.TrimSpace(body)) == 0 {
+		return v, true
+	}
+
+	if !isJSON(r.Header.Get("Content-Type")) {
+		response.UnsupportedMediaType(w)
+		return v, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(&v)
+	if err != nil {
+		decodeError(w, err)
+		return v, false
+	}
+
+	_, err = dec.Token()
+	if !errors.Is(err, io.EOF) {
+		response.Error(w, http.StatusBadRequest, response.CodeInvalidBody, "unexpected data after the JSON value")
+		return v, false
+	}
+
+	errs := Validate(&v)
+	if len(errs) > 0 {
+		response.ValidationFailed(w, errs)
+		return v, false
+	}
+
+	return v, true
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeError tells the client what's wrong with a body that doesn't
+// decode, as precisely as encoding/json lets on.
+func decodeError(w http.ResponseWriter, err error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		response.Error(w, http.StatusBadRequest, response.CodeInvalidBody, "the body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		response.Error(w, http.StatusBadRequest, response.CodeInvalidBody, "malformed JSON: unexpected end of the body")
+	case errors.As(err, &syntaxErr):
+		response.Error(w, http.StatusBadRequest, response.CodeInvalidBody, fmt.Sprintf("malformed JSON at byte %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		response.Invalid(w, typeErr.Field, response.FieldInvalid, typeErr.Field+" must be "+jsonType(typeErr.Type))
+	case errors.As(err, &typeErr):
+		response.Error(w, http.StatusBadRequest, response.CodeInvalidBody, "the body must be "+jsonType(typeErr.Type))
+	default:
+		// encoding/json has no type for these.
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			name, _ = strconv.Unquote(name)
+			response.Invalid(w, name, response.FieldUnknown, "unknown field "+name)
+			return
+		}
+
+		response.InvalidRequestBody(w)
+	}
+}
+
+// jsonType describes t the way JSON would.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
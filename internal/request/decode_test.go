@@ -0,0 +1,175 @@
+package request
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/absurek/go-http-servers/internal/response"
+)
+
+type testChirp struct {
+	Body  string `json:"body" validate:"required,max=10"`
+	Count int    `json:"count"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantField   string
+	}{
+		{
+			name:        "valid",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"body":"hi","count":1}`,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "activity json",
+			contentType: "application/activity+json",
+			body:        `{"body":"hi"}`,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:       "no content type",
+			body:       `{"body":"hi"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantCode:   response.CodeUnsupportedMedia,
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `body=hi`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    response.CodeUnsupportedMedia,
+		},
+		{
+			name:        "empty",
+			contentType: "application/json",
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    response.CodeInvalidBody,
+		},
+		{
+			name:        "malformed",
+			contentType: "application/json",
+			body:        `{"body":}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    response.CodeInvalidBody,
+		},
+		{
+			name:        "cut off",
+			contentType: "application/json",
+			body:        `{"body":"hi"`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    response.CodeInvalidBody,
+		},
+		{
+			name:        "trailing data",
+			contentType: "application/json",
+			body:        `{"body":"hi"} {"body":"again"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    response.CodeInvalidBody,
+		},
+		{
+			name:        "trailing whitespace",
+			contentType: "application/json",
+			body:        "{\"body\":\"hi\"}\n",
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/json",
+			body:        `{"body":"hi","user_id":"x"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    response.CodeValidationFailed,
+			wantField:   "user_id",
+		},
+		{
+			name:        "wrong type",
+			contentType: "application/json",
+			body:        `{"body":"hi","count":"1"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    response.CodeValidationFailed,
+			wantField:   "count",
+		},
+		{
+			name:        "not an object",
+			contentType: "application/json",
+			body:        `[]`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    response.CodeInvalidBody,
+		},
+		{
+			name:        "invalid",
+			contentType: "application/json",
+			body:        `{"body":"far too long"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    response.CodeValidationFailed,
+			wantField:   "body",
+		},
+		{
+			name:        "too large",
+			contentType: "application/json",
+			body:        `{"body":"` + strings.Repeat("a", MaxBodySize) + `"}`,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    response.CodePayloadTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			w := httptest.NewRecorder()
+			chirp, ok := DecodeJSON[testChirp](w, r)
+
+			if tt.wantStatus == http.StatusOK {
+				if !ok || chirp.Body != "hi" {
+					t.Fatalf("DecodeJSON() = %+v, %v, response %s", chirp, ok, w.Body)
+				}
+				return
+			}
+
+			if ok {
+				t.Fatalf("DecodeJSON() succeeded, want %d", tt.wantStatus)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			var problem response.Problem
+			err := json.Unmarshal(w.Body.Bytes(), &problem)
+			if err != nil {
+				t.Fatalf("body doesn't parse: %v", err)
+			}
+
+			if problem.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", problem.Code, tt.wantCode)
+			}
+
+			if tt.wantField != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != tt.wantField) {
+				t.Errorf("errors = %+v, want one about %s", problem.Errors, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestDecodeOptionalJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/drafts/1/publish", nil)
+	w := httptest.NewRecorder()
+
+	chirp, ok := DecodeOptionalJSON[testChirp](w, r)
+	if !ok || chirp != (testChirp{}) {
+		t.Errorf("DecodeOptionalJSON() = %+v, %v, want the zero value", chirp, ok)
+	}
+}
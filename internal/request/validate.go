@@ -0,0 +1,227 @@
+package request
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/google/uuid"
+)
+
+// Rules are declared in a validate tag, separated by commas:
+//
+//	required  not empty
+//	email     a bare email address
+//	https     an absolute https URL
+//	uuid      a UUID, or only UUIDs for slices
+//	min=N     at least N characters, or N items for slices
+//	max=N     at most N characters, or N items for slices
+//
+// The rules besides required pass empty values.
+type Rule struct {
+	Name string
+	// N of min and max.
+	Arg int
+}
+
+const (
+	RuleRequired = "required"
+	RuleEmail    = "email"
+	RuleHTTPS    = "https"
+	RuleUUID     = "uuid"
+	RuleMin      = "min"
+	RuleMax      = "max"
+)
+
+// Validator is implemented by requests with rules tags can't express, like
+// ones depending on several fields. Its errors come after those of the tags.
+type Validator interface {
+	Validate() []response.FieldError
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// ParseRules parses a validate tag. A tag it doesn't understand is a bug,
+// so it panics.
+func ParseRules(tag string) []Rule {
+	if tag == "" {
+		return nil
+	}
+
+	var rules []Rule
+	for _, part := range strings.Split(tag, ",") {
+		name, arg, hasArg := strings.Cut(part, "=")
+
+		rule := Rule{Name: name}
+		switch name {
+		case RuleRequired, RuleEmail, RuleHTTPS, RuleUUID:
+			if hasArg {
+				panic(fmt.Sprintf("validate tag %q: %s takes no argument", tag, name))
+			}
+		case RuleMin, RuleMax:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				panic(fmt.Sprintf("validate tag %q: %s needs a length", tag, name))
+			}
+			rule.Arg = n
+		default:
+			panic(fmt.Sprintf("validate tag %q: unknown rule %q", tag, name))
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// Validate checks v, a struct or a pointer to one, against its validate
+// tags and its Validate method. Fields are named as in JSON.
+func Validate(v any) []response.FieldError {
+	var errs []response.FieldError
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Struct {
+		errs = validateStruct(rv, "", errs)
+	}
+
+	if validator, ok := v.(Validator); ok {
+		errs = append(errs, validator.Validate()...)
+	}
+
+	return errs
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs []response.FieldError) []response.FieldError {
+	t := rv.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		name = prefix + name
+
+		value := rv.Field(i)
+		if err, ok := checkRules(name, value, ParseRules(field.Tag.Get("validate"))); !ok {
+			errs = append(errs, err)
+			continue
+		}
+
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+
+		if value.Kind() == reflect.Struct && value.Type() != timeType {
+			errs = validateStruct(value, name+".", errs)
+		}
+	}
+
+	return errs
+}
+
+// checkRules reports the first rule value breaks.
+func checkRules(name string, value reflect.Value, rules []Rule) (response.FieldError, bool) {
+	for _, rule := range rules {
+		if isEmpty(value) {
+			if rule.Name == RuleRequired {
+				return response.FieldError{Field: name, Code: response.FieldRequired, Message: name + " is required"}, false
+			}
+
+			continue
+		}
+
+		err, ok := checkRule(name, value, rule)
+		if !ok {
+			return err, false
+		}
+	}
+
+	return response.FieldError{}, true
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+func checkRule(name string, value reflect.Value, rule Rule) (response.FieldError, bool) {
+	invalid := func(message string) (response.FieldError, bool) {
+		return response.FieldError{Field: name, Code: response.FieldInvalid, Message: message}, false
+	}
+
+	switch rule.Name {
+	case RuleEmail:
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Name != "" || address.Address != value.String() {
+			return invalid(name + " must be an email address")
+		}
+	case RuleHTTPS:
+		u, err := url.Parse(value.String())
+		if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+			return invalid(name + " must be an https url")
+		}
+	case RuleUUID:
+		if value.Kind() == reflect.Slice {
+			for i := range value.Len() {
+				_, err := uuid.Parse(value.Index(i).String())
+				if err != nil {
+					return invalid(fmt.Sprintf("%s[%d] must be a uuid", name, i))
+				}
+			}
+
+			return response.FieldError{}, true
+		}
+
+		_, err := uuid.Parse(value.String())
+		if err != nil {
+			return invalid(name + " must be a uuid")
+		}
+	case RuleMin, RuleMax:
+		length, unit := value.Len(), "items"
+		if value.Kind() == reflect.String {
+			length, unit = utf8.RuneCountInString(value.String()), "characters"
+		}
+
+		if rule.Name == RuleMin && length < rule.Arg {
+			return response.FieldError{
+				Field:   name,
+				Code:    response.FieldTooShort,
+				Message: fmt.Sprintf("%s must be at least %d %s", name, rule.Arg, unit),
+			}, false
+		}
+
+		if rule.Name == RuleMax && length > rule.Arg {
+			code := response.FieldTooLong
+			if unit == "items" {
+				code = response.FieldTooMany
+			}
+
+			return response.FieldError{
+				Field:   name,
+				Code:    code,
+				Message: fmt.Sprintf("%s must be at most %d %s", name, rule.Arg, unit),
+			}, false
+		}
+	}
+
+	return response.FieldError{}, true
+}
@@ -0,0 +1,142 @@
+package request
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/absurek/go-http-servers/internal/response"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type testSignup struct {
+	Email    string       `json:"email" validate:"required,email,max=254"`
+	Name     string       `json:"name" validate:"min=2,max=5"`
+	Website  string       `json:"website" validate:"https"`
+	Friends  []string     `json:"friends" validate:"uuid,max=2"`
+	Address  *testAddress `json:"address"`
+	Password string       `json:"-" validate:"required"`
+	Accept   bool         `json:"accept"`
+}
+
+func (s testSignup) Validate() []response.FieldError {
+	if !s.Accept {
+		return []response.FieldError{{Field: "accept", Code: response.FieldRequired, Message: "the terms must be accepted"}}
+	}
+
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	valid := testSignup{
+		Email:   "alice@example.com",
+		Name:    "Alïce",
+		Website: "https://alice.example/",
+		Friends: []string{"0a8a4c2e-59f5-4d5f-a7ad-0c2c3d8e5e9b"},
+		Address: &testAddress{City: "Bern"},
+		Accept:  true,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *testSignup)
+		want   []response.FieldError
+	}{
+		{
+			name:   "valid",
+			modify: func(s *testSignup) {},
+		},
+		{
+			name: "optional fields left out",
+			modify: func(s *testSignup) {
+				s.Name, s.Website, s.Friends, s.Address = "", "", nil, nil
+			},
+		},
+		{
+			name:   "required",
+			modify: func(s *testSignup) { s.Email = "" },
+			want:   []response.FieldError{{Field: "email", Code: response.FieldRequired}},
+		},
+		{
+			name:   "email with a name",
+			modify: func(s *testSignup) { s.Email = "Alice <alice@example.com>" },
+			want:   []response.FieldError{{Field: "email", Code: response.FieldInvalid}},
+		},
+		{
+			name:   "too long",
+			modify: func(s *testSignup) { s.Email = strings.Repeat("a", 250) + "@example.com" },
+			want:   []response.FieldError{{Field: "email", Code: response.FieldTooLong}},
+		},
+		{
+			name:   "too short",
+			modify: func(s *testSignup) { s.Name = "A" },
+			want:   []response.FieldError{{Field: "name", Code: response.FieldTooShort}},
+		},
+		{
+			name:   "http",
+			modify: func(s *testSignup) { s.Website = "http://alice.example/" },
+			want:   []response.FieldError{{Field: "website", Code: response.FieldInvalid}},
+		},
+		{
+			name:   "not a uuid",
+			modify: func(s *testSignup) { s.Friends = []string{valid.Friends[0], "bob"} },
+			want:   []response.FieldError{{Field: "friends", Code: response.FieldInvalid}},
+		},
+		{
+			name:   "too many",
+			modify: func(s *testSignup) { s.Friends = slices.Repeat(valid.Friends, 3) },
+			want:   []response.FieldError{{Field: "friends", Code: response.FieldTooMany}},
+		},
+		{
+			name:   "nested",
+			modify: func(s *testSignup) { s.Address = &testAddress{} },
+			want:   []response.FieldError{{Field: "address.city", Code: response.FieldRequired}},
+		},
+		{
+			name: "all at once",
+			modify: func(s *testSignup) {
+				s.Email, s.Website, s.Accept = "", "ftp://alice.example/", false
+			},
+			want: []response.FieldError{
+				{Field: "email", Code: response.FieldRequired},
+				{Field: "website", Code: response.FieldInvalid},
+				{Field: "accept", Code: response.FieldRequired},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.modify(&s)
+
+			errs := Validate(&s)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("Validate() = %+v, want %+v", errs, tt.want)
+			}
+
+			for i := range errs {
+				if errs[i].Field != tt.want[i].Field || errs[i].Code != tt.want[i].Code || errs[i].Message == "" {
+					t.Errorf("Validate()[%d] = %+v, want %+v", i, errs[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRulesPanics(t *testing.T) {
+	for _, tag := range []string{"requird", "max", "max=-1", "email=1"} {
+		t.Run(tag, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ParseRules(%q) didn't panic", tag)
+				}
+			}()
+
+			ParseRules(tag)
+		})
+	}
+}
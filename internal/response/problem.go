@@ -0,0 +1,224 @@
+package response
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ContentTypeProblem = "application/problem+json"
+	// Clients listing this in Accept get the old {"error": "..."} bodies
+	// while they migrate to problem documents.
+	ContentTypeLegacyError = "application/vnd.chirpy.legacy-error+json"
+
+	RequestIDHeader = "X-Request-ID"
+)
+
+// Codes of problems, stable for clients to switch on. Handlers use more
+// specific ones where a client can act on the difference.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeInvalidBody        = "invalid_body"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodePayloadTooLarge    = "payload_too_large"
+	CodeUnsupportedMedia   = "unsupported_media_type"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+	CodeServiceUnavailable = "service_unavailable"
+)
+
+// Codes of field errors.
+const (
+	FieldRequired = "required"
+	FieldInvalid  = "invalid"
+	FieldTooShort = "too_short"
+	FieldTooLong  = "too_long"
+	FieldTooMany  = "too_many"
+	FieldUnknown  = "unknown"
+)
+
+// Problem is an RFC 9457 problem document.
+type Problem struct {
+	// "/problems/<code>" unless set.
+	Type string `json:"type"`
+	// The status text unless set.
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// What went wrong this time, for humans.
+	Detail string `json:"detail,omitempty"`
+	// The request path, set by Middleware.
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is what's wrong with one field of the body, or one query or
+// path parameter.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type legacyError struct {
+	ErrorText string `json:"error"`
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// problemWriter carries what problems need to know about the request to
+// the helpers, which only get the ResponseWriter.
+type problemWriter struct {
+	http.ResponseWriter
+	requestID string
+	instance  string
+	legacy    bool
+}
+
+func (w *problemWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush and Hijack are passed through for event streams and websockets,
+// which assert them on the writer.
+func (w *problemWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *problemWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Middleware gives every request an ID, taken from X-Request-ID when the
+// client sent a sane one, echoes it back and negotiates the error format.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+
+		next.ServeHTTP(&problemWriter{
+			ResponseWriter: w,
+			requestID:      requestID,
+			instance:       r.URL.Path,
+			legacy:         acceptsLegacy(r.Header.Get("Accept")),
+		}, r)
+	})
+}
+
+func acceptsLegacy(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), ContentTypeLegacyError) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findProblemWriter looks through the writers wrapping the one Middleware
+// made.
+func findProblemWriter(w http.ResponseWriter) *problemWriter {
+	for {
+		switch rw := w.(type) {
+		case *problemWriter:
+			return rw
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// WriteProblem writes p, filling in what it leaves out.
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	if p.Type == "" {
+		p.Type = "/problems/" + strings.ReplaceAll(p.Code, "_", "-")
+	}
+
+	legacy := false
+	if pw := findProblemWriter(w); pw != nil {
+		p.RequestID = pw.requestID
+		p.Instance = pw.instance
+		legacy = pw.legacy
+	}
+
+	var body any = p
+	contentType := ContentTypeProblem
+	if legacy {
+		body = legacyError{ErrorText: p.legacyText()}
+		contentType = "application/json"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(p.Status)
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+
+	w.Write(payload)
+}
+
+// legacyText is what the old format said.
+func (p Problem) legacyText() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+
+	return strings.ToLower(p.Title)
+}
+
+// Error writes a problem with a code and detail.
+func Error(w http.ResponseWriter, status int, code, detail string) {
+	WriteProblem(w, Problem{
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	})
+}
+
+// ValidationFailed answers 400 listing every field error.
+func ValidationFailed(w http.ResponseWriter, errs []FieldError) {
+	messages := make([]string, 0, len(errs))
+	for _, fieldErr := range errs {
+		messages = append(messages, fieldErr.Message)
+	}
+
+	WriteProblem(w, Problem{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidationFailed,
+		Detail: strings.Join(messages, "; "),
+		Errors: errs,
+	})
+}
+
+// Invalid answers 400 for a single field or parameter.
+func Invalid(w http.ResponseWriter, field, code, message string) {
+	ValidationFailed(w, []FieldError{{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	}})
+}
@@ -0,0 +1,169 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serve(t *testing.T, r *http.Request, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	Middleware(handler).ServeHTTP(rec, r)
+
+	return rec
+}
+
+func TestProblem(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/chirps?draft=1", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+
+	rec := serve(t, r, func(w http.ResponseWriter, r *http.Request) {
+		ValidationFailed(w, []FieldError{
+			{Field: "body", Code: FieldRequired, Message: "body is required"},
+			{Field: "publish_at", Code: FieldInvalid, Message: "invalid publish_at"},
+		})
+	})
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if got := rec.Header().Get("Content-Type"); got != ContentTypeProblem {
+		t.Errorf("Content-Type = %q, want %q", got, ContentTypeProblem)
+	}
+
+	if got := rec.Header().Get(RequestIDHeader); got != "req-1" {
+		t.Errorf("%s = %q, want the client's", RequestIDHeader, got)
+	}
+
+	var got Problem
+	err := json.Unmarshal(rec.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("body doesn't parse: %v", err)
+	}
+
+	want := Problem{
+		Type:      "/problems/validation-failed",
+		Title:     "Bad Request",
+		Status:    http.StatusBadRequest,
+		Detail:    "body is required; invalid publish_at",
+		Instance:  "/api/chirps",
+		Code:      CodeValidationFailed,
+		RequestID: "req-1",
+	}
+	if got.Type != want.Type || got.Title != want.Title || got.Status != want.Status || got.Detail != want.Detail ||
+		got.Instance != want.Instance || got.Code != want.Code || got.RequestID != want.RequestID {
+		t.Errorf("problem = %+v, want %+v", got, want)
+	}
+
+	if len(got.Errors) != 2 || got.Errors[1].Field != "publish_at" || got.Errors[1].Code != FieldInvalid {
+		t.Errorf("errors = %+v", got.Errors)
+	}
+}
+
+func TestProblemLegacy(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter)
+		want  string
+	}{
+		{
+			name:  "detail",
+			write: func(w http.ResponseWriter) { Invalid(w, "chirpID", FieldInvalid, "invalid chirp id") },
+			want:  "invalid chirp id",
+		},
+		{
+			name:  "title",
+			write: NotFound,
+			want:  "not found",
+		},
+		{
+			name:  "internal",
+			write: InternalServerError,
+			want:  "internal server error",
+		},
+		{
+			name:  "own title",
+			write: PayloadTooLarge,
+			want:  "payload too large",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+			r.Header.Set("Accept", "application/json, "+ContentTypeLegacyError+";q=0.9")
+
+			rec := serve(t, r, func(w http.ResponseWriter, r *http.Request) {
+				tt.write(w)
+			})
+
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+
+			var got map[string]any
+			err := json.Unmarshal(rec.Body.Bytes(), &got)
+			if err != nil {
+				t.Fatalf("body doesn't parse: %v", err)
+			}
+
+			if len(got) != 1 || got["error"] != tt.want {
+				t.Errorf("body = %v, want {\"error\": %q}", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		keepsOwn bool
+	}{
+		{name: "missing"},
+		{name: "sane", header: "0a1b-2c.3d_4e", keepsOwn: true},
+		{name: "spaces", header: "a b"},
+		{name: "header injection", header: "a\r\nSet-Cookie: x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+			if tt.header != "" {
+				r.Header.Set(RequestIDHeader, tt.header)
+			}
+
+			rec := serve(t, r, func(w http.ResponseWriter, r *http.Request) {
+				Unauthorized(w)
+			})
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got == "" {
+				t.Fatalf("%s not set", RequestIDHeader)
+			}
+
+			if (got == tt.header) != tt.keepsOwn {
+				t.Errorf("%s = %q, client sent %q", RequestIDHeader, got, tt.header)
+			}
+		})
+	}
+}
+
+func TestProblemWithoutMiddleware(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Conflict(rec, "the email is used by another account")
+
+	var got Problem
+	err := json.Unmarshal(rec.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("body doesn't parse: %v", err)
+	}
+
+	if got.Code != CodeConflict || got.Status != http.StatusConflict || got.RequestID != "" || got.Instance != "" {
+		t.Errorf("problem = %+v", got)
+	}
+}
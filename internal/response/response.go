@@ -5,22 +5,8 @@ import (
 	"net/http"
 )
 
-type errorResponse struct {
-	ErrorText string `json:"error"`
-}
-
 func InternalServerError(w http.ResponseWriter) {
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
-
-	payload, err := json.Marshal(errorResponse{
-		ErrorText: "internal server error",
-	})
-	if err != nil {
-		return
-	}
-
-	w.Write(payload)
+	Error(w, http.StatusInternalServerError, CodeInternal, "")
 }
 
 func JSON[T any](w http.ResponseWriter, status int, resp T) {
@@ -37,25 +23,21 @@ func JSON[T any](w http.ResponseWriter, status int, resp T) {
 }
 
 func NotFound(w http.ResponseWriter) {
-	JSON(w, http.StatusNotFound, errorResponse{
-		ErrorText: "not found",
-	})
+	Error(w, http.StatusNotFound, CodeNotFound, "")
 }
 
 func BadRequest(w http.ResponseWriter, errorText string) {
-	JSON(w, http.StatusBadRequest, errorResponse{
-		ErrorText: errorText,
-	})
+	Error(w, http.StatusBadRequest, CodeBadRequest, errorText)
 }
 
+// InvalidRequestBody is for bodies that don't decode, ValidationFailed for
+// ones that do but break the rules.
 func InvalidRequestBody(w http.ResponseWriter) {
-	BadRequest(w, "invalid request body")
+	Error(w, http.StatusBadRequest, CodeInvalidBody, "invalid request body")
 }
 
 func Unauthorized(w http.ResponseWriter) {
-	JSON(w, http.StatusUnauthorized, errorResponse{
-		ErrorText: "unauthorized",
-	})
+	Error(w, http.StatusUnauthorized, CodeUnauthorized, "")
 }
 
 func NoContent(w http.ResponseWriter) {
@@ -63,7 +45,29 @@ func NoContent(w http.ResponseWriter) {
 }
 
 func Forbidden(w http.ResponseWriter) {
-	JSON(w, http.StatusForbidden, errorResponse{
-		ErrorText: "forbidden",
+	Error(w, http.StatusForbidden, CodeForbidden, "")
+}
+
+func Conflict(w http.ResponseWriter, errorText string) {
+	Error(w, http.StatusConflict, CodeConflict, errorText)
+}
+
+func PayloadTooLarge(w http.ResponseWriter) {
+	WriteProblem(w, Problem{
+		Title:  "Payload Too Large",
+		Status: http.StatusRequestEntityTooLarge,
+		Code:   CodePayloadTooLarge,
 	})
 }
+
+func TooManyRequests(w http.ResponseWriter) {
+	Error(w, http.StatusTooManyRequests, CodeRateLimited, "")
+}
+
+func UnsupportedMediaType(w http.ResponseWriter) {
+	Error(w, http.StatusUnsupportedMediaType, CodeUnsupportedMedia, "")
+}
+
+func ServiceUnavailable(w http.ResponseWriter) {
+	Error(w, http.StatusServiceUnavailable, CodeServiceUnavailable, "")
+}
@@ -3,15 +3,55 @@ package settings
 import "os"
 
 type Settings struct {
+	BaseURL string
+
 	DBUrl     string
 	JWTSecret string
 	PolkaKey  string
+
+	PolkaWebhookSecrets string
+
+	MediaStore  string
+	MediaDir    string
+	S3Endpoint  string
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+
+	MessageKeys string
+
+	ExportLinkSecret string
+
+	JobWorkers string
+
+	WSAllowedOrigins string
 }
 
 func NewSettings() Settings {
 	return Settings{
+		BaseURL: os.Getenv("BASE_URL"),
+
 		DBUrl:     os.Getenv("DB_URL"),
 		JWTSecret: os.Getenv("JWT_SECRET"),
 		PolkaKey:  os.Getenv("POLKA_KEY"),
+
+		PolkaWebhookSecrets: os.Getenv("POLKA_WEBHOOK_SECRETS"),
+
+		MediaStore:  os.Getenv("MEDIA_STORE"),
+		MediaDir:    os.Getenv("MEDIA_DIR"),
+		S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+		S3Bucket:    os.Getenv("S3_BUCKET"),
+		S3Region:    os.Getenv("S3_REGION"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+
+		MessageKeys: os.Getenv("MESSAGE_KEYS"),
+
+		ExportLinkSecret: os.Getenv("EXPORT_LINK_SECRET"),
+
+		JobWorkers: os.Getenv("JOB_WORKERS"),
+
+		WSAllowedOrigins: os.Getenv("WS_ALLOWED_ORIGINS"),
 	}
 }
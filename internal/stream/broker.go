@@ -0,0 +1,135 @@
+package stream
+
+import (
+	"errors"
+	"sync"
+)
+
+const subscriptionBuffer = 64
+
+var ErrClosed = errors.New("broker closed")
+
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+}
+
+// Broker fans events out to the subscribers of this instance and remembers
+// the most recent ones so clients can resume with Last-Event-ID.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	replay      []Event
+	replayStart int
+	replaySize  int
+	closed      bool
+}
+
+func NewBroker(replaySize int) *Broker {
+	return &Broker{
+		subscribers: make(map[*Subscription]struct{}),
+		replay:      make([]Event, 0, replaySize),
+		replaySize:  replaySize,
+	}
+}
+
+// Subscribe registers a new subscriber. When lastEventID is non zero the
+// events after it are returned for replay, ok is false if that event already
+// fell out of the replay buffer and the client has to resync.
+func (b *Broker) Subscribe(lastEventID int64) (sub *Subscription, replay []Event, ok bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, nil, false, ErrClosed
+	}
+
+	events := make(chan Event, subscriptionBuffer)
+	sub = &Subscription{Events: events, events: events}
+	b.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil, true, nil
+	}
+
+	// Events are replayed by position rather than by comparing IDs, IDs
+	// come from a sequence and can arrive slightly out of order.
+	buffered := b.buffered()
+	for i, event := range buffered {
+		if event.ID == lastEventID {
+			return sub, buffered[i+1:], true, nil
+		}
+	}
+
+	return sub, nil, false, nil
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Publish delivers an event to every subscriber of this instance. A
+// subscriber that can't keep up is dropped, its channel gets closed and the
+// client is expected to reconnect with Last-Event-ID.
+func (b *Broker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.remember(event)
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Close ends every subscription, used on shutdown so long lived streams don't
+// hold up the HTTP server.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (b *Broker) remember(event Event) {
+	if b.replaySize == 0 {
+		return
+	}
+
+	if len(b.replay) < b.replaySize {
+		b.replay = append(b.replay, event)
+		return
+	}
+
+	b.replay[b.replayStart] = event
+	b.replayStart = (b.replayStart + 1) % b.replaySize
+}
+
+func (b *Broker) buffered() []Event {
+	events := make([]Event, 0, len(b.replay))
+	events = append(events, b.replay[b.replayStart:]...)
+	return append(events, b.replay[:b.replayStart]...)
+}
@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"testing"
+)
+
+func eventIDs(events []Event) []int64 {
+	var ids []int64
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+
+	return ids
+}
+
+func TestBrokerReplay(t *testing.T) {
+	b := NewBroker(3)
+	for _, id := range []int64{1, 2, 4, 3, 5} {
+		b.Publish(Event{ID: id})
+	}
+
+	tests := []struct {
+		name         string
+		lastEventID  int64
+		wantIDs      []int64
+		wantComplete bool
+	}{
+		{
+			name:         "No last event id",
+			lastEventID:  0,
+			wantIDs:      nil,
+			wantComplete: true,
+		},
+		{
+			name:         "Resume by position",
+			lastEventID:  4,
+			wantIDs:      []int64{3, 5},
+			wantComplete: true,
+		},
+		{
+			name:         "Up to date",
+			lastEventID:  5,
+			wantIDs:      nil,
+			wantComplete: true,
+		},
+		{
+			name:         "Fell out of the buffer",
+			lastEventID:  1,
+			wantIDs:      nil,
+			wantComplete: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete, err := b.Subscribe(tt.lastEventID)
+			if err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+			defer b.Unsubscribe(sub)
+
+			gotIDs := eventIDs(replay)
+			if complete != tt.wantComplete || len(gotIDs) != len(tt.wantIDs) {
+				t.Fatalf("Subscribe() = %v (complete %v), want %v (complete %v)", gotIDs, complete, tt.wantIDs, tt.wantComplete)
+			}
+
+			for i := range gotIDs {
+				if gotIDs[i] != tt.wantIDs[i] {
+					t.Errorf("Subscribe() = %v, want %v", gotIDs, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(0)
+	slow, _, _, _ := b.Subscribe(0)
+
+	for i := range subscriptionBuffer + 1 {
+		b.Publish(Event{ID: int64(i)})
+	}
+
+	received := 0
+	for range slow.Events {
+		received++
+	}
+
+	if received != subscriptionBuffer {
+		t.Errorf("slow subscriber received %d events before being dropped, want %d", received, subscriptionBuffer)
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(1)
+	sub, _, _, _ := b.Subscribe(0)
+
+	b.Close()
+
+	if _, ok := <-sub.Events; ok {
+		t.Errorf("subscription still open after Close()")
+	}
+
+	if _, _, _, err := b.Subscribe(0); err != ErrClosed {
+		t.Errorf("Subscribe() after Close() error = %v, want ErrClosed", err)
+	}
+}
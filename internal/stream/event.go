@@ -0,0 +1,26 @@
+package stream
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventChirpUpdated = "chirp.updated"
+)
+
+// Event is what travels through Postgres NOTIFY and what SSE clients receive
+// as data. Chirp holds the same JSON as the REST API returns for the chirp and
+// is left out for deletions.
+type Event struct {
+	ID       int64           `json:"id"`
+	Type     string          `json:"type"`
+	ChirpID  uuid.UUID       `json:"chirp_id"`
+	AuthorID uuid.UUID       `json:"author_id"`
+	Hashtags []string        `json:"hashtags,omitempty"`
+	Mentions []uuid.UUID     `json:"mentions,omitempty"`
+	Chirp    json.RawMessage `json:"chirp,omitempty"`
+}
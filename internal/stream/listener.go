@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	notifyChannel        = "chirp_events"
+	minReconnectInterval = 1 * time.Second
+	maxReconnectInterval = 1 * time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+// Listener feeds the local broker with the events every instance publishes
+// through Postgres NOTIFY, this is what makes streams work across instances.
+type Listener struct {
+	listener *pq.Listener
+	broker   *Broker
+	logger   *log.Logger
+	done     chan struct{}
+}
+
+func NewListener(dbURL string, broker *Broker, logger *log.Logger) (*Listener, error) {
+	l := &Listener{
+		broker: broker,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	l.listener = pq.NewListener(dbURL, minReconnectInterval, maxReconnectInterval, l.reportProblem)
+	err := l.listener.Listen(notifyChannel)
+	if err != nil {
+		l.listener.Close()
+		return nil, fmt.Errorf("listen %s: %w", notifyChannel, err)
+	}
+
+	go l.run()
+	return l, nil
+}
+
+func (l *Listener) run() {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case n, ok := <-l.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// A nil notification means the connection was re-established,
+			// anything sent in between is lost and clients will resync.
+			if n == nil {
+				continue
+			}
+
+			var event Event
+			err := json.Unmarshal([]byte(n.Extra), &event)
+			if err != nil {
+				l.logger.Printf("Error(Listener): decode event: %v", err)
+				continue
+			}
+
+			l.broker.Publish(event)
+		case <-ticker.C:
+			go l.listener.Ping()
+		}
+	}
+}
+
+func (l *Listener) reportProblem(ev pq.ListenerEventType, err error) {
+	if err != nil {
+		l.logger.Printf("Error(Listener): %v", err)
+	}
+}
+
+func (l *Listener) Close() error {
+	close(l.done)
+	return l.listener.Close()
+}
@@ -0,0 +1,25 @@
+package stream
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:     "GET /api/stream",
+		Tag:         "chirps",
+		Summary:     "Stream chirp events",
+		Description: "Server-sent events, each event's data is an Event. Reconnecting with Last-Event-ID replays what was missed, as far as the buffer reaches. Filters are combined with AND, their comma separated values with OR.",
+		Auth:        openapi.AuthOptional,
+		Params: []openapi.Param{
+			openapi.Query("author_id", "Only chirps by these users."),
+			openapi.Query("hashtag", "Only chirps with one of these hashtags."),
+			openapi.Query("following", "true for only chirps by users the viewer follows, needs a token."),
+			openapi.Header("Last-Event-ID", "The last event seen, ?last_event_id= for clients that can't set it."),
+		},
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The event stream", ContentType: "text/event-stream", Body: Event{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusServiceUnavailable},
+	},
+}
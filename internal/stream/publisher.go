@@ -0,0 +1,36 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/absurek/go-http-servers/internal/database"
+)
+
+type Publisher struct {
+	dbQueries *database.Queries
+}
+
+func NewPublisher(dbQueries *database.Queries) *Publisher {
+	return &Publisher{
+		dbQueries: dbQueries,
+	}
+}
+
+// Publish hands the event to Postgres, every instance (this one included)
+// picks it up through its Listener.
+func (p *Publisher) Publish(ctx context.Context, event Event) error {
+	id, err := p.dbQueries.NextChirpEventID(ctx)
+	if err != nil {
+		return fmt.Errorf("next event id: %w", err)
+	}
+	event.ID = id
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	return p.dbQueries.NotifyChirpEvent(ctx, string(payload))
+}
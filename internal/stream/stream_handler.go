@@ -0,0 +1,245 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/chirptext"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+	clientRetry       = 3 * time.Second
+)
+
+type filter struct {
+	authorIDs []uuid.UUID
+	hashtags  []string
+	// following narrows the stream to the users the viewer follows, they
+	// are resolved to followed when connecting.
+	following bool
+	followed  map[uuid.UUID]bool
+	rules     *visibility.Rules
+}
+
+func parseFilter(r *http.Request) (filter, error) {
+	var f filter
+	query := r.URL.Query()
+
+	for _, value := range query["author_id"] {
+		for _, s := range strings.Split(value, ",") {
+			authorID, err := uuid.Parse(s)
+			if err != nil {
+				return filter{}, fmt.Errorf("invalid author_id: %s", s)
+			}
+
+			f.authorIDs = append(f.authorIDs, authorID)
+		}
+	}
+
+	for _, value := range query["hashtag"] {
+		for _, s := range strings.Split(value, ",") {
+			tag := chirptext.NormalizeHashtag(s)
+			if tag == "" {
+				return filter{}, fmt.Errorf("invalid hashtag: %q", s)
+			}
+
+			f.hashtags = append(f.hashtags, tag)
+		}
+	}
+
+	if value := query.Get("following"); value != "" {
+		following, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter{}, fmt.Errorf("invalid following: %q", value)
+		}
+
+		f.following = following
+	}
+
+	return f, nil
+}
+
+// matches combines the filters with AND, values of the same filter with OR.
+// Muted authors only come through when the client asked for them by ID.
+func (f filter) matches(event Event) bool {
+	if len(f.authorIDs) > 0 {
+		if !slices.Contains(f.authorIDs, event.AuthorID) || !f.rules.CanSee(event.AuthorID) {
+			return false
+		}
+	} else if !f.rules.InTimeline(event.AuthorID) {
+		return false
+	}
+
+	if f.following && !f.followed[event.AuthorID] {
+		return false
+	}
+
+	if len(f.hashtags) > 0 && !slices.ContainsFunc(f.hashtags, func(tag string) bool {
+		return slices.Contains(event.Hashtags, tag)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+type StreamHandler struct {
+	settings  settings.Settings
+	dbQueries *database.Queries
+	broker    *Broker
+	filter    *visibility.Filter
+	logger    *log.Logger
+}
+
+func NewStreamHandler(s settings.Settings, dbQueries *database.Queries, broker *Broker, filter *visibility.Filter, logger *log.Logger) *StreamHandler {
+	return &StreamHandler{
+		settings:  s,
+		dbQueries: dbQueries,
+		broker:    broker,
+		filter:    filter,
+		logger:    logger,
+	}
+}
+
+func lastEventID(r *http.Request) int64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+func writeEvent(w io.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+func (h *StreamHandler) GetStream(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := auth.GetOptionalUserID(r.Header, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	f, err := parseFilter(r)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "invalid_filter", err.Error())
+		return
+	}
+
+	if f.following && !viewerID.Valid {
+		response.Unauthorized(w)
+		return
+	}
+
+	// Blocks, mutes and follows are read once, they apply from the next
+	// connection.
+	f.rules, err = h.filter.ForViewer(r.Context(), viewerID)
+	if err != nil {
+		h.logger.Printf("Error(GetStream): visibility rules: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if f.following {
+		followedIDs, err := h.dbQueries.GetFollowedUserIDs(r.Context(), viewerID.UUID)
+		if err != nil {
+			h.logger.Printf("Error(GetStream): db get followed user ids (user_id=%s): %v", viewerID.UUID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		f.followed = make(map[uuid.UUID]bool, len(followedIDs))
+		for _, followedID := range followedIDs {
+			f.followed[followedID] = true
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.logger.Printf("Error(GetStream): response writer does not support flushing")
+		response.InternalServerError(w)
+		return
+	}
+
+	sub, replay, complete, err := h.broker.Subscribe(lastEventID(r))
+	if err != nil {
+		response.ServiceUnavailable(w)
+		return
+	}
+	defer h.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", clientRetry.Milliseconds())
+	if !complete {
+		// The client missed more than we remember, it should refetch.
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+
+	for _, event := range replay {
+		if f.matches(event) {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+
+			if !f.matches(event) {
+				continue
+			}
+
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+func TestFilterMatches(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name     string
+		query    string
+		followed []uuid.UUID
+		event    Event
+		want     bool
+	}{
+		{
+			name:  "No filter",
+			event: Event{AuthorID: alice},
+			want:  true,
+		},
+		{
+			name:  "Author",
+			query: "author_id=" + alice.String() + "," + bob.String(),
+			event: Event{AuthorID: bob},
+			want:  true,
+		},
+		{
+			name:  "Other author",
+			query: "author_id=" + alice.String(),
+			event: Event{AuthorID: carol},
+			want:  false,
+		},
+		{
+			name:  "Hashtag",
+			query: "hashtag=Go,rust",
+			event: Event{AuthorID: alice, Hashtags: []string{"go"}},
+			want:  true,
+		},
+		{
+			name:  "Other hashtag",
+			query: "hashtag=go",
+			event: Event{AuthorID: alice, Hashtags: []string{"zig"}},
+			want:  false,
+		},
+		{
+			name:     "Followed",
+			query:    "following=true",
+			followed: []uuid.UUID{alice, bob},
+			event:    Event{AuthorID: bob},
+			want:     true,
+		},
+		{
+			name:     "Not followed",
+			query:    "following=true",
+			followed: []uuid.UUID{alice},
+			event:    Event{AuthorID: carol},
+			want:     false,
+		},
+		{
+			name:  "Following nobody",
+			query: "following=true",
+			event: Event{AuthorID: alice},
+			want:  false,
+		},
+		{
+			name:     "Followed without the hashtag",
+			query:    "following=true&hashtag=go",
+			followed: []uuid.UUID{alice},
+			event:    Event{AuthorID: alice, Hashtags: []string{"zig"}},
+			want:     false,
+		},
+		{
+			name:  "Following off",
+			query: "following=false",
+			event: Event{AuthorID: carol},
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseFilter(httptest.NewRequest("GET", "/api/stream?"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("parseFilter() error = %v", err)
+			}
+
+			f.rules = visibility.Public
+			if f.following {
+				f.followed = make(map[uuid.UUID]bool)
+				for _, id := range tt.followed {
+					f.followed[id] = true
+				}
+			}
+
+			if got := f.matches(tt.event); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterInvalid(t *testing.T) {
+	for _, query := range []string{"author_id=nope", "hashtag=%23", "following=maybe"} {
+		_, err := parseFilter(httptest.NewRequest("GET", "/api/stream?"+query, nil))
+		if err == nil {
+			t.Errorf("parseFilter(%q) error = nil, want an error", query)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package subscriptions
+
+import (
+	"context"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/jobs"
+)
+
+const expireBatchSize = 100
+
+// ExpireJob ends subscriptions whose period ran out without a renewal, it is
+// scheduled every minute.
+var ExpireJob = jobs.Kind[struct{}]{Name: "subscriptions.expire", Timeout: 5 * time.Minute}
+
+// Expire runs ExpireDue in batches until nothing is due.
+func (svc *Service) Expire(ctx context.Context, _ struct{}) error {
+	for {
+		expired, err := svc.ExpireDue(ctx, expireBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if expired < expireBatchSize {
+			return nil
+		}
+	}
+}
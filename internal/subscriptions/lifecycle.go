@@ -0,0 +1,111 @@
+package subscriptions
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	StatusActive = "active"
+	// The last payment failed. Polka keeps retrying, the user keeps Chirpy
+	// Red until the period ends.
+	StatusPastDue = "past_due"
+	// The user canceled. Chirpy Red stays until the period ends.
+	StatusCanceled = "canceled"
+	StatusExpired  = "expired"
+
+	EventUpgraded      = "user.upgraded"
+	EventRenewed       = "user.renewed"
+	EventPaymentFailed = "user.payment_failed"
+	EventCanceled      = "user.canceled"
+	EventDowngraded    = "user.downgraded"
+	// Recorded by the expirer, Polka never sends it.
+	EventExpired = "subscription.expired"
+
+	// Polka only sometimes tells us when a period ends, the plan is monthly.
+	defaultPeriod = 30 * 24 * time.Hour
+)
+
+var ErrUnknownEvent = errors.New("unknown subscription event")
+
+// State is where a subscription stands.
+type State struct {
+	Status            string
+	PeriodEnd         time.Time
+	CancelAtPeriodEnd bool
+}
+
+// Entitled reports whether the subscription grants Chirpy Red at now.
+func (s State) Entitled(now time.Time) bool {
+	return s.Status != StatusExpired && now.Before(s.PeriodEnd)
+}
+
+// Handles reports whether event is one Polka sends about subscriptions.
+func Handles(event string) bool {
+	switch event {
+	case EventUpgraded, EventRenewed, EventPaymentFailed, EventCanceled, EventDowngraded:
+		return true
+	}
+
+	return false
+}
+
+// Transition applies a Polka event to a subscription. current is the zero
+// State for users who never subscribed. periodEnd is the end of the period
+// the event reports, zero if it reports none.
+func Transition(current State, event string, periodEnd, now time.Time) (State, error) {
+	switch event {
+	case EventUpgraded:
+		if periodEnd.IsZero() {
+			periodEnd = now.Add(defaultPeriod)
+		}
+
+		return State{Status: StatusActive, PeriodEnd: periodEnd}, nil
+	case EventRenewed:
+		if periodEnd.IsZero() {
+			// Renewing early doesn't forfeit what's left of the period.
+			start := now
+			if current.Entitled(now) {
+				start = current.PeriodEnd
+			}
+			periodEnd = start.Add(defaultPeriod)
+		}
+
+		return State{Status: StatusActive, PeriodEnd: periodEnd}, nil
+	case EventPaymentFailed, EventCanceled:
+		// Nothing left to fail or cancel, the event is only history.
+		if !current.Entitled(now) {
+			return expired(current, now), nil
+		}
+
+		next := current
+		if !periodEnd.IsZero() {
+			next.PeriodEnd = periodEnd
+		}
+
+		if event == EventCanceled {
+			next.Status = StatusCanceled
+			next.CancelAtPeriodEnd = true
+		} else if next.Status != StatusCanceled {
+			// A canceled subscription stays canceled, it won't be renewed
+			// either way.
+			next.Status = StatusPastDue
+		}
+
+		return next, nil
+	case EventDowngraded:
+		// Takes effect right away, unlike a cancellation.
+		return expired(current, now), nil
+	}
+
+	return State{}, ErrUnknownEvent
+}
+
+func expired(current State, now time.Time) State {
+	periodEnd := current.PeriodEnd
+	if periodEnd.IsZero() || periodEnd.After(now) {
+		periodEnd = now
+	}
+
+	return State{Status: StatusExpired, PeriodEnd: periodEnd}
+}
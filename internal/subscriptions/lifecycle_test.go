@@ -0,0 +1,59 @@
+package subscriptions
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransition(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(10 * 24 * time.Hour)
+	active := State{Status: StatusActive, PeriodEnd: periodEnd}
+	lapsed := State{Status: StatusActive, PeriodEnd: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name      string
+		current   State
+		event     string
+		periodEnd time.Time
+		want      State
+		entitled  bool
+	}{
+		{"upgrade", State{}, EventUpgraded, time.Time{}, State{Status: StatusActive, PeriodEnd: now.Add(defaultPeriod)}, true},
+		{"upgrade with period", State{}, EventUpgraded, periodEnd, active, true},
+		{"renew early", active, EventRenewed, time.Time{}, State{Status: StatusActive, PeriodEnd: periodEnd.Add(defaultPeriod)}, true},
+		{"renew lapsed", lapsed, EventRenewed, time.Time{}, State{Status: StatusActive, PeriodEnd: now.Add(defaultPeriod)}, true},
+		{"renew canceled", State{Status: StatusCanceled, PeriodEnd: periodEnd, CancelAtPeriodEnd: true}, EventRenewed, time.Time{}, State{Status: StatusActive, PeriodEnd: periodEnd.Add(defaultPeriod)}, true},
+		{"payment failed", active, EventPaymentFailed, time.Time{}, State{Status: StatusPastDue, PeriodEnd: periodEnd}, true},
+		{"payment failed after cancel", State{Status: StatusCanceled, PeriodEnd: periodEnd, CancelAtPeriodEnd: true}, EventPaymentFailed, time.Time{}, State{Status: StatusCanceled, PeriodEnd: periodEnd, CancelAtPeriodEnd: true}, true},
+		{"cancel", active, EventCanceled, time.Time{}, State{Status: StatusCanceled, PeriodEnd: periodEnd, CancelAtPeriodEnd: true}, true},
+		{"cancel lapsed", lapsed, EventCanceled, time.Time{}, State{Status: StatusExpired, PeriodEnd: lapsed.PeriodEnd}, false},
+		{"cancel never subscribed", State{}, EventCanceled, time.Time{}, State{Status: StatusExpired, PeriodEnd: now}, false},
+		{"downgrade", active, EventDowngraded, time.Time{}, State{Status: StatusExpired, PeriodEnd: now}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transition(tt.current, tt.event, tt.periodEnd, now)
+			if err != nil {
+				t.Fatalf("Transition() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Transition() = %+v, want %+v", got, tt.want)
+			}
+
+			if got.Entitled(now) != tt.entitled {
+				t.Errorf("Entitled() = %v, want %v", got.Entitled(now), tt.entitled)
+			}
+		})
+	}
+}
+
+func TestTransitionUnknownEvent(t *testing.T) {
+	_, err := Transition(State{}, "user.unknown", time.Time{}, time.Now())
+	if !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Transition() error = %v, want ErrUnknownEvent", err)
+	}
+}
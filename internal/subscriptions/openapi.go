@@ -0,0 +1,18 @@
+package subscriptions
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:   "GET /api/me/subscription",
+		Tag:       "subscriptions",
+		Summary:   "Get the user's subscription",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The subscription and its history", Body: subscriptionResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+}
@@ -0,0 +1,189 @@
+package subscriptions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/entitlements"
+	"github.com/absurek/go-http-servers/internal/outbox"
+	"github.com/google/uuid"
+)
+
+// Where Apply's events come from, in webhook_events.
+const webhookSource = "polka"
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrDuplicateEvent = errors.New("duplicate event")
+)
+
+// Service moves subscriptions through their lifecycle, for the Polka
+// webhook and the expiry job.
+type Service struct {
+	db           *sql.DB
+	dbQueries    *database.Queries
+	entitlements *entitlements.Service
+}
+
+func NewService(db *sql.DB, dbQueries *database.Queries, entitlementsService *entitlements.Service) *Service {
+	return &Service{
+		db:           db,
+		dbQueries:    dbQueries,
+		entitlements: entitlementsService,
+	}
+}
+
+// upgradedEventData is the payload of user.upgraded in the outbox.
+type upgradedEventData struct {
+	UserID    uuid.UUID `json:"user_id"`
+	PeriodEnd time.Time `json:"period_end"`
+}
+
+// Apply records a Polka event for a user and moves their subscription along.
+// is_chirpy_red follows the subscription in the same transaction. An event ID
+// seen before is reported as ErrDuplicateEvent and changes nothing.
+func (svc *Service) Apply(ctx context.Context, eventID string, userID uuid.UUID, event string, periodEnd time.Time) error {
+	now := time.Now()
+
+	tx, err := svc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("db begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := svc.dbQueries.WithTx(tx)
+
+	// Rolled back with the rest if applying fails, so a retry isn't taken
+	// for a duplicate.
+	recorded, err := qtx.RecordWebhookEvent(ctx, database.RecordWebhookEventParams{
+		Source:  webhookSource,
+		EventID: eventID,
+		Event:   event,
+	})
+	if err != nil {
+		return fmt.Errorf("db record webhook event: %w", err)
+	}
+
+	if recorded == 0 {
+		return ErrDuplicateEvent
+	}
+
+	var current State
+	sub, err := qtx.GetSubscriptionForUpdate(ctx, userID)
+	switch {
+	case err == nil:
+		current = State{Status: sub.Status, PeriodEnd: sub.CurrentPeriodEnd, CancelAtPeriodEnd: sub.CancelAtPeriodEnd}
+	case errors.Is(err, sql.ErrNoRows):
+	default:
+		return fmt.Errorf("db get subscription: %w", err)
+	}
+
+	next, err := Transition(current, event, periodEnd, now)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := qtx.SetChirpyRed(ctx, database.SetChirpyRedParams{
+		ID:          userID,
+		IsChirpyRed: next.Entitled(now),
+	})
+	if err != nil {
+		return fmt.Errorf("db set chirpy red: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	_, err = qtx.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:            userID,
+		Status:            next.Status,
+		CurrentPeriodEnd:  next.PeriodEnd,
+		CancelAtPeriodEnd: next.CancelAtPeriodEnd,
+	})
+	if err != nil {
+		return fmt.Errorf("db upsert subscription: %w", err)
+	}
+
+	err = qtx.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		UserID:    userID,
+		Event:     event,
+		Status:    next.Status,
+		PeriodEnd: next.PeriodEnd,
+	})
+	if err != nil {
+		return fmt.Errorf("db create subscription event: %w", err)
+	}
+
+	if !current.Entitled(now) && next.Entitled(now) {
+		err = outbox.Write(ctx, qtx, outbox.AggregateUser, userID, outbox.EventUserUpgraded, upgradedEventData{
+			UserID:    userID,
+			PeriodEnd: next.PeriodEnd,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("db commit: %w", err)
+	}
+
+	// Other instances pick the change up once their cache runs out.
+	svc.entitlements.Invalidate(userID)
+
+	return nil
+}
+
+// ExpireDue expires up to maxSubscriptions subscriptions whose period has
+// ended and takes Chirpy Red away. It reports how many it expired.
+func (svc *Service) ExpireDue(ctx context.Context, maxSubscriptions int) (int, error) {
+	tx, err := svc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("db begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := svc.dbQueries.WithTx(tx)
+
+	subs, err := qtx.ExpireSubscriptions(ctx, int32(maxSubscriptions))
+	if err != nil {
+		return 0, fmt.Errorf("db expire subscriptions: %w", err)
+	}
+
+	for _, sub := range subs {
+		_, err = qtx.SetChirpyRed(ctx, database.SetChirpyRedParams{
+			ID:          sub.UserID,
+			IsChirpyRed: false,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("db set chirpy red (user_id=%s): %w", sub.UserID, err)
+		}
+
+		err = qtx.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+			UserID:    sub.UserID,
+			Event:     EventExpired,
+			Status:    sub.Status,
+			PeriodEnd: sub.CurrentPeriodEnd,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("db create subscription event (user_id=%s): %w", sub.UserID, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("db commit: %w", err)
+	}
+
+	for _, sub := range subs {
+		svc.entitlements.Invalidate(sub.UserID)
+	}
+
+	return len(subs), nil
+}
@@ -0,0 +1,112 @@
+package subscriptions
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/entitlements"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+)
+
+const historyLength = 50
+
+type eventResponse struct {
+	Event     string    `json:"event"`
+	Status    string    `json:"status"`
+	PeriodEnd time.Time `json:"period_end"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type subscriptionResponse struct {
+	Tier              string          `json:"tier"`
+	Status            string          `json:"status"`
+	CurrentPeriodEnd  *time.Time      `json:"current_period_end"`
+	CancelAtPeriodEnd bool            `json:"cancel_at_period_end"`
+	History           []eventResponse `json:"history"`
+}
+
+type SubscriptionsHandler struct {
+	settings     settings.Settings
+	dbQueries    *database.Queries
+	entitlements *entitlements.Service
+	logger       *log.Logger
+}
+
+func NewSubscriptionsHandler(s settings.Settings, dbQueries *database.Queries, entitlementsService *entitlements.Service, logger *log.Logger) *SubscriptionsHandler {
+	return &SubscriptionsHandler{
+		settings:     s,
+		dbQueries:    dbQueries,
+		entitlements: entitlementsService,
+		logger:       logger,
+	}
+}
+
+func (h *SubscriptionsHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	e, err := h.entitlements.ForUser(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetSubscription): %v (user_id=%s)", err, userID)
+		response.InternalServerError(w)
+		return
+	}
+
+	// Users who never subscribed have no row, only the free tier.
+	resp := subscriptionResponse{
+		Tier:    e.Tier,
+		Status:  "none",
+		History: []eventResponse{},
+	}
+
+	sub, err := h.dbQueries.GetSubscription(r.Context(), userID)
+	switch {
+	case err == nil:
+		resp.Status = sub.Status
+		resp.CurrentPeriodEnd = &sub.CurrentPeriodEnd
+		resp.CancelAtPeriodEnd = sub.CancelAtPeriodEnd
+	case errors.Is(err, sql.ErrNoRows):
+		response.JSON(w, http.StatusOK, resp)
+		return
+	default:
+		h.logger.Printf("Error(GetSubscription): db get subscription (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	events, err := h.dbQueries.GetSubscriptionEvents(r.Context(), database.GetSubscriptionEventsParams{
+		UserID:    userID,
+		MaxEvents: historyLength,
+	})
+	if err != nil {
+		h.logger.Printf("Error(GetSubscription): db get subscription events (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	for _, event := range events {
+		resp.History = append(resp.History, eventResponse{
+			Event:     event.Event,
+			Status:    event.Status,
+			PeriodEnd: event.PeriodEnd,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
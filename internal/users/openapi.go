@@ -0,0 +1,80 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:   "POST /api/users",
+		Tag:       "users",
+		Summary:   "Sign up",
+		Request:   userRequest{},
+		Responses: []openapi.Response{{Status: http.StatusCreated, Description: "The new user", Body: userResponse{}}},
+		Errors:    []int{http.StatusBadRequest},
+	},
+	{
+		Pattern:   "PUT /api/users",
+		Tag:       "users",
+		Summary:   "Change email and password",
+		Auth:      openapi.AuthBearer,
+		Request:   userRequest{},
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The updated user", Body: userResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		Pattern:     "DELETE /api/users",
+		Tag:         "users",
+		Summary:     "Delete the account",
+		Description: "Same as DELETE /api/me.",
+		Auth:        openapi.AuthBearer,
+		Request:     deleteUserRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusNoContent, Description: "Deleted, restorable during the grace period"}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Pattern:     "DELETE /api/me",
+		Tag:         "users",
+		Summary:     "Delete the account",
+		Description: "Signs the account out everywhere. It can be restored with POST /api/users/restore until the grace period runs out.",
+		Auth:        openapi.AuthBearer,
+		Request:     deleteUserRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusNoContent, Description: "Deleted, restorable during the grace period"}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Pattern:     "POST /api/users/restore",
+		Tag:         "users",
+		Summary:     "Restore a deleted account",
+		Description: "Fails with 409 if a new account took the email meanwhile.",
+		Request:     userRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusOK, Description: "The restored user", Body: userResponse{}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict},
+	},
+	{
+		Pattern:   "POST /api/login",
+		Tag:       "users",
+		Summary:   "Log in",
+		Request:   userRequest{},
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "An access and a refresh token", Body: loginResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		Pattern:   "POST /api/refresh",
+		Tag:       "users",
+		Summary:   "Get a new access token",
+		Auth:      openapi.AuthRefreshToken,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "A new access token", Body: refreshResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "POST /api/revoke",
+		Tag:       "users",
+		Summary:   "Revoke a refresh token",
+		Auth:      openapi.AuthRefreshToken,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Revoked"}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+}
@@ -0,0 +1,76 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/jobs"
+	"github.com/absurek/go-http-servers/internal/metrics"
+)
+
+const (
+	tokenBatchSize    = 1000
+	tokenBatchTimeout = 30 * time.Second
+
+	// Dead tokens are kept a while so a refresh with one can still be told
+	// apart from a made up token in the logs.
+	TokenGracePeriod = 7 * 24 * time.Hour
+)
+
+// TokenGCJob removes expired and revoked refresh tokens, it is scheduled
+// hourly.
+var TokenGCJob = jobs.Kind[struct{}]{Name: "refresh_tokens.gc", Timeout: 15 * time.Minute}
+
+// TokenCollector deletes refresh tokens that expired or were revoked more
+// than TokenGracePeriod ago. Every login adds a row, nothing else removes
+// them.
+type TokenCollector struct {
+	dbQueries *database.Queries
+	metrics   *metrics.Metrics
+	logger    *log.Logger
+}
+
+func NewTokenCollector(dbQueries *database.Queries, metrics *metrics.Metrics, logger *log.Logger) *TokenCollector {
+	return &TokenCollector{
+		dbQueries: dbQueries,
+		metrics:   metrics,
+		logger:    logger,
+	}
+}
+
+// Run deletes in batches until nothing is left, so it never holds locks on
+// many rows at once.
+func (c *TokenCollector) Run(ctx context.Context, _ struct{}) error {
+	now := time.Now()
+	cutoff := now.Add(-TokenGracePeriod)
+
+	var total int64
+	defer func() {
+		c.metrics.AddRefreshTokensPurged(total, now)
+		if total > 0 {
+			c.logger.Printf("Purged %d refresh tokens", total)
+		}
+	}()
+
+	for {
+		batchCtx, cancel := context.WithTimeout(ctx, tokenBatchTimeout)
+		purged, err := c.dbQueries.PurgeRefreshTokens(batchCtx, database.PurgeRefreshTokensParams{
+			ExpiredBefore: cutoff,
+			RevokedBefore: sql.NullTime{Time: cutoff, Valid: true},
+			MaxTokens:     tokenBatchSize,
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("db purge refresh tokens: %w", err)
+		}
+
+		total += purged
+		if purged < tokenBatchSize {
+			return nil
+		}
+	}
+}
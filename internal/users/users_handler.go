@@ -1,25 +1,35 @@
 package users
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/absurek/go-http-servers/internal/auth"
 	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/request"
 	"github.com/absurek/go-http-servers/internal/response"
 	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const jwtExpiresIn = 1 * time.Hour
 const refreshTokenExpiresIn = 60 * 24 * time.Hour
 
+// How long a deleted account can be restored. The purge job keeps deleted
+// accounts at least this long.
+const RestoreGracePeriod = 30 * 24 * time.Hour
+
+const uniqueViolation = "23505"
+
 type userRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required"`
 }
 
 type userResponse struct {
@@ -40,6 +50,10 @@ type loginResponse struct {
 	RefreshToken string    `json:"refresh_token"`
 }
 
+type deleteUserRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
 type refreshResponse struct {
 	Token string `json:"token"`
 }
@@ -61,10 +75,8 @@ func NewUsersHandler(s settings.Settings, db *sql.DB, dbQueries *database.Querie
 }
 
 func (h *UsersHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var req userRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		response.InvalidRequestBody(w)
+	req, ok := request.DecodeJSON[userRequest](w, r)
+	if !ok {
 		return
 	}
 
@@ -107,10 +119,8 @@ func (h *UsersHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req userRequest
-	err = json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		response.InvalidRequestBody(w)
+	req, ok := request.DecodeJSON[userRequest](w, r)
+	if !ok {
 		return
 	}
 
@@ -142,10 +152,8 @@ func (h *UsersHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req userRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		response.InvalidRequestBody(w)
+	req, ok := request.DecodeJSON[userRequest](w, r)
+	if !ok {
 		return
 	}
 
@@ -174,6 +182,11 @@ func (h *UsersHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.SuspendedAt.Valid {
+		response.Forbidden(w)
+		return
+	}
+
 	jwt, err := auth.MakeJWT(user.ID, h.settings.JWTSecret, jwtExpiresIn)
 	if err != nil {
 		h.logger.Printf("Error(Login): make jwt (user_id=%s): %v", user.ID, err)
@@ -269,3 +282,161 @@ func (h *UsersHandler) Revoke(w http.ResponseWriter, r *http.Request) {
 
 	response.NoContent(w)
 }
+
+// DeleteUser soft deletes the account and signs it out everywhere, the
+// password has to be entered again. It can be restored with the password
+// until the grace period runs out, then the purge job erases it.
+func (h *UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	req, ok := request.DecodeJSON[deleteUserRequest](w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(DeleteUser): db get user (user_id=%s): %v", userID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	isValidPassword, err := auth.CheckPasswordHash(req.Password, user.HashedPassword)
+	if err != nil {
+		h.logger.Printf("Error(DeleteUser): check password (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !isValidPassword {
+		response.Forbidden(w)
+		return
+	}
+
+	deleted, err := h.deleteAccount(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(DeleteUser): %v (user_id=%s)", err, userID)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !deleted {
+		response.NotFound(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+func (h *UsersHandler) deleteAccount(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("db begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := h.dbQueries.WithTx(tx)
+
+	rowsAffected, err := qtx.SoftDeleteUser(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("db soft delete user: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	err = qtx.RevokeUserTokens(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("db revoke user tokens: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("db commit: %w", err)
+	}
+
+	return true, nil
+}
+
+// RestoreUser brings back a deleted account within the grace period. It
+// fails with a conflict if the email got taken by a new account meanwhile.
+func (h *UsersHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	req, ok := request.DecodeJSON[userRequest](w, r)
+	if !ok {
+		return
+	}
+
+	deletedAfter := sql.NullTime{Time: time.Now().Add(-RestoreGracePeriod), Valid: true}
+
+	user, err := h.dbQueries.GetDeletedUserByEmail(r.Context(), database.GetDeletedUserByEmailParams{
+		Email:        req.Email,
+		DeletedAfter: deletedAfter,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.Unauthorized(w)
+		default:
+			h.logger.Printf("Error(RestoreUser): db get deleted user by email: %v", err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	isValidPassword, err := auth.CheckPasswordHash(req.Password, user.HashedPassword)
+	if err != nil {
+		h.logger.Printf("Error(RestoreUser): check password: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if !isValidPassword {
+		response.Unauthorized(w)
+		return
+	}
+
+	user, err = h.dbQueries.RestoreUser(r.Context(), database.RestoreUserParams{
+		ID:           user.ID,
+		DeletedAfter: deletedAfter,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.Unauthorized(w)
+		case errors.As(err, &pqErr) && pqErr.Code == uniqueViolation:
+			response.Error(w, http.StatusConflict, "email_taken", "the email is used by another account")
+		default:
+			h.logger.Printf("Error(RestoreUser): db restore user (user_id=%s): %v", user.ID, err)
+			response.InternalServerError(w)
+		}
+
+		return
+	}
+
+	response.JSON(w, http.StatusOK, userResponse{
+		ID:          user.ID.String(),
+		Email:       user.Email,
+		CreatedAt:   user.CreatedAt.Time,
+		UpdatedAt:   user.UpdatedAt.Time,
+		IsChirpyRed: user.IsChirpyRed.Bool,
+	})
+}
@@ -0,0 +1,105 @@
+package visibility
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/google/uuid"
+)
+
+// Rules answer what one viewer may see. Every read path (chirp lists, single
+// chirps, streams, notifications, ...) asks the same Rules instead of
+// checking blocks and mutes on its own.
+//
+//   - A block works both ways: neither side sees the other's content.
+//   - A mute only affects the muter: the muted user's content is left out of
+//     their timelines and notifications, but stays reachable directly.
+type Rules struct {
+	blocked   map[uuid.UUID]bool
+	blockedBy map[uuid.UUID]bool
+	muted     map[uuid.UUID]bool
+}
+
+// Public is what an anonymous viewer gets: everything is visible.
+var Public = &Rules{}
+
+func NewRules() *Rules {
+	return &Rules{
+		blocked:   make(map[uuid.UUID]bool),
+		blockedBy: make(map[uuid.UUID]bool),
+		muted:     make(map[uuid.UUID]bool),
+	}
+}
+
+// CanSee reports whether content by authorID may be shown at all.
+func (r *Rules) CanSee(authorID uuid.UUID) bool {
+	return !r.blocked[authorID] && !r.blockedBy[authorID]
+}
+
+// InTimeline reports whether content by authorID belongs in a feed that the
+// viewer didn't explicitly narrow down to that author.
+func (r *Rules) InTimeline(authorID uuid.UUID) bool {
+	return r.CanSee(authorID) && !r.muted[authorID]
+}
+
+// ShouldNotify reports whether the viewer should hear about actorID's
+// activity.
+func (r *Rules) ShouldNotify(actorID uuid.UUID) bool {
+	return r.InTimeline(actorID)
+}
+
+// Store is the part of database.Queries a Filter needs.
+type Store interface {
+	GetVisibilityRelations(ctx context.Context, viewerID uuid.UUID) ([]database.GetVisibilityRelationsRow, error)
+	IsBlockedEitherWay(ctx context.Context, arg database.IsBlockedEitherWayParams) (bool, error)
+}
+
+type Filter struct {
+	store Store
+}
+
+func NewFilter(store Store) *Filter {
+	return &Filter{
+		store: store,
+	}
+}
+
+func (f *Filter) ForViewer(ctx context.Context, viewerID uuid.NullUUID) (*Rules, error) {
+	if !viewerID.Valid {
+		return Public, nil
+	}
+
+	relations, err := f.store.GetVisibilityRelations(ctx, viewerID.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("get visibility relations: %w", err)
+	}
+
+	rules := NewRules()
+	for _, relation := range relations {
+		switch relation.Relation {
+		case "blocked":
+			rules.blocked[relation.UserID] = true
+		case "blocked_by":
+			rules.blockedBy[relation.UserID] = true
+		case "muted":
+			rules.muted[relation.UserID] = true
+		}
+	}
+
+	return rules, nil
+}
+
+// CanInteract reports whether actorID may reach targetID (mention, message,
+// ...), which is not the case if either one blocked the other.
+func (f *Filter) CanInteract(ctx context.Context, actorID, targetID uuid.UUID) (bool, error) {
+	blocked, err := f.store.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{
+		BlockerID: actorID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("is blocked either way: %w", err)
+	}
+
+	return !blocked, nil
+}
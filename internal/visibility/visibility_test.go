@@ -0,0 +1,50 @@
+package visibility
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRules(t *testing.T) {
+	blocked := uuid.New()
+	blockedBy := uuid.New()
+	muted := uuid.New()
+	stranger := uuid.New()
+
+	rules := NewRules()
+	rules.blocked[blocked] = true
+	rules.blockedBy[blockedBy] = true
+	rules.muted[muted] = true
+
+	tests := []struct {
+		name           string
+		authorID       uuid.UUID
+		wantCanSee     bool
+		wantInTimeline bool
+		wantNotify     bool
+	}{
+		{name: "Blocked", authorID: blocked},
+		{name: "Blocked by", authorID: blockedBy},
+		{name: "Muted", authorID: muted, wantCanSee: true},
+		{name: "Stranger", authorID: stranger, wantCanSee: true, wantInTimeline: true, wantNotify: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.CanSee(tt.authorID); got != tt.wantCanSee {
+				t.Errorf("CanSee() = %v, want %v", got, tt.wantCanSee)
+			}
+			if got := rules.InTimeline(tt.authorID); got != tt.wantInTimeline {
+				t.Errorf("InTimeline() = %v, want %v", got, tt.wantInTimeline)
+			}
+			if got := rules.ShouldNotify(tt.authorID); got != tt.wantNotify {
+				t.Errorf("ShouldNotify() = %v, want %v", got, tt.wantNotify)
+			}
+		})
+	}
+
+	if !Public.InTimeline(blocked) {
+		t.Errorf("Public.InTimeline() = false, want true")
+	}
+}
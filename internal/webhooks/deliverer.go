@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/database"
+)
+
+const (
+	deliverInterval  = 5 * time.Second
+	deliverBatchSize = 50
+	// Deliveries sent at the same time, one slow receiver shouldn't hold up
+	// everyone else's.
+	deliverConcurrency = 10
+	// Longer than a send can take, a claimed delivery is only retried early
+	// if its sender died.
+	deliverLease = 1 * time.Minute
+
+	// With the backoff below the last attempt is about 4 hours after the
+	// first.
+	MaxAttempts = 10
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// backoff is the wait after the given failed attempt, doubling each time.
+func backoff(attempt int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return wait
+}
+
+// Deliverer sends queued deliveries and retries failed ones until they run
+// out of attempts. Every instance runs one, claiming skips deliveries another
+// instance is sending.
+type Deliverer struct {
+	dbQueries *database.Queries
+	sender    *Sender
+	logger    *log.Logger
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewDeliverer(dbQueries *database.Queries, sender *Sender, logger *log.Logger) *Deliverer {
+	d := &Deliverer{
+		dbQueries: dbQueries,
+		sender:    sender,
+		logger:    logger,
+		done:      make(chan struct{}),
+	}
+
+	d.wg.Add(1)
+	go d.run()
+
+	return d
+}
+
+func (d *Deliverer) run() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(deliverInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+
+		for d.deliverBatch() == deliverBatchSize {
+			select {
+			case <-d.done:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// deliverBatch claims due deliveries and sends them. It reports how many it
+// claimed.
+func (d *Deliverer) deliverBatch() int {
+	ctx := context.Background()
+
+	claimed, err := d.dbQueries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil:    time.Now().Add(deliverLease),
+		MaxDeliveries: deliverBatchSize,
+	})
+	if err != nil {
+		d.logger.Printf("Error(Deliverer): db claim webhook deliveries: %v", err)
+		return 0
+	}
+
+	sem := make(chan struct{}, deliverConcurrency)
+	var wg sync.WaitGroup
+	for _, row := range claimed {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			d.deliver(ctx, row)
+		}()
+	}
+	wg.Wait()
+
+	return len(claimed)
+}
+
+func (d *Deliverer) deliver(ctx context.Context, row database.ClaimWebhookDeliveriesRow) {
+	attempt := d.sender.Send(ctx, Delivery{
+		ID:        row.ID,
+		Event:     row.Event,
+		URL:       row.Url,
+		Secret:    row.Secret,
+		Payload:   row.Payload,
+		CreatedAt: row.CreatedAt,
+	}, time.Now())
+
+	params := database.CreateWebhookDeliveryAttemptParams{
+		DeliveryID: row.ID,
+		Attempt:    row.Attempts,
+		LatencyMs:  int32(attempt.Latency.Milliseconds()),
+	}
+	if attempt.StatusCode != 0 {
+		params.StatusCode = sql.NullInt32{Int32: int32(attempt.StatusCode), Valid: true}
+	}
+	if attempt.Err != nil {
+		params.Error = sql.NullString{String: attempt.Err.Error(), Valid: true}
+	}
+
+	err := d.dbQueries.CreateWebhookDeliveryAttempt(ctx, params)
+	if err != nil {
+		d.logger.Printf("Error(Deliverer): db create webhook delivery attempt (delivery_id=%s): %v", row.ID, err)
+	}
+
+	// A failed update leaves the lease in place, the delivery is sent again
+	// once it runs out.
+	switch {
+	case attempt.OK():
+		err = d.dbQueries.CompleteWebhookDelivery(ctx, row.ID)
+	case row.Attempts >= MaxAttempts:
+		err = d.dbQueries.DeadLetterWebhookDelivery(ctx, row.ID)
+	default:
+		err = d.dbQueries.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+			ID:            row.ID,
+			NextAttemptAt: time.Now().Add(backoff(int(row.Attempts))),
+		})
+	}
+	if err != nil {
+		d.logger.Printf("Error(Deliverer): db update webhook delivery (delivery_id=%s): %v", row.ID, err)
+	}
+}
+
+// Close stops the deliverer, waiting for a batch in flight.
+func (d *Deliverer) Close() {
+	close(d.done)
+	d.wg.Wait()
+}
@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/outbox"
+	"github.com/google/uuid"
+)
+
+// Webhooks carry the outbox events of the same name.
+const (
+	EventChirpCreated = outbox.EventChirpCreated
+	EventChirpUpdated = outbox.EventChirpUpdated
+	EventChirpDeleted = outbox.EventChirpDeleted
+	// Only sent to the upgraded user's own webhooks.
+	EventUserUpgraded = outbox.EventUserUpgraded
+)
+
+// Events are the events a webhook can subscribe to.
+var Events = []string{EventChirpCreated, EventChirpUpdated, EventChirpDeleted, EventUserUpgraded}
+
+// Dispatcher queues events for the webhooks subscribed to them, the Deliverer
+// sends them. It gets the events from the outbox relay.
+type Dispatcher struct {
+	dbQueries *database.Queries
+}
+
+func NewDispatcher(dbQueries *database.Queries) *Dispatcher {
+	return &Dispatcher{
+		dbQueries: dbQueries,
+	}
+}
+
+// HandleOutbox is the relay subscription for all of Events. Chirp events are
+// as public as the chirps, user events go to the user's webhooks only.
+func (d *Dispatcher) HandleOutbox(ctx context.Context, msg outbox.Message) error {
+	var ownerID uuid.NullUUID
+	if msg.AggregateType == outbox.AggregateUser {
+		ownerID = uuid.NullUUID{UUID: msg.AggregateID, Valid: true}
+	}
+
+	_, err := d.dbQueries.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		Event:    msg.Event,
+		Payload:  msg.Payload,
+		OutboxID: msg.ID,
+		OwnerID:  ownerID,
+	})
+	if err != nil {
+		return fmt.Errorf("db enqueue webhook deliveries: %w", err)
+	}
+
+	return nil
+}
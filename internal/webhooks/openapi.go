@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern:     "POST /api/webhooks",
+		Tag:         "webhooks",
+		Summary:     "Register a webhook",
+		Description: "The response holds the secret deliveries are signed with, it isn't shown again.",
+		Auth:        openapi.AuthBearer,
+		Request:     createWebhookRequest{},
+		Responses:   []openapi.Response{{Status: http.StatusCreated, Description: "The webhook with its secret", Body: webhookResponse{}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "GET /api/webhooks",
+		Tag:       "webhooks",
+		Summary:   "List webhooks",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "The webhooks, without secrets", Body: []webhookResponse{}}},
+		Errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		Pattern:   "DELETE /api/webhooks/{webhookID}",
+		Tag:       "webhooks",
+		Summary:   "Delete a webhook",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Deleted"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:   "GET /api/webhooks/{webhookID}/deliveries",
+		Tag:       "webhooks",
+		Summary:   "List recent deliveries",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "Deliveries with their attempts, newest first", Body: []deliveryResponse{}}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Pattern:   "POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver",
+		Tag:       "webhooks",
+		Summary:   "Retry a dead delivery",
+		Auth:      openapi.AuthBearer,
+		Responses: []openapi.Response{{Status: http.StatusNoContent, Description: "Queued again"}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+}
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+
+	sendTimeout = 10 * time.Second
+)
+
+var ErrForbiddenAddress = errors.New("webhook address not allowed")
+
+// Delivery is one event on its way to one webhook.
+type Delivery struct {
+	ID        uuid.UUID
+	Event     string
+	URL       string
+	Secret    string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// What the receiver gets as the body.
+type envelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Attempt is the outcome of sending a delivery once.
+type Attempt struct {
+	StatusCode int
+	Err        error
+	Latency    time.Duration
+}
+
+// OK reports whether the receiver accepted the delivery. Anything but a 2xx,
+// redirects included, is a failure.
+func (a Attempt) OK() bool {
+	return a.Err == nil && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// NewClient returns the client deliveries go out with. Webhook URLs come from
+// users, so it won't connect to loopback, private or link-local addresses,
+// checked after DNS resolution, and won't follow redirects.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(client *http.Client) *Sender {
+	return &Sender{
+		client: client,
+	}
+}
+
+// Send posts a delivery, signed with the webhook's secret, and times it.
+func (s *Sender) Send(ctx context.Context, d Delivery, now time.Time) Attempt {
+	body, err := json.Marshal(envelope{
+		ID:        d.ID,
+		Event:     d.Event,
+		CreatedAt: d.CreatedAt,
+		Data:      d.Payload,
+	})
+	if err != nil {
+		return Attempt{Err: fmt.Errorf("marshal envelope: %w", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return Attempt{Err: fmt.Errorf("new request: %w", err)}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks")
+	req.Header.Set(SignatureHeader, Sign(d.Secret, body, now))
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID.String())
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Attempt{Err: err, Latency: time.Since(start)}
+	}
+	defer resp.Body.Close()
+
+	// Only the status matters, reading a little lets the connection be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	return Attempt{StatusCode: resp.StatusCode, Latency: time.Since(start)}
+}
@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSenderSend(t *testing.T) {
+	delivery := Delivery{
+		ID:        uuid.New(),
+		Event:     EventChirpCreated,
+		Secret:    "whsec_test",
+		Payload:   json.RawMessage(`{"chirp_id":"c1"}`),
+		CreatedAt: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	calls := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		body, _ := io.ReadAll(r.Body)
+		err := Verify(r.Header.Get(SignatureHeader), body, []string{delivery.Secret}, SignatureTolerance, time.Now())
+		if err != nil {
+			t.Errorf("receiver: Verify() error = %v", err)
+		}
+
+		if r.Header.Get(EventHeader) != delivery.Event || r.Header.Get(DeliveryHeader) != delivery.ID.String() {
+			t.Errorf("receiver: headers = %v", r.Header)
+		}
+
+		var got envelope
+		err = json.Unmarshal(body, &got)
+		if err != nil {
+			t.Fatalf("receiver: unmarshal body: %v", err)
+		}
+
+		if got.ID != delivery.ID || got.Event != delivery.Event || string(got.Data) != string(delivery.Payload) {
+			t.Errorf("receiver: body = %s", body)
+		}
+
+		// Fails the first time, like a receiver being deployed.
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	delivery.URL = server.URL
+	sender := NewSender(server.Client())
+
+	attempt := sender.Send(context.Background(), delivery, time.Now())
+	if attempt.OK() || attempt.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("first Send() = %+v, want a 503 failure", attempt)
+	}
+
+	attempt = sender.Send(context.Background(), delivery, time.Now())
+	if !attempt.OK() || attempt.StatusCode != http.StatusNoContent {
+		t.Errorf("second Send() = %+v, want a 204 success", attempt)
+	}
+
+	if attempt.Latency <= 0 {
+		t.Errorf("Latency = %v, want > 0", attempt.Latency)
+	}
+}
+
+func TestSenderUnreachable(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	client := server.Client()
+	url := server.URL
+	server.Close()
+
+	attempt := NewSender(client).Send(context.Background(), Delivery{ID: uuid.New(), URL: url, Payload: json.RawMessage(`{}`)}, time.Now())
+	if attempt.OK() || attempt.Err == nil {
+		t.Errorf("Send() = %+v, want an error", attempt)
+	}
+}
+
+func TestClientRefusesLocalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request reached a loopback receiver")
+	}))
+	defer server.Close()
+
+	attempt := NewSender(NewClient()).Send(context.Background(), Delivery{ID: uuid.New(), URL: server.URL, Payload: json.RawMessage(`{}`)}, time.Now())
+	if !errors.Is(attempt.Err, ErrForbiddenAddress) {
+		t.Errorf("Send() error = %v, want ErrForbiddenAddress", attempt.Err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, 1 * time.Minute},
+		{5, 8 * time.Minute},
+		{9, 128 * time.Minute},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How far a signature's timestamp may be from our clock. Anything older is a
+// replay, or a retry the sender should have signed again.
+const SignatureTolerance = 5 * time.Minute
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrStaleSignature   = errors.New("signature timestamp outside tolerance")
+)
+
+func signature(secret string, timestamp int64, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
+
+// Sign returns a signature header value for body: "t=<unix time>,v1=<hex
+// HMAC-SHA256 of "<unix time>.<body>">". The timestamp is part of what's
+// signed, so it can't be swapped for a fresh one.
+func Sign(secret string, body []byte, now time.Time) string {
+	timestamp := now.Unix()
+
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + hex.EncodeToString(signature(secret, timestamp, body))
+}
+
+// Verify checks a signature header made by Sign against each of secrets. The
+// header may carry several v1 signatures, e.g. while the sender rotates keys,
+// one match with any secret is enough.
+func Verify(header string, body []byte, secrets []string, tolerance time.Duration, now time.Time) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp int64
+	var hasTimestamp bool
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+
+			timestamp = t
+			hasTimestamp = true
+		case "v1":
+			sig, err := hex.DecodeString(value)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+
+			signatures = append(signatures, sig)
+		}
+		// Other schemes are for other versions, skip them.
+	}
+
+	if !hasTimestamp || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	// Check the signature first, a stale timestamp is only worth reporting
+	// when it is genuine.
+	matched := false
+	for _, secret := range secrets {
+		expected := signature(secret, timestamp, body)
+		for _, sig := range signatures {
+			if hmac.Equal(sig, expected) {
+				matched = true
+			}
+		}
+	}
+
+	if !matched {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrStaleSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	header := Sign("secret", body, now)
+	timestamp, _, _ := strings.Cut(header, ",")
+	_, otherSignature, _ := strings.Cut(Sign("other", body, now), ",")
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		secrets []string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", header, body, []string{"secret"}, now, nil},
+		{"rotated key", header, body, []string{"new secret", "secret"}, now, nil},
+		{"several signatures", header + "," + otherSignature, body, []string{"other"}, now, nil},
+		{"within tolerance", header, body, []string{"secret"}, now.Add(SignatureTolerance), nil},
+		{"missing", "", body, []string{"secret"}, now, ErrMissingSignature},
+		{"wrong secret", header, body, []string{"other"}, now, ErrInvalidSignature},
+		{"tampered body", header, []byte(`{"id":"evt_1","event":"user.downgraded"}`), []string{"secret"}, now, ErrInvalidSignature},
+		{"swapped timestamp", "t=1767268900" + strings.TrimPrefix(header, timestamp), body, []string{"secret"}, now, ErrInvalidSignature},
+		{"no timestamp", strings.TrimPrefix(header, timestamp+","), body, []string{"secret"}, now, ErrInvalidSignature},
+		{"malformed", "garbage", body, []string{"secret"}, now, ErrInvalidSignature},
+		{"replayed", header, body, []string{"secret"}, now.Add(SignatureTolerance + time.Second), ErrStaleSignature},
+		{"from the future", header, body, []string{"secret"}, now.Add(-SignatureTolerance - time.Second), ErrStaleSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.header, tt.body, tt.secrets, SignatureTolerance, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
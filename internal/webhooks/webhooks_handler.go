@@ -0,0 +1,365 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/request"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+const (
+	maxWebhooks   = 10
+	historyLength = 50
+)
+
+type createWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,https,max=2048"`
+	Events []string `json:"events" validate:"required"`
+}
+
+func (req createWebhookRequest) Validate() []response.FieldError {
+	var errs []response.FieldError
+	for _, event := range req.Events {
+		if !slices.Contains(Events, event) {
+			errs = append(errs, response.FieldError{
+				Field:   "events",
+				Code:    response.FieldUnknown,
+				Message: "unknown event: " + event,
+			})
+		}
+	}
+
+	return errs
+}
+
+type webhookResponse struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Only shown once, when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type attemptResponse struct {
+	Attempt    int       `json:"attempt"`
+	StatusCode *int      `json:"status_code"`
+	Error      *string   `json:"error"`
+	LatencyMs  int       `json:"latency_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type deliveryResponse struct {
+	ID            string            `json:"id"`
+	Event         string            `json:"event"`
+	Status        string            `json:"status"`
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+	CompletedAt   *time.Time        `json:"completed_at"`
+	Log           []attemptResponse `json:"log"`
+}
+
+type WebhooksHandler struct {
+	settings  settings.Settings
+	db        *sql.DB
+	dbQueries *database.Queries
+	logger    *log.Logger
+}
+
+func NewWebhooksHandler(s settings.Settings, db *sql.DB, dbQueries *database.Queries, logger *log.Logger) *WebhooksHandler {
+	return &WebhooksHandler{
+		settings:  s,
+		db:        db,
+		dbQueries: dbQueries,
+		logger:    logger,
+	}
+}
+
+func newWebhookResponse(webhook database.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:        webhook.ID.String(),
+		URL:       webhook.Url,
+		Events:    webhook.Events,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+// userID authenticates the request. It writes the error response itself and
+// reports ok=false in that case.
+func (h *WebhooksHandler) userID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	jwt, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(jwt, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// webhook loads one of the user's webhooks from the path. It writes the error
+// response itself and reports ok=false in that case.
+func (h *WebhooksHandler) webhook(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Webhook, bool) {
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		response.Invalid(w, "webhookID", response.FieldInvalid, "invalid webhook id")
+		return database.Webhook{}, false
+	}
+
+	webhook, err := h.dbQueries.GetWebhook(r.Context(), database.GetWebhookParams{
+		ID:     webhookID,
+		UserID: userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			response.NotFound(w)
+		default:
+			h.logger.Printf("Error(webhook): db get webhook (webhook_id=%s): %v", webhookID, err)
+			response.InternalServerError(w)
+		}
+
+		return database.Webhook{}, false
+	}
+
+	return webhook, true
+}
+
+// CreateWebhook registers an HTTPS endpoint for some events. The response
+// holds the secret deliveries are signed with, it isn't shown again.
+func (h *WebhooksHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := request.DecodeJSON[createWebhookRequest](w, r)
+	if !ok {
+		return
+	}
+
+	events := []string{}
+	for _, event := range req.Events {
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+
+	count, err := h.dbQueries.CountWebhooks(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(CreateWebhook): db count webhooks (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if count >= maxWebhooks {
+		response.Error(w, http.StatusBadRequest, "webhook_limit", "too many webhooks")
+		return
+	}
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	webhook, err := h.dbQueries.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		UserID: userID,
+		Url:    req.URL,
+		Secret: "whsec_" + hex.EncodeToString(secret),
+		Events: events,
+	})
+	if err != nil {
+		h.logger.Printf("Error(CreateWebhook): db create webhook (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := newWebhookResponse(webhook)
+	resp.Secret = webhook.Secret
+
+	response.JSON(w, http.StatusCreated, resp)
+}
+
+func (h *WebhooksHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.dbQueries.GetWebhooks(r.Context(), userID)
+	if err != nil {
+		h.logger.Printf("Error(GetWebhooks): db get webhooks (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	resp := []webhookResponse{}
+	for _, webhook := range webhooks {
+		resp = append(resp, newWebhookResponse(webhook))
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// DeleteWebhook removes a webhook, deliveries still queued for it are
+// dropped.
+func (h *WebhooksHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		response.Invalid(w, "webhookID", response.FieldInvalid, "invalid webhook id")
+		return
+	}
+
+	rowsAffected, err := h.dbQueries.DeleteWebhook(r.Context(), database.DeleteWebhookParams{
+		ID:     webhookID,
+		UserID: userID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(DeleteWebhook): db delete webhook (webhook_id=%s): %v", webhookID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	if rowsAffected == 0 {
+		response.NotFound(w)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// GetDeliveries shows the latest deliveries of a webhook with every attempt
+// made, newest first.
+func (h *WebhooksHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	webhook, ok := h.webhook(w, r, userID)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.dbQueries.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID:     webhook.ID,
+		MaxDeliveries: historyLength,
+	})
+	if err != nil {
+		h.logger.Printf("Error(GetDeliveries): db get webhook deliveries (webhook_id=%s): %v", webhook.ID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.ID)
+	}
+
+	attempts, err := h.dbQueries.GetWebhookDeliveryAttempts(r.Context(), ids)
+	if err != nil {
+		h.logger.Printf("Error(GetDeliveries): db get webhook delivery attempts (webhook_id=%s): %v", webhook.ID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	logs := make(map[uuid.UUID][]attemptResponse)
+	for _, attempt := range attempts {
+		resp := attemptResponse{
+			Attempt:   int(attempt.Attempt),
+			LatencyMs: int(attempt.LatencyMs),
+			CreatedAt: attempt.CreatedAt,
+		}
+		if attempt.StatusCode.Valid {
+			statusCode := int(attempt.StatusCode.Int32)
+			resp.StatusCode = &statusCode
+		}
+		if attempt.Error.Valid {
+			resp.Error = &attempt.Error.String
+		}
+
+		logs[attempt.DeliveryID] = append(logs[attempt.DeliveryID], resp)
+	}
+
+	resp := []deliveryResponse{}
+	for _, delivery := range deliveries {
+		d := deliveryResponse{
+			ID:        delivery.ID.String(),
+			Event:     delivery.Event,
+			Status:    delivery.Status,
+			Attempts:  int(delivery.Attempts),
+			CreatedAt: delivery.CreatedAt,
+			Log:       []attemptResponse{},
+		}
+		if delivery.Status == "pending" {
+			d.NextAttemptAt = &delivery.NextAttemptAt
+		}
+		if delivery.CompletedAt.Valid {
+			d.CompletedAt = &delivery.CompletedAt.Time
+		}
+		if entries, ok := logs[delivery.ID]; ok {
+			d.Log = entries
+		}
+
+		resp = append(resp, d)
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// Redeliver queues a dead delivery again with a fresh set of attempts.
+func (h *WebhooksHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	webhook, ok := h.webhook(w, r, userID)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		response.Invalid(w, "deliveryID", response.FieldInvalid, "invalid delivery id")
+		return
+	}
+
+	rowsAffected, err := h.dbQueries.RedeliverWebhookDelivery(r.Context(), database.RedeliverWebhookDeliveryParams{
+		ID:        deliveryID,
+		WebhookID: webhook.ID,
+	})
+	if err != nil {
+		h.logger.Printf("Error(Redeliver): db redeliver webhook delivery (delivery_id=%s): %v", deliveryID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	// Unknown, or not dead.
+	if rowsAffected == 0 {
+		response.NotFound(w)
+		return
+	}
+
+	response.NoContent(w)
+}
@@ -4,22 +4,28 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/absurek/go-http-servers/internal/media"
 	"github.com/absurek/go-http-servers/internal/metrics"
 )
 
 type Website struct {
-	metrics *metrics.Metrics
-	logger  *log.Logger
+	blobStore media.BlobStore
+	metrics   *metrics.Metrics
+	logger    *log.Logger
 }
 
-func NewWebsite(metrics *metrics.Metrics, logger *log.Logger) *Website {
+func NewWebsite(blobStore media.BlobStore, metrics *metrics.Metrics, logger *log.Logger) *Website {
 	return &Website{
-		metrics: metrics,
-		logger:  logger,
+		blobStore: blobStore,
+		metrics:   metrics,
+		logger:    logger,
 	}
 }
 
 func (w *Website) SetupRoutes(mux *http.ServeMux) {
 	fileServerHandler := http.StripPrefix("/app/", http.FileServer(http.Dir(".")))
 	mux.Handle("/app/", w.metrics.ServerHitCounter(fileServerHandler))
+
+	mediaHandler := http.StripPrefix(media.URLPrefix, media.NewFileServer(w.blobStore, w.logger))
+	mux.Handle("GET "+media.URLPrefix, w.metrics.ServerHitCounter(mediaHandler))
 }
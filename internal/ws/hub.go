@@ -0,0 +1,243 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/absurek/go-http-servers/internal/stream"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+const (
+	TopicTimeline    = "timeline"
+	TopicMentions    = "mentions"
+	topicUserPrefix  = "user:"
+	clientBufferSize = 64
+
+	// How many messages in a row a client may miss before it is disconnected.
+	maxDropped = 16
+)
+
+var ErrInvalidTopic = errors.New("invalid topic")
+
+// ParseTopic validates a topic sent by a client: "timeline", "mentions" or
+// "user:<user id>".
+func ParseTopic(topic string) (string, error) {
+	switch {
+	case topic == TopicTimeline, topic == TopicMentions:
+		return topic, nil
+	case strings.HasPrefix(topic, topicUserPrefix):
+		userID, err := uuid.Parse(strings.TrimPrefix(topic, topicUserPrefix))
+		if err != nil {
+			return "", ErrInvalidTopic
+		}
+
+		return UserTopic(userID), nil
+	default:
+		return "", ErrInvalidTopic
+	}
+}
+
+func UserTopic(userID uuid.UUID) string {
+	return topicUserPrefix + userID.String()
+}
+
+type Client struct {
+	userID  uuid.UUID
+	rules   *visibility.Rules
+	send    chan []byte
+	topics  map[string]bool
+	dropped int
+}
+
+// Send yields the messages for the client. It is closed once the client is
+// removed from the hub, either by Unregister or for being too slow.
+func (c *Client) Send() <-chan []byte {
+	return c.send
+}
+
+func (c *Client) UserID() uuid.UUID {
+	return c.userID
+}
+
+// Hub keeps track of connected clients and their topics. It knows nothing
+// about the network, connections push into it and read from Client.Send.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	closed  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[*Client]struct{}),
+	}
+}
+
+// Register adds a client. The rules decide which events it may see, they are
+// fixed for the lifetime of the connection.
+func (h *Hub) Register(userID uuid.UUID, rules *visibility.Rules) (*Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, stream.ErrClosed
+	}
+
+	c := &Client{
+		userID: userID,
+		rules:  rules,
+		send:   make(chan []byte, clientBufferSize),
+		topics: make(map[string]bool),
+	}
+	h.clients[c] = struct{}{}
+
+	return c, nil
+}
+
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(c)
+}
+
+func (h *Hub) Subscribe(c *Client, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c.topics[topic] = true
+}
+
+func (h *Hub) Unsubscribe(c *Client, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(c.topics, topic)
+}
+
+// Send queues a message for one client, it reports false if the client is
+// gone (or got dropped because of it).
+func (h *Hub) Send(c *Client, msg any) bool {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.deliver(c, payload)
+}
+
+// Broadcast sends a chirp event to every client subscribed to a matching
+// topic. Clients get an event once even if several of their topics match.
+func (h *Hub) Broadcast(event stream.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	payloads := make(map[string][]byte)
+	for c := range h.clients {
+		topic := c.match(event)
+		if topic == "" {
+			continue
+		}
+
+		payload, ok := payloads[topic]
+		if !ok {
+			var err error
+			payload, err = json.Marshal(eventMessage{Type: messageEvent, Topic: topic, Event: event})
+			if err != nil {
+				return
+			}
+			payloads[topic] = payload
+		}
+
+		h.deliver(c, payload)
+	}
+}
+
+// Run feeds the hub from the broker until the broker is closed, then it
+// disconnects everyone.
+func (h *Hub) Run(broker *stream.Broker) error {
+	defer h.Close()
+
+	for {
+		sub, _, _, err := broker.Subscribe(0)
+		if err != nil {
+			if errors.Is(err, stream.ErrClosed) {
+				return nil
+			}
+
+			return fmt.Errorf("subscribe: %w", err)
+		}
+
+		for event := range sub.Events {
+			h.Broadcast(event)
+		}
+
+		// The hub itself fell behind, start over with a fresh subscription.
+		broker.Unsubscribe(sub)
+	}
+}
+
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		h.remove(c)
+	}
+}
+
+// deliver never blocks, a full buffer counts as a dropped message. Must be
+// called with h.mu held.
+func (h *Hub) deliver(c *Client, payload []byte) bool {
+	if _, ok := h.clients[c]; !ok {
+		return false
+	}
+
+	select {
+	case c.send <- payload:
+		c.dropped = 0
+		return true
+	default:
+		c.dropped++
+		if c.dropped > maxDropped {
+			h.remove(c)
+		}
+
+		return false
+	}
+}
+
+// Must be called with h.mu held.
+func (h *Hub) remove(c *Client) {
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+func (c *Client) match(event stream.Event) string {
+	if !c.rules.CanSee(event.AuthorID) {
+		return ""
+	}
+
+	switch {
+	case c.topics[TopicMentions] && slices.Contains(event.Mentions, c.userID) && c.rules.ShouldNotify(event.AuthorID):
+		return TopicMentions
+	case c.topics[UserTopic(event.AuthorID)]:
+		return UserTopic(event.AuthorID)
+	case c.topics[TopicTimeline] && c.rules.InTimeline(event.AuthorID):
+		return TopicTimeline
+	default:
+		return ""
+	}
+}
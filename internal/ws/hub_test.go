@@ -0,0 +1,182 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/absurek/go-http-servers/internal/stream"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+)
+
+func receive(t *testing.T, c *Client) (eventMessage, bool) {
+	t.Helper()
+
+	select {
+	case payload, ok := <-c.Send():
+		if !ok {
+			return eventMessage{}, false
+		}
+
+		var msg eventMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatalf("unmarshal message: %v", err)
+		}
+		return msg, true
+	default:
+		return eventMessage{}, false
+	}
+}
+
+func TestHubBroadcast(t *testing.T) {
+	alice := uuid.New()
+	bob := uuid.New()
+	event := stream.Event{ID: 1, Type: stream.EventChirpCreated, AuthorID: bob, Mentions: []uuid.UUID{alice}}
+
+	tests := []struct {
+		name      string
+		userID    uuid.UUID
+		topics    []string
+		wantTopic string
+	}{
+		{
+			name:      "Timeline",
+			userID:    uuid.New(),
+			topics:    []string{TopicTimeline},
+			wantTopic: TopicTimeline,
+		},
+		{
+			name:      "Author topic wins over timeline",
+			userID:    uuid.New(),
+			topics:    []string{TopicTimeline, UserTopic(bob)},
+			wantTopic: UserTopic(bob),
+		},
+		{
+			name:      "Mentioned user",
+			userID:    alice,
+			topics:    []string{TopicMentions, TopicTimeline},
+			wantTopic: TopicMentions,
+		},
+		{
+			name:      "Mentions of someone else",
+			userID:    uuid.New(),
+			topics:    []string{TopicMentions},
+			wantTopic: "",
+		},
+		{
+			name:      "Other author",
+			userID:    uuid.New(),
+			topics:    []string{UserTopic(alice)},
+			wantTopic: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			c, err := hub.Register(tt.userID, visibility.Public)
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+
+			for _, topic := range tt.topics {
+				hub.Subscribe(c, topic)
+			}
+
+			hub.Broadcast(event)
+
+			msg, ok := receive(t, c)
+			if tt.wantTopic == "" {
+				if ok {
+					t.Errorf("Broadcast() delivered %+v, want nothing", msg)
+				}
+				return
+			}
+
+			if !ok || msg.Topic != tt.wantTopic || msg.Event.ID != event.ID {
+				t.Errorf("Broadcast() delivered %+v (ok %v), want event on %s", msg, ok, tt.wantTopic)
+			}
+
+			if _, ok := receive(t, c); ok {
+				t.Errorf("Broadcast() delivered the event twice")
+			}
+		})
+	}
+}
+
+func TestHubDisconnectsSlowClient(t *testing.T) {
+	hub := NewHub()
+	slow, _ := hub.Register(uuid.New(), visibility.Public)
+	fast, _ := hub.Register(uuid.New(), visibility.Public)
+	hub.Subscribe(slow, TopicTimeline)
+	hub.Subscribe(fast, TopicTimeline)
+
+	for i := range clientBufferSize + maxDropped + 1 {
+		hub.Broadcast(stream.Event{ID: int64(i)})
+
+		// Keep the fast client drained.
+		for {
+			if _, ok := receive(t, fast); !ok {
+				break
+			}
+		}
+	}
+
+	received := 0
+	for range slow.Send() {
+		received++
+	}
+
+	if received != clientBufferSize {
+		t.Errorf("slow client received %d messages, want %d", received, clientBufferSize)
+	}
+
+	if !hub.Send(fast, topicMessage{Type: messageSubscribed, Topic: TopicTimeline}) {
+		t.Errorf("fast client got disconnected too")
+	}
+}
+
+func TestHubRunStopsWithBroker(t *testing.T) {
+	broker := stream.NewBroker(0)
+	hub := NewHub()
+	c, _ := hub.Register(uuid.New(), visibility.Public)
+
+	done := make(chan error)
+	go func() { done <- hub.Run(broker) }()
+
+	broker.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if _, ok := <-c.Send(); ok {
+		t.Errorf("client still connected after the broker closed")
+	}
+
+	if _, err := hub.Register(uuid.New(), visibility.Public); err == nil {
+		t.Errorf("Register() after shutdown succeeded")
+	}
+}
+
+func TestParseTopic(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		topic   string
+		want    string
+		wantErr bool
+	}{
+		{topic: "timeline", want: TopicTimeline},
+		{topic: "mentions", want: TopicMentions},
+		{topic: "user:" + userID.String(), want: UserTopic(userID)},
+		{topic: "user:nope", wantErr: true},
+		{topic: "everything", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTopic(tt.topic)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTopic(%q) = %q, %v, want %q (wantErr %v)", tt.topic, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ws
+
+import (
+	"encoding/json"
+
+	"github.com/absurek/go-http-servers/internal/stream"
+)
+
+const (
+	messageSubscribe   = "subscribe"
+	messageUnsubscribe = "unsubscribe"
+	messagePostChirp   = "post_chirp"
+
+	messageSubscribed   = "subscribed"
+	messageUnsubscribed = "unsubscribed"
+	messageEvent        = "event"
+	messageAck          = "ack"
+	messageError        = "error"
+)
+
+// clientMessage is everything a client can send, Type decides which of the
+// other fields are used.
+type clientMessage struct {
+	Type          string   `json:"type"`
+	ID            string   `json:"id"`
+	Topic         string   `json:"topic"`
+	Body          string   `json:"body"`
+	AttachmentIDs []string `json:"attachment_ids"`
+}
+
+type topicMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+}
+
+type eventMessage struct {
+	Type  string       `json:"type"`
+	Topic string       `json:"topic"`
+	Event stream.Event `json:"event"`
+}
+
+type ackMessage struct {
+	Type  string          `json:"type"`
+	ID    string          `json:"id"`
+	Chirp json.RawMessage `json:"chirp"`
+}
+
+type errorMessage struct {
+	Type  string `json:"type"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,32 @@
+package ws
+
+import (
+	"net/http"
+
+	"github.com/absurek/go-http-servers/internal/openapi"
+)
+
+var Operations = []openapi.Operation{
+	{
+		Pattern: "GET /api/ws",
+		Tag:     "chirps",
+		Summary: "Open a WebSocket",
+		Description: "Clients subscribe to and unsubscribe from topics and post chirps. The server answers with subscribed, unsubscribed, event, ack and error messages. " +
+			"Browsers, which can't set headers on the handshake, pass a ticket instead, and only from this server's origin or WS_ALLOWED_ORIGINS.",
+		Auth: openapi.AuthBearer,
+		Params: []openapi.Param{
+			openapi.Query("ticket", "A ticket from POST /api/ws/tickets, instead of the Authorization header."),
+		},
+		Responses: []openapi.Response{{Status: http.StatusSwitchingProtocols, Description: "Upgraded to a WebSocket"}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusServiceUnavailable},
+	},
+	{
+		Pattern:     "POST /api/ws/tickets",
+		Tag:         "chirps",
+		Summary:     "Get a WebSocket ticket",
+		Description: "The ticket authenticates one handshake of GET /api/ws within 30 seconds.",
+		Auth:        openapi.AuthBearer,
+		Responses:   []openapi.Response{{Status: http.StatusCreated, Description: "The ticket", Body: ticketResponse{}}},
+		Errors:      []int{http.StatusUnauthorized},
+	},
+}
@@ -0,0 +1,169 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/google/uuid"
+)
+
+const (
+	// How long a ticket can be used for a handshake.
+	TicketTTL    = 30 * time.Second
+	ticketLength = 32
+)
+
+// ticketStore is the part of database.Queries tickets need.
+type ticketStore interface {
+	CreateWSTicket(ctx context.Context, arg database.CreateWSTicketParams) error
+	ConsumeWSTicket(ctx context.Context, ticketHash []byte) (uuid.UUID, error)
+}
+
+type ticketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// hashTicket is what is stored, a leaked table can't open sockets.
+func hashTicket(ticket string) []byte {
+	sum := sha256.Sum256([]byte(ticket))
+	return sum[:]
+}
+
+// CreateTicket hands out a ticket for one handshake. Browsers can't set
+// headers on a WebSocket handshake, and a JWT in the URL would end up in
+// access logs and the browser history. A ticket is worthless once used or
+// after TicketTTL.
+func (h *WSHandler) CreateTicket(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, h.settings.JWTSecret)
+	if err != nil {
+		response.Unauthorized(w)
+		return
+	}
+
+	raw := make([]byte, ticketLength)
+	_, err = rand.Read(raw)
+	if err != nil {
+		h.logger.Printf("Error(CreateTicket): generate ticket (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	ticket := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := time.Now().Add(TicketTTL).UTC()
+
+	err = h.tickets.CreateWSTicket(r.Context(), database.CreateWSTicketParams{
+		TicketHash: hashTicket(ticket),
+		UserID:     userID,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		h.logger.Printf("Error(CreateTicket): db create ticket (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, ticketResponse{
+		Ticket:    ticket,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// authenticate takes an access token from the Authorization header, for
+// clients that can set it, or else a ticket from ?ticket=, using it up. It
+// writes the error response when neither is valid.
+func (h *WSHandler) authenticate(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err == nil {
+		userID, err := auth.ValidateJWT(token, h.settings.JWTSecret)
+		if err != nil {
+			response.Unauthorized(w)
+			return uuid.Nil, false
+		}
+
+		return userID, true
+	}
+
+	ticket := r.URL.Query().Get("ticket")
+	if ticket == "" {
+		response.Unauthorized(w)
+		return uuid.Nil, false
+	}
+
+	userID, err := h.tickets.ConsumeWSTicket(r.Context(), hashTicket(ticket))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// Made up, used or expired.
+			response.Unauthorized(w)
+		default:
+			h.logger.Printf("Error(Connect): db consume ticket: %v", err)
+			response.InternalServerError(w)
+		}
+
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// parseOrigins parses WS_ALLOWED_ORIGINS, comma separated origins like
+// https://app.example.com.
+func parseOrigins(spec string) map[string]bool {
+	origins := make(map[string]bool)
+	for _, origin := range strings.Split(spec, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin != "" {
+			origins[normalizeOrigin(origin)] = true
+		}
+	}
+
+	return origins
+}
+
+func normalizeOrigin(origin string) string {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// checkOrigin lets through handshakes without an Origin, which don't come
+// from browsers, from the server's own origin and from the allowed ones.
+// Other sites can't open sockets for a user, even with a ticket.
+func (h *WSHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return h.allowedOrigins[normalizeOrigin(origin)]
+}
@@ -0,0 +1,138 @@
+package ws
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/auth"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/google/uuid"
+)
+
+// memStore implements ticketStore with the semantics of the queries.
+type memStore struct {
+	mu      sync.Mutex
+	tickets map[string]database.CreateWSTicketParams
+}
+
+func (s *memStore) CreateWSTicket(ctx context.Context, arg database.CreateWSTicketParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tickets[string(arg.TicketHash)] = arg
+	return nil
+}
+
+func (s *memStore) ConsumeWSTicket(ctx context.Context, ticketHash []byte) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ticket, ok := s.tickets[string(ticketHash)]
+	if !ok || !ticket.ExpiresAt.After(time.Now()) {
+		return uuid.Nil, sql.ErrNoRows
+	}
+
+	delete(s.tickets, string(ticketHash))
+	return ticket.UserID, nil
+}
+
+func newTestHandler(allowedOrigins string) (*WSHandler, *memStore) {
+	store := &memStore{tickets: make(map[string]database.CreateWSTicketParams)}
+	return &WSHandler{
+		settings:       settings.Settings{JWTSecret: "secret"},
+		tickets:        store,
+		allowedOrigins: parseOrigins(allowedOrigins),
+		logger:         log.New(io.Discard, "", 0),
+	}, store
+}
+
+func TestTickets(t *testing.T) {
+	h, store := newTestHandler("")
+
+	userID := uuid.New()
+	jwt, err := auth.MakeJWT(userID, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/ws/tickets", nil)
+	r.Header.Set("Authorization", "Bearer "+jwt)
+	w := httptest.NewRecorder()
+	h.CreateTicket(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateTicket() status = %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	var resp ticketResponse
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatalf("unmarshal ticket: %v", err)
+	}
+
+	connect := func(query string) (uuid.UUID, int) {
+		w := httptest.NewRecorder()
+		got, ok := h.authenticate(w, httptest.NewRequest(http.MethodGet, "/api/ws?"+query, nil))
+		if ok {
+			return got, http.StatusOK
+		}
+		return got, w.Code
+	}
+
+	if got, status := connect("ticket=" + resp.Ticket); status != http.StatusOK || got != userID {
+		t.Errorf("first use = %s %d, want %s", got, status, userID)
+	}
+
+	if _, status := connect("ticket=" + resp.Ticket); status != http.StatusUnauthorized {
+		t.Errorf("second use status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// Made up, expired, and the old query string token.
+	store.CreateWSTicket(context.Background(), database.CreateWSTicketParams{
+		TicketHash: hashTicket("expired"),
+		UserID:     userID,
+		ExpiresAt:  time.Now().Add(-time.Second),
+	})
+	for _, query := range []string{"ticket=made-up", "ticket=expired", "access_token=" + jwt, ""} {
+		if _, status := connect(query); status != http.StatusUnauthorized {
+			t.Errorf("%q status = %d, want %d", query, status, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	h, _ := newTestHandler("https://app.example.com, https://Other.example.com")
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "", want: true},
+		{origin: "https://chirpy.example.com", want: true},
+		{origin: "https://app.example.com", want: true},
+		{origin: "https://other.example.com", want: true},
+		{origin: "http://app.example.com", want: false},
+		{origin: "https://evil.example.com", want: false},
+		{origin: "null", want: false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "https://chirpy.example.com/api/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+
+		if got := h.checkOrigin(r); got != tt.want {
+			t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
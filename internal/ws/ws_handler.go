@@ -0,0 +1,221 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/absurek/go-http-servers/internal/chirps"
+	"github.com/absurek/go-http-servers/internal/database"
+	"github.com/absurek/go-http-servers/internal/response"
+	"github.com/absurek/go-http-servers/internal/settings"
+	"github.com/absurek/go-http-servers/internal/visibility"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 8 << 10
+	postTimeout    = 10 * time.Second
+)
+
+type WSHandler struct {
+	settings       settings.Settings
+	hub            *Hub
+	chirpsHandler  *chirps.ChirpsHandler
+	filter         *visibility.Filter
+	tickets        ticketStore
+	allowedOrigins map[string]bool
+	upgrader       websocket.Upgrader
+	logger         *log.Logger
+}
+
+func NewWSHandler(s settings.Settings, dbQueries *database.Queries, hub *Hub, chirpsHandler *chirps.ChirpsHandler, filter *visibility.Filter, logger *log.Logger) *WSHandler {
+	allowedOrigins := parseOrigins(s.WSAllowedOrigins)
+	if s.BaseURL != "" {
+		allowedOrigins[normalizeOrigin(s.BaseURL)] = true
+	}
+
+	h := &WSHandler{
+		settings:       s,
+		hub:            hub,
+		chirpsHandler:  chirpsHandler,
+		filter:         filter,
+		tickets:        dbQueries,
+		allowedOrigins: allowedOrigins,
+		logger:         logger,
+	}
+
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     h.checkOrigin,
+	}
+
+	return h
+}
+
+func (h *WSHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	// Checked before a ticket is used up, the upgrader checks again.
+	if !h.checkOrigin(r) {
+		response.Forbidden(w)
+		return
+	}
+
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	rules, err := h.filter.ForViewer(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		h.logger.Printf("Error(Connect): visibility rules (user_id=%s): %v", userID, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	client, err := h.hub.Register(userID, rules)
+	if err != nil {
+		response.ServiceUnavailable(w)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already replied with an error.
+		h.hub.Unregister(client)
+		return
+	}
+
+	go h.writePump(conn, client)
+	h.readPump(conn, client)
+}
+
+func (h *WSHandler) readPump(conn *websocket.Conn, client *Client) {
+	defer func() {
+		h.hub.Unregister(client)
+		conn.Close()
+	}()
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				h.logger.Printf("Error(WS): read (user_id=%s): %v", client.UserID(), err)
+			}
+			return
+		}
+
+		var msg clientMessage
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			h.hub.Send(client, errorMessage{Type: messageError, Error: "invalid message"})
+			continue
+		}
+
+		h.handleMessage(client, msg)
+	}
+}
+
+func (h *WSHandler) handleMessage(client *Client, msg clientMessage) {
+	switch msg.Type {
+	case messageSubscribe, messageUnsubscribe:
+		topic, err := ParseTopic(msg.Topic)
+		if err != nil {
+			h.hub.Send(client, errorMessage{Type: messageError, ID: msg.ID, Error: err.Error()})
+			return
+		}
+
+		if msg.Type == messageSubscribe {
+			h.hub.Subscribe(client, topic)
+			h.hub.Send(client, topicMessage{Type: messageSubscribed, Topic: topic})
+		} else {
+			h.hub.Unsubscribe(client, topic)
+			h.hub.Send(client, topicMessage{Type: messageUnsubscribed, Topic: topic})
+		}
+	case messagePostChirp:
+		h.postChirp(client, msg)
+	default:
+		h.hub.Send(client, errorMessage{Type: messageError, ID: msg.ID, Error: "unknown message type"})
+	}
+}
+
+func (h *WSHandler) postChirp(client *Client, msg clientMessage) {
+	attachmentIDs := make([]uuid.UUID, 0, len(msg.AttachmentIDs))
+	for _, idString := range msg.AttachmentIDs {
+		attachmentID, err := uuid.Parse(idString)
+		if err != nil {
+			h.hub.Send(client, errorMessage{Type: messageError, ID: msg.ID, Error: chirps.ErrInvalidAttachment.Error()})
+			return
+		}
+
+		attachmentIDs = append(attachmentIDs, attachmentID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+
+	chirp, err := h.chirpsHandler.PostChirp(ctx, client.UserID(), msg.Body, attachmentIDs, time.Time{})
+	if err != nil {
+		switch {
+		case errors.Is(err, chirps.ErrChirpTooLong), errors.Is(err, chirps.ErrTooManyAttachments), errors.Is(err, chirps.ErrInvalidAttachment), errors.Is(err, chirps.ErrChirpRejected), errors.Is(err, chirps.ErrUserSuspended):
+			h.hub.Send(client, errorMessage{Type: messageError, ID: msg.ID, Error: err.Error()})
+		default:
+			h.logger.Printf("Error(WS): post chirp (user_id=%s): %v", client.UserID(), err)
+			h.hub.Send(client, errorMessage{Type: messageError, ID: msg.ID, Error: "internal server error"})
+		}
+
+		return
+	}
+
+	payload, err := json.Marshal(chirp)
+	if err != nil {
+		h.logger.Printf("Error(WS): marshal chirp (user_id=%s): %v", client.UserID(), err)
+		return
+	}
+
+	h.hub.Send(client, ackMessage{Type: messageAck, ID: msg.ID, Chirp: payload})
+}
+
+func (h *WSHandler) writePump(conn *websocket.Conn, client *Client) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-client.Send():
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// Removed by the hub: shutting down or too slow.
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+
+			err := conn.WriteMessage(websocket.TextMessage, payload)
+			if err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err := conn.WriteMessage(websocket.PingMessage, nil)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+-- name: CreateActorKey :exec
+-- Two requests may race to create a key, the first one wins.
+INSERT INTO actor_keys (user_id, public_key_pem, private_key_pem)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetActorKey :one
+SELECT * FROM actor_keys WHERE user_id = $1;
+
+-- name: UpsertRemoteActor :one
+INSERT INTO remote_actors (id, inbox, shared_inbox, public_key_id, public_key_pem)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (id) DO UPDATE
+SET inbox = EXCLUDED.inbox,
+    shared_inbox = EXCLUDED.shared_inbox,
+    public_key_id = EXCLUDED.public_key_id,
+    public_key_pem = EXCLUDED.public_key_pem,
+    fetched_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: GetRemoteActor :one
+SELECT * FROM remote_actors WHERE id = $1;
+
+-- name: GetRemoteActorByKeyID :one
+SELECT * FROM remote_actors WHERE public_key_id = $1;
+
+-- name: DeleteRemoteActor :execrows
+DELETE FROM remote_actors WHERE id = $1;
+
+-- name: AddRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_id, follow_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET follow_id = EXCLUDED.follow_id;
+
+-- name: RemoveRemoteFollower :execrows
+DELETE FROM remote_followers WHERE actor_id = $1 AND follow_id = $2;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM remote_followers WHERE user_id = $1;
+
+-- name: GetFollowerInboxes :many
+-- Followers on the same server share an inbox if it has one, they get one
+-- delivery between them.
+SELECT DISTINCT COALESCE(remote_actors.shared_inbox, remote_actors.inbox)::text AS inbox
+FROM remote_followers
+JOIN remote_actors ON remote_actors.id = remote_followers.actor_id
+WHERE remote_followers.user_id = $1;
+
+-- name: CreateRemoteFollow :execrows
+INSERT INTO remote_follows (user_id, actor_id, follow_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, actor_id) DO NOTHING;
+
+-- name: DeleteRemoteFollow :one
+DELETE FROM remote_follows WHERE user_id = $1 AND actor_id = $2
+RETURNING follow_id;
+
+-- name: GetRemoteFollows :many
+SELECT * FROM remote_follows WHERE user_id = $1 ORDER BY created_at DESC;
+
+-- name: AcceptRemoteFollow :execrows
+UPDATE remote_follows SET accepted_at = CURRENT_TIMESTAMP
+WHERE follow_id = $1 AND actor_id = $2 AND accepted_at IS NULL;
+
+-- name: RejectRemoteFollow :execrows
+DELETE FROM remote_follows WHERE follow_id = $1 AND actor_id = $2;
+
+-- name: IsRemoteActorFollowed :one
+SELECT EXISTS (SELECT 1 FROM remote_follows WHERE actor_id = $1);
+
+-- name: CreateRemoteNote :exec
+INSERT INTO remote_notes (id, actor_id, content, in_reply_to, published_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: DeleteRemoteNote :execrows
+DELETE FROM remote_notes WHERE id = $1 AND actor_id = $2;
+
+-- name: GetRemoteNotes :many
+-- Notes by the actors a user follows, newest first.
+SELECT remote_notes.* FROM remote_notes
+JOIN remote_follows ON remote_follows.actor_id = remote_notes.actor_id
+WHERE remote_follows.user_id = sqlc.arg('user_id') AND remote_follows.accepted_at IS NOT NULL
+ORDER BY remote_notes.published_at DESC
+LIMIT sqlc.arg('max_notes');
+
+-- name: CreateRemoteLike :exec
+INSERT INTO remote_likes (id, actor_id, chirp_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteRemoteLike :execrows
+DELETE FROM remote_likes WHERE id = $1 AND actor_id = $2;
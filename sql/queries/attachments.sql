@@ -0,0 +1,30 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, user_id, chirp_id, position, content_type, size_bytes, width, height, storage_key, thumbnail_key, created_at, updated_at)
+VALUES ($1, $2, NULL, 0, $3, $4, $5, $6, $7, $8, DEFAULT, DEFAULT)
+RETURNING *;
+
+-- name: AttachToChirp :execrows
+UPDATE attachments
+SET chirp_id = $1, position = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL;
+
+-- name: GetAttachmentsByChirpIDs :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: GetPurgeableAttachments :many
+-- Files of chirps and users past retention, and uploads that never made it
+-- into a chirp or a draft.
+SELECT attachments.* FROM attachments
+LEFT JOIN chirps ON chirps.id = attachments.chirp_id
+JOIN users ON users.id = attachments.user_id
+WHERE chirps.deleted_at < sqlc.arg('deleted_before')
+   OR users.deleted_at < sqlc.arg('deleted_before')
+   OR (attachments.chirp_id IS NULL
+       AND attachments.created_at < sqlc.arg('orphaned_before')
+       AND NOT EXISTS (SELECT 1 FROM drafts WHERE attachments.id = ANY(drafts.attachment_ids)))
+LIMIT sqlc.arg('max_attachments');
+
+-- name: DeleteAttachments :execrows
+DELETE FROM attachments WHERE id = ANY(sqlc.arg('ids')::uuid[]);
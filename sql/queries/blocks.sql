@@ -0,0 +1,36 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, DEFAULT)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlockedUsers :many
+SELECT * FROM user_blocks WHERE blocker_id = $1 ORDER BY created_at DESC;
+
+-- name: MuteUser :exec
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, DEFAULT)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutedUsers :many
+SELECT * FROM user_mutes WHERE muter_id = $1 ORDER BY created_at DESC;
+
+-- name: GetVisibilityRelations :many
+SELECT blocked_id AS user_id, 'blocked'::text AS relation FROM user_blocks WHERE user_blocks.blocker_id = sqlc.arg('viewer_id')
+UNION ALL
+SELECT blocker_id AS user_id, 'blocked_by'::text AS relation FROM user_blocks WHERE user_blocks.blocked_id = sqlc.arg('viewer_id')
+UNION ALL
+SELECT muted_id AS user_id, 'muted'::text AS relation FROM user_mutes WHERE user_mutes.muter_id = sqlc.arg('viewer_id');
+
+-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2)
+       OR (blocker_id = $2 AND blocked_id = $1)
+);
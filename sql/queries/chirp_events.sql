@@ -0,0 +1,5 @@
+-- name: NextChirpEventID :one
+SELECT nextval('chirp_event_ids')::bigint;
+
+-- name: NotifyChirpEvent :exec
+SELECT pg_notify('chirp_events', sqlc.arg('payload')::text);
@@ -1,22 +1,107 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, user_id, body, created_at, updated_at)
-VALUES (gen_random_uuid(), $1, $2, DEFAULT, DEFAULT)
+INSERT INTO chirps (id, user_id, body, publish_at, created_at, updated_at)
+VALUES (gen_random_uuid(), sqlc.arg('user_id'), sqlc.arg('body'), sqlc.narg('publish_at'), DEFAULT, DEFAULT)
 RETURNING *;
 
 -- name: GetAllChirps :many
 SELECT * FROM chirps 
-WHERE (sqlc.narg('user_id')::uuid IS NULL OR user_id = sqlc.narg('user_id'))
+WHERE (sqlc.narg('user_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('user_id'))
+  AND chirps.deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.deleted_at IS NULL)
 ORDER BY created_at;
 
+-- name: GetChirpsPage :many
+SELECT * FROM chirps
+WHERE (sqlc.narg('user_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('user_id'))
+  AND (sqlc.narg('before_at')::timestamptz IS NULL
+       OR (chirps.created_at, chirps.id) < (sqlc.narg('before_at')::timestamptz, sqlc.narg('before_id')::uuid))
+  AND chirps.deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.deleted_at IS NULL)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('max_chirps');
+
 -- name: GetChirpByID :one
-SELECT * FROM chirps WHERE id = $1;
+SELECT * FROM chirps
+WHERE chirps.id = $1
+  AND chirps.deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.deleted_at IS NULL);
 
 -- name: CheckChirpAccess :one
 SELECT EXISTS (
     SELECT 1
     FROM chirps
-    WHERE id = $1 AND user_id = $2
+    WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
 );
 
 -- name: DeleteChirp :execrows
-DELETE FROM chirps WHERE id = $1 AND user_id = $2;
+UPDATE chirps
+SET deleted_at = CURRENT_TIMESTAMP, deleted_by = sqlc.arg('deleted_by')
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = sqlc.arg('id')
+  AND user_id = sqlc.arg('user_id')
+  AND deleted_by = sqlc.arg('user_id')
+  AND deleted_at > sqlc.arg('deleted_after')
+RETURNING *;
+
+-- name: HideChirp :one
+UPDATE chirps SET hidden_at = CURRENT_TIMESTAMP WHERE id = $1 AND hidden_at IS NULL AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UnhideChirp :one
+UPDATE chirps SET hidden_at = NULL WHERE id = $1 AND hidden_at IS NOT NULL AND deleted_at IS NULL
+RETURNING *;
+
+-- name: PurgeChirps :execrows
+DELETE FROM chirps
+WHERE id IN (
+    SELECT id FROM chirps AS purged
+    WHERE purged.deleted_at < sqlc.arg('deleted_before')
+    LIMIT sqlc.arg('max_chirps')
+);
+
+-- name: GetChirpsByUser :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: ReleaseScheduledChirps :many
+-- Rows locked by another instance are skipped, so every due chirp is
+-- released exactly once. created_at becomes the publish time so the chirp
+-- lands where readers expect it in timelines. Chirps of deleted accounts
+-- wait, they are released if the account is restored and purged with it
+-- otherwise.
+UPDATE chirps
+SET publish_at = NULL, created_at = chirps.publish_at, updated_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT id FROM chirps AS due
+    WHERE due.publish_at <= CURRENT_TIMESTAMP AND due.deleted_at IS NULL
+      AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = due.user_id AND users.deleted_at IS NOT NULL)
+    ORDER BY due.publish_at
+    LIMIT sqlc.arg('max_chirps')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = sqlc.arg('body'), updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id') AND user_id = sqlc.arg('user_id') AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetFeedChirps :many
+-- The newest chirps anyone may see, by author or hashtag. The tag pattern
+-- mirrors chirptext.Hashtags, tags are letters, digits and underscores so
+-- they need no escaping.
+SELECT * FROM chirps
+WHERE (sqlc.narg('user_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('user_id'))
+  AND (sqlc.narg('hashtag')::text IS NULL OR chirps.body ~* ('(^|[^[:alnum:]_#])#' || sqlc.narg('hashtag')::text || '($|[^[:alnum:]_])'))
+  AND chirps.deleted_at IS NULL
+  AND chirps.hidden_at IS NULL
+  AND chirps.publish_at IS NULL
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.deleted_at IS NULL)
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_chirps');
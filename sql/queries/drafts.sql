@@ -0,0 +1,21 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, user_id, body, attachment_ids)
+VALUES (gen_random_uuid(), sqlc.arg('user_id'), sqlc.arg('body'), sqlc.arg('attachment_ids')::uuid[])
+RETURNING *;
+
+-- name: GetDrafts :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+
+-- name: GetDraft :one
+SELECT * FROM drafts WHERE id = $1 AND user_id = $2;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = sqlc.arg('body'), attachment_ids = sqlc.arg('attachment_ids')::uuid[], updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id') AND user_id = sqlc.arg('user_id')
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts WHERE id = $1 AND user_id = $2;
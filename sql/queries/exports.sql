@@ -0,0 +1,40 @@
+-- name: CreateExport :one
+INSERT INTO exports (id, user_id)
+VALUES (gen_random_uuid(), $1)
+ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+RETURNING *;
+
+-- name: GetLatestExport :one
+SELECT * FROM exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetExportByID :one
+SELECT * FROM exports WHERE id = $1;
+
+-- name: CompleteExport :execrows
+UPDATE exports
+SET status = 'ready',
+    storage_key = sqlc.arg('storage_key'),
+    size_bytes = sqlc.arg('size_bytes'),
+    completed_at = CURRENT_TIMESTAMP,
+    expires_at = sqlc.arg('expires_at')
+WHERE id = sqlc.arg('id') AND status = 'pending';
+
+-- name: FailExport :execrows
+-- Failed exports have nothing to download, they expire right away.
+UPDATE exports
+SET status = 'failed', completed_at = CURRENT_TIMESTAMP, expires_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'pending';
+
+-- name: GetExpiredExports :many
+-- Archives past their expiry, and any archive of a deleted account.
+SELECT exports.* FROM exports
+JOIN users ON users.id = exports.user_id
+WHERE exports.expires_at < sqlc.arg('expired_before')
+   OR (users.deleted_at IS NOT NULL AND exports.status <> 'pending')
+LIMIT sqlc.arg('max_exports');
+
+-- name: DeleteExports :execrows
+DELETE FROM exports WHERE id = ANY(sqlc.arg('ids')::uuid[]);
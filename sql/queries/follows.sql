@@ -0,0 +1,13 @@
+-- name: FollowUser :execrows
+INSERT INTO user_follows (follower_id, followed_id, created_at)
+VALUES ($1, $2, DEFAULT)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM user_follows WHERE follower_id = $1 AND followed_id = $2;
+
+-- name: GetFollowedUsers :many
+SELECT * FROM user_follows WHERE follower_id = $1 ORDER BY created_at DESC;
+
+-- name: GetFollowedUserIDs :many
+SELECT followed_id FROM user_follows WHERE follower_id = $1;
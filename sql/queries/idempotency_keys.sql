@@ -0,0 +1,34 @@
+-- name: ClaimIdempotencyKey :execrows
+-- Takes the key for a request. A key already taken is only taken over once
+-- it expired, or when its request was abandoned without a response.
+INSERT INTO idempotency_keys (user_id, key, fingerprint)
+VALUES (sqlc.arg('user_id'), sqlc.arg('key'), sqlc.arg('fingerprint'))
+ON CONFLICT (user_id, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint,
+    status = NULL,
+    headers = '{}',
+    body = '',
+    created_at = CURRENT_TIMESTAMP
+WHERE idempotency_keys.created_at < sqlc.arg('expired_before')
+   OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at < sqlc.arg('abandoned_before'));
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE user_id = $1 AND key = $2;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status = $3, headers = $4, body = $5
+WHERE user_id = $1 AND key = $2;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE user_id = $1 AND key = $2;
+
+-- name: PurgeIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE (user_id, key) IN (
+    SELECT user_id, key FROM idempotency_keys AS purged
+    WHERE purged.created_at < sqlc.arg('created_before')
+    LIMIT sqlc.arg('max_keys')
+);
@@ -0,0 +1,73 @@
+-- name: EnqueueJob :execrows
+INSERT INTO jobs (id, kind, payload, unique_key, max_attempts, run_at)
+VALUES (gen_random_uuid(), sqlc.arg('kind'), sqlc.arg('payload'), sqlc.narg('unique_key'), sqlc.arg('max_attempts'), sqlc.arg('run_at'))
+ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('queued', 'running') DO NOTHING;
+
+-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_until = sqlc.arg('lease_until')::timestamptz, updated_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT due.id FROM jobs AS due
+    WHERE (due.status = 'queued' AND due.run_at <= CURRENT_TIMESTAMP)
+    OR (due.status = 'running' AND due.locked_until <= CURRENT_TIMESTAMP)
+    ORDER BY due.run_at
+    LIMIT sqlc.arg('max_jobs')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_until = NULL, last_error = NULL, updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'queued', locked_until = NULL, run_at = sqlc.arg('run_at'), last_error = sqlc.arg('last_error'), updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id');
+
+-- name: KillJob :exec
+UPDATE jobs
+SET status = 'dead', locked_until = NULL, last_error = sqlc.arg('last_error'), updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id');
+
+-- name: GetJobs :many
+SELECT * FROM jobs
+WHERE sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status')::text
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_jobs');
+
+-- name: GetJobStats :many
+SELECT kind, status, COUNT(*) AS count
+FROM jobs
+GROUP BY kind, status
+ORDER BY kind, status;
+
+-- name: PurgeJobs :execrows
+DELETE FROM jobs
+WHERE id IN (
+    SELECT id FROM jobs AS purged
+    WHERE purged.finished_at < sqlc.arg('finished_before')
+    LIMIT sqlc.arg('max_jobs')
+);
+
+-- name: UpsertJobSchedule :exec
+-- A changed spec starts over from the new next run.
+INSERT INTO job_schedules (name, spec, next_run_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE
+SET spec = EXCLUDED.spec,
+    next_run_at = CASE WHEN job_schedules.spec = EXCLUDED.spec THEN job_schedules.next_run_at ELSE EXCLUDED.next_run_at END;
+
+-- name: GetDueJobSchedules :many
+SELECT * FROM job_schedules
+WHERE next_run_at <= CURRENT_TIMESTAMP
+FOR UPDATE SKIP LOCKED;
+
+-- name: SetJobScheduleNextRun :exec
+UPDATE job_schedules
+SET next_run_at = $2, last_run_at = CURRENT_TIMESTAMP
+WHERE name = $1;
+
+-- name: GetJobSchedules :many
+SELECT * FROM job_schedules ORDER BY name;
@@ -0,0 +1,74 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (gen_random_uuid(), DEFAULT, DEFAULT)
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, last_read_at, joined_at)
+VALUES ($1, $2, NULL, DEFAULT);
+
+-- name: GetConversationByParticipants :one
+SELECT conversation_id
+FROM conversation_participants
+GROUP BY conversation_id
+HAVING COUNT(*) = cardinality(sqlc.arg('user_ids')::uuid[])
+   AND bool_and(user_id = ANY(sqlc.arg('user_ids')::uuid[]))
+LIMIT 1;
+
+-- name: IsConversationParticipant :one
+SELECT EXISTS (
+    SELECT 1
+    FROM conversation_participants
+    WHERE conversation_id = $1 AND user_id = $2
+);
+
+-- name: GetConversationsForUser :many
+SELECT
+    conversations.*,
+    (
+        SELECT COUNT(*)
+        FROM messages
+        WHERE messages.conversation_id = conversations.id
+          AND messages.sender_id <> conversation_participants.user_id
+          AND (conversation_participants.last_read_at IS NULL OR messages.created_at > conversation_participants.last_read_at)
+    )::int AS unread_count
+FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversation_participants.user_id = $1
+ORDER BY conversations.updated_at DESC;
+
+-- name: GetConversationParticipants :many
+SELECT * FROM conversation_participants
+WHERE conversation_id = ANY(sqlc.arg('conversation_ids')::uuid[])
+ORDER BY joined_at;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = CURRENT_TIMESTAMP WHERE id = $1;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants
+SET last_read_at = CURRENT_TIMESTAMP
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, conversation_id, sender_id, key_id, nonce, ciphertext, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, DEFAULT)
+RETURNING *;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg('conversation_id')
+  AND (sqlc.narg('before')::timestamptz IS NULL OR created_at < sqlc.narg('before'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_messages');
+
+-- name: GetMessagesNotSealedWith :many
+SELECT * FROM messages
+WHERE key_id <> sqlc.arg('key_id')
+  AND NOT (id = ANY(sqlc.arg('skip_ids')::uuid[]))
+LIMIT sqlc.arg('max_messages');
+
+-- name: ResealMessage :execrows
+UPDATE messages
+SET key_id = sqlc.arg('key_id'), nonce = sqlc.arg('nonce'), ciphertext = sqlc.arg('ciphertext')
+WHERE id = sqlc.arg('id') AND key_id = sqlc.arg('old_key_id');
@@ -0,0 +1,19 @@
+-- name: GetModerationRules :many
+SELECT * FROM moderation_rules ORDER BY position, created_at;
+
+-- name: CreateModerationRule :one
+INSERT INTO moderation_rules (id, kind, pattern, action, position, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, DEFAULT, DEFAULT)
+RETURNING *;
+
+-- name: UpdateModerationRule :one
+UPDATE moderation_rules
+SET kind = $2, pattern = $3, action = $4, position = $5, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteModerationRule :execrows
+DELETE FROM moderation_rules WHERE id = $1;
+
+-- name: NotifyModerationRulesChanged :exec
+SELECT pg_notify('moderation_rules', '');
@@ -0,0 +1,58 @@
+-- name: CreateNotification :execrows
+INSERT INTO notifications (id, user_id, actor_id, type, chirp_id, read_at, created_at)
+SELECT gen_random_uuid(), sqlc.arg('user_id'), sqlc.arg('actor_id'), sqlc.arg('type'), sqlc.narg('chirp_id'), NULL, DEFAULT
+WHERE NOT EXISTS (
+    SELECT 1
+    FROM notification_preferences
+    WHERE notification_preferences.user_id = sqlc.arg('user_id')
+      AND notification_preferences.type = sqlc.arg('type')
+      AND notification_preferences.muted
+);
+
+-- name: GetNotificationGroups :many
+-- Notifications of one type about one chirp are grouped per UTC day, so a
+-- group only changes on the day it is about and follows, which have no
+-- chirp, don't all end up in one group. Only the latest max_ids
+-- notification IDs of a group are returned. Pages are keyed on
+-- (latest_at, type, chirp_id), with a missing chirp sorting as the nil UUID.
+WITH groups AS (
+    SELECT
+        type,
+        chirp_id,
+        COUNT(*)::int AS count,
+        COUNT(DISTINCT actor_id)::int AS actor_count,
+        (array_agg(actor_id ORDER BY created_at DESC))[1:3]::uuid[] AS recent_actor_ids,
+        (array_agg(id ORDER BY created_at DESC))[1:sqlc.arg('max_ids')::int]::uuid[] AS notification_ids,
+        bool_and(read_at IS NOT NULL) AS read,
+        MAX(created_at)::timestamptz AS latest_at
+    FROM notifications
+    WHERE user_id = sqlc.arg('user_id')
+    GROUP BY type, chirp_id, date_trunc('day', created_at AT TIME ZONE 'UTC')
+    HAVING NOT sqlc.arg('unread_only')::bool OR bool_or(read_at IS NULL)
+)
+SELECT * FROM groups
+WHERE sqlc.narg('before_at')::timestamptz IS NULL
+   OR (latest_at, type, COALESCE(chirp_id, '00000000-0000-0000-0000-000000000000'))
+    < (sqlc.narg('before_at')::timestamptz, sqlc.narg('before_type')::text, sqlc.narg('before_chirp_id')::uuid)
+ORDER BY latest_at DESC, type DESC, COALESCE(chirp_id, '00000000-0000-0000-0000-000000000000') DESC
+LIMIT sqlc.arg('max_groups');
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = CURRENT_TIMESTAMP
+WHERE user_id = sqlc.arg('user_id')
+  AND read_at IS NULL
+  AND (sqlc.arg('all')::bool OR id = ANY(sqlc.arg('ids')::uuid[]));
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: UpsertNotificationPreferences :exec
+-- types and muted are parallel arrays, one statement sets them all.
+INSERT INTO notification_preferences (user_id, type, muted)
+SELECT sqlc.arg('user_id'), unnest(sqlc.arg('types')::text[]), unnest(sqlc.arg('muted')::bool[])
+ON CONFLICT (user_id, type) DO UPDATE
+SET muted = EXCLUDED.muted, updated_at = CURRENT_TIMESTAMP;
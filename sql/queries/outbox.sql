@@ -0,0 +1,46 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox (aggregate_type, aggregate_id, event, payload)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetDueOutboxEvents :many
+-- An event waits while an earlier one of its aggregate backs off, so
+-- subscribers see every aggregate's events in order.
+SELECT * FROM outbox AS o
+WHERE o.published_at IS NULL
+AND o.next_attempt_at <= CURRENT_TIMESTAMP
+AND NOT EXISTS (
+    SELECT 1 FROM outbox AS earlier
+    WHERE earlier.published_at IS NULL
+    AND earlier.aggregate_type = o.aggregate_type
+    AND earlier.aggregate_id = o.aggregate_id
+    AND earlier.id < o.id
+    AND earlier.next_attempt_at > CURRENT_TIMESTAMP
+)
+ORDER BY o.id
+LIMIT sqlc.arg('max_events');
+
+-- name: MarkOutboxEventsPublished :exec
+UPDATE outbox
+SET published_at = CURRENT_TIMESTAMP
+WHERE id = ANY(sqlc.arg('ids')::bigint[]);
+
+-- name: RetryOutboxEvent :exec
+UPDATE outbox
+SET attempts = attempts + 1, last_error = sqlc.arg('last_error'), next_attempt_at = sqlc.arg('next_attempt_at')
+WHERE id = sqlc.arg('id');
+
+-- name: GetOutboxLag :one
+SELECT COUNT(*) AS pending, COALESCE(MIN(created_at), CURRENT_TIMESTAMP)::timestamptz AS oldest
+FROM outbox
+WHERE published_at IS NULL;
+
+-- name: TryAdvisoryLock :one
+SELECT pg_try_advisory_lock(sqlc.arg('key')::bigint);
+
+-- name: PurgeOutboxEvents :execrows
+DELETE FROM outbox
+WHERE id IN (
+    SELECT id FROM outbox AS purged
+    WHERE purged.published_at < sqlc.arg('published_before')
+    LIMIT sqlc.arg('max_events')
+);
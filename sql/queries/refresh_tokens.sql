@@ -11,3 +11,23 @@ SELECT * FROM refresh_tokens WHERE token = $1;
 UPDATE refresh_tokens
 SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
 WHERE token = $1;
+
+-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensByUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: PurgeRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE token IN (
+    SELECT purged.token FROM refresh_tokens AS purged
+    WHERE purged.expires_at < sqlc.arg('expired_before')
+    OR purged.revoked_at < sqlc.arg('revoked_before')
+    LIMIT sqlc.arg('max_tokens')
+    FOR UPDATE SKIP LOCKED
+);
@@ -0,0 +1,46 @@
+-- name: CreateReport :execrows
+INSERT INTO reports (id, reporter_id, user_id, chirp_id, chirp_body, reason, details, status, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, 'open', DEFAULT)
+ON CONFLICT DO NOTHING;
+
+-- name: FlagChirp :exec
+INSERT INTO reports (id, reporter_id, user_id, chirp_id, chirp_body, reason, details, status, created_at)
+SELECT gen_random_uuid(), NULL, chirps.user_id, chirps.id, chirps.body, 'flagged', 'Matched moderation rule ' || sqlc.arg('rule_id')::uuid, 'open', DEFAULT
+FROM chirps
+WHERE chirps.id = sqlc.arg('chirp_id');
+
+-- name: GetReports :many
+SELECT * FROM reports
+WHERE status = sqlc.arg('status')
+  AND (sqlc.narg('after')::timestamptz IS NULL OR created_at > sqlc.narg('after'))
+ORDER BY created_at
+LIMIT sqlc.arg('max_reports');
+
+-- name: GetReportByID :one
+SELECT * FROM reports WHERE id = $1;
+
+-- name: ResolveReport :execrows
+UPDATE reports
+SET status = sqlc.arg('status'), resolved_by = sqlc.arg('resolved_by'), resolved_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id') AND status = 'open';
+
+-- name: ResolveChirpReports :execrows
+UPDATE reports
+SET status = sqlc.arg('status'), resolved_by = sqlc.arg('resolved_by'), resolved_at = CURRENT_TIMESTAMP
+WHERE chirp_id = sqlc.arg('chirp_id') AND status = 'open';
+
+-- name: ResolveUserReports :execrows
+UPDATE reports
+SET status = 'actioned', resolved_by = sqlc.arg('resolved_by'), resolved_at = CURRENT_TIMESTAMP
+WHERE user_id = sqlc.arg('user_id') AND status = 'open';
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, moderator_id, action, report_id, user_id, chirp_id, reason, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, DEFAULT)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT * FROM moderation_actions
+WHERE (sqlc.narg('before')::timestamptz IS NULL OR created_at < sqlc.narg('before'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_actions');
@@ -0,0 +1,36 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: GetSubscriptionForUpdate :one
+SELECT * FROM subscriptions WHERE user_id = $1 FOR UPDATE;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, status, current_period_end, cancel_at_period_end)
+VALUES (sqlc.arg('user_id'), sqlc.arg('status'), sqlc.arg('current_period_end'), sqlc.arg('cancel_at_period_end'))
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    cancel_at_period_end = EXCLUDED.cancel_at_period_end,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (id, user_id, event, status, period_end)
+VALUES (gen_random_uuid(), $1, $2, $3, $4);
+
+-- name: GetSubscriptionEvents :many
+SELECT * FROM subscription_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_events');
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', cancel_at_period_end = false, updated_at = CURRENT_TIMESTAMP
+WHERE user_id IN (
+    SELECT user_id FROM subscriptions AS due
+    WHERE due.status <> 'expired' AND due.current_period_end <= CURRENT_TIMESTAMP
+    LIMIT sqlc.arg('max_subscriptions')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
@@ -7,13 +7,61 @@ RETURNING *;
 DELETE FROM users;
 
 -- name: GetUserByEmail :one
-SELECT * FROM users WHERE email = $1;
+SELECT * FROM users WHERE email = $1 AND deleted_at IS NULL;
 
 -- name: UpdateUser :one
 UPDATE users
 SET email = $1, hashed_password = $2, updated_at = CURRENT_TIMESTAMP
-WHERE id = $3
+WHERE id = $3 AND deleted_at IS NULL
 RETURNING *;
 
--- name: UpgradeUserToChirpyRed :exec
-UPDATE users SET is_chirpy_red = true WHERE id = $1;
+-- name: GetUserIDsByEmails :many
+SELECT id FROM users WHERE lower(email) = ANY(sqlc.arg('emails')::text[]) AND deleted_at IS NULL;
+
+-- name: UserExists :one
+SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL);
+
+-- name: IsModerator :one
+SELECT is_moderator FROM users WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: SuspendUser :execrows
+UPDATE users SET suspended_at = CURRENT_TIMESTAMP WHERE id = $1 AND suspended_at IS NULL;
+
+-- name: IsUserSuspended :one
+-- A deleted account counts as suspended until it is restored.
+SELECT (suspended_at IS NOT NULL OR deleted_at IS NOT NULL)::bool AS suspended FROM users WHERE id = $1;
+
+-- name: SoftDeleteUser :execrows
+UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetDeletedUserByEmail :one
+SELECT * FROM users
+WHERE email = sqlc.arg('email') AND deleted_at > sqlc.arg('deleted_after')
+ORDER BY deleted_at DESC
+LIMIT 1;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id') AND deleted_at > sqlc.arg('deleted_after')
+RETURNING *;
+
+-- name: PurgeUsers :execrows
+DELETE FROM users
+WHERE id IN (
+    SELECT id FROM users AS purged
+    WHERE purged.deleted_at < sqlc.arg('deleted_before')
+    LIMIT sqlc.arg('max_users')
+);
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetUsersByIDs :many
+SELECT * FROM users WHERE id = ANY(sqlc.arg('ids')::uuid[]) AND deleted_at IS NULL;
+
+-- name: IsChirpyRed :one
+SELECT COALESCE(is_chirpy_red, false)::bool AS is_chirpy_red FROM users WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: SetChirpyRed :execrows
+UPDATE users SET is_chirpy_red = sqlc.arg('is_chirpy_red')::bool WHERE id = sqlc.arg('id') AND deleted_at IS NULL;
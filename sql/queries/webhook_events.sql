@@ -0,0 +1,12 @@
+-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (source, event_id, event)
+VALUES ($1, $2, $3)
+ON CONFLICT (source, event_id) DO NOTHING;
+
+-- name: PurgeWebhookEvents :execrows
+DELETE FROM webhook_events
+WHERE (source, event_id) IN (
+    SELECT source, event_id FROM webhook_events AS purged
+    WHERE purged.received_at < sqlc.arg('received_before')
+    LIMIT sqlc.arg('max_events')
+);
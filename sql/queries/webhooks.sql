@@ -0,0 +1,88 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, user_id, url, secret, events)
+VALUES (gen_random_uuid(), $1, $2, $3, sqlc.arg('events')::text[])
+RETURNING *;
+
+-- name: CountWebhooks :one
+SELECT COUNT(*) FROM webhooks WHERE user_id = $1;
+
+-- name: GetWebhooks :many
+SELECT * FROM webhooks WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Owner-only events (owner_id set) go to the owner's webhooks, the rest to
+-- every webhook subscribed to the event. An outbox event already enqueued is
+-- skipped.
+INSERT INTO webhook_deliveries (id, webhook_id, event, payload, outbox_id)
+SELECT gen_random_uuid(), webhooks.id, sqlc.arg('event')::text, sqlc.arg('payload')::jsonb, sqlc.arg('outbox_id')::bigint
+FROM webhooks
+JOIN users ON users.id = webhooks.user_id
+WHERE sqlc.arg('event')::text = ANY(webhooks.events)
+AND users.deleted_at IS NULL
+AND (sqlc.narg('owner_id')::uuid IS NULL OR webhooks.user_id = sqlc.narg('owner_id')::uuid)
+ON CONFLICT (webhook_id, outbox_id) DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+-- Counts the attempt up front and pushes the delivery back by a lease, so a
+-- crashed sender's deliveries are retried once the lease runs out.
+UPDATE webhook_deliveries
+SET attempts = webhook_deliveries.attempts + 1, next_attempt_at = sqlc.arg('lease_until')
+FROM webhooks
+WHERE webhooks.id = webhook_deliveries.webhook_id
+AND webhook_deliveries.id IN (
+    SELECT due.id FROM webhook_deliveries AS due
+    WHERE due.status = 'pending' AND due.next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY due.next_attempt_at
+    LIMIT sqlc.arg('max_deliveries')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING webhook_deliveries.id, webhook_deliveries.event, webhook_deliveries.payload, webhook_deliveries.attempts, webhook_deliveries.created_at, webhooks.url, webhooks.secret;
+
+-- name: CompleteWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', completed_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg('next_attempt_at')
+WHERE id = sqlc.arg('id');
+
+-- name: DeadLetterWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'dead', completed_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RedeliverWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, completed_at = NULL
+WHERE id = $1 AND webhook_id = $2 AND status = 'dead';
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempt, status_code, error, latency_ms)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5);
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_deliveries');
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = ANY(sqlc.arg('delivery_ids')::uuid[])
+ORDER BY attempt;
+
+-- name: PurgeWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE id IN (
+    SELECT id FROM webhook_deliveries AS purged
+    WHERE purged.completed_at < sqlc.arg('completed_before')
+    LIMIT sqlc.arg('max_deliveries')
+);
@@ -0,0 +1,16 @@
+-- name: CreateWSTicket :exec
+INSERT INTO ws_tickets (ticket_hash, user_id, expires_at)
+VALUES (sqlc.arg('ticket_hash'), sqlc.arg('user_id'), sqlc.arg('expires_at'));
+
+-- name: ConsumeWSTicket :one
+DELETE FROM ws_tickets
+WHERE ticket_hash = sqlc.arg('ticket_hash') AND expires_at > CURRENT_TIMESTAMP
+RETURNING user_id;
+
+-- name: PurgeWSTickets :execrows
+DELETE FROM ws_tickets
+WHERE ticket_hash IN (
+    SELECT ticket_hash FROM ws_tickets AS purged
+    WHERE purged.expires_at < sqlc.arg('expired_before')
+    LIMIT sqlc.arg('max_tickets')
+);
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS attachments (
+    id            UUID PRIMARY KEY,
+    user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id      UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    position      INTEGER NOT NULL DEFAULT 0,
+    content_type  TEXT NOT NULL,
+    size_bytes    BIGINT NOT NULL,
+    width         INTEGER NOT NULL,
+    height        INTEGER NOT NULL,
+    storage_key   TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    created_at    TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS attachments_chirp_id_idx ON attachments (chirp_id);
+
+-- +goose Down
+DROP TABLE attachments;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS moderation_rules (
+    id         UUID PRIMARY KEY,
+    kind       TEXT NOT NULL CHECK (kind IN ('word', 'regex')),
+    pattern    TEXT NOT NULL,
+    action     TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+    position   INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The words that used to be hard-coded.
+INSERT INTO moderation_rules (id, kind, pattern, action)
+VALUES
+    (gen_random_uuid(), 'word', 'kerfuffle', 'mask'),
+    (gen_random_uuid(), 'word', 'sharbert', 'mask'),
+    (gen_random_uuid(), 'word', 'fornax', 'mask');
+
+-- Chirps that matched a "flag" rule, waiting for a moderator.
+CREATE TABLE IF NOT EXISTS chirp_flags (
+    chirp_id   UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    rule_id    UUID NOT NULL REFERENCES moderation_rules(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chirp_id, rule_id)
+);
+
+ALTER TABLE users ADD COLUMN is_moderator BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN is_moderator;
+DROP TABLE chirp_flags;
+DROP TABLE moderation_rules;
@@ -0,0 +1,62 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP WITH TIME ZONE;
+
+-- A report is about a user, and about one of their chirps if chirp_id is
+-- set. Reports without a reporter come from "flag" moderation rules.
+CREATE TABLE IF NOT EXISTS reports (
+    id          UUID PRIMARY KEY,
+    reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id    UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    chirp_body  TEXT,
+    reason      TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other', 'flagged')),
+    details     TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'dismissed', 'actioned')),
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS reports_status_created_at_idx ON reports (status, created_at);
+
+-- One open report per reporter and target.
+CREATE UNIQUE INDEX IF NOT EXISTS reports_open_chirp_idx ON reports (reporter_id, chirp_id) WHERE status = 'open' AND chirp_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reports_open_user_idx ON reports (reporter_id, user_id) WHERE status = 'open' AND chirp_id IS NULL;
+
+-- Flags from the moderation rules become reports in the same queue.
+INSERT INTO reports (id, reporter_id, user_id, chirp_id, chirp_body, reason, details, created_at)
+SELECT gen_random_uuid(), NULL, chirps.user_id, chirps.id, chirps.body, 'flagged', 'Matched moderation rule ' || chirp_flags.rule_id, chirp_flags.created_at
+FROM chirp_flags
+JOIN chirps ON chirps.id = chirp_flags.chirp_id;
+
+DROP TABLE chirp_flags;
+
+-- The audit log. Targets are not foreign keys, the record has to outlive a
+-- deleted chirp.
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id           UUID PRIMARY KEY,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action       TEXT NOT NULL CHECK (action IN ('dismiss', 'hide', 'delete', 'suspend')),
+    report_id    UUID REFERENCES reports(id) ON DELETE SET NULL,
+    user_id      UUID NOT NULL,
+    chirp_id     UUID,
+    reason       TEXT NOT NULL,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS moderation_actions_created_at_idx ON moderation_actions (created_at DESC);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+
+CREATE TABLE IF NOT EXISTS chirp_flags (
+    chirp_id   UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    rule_id    UUID NOT NULL REFERENCES moderation_rules(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chirp_id, rule_id)
+);
+
+DROP TABLE reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
+ALTER TABLE users DROP COLUMN suspended_at;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE chirps ADD COLUMN deleted_by UUID;
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS chirps_deleted_at_idx ON chirps (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- A deleted account doesn't hold on to its email, someone else may sign up
+-- with it during the grace period.
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (email) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX users_email_idx;
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+DELETE FROM chirps WHERE deleted_at IS NOT NULL;
+DROP INDEX users_deleted_at_idx;
+DROP INDEX chirps_deleted_at_idx;
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE chirps DROP COLUMN deleted_by;
+ALTER TABLE chirps DROP COLUMN deleted_at;
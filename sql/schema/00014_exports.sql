@@ -0,0 +1,22 @@
+-- +goose Up
+-- Data exports are built in the background, storage_key points at the zip
+-- archive in the blob store once it is ready.
+CREATE TABLE IF NOT EXISTS exports (
+    id           UUID PRIMARY KEY,
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status       TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    storage_key  TEXT,
+    size_bytes   BIGINT,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at   TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS exports_user_id_created_at_idx ON exports (user_id, created_at);
+CREATE INDEX IF NOT EXISTS exports_expires_at_idx ON exports (expires_at) WHERE expires_at IS NOT NULL;
+
+-- One export in the works per user.
+CREATE UNIQUE INDEX IF NOT EXISTS exports_pending_idx ON exports (user_id) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS exports;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- A chirp with publish_at set is scheduled, only its author sees it. The
+-- publisher clears it once the time comes.
+ALTER TABLE chirps ADD COLUMN publish_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS chirps_publish_at_idx ON chirps (publish_at) WHERE publish_at IS NOT NULL;
+
+-- Drafts reference uploads that are not attached to a chirp yet, they are
+-- attached when the draft is published.
+CREATE TABLE IF NOT EXISTS drafts (
+    id             UUID PRIMARY KEY,
+    user_id        UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body           TEXT NOT NULL DEFAULT '',
+    attachment_ids UUID[] NOT NULL DEFAULT '{}',
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS drafts_user_id_updated_at_idx ON drafts (user_id, updated_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS drafts;
+DROP INDEX IF EXISTS chirps_publish_at_idx;
+ALTER TABLE chirps DROP COLUMN publish_at;
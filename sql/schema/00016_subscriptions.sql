@@ -0,0 +1,35 @@
+-- +goose Up
+-- One row per user who ever subscribed. users.is_chirpy_red stays the flag
+-- everything else reads, it is kept in sync with the subscription.
+CREATE TABLE IF NOT EXISTS subscriptions (
+    user_id              UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    status               TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'expired')),
+    current_period_end   TIMESTAMP WITH TIME ZONE NOT NULL,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT false,
+    created_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_current_period_end_idx ON subscriptions (current_period_end) WHERE status <> 'expired';
+
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event      TEXT NOT NULL,
+    status     TEXT NOT NULL,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS subscription_events_user_id_created_at_idx ON subscription_events (user_id, created_at);
+
+-- Upgrades so far carried no period, give them one so they expire unless
+-- Polka renews them.
+INSERT INTO subscriptions (user_id, status, current_period_end)
+SELECT id, 'active', CURRENT_TIMESTAMP + INTERVAL '30 days'
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE IF EXISTS subscription_events;
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Events already handled, per sender, so a redelivered event is a no-op.
+CREATE TABLE IF NOT EXISTS webhook_events (
+    source      TEXT NOT NULL,
+    event_id    TEXT NOT NULL,
+    event       TEXT NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_events_received_at_idx ON webhook_events (received_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_events;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- Background jobs. A job waiting for a retry is queued again with a later
+-- run_at, a running job whose lease ran out is up for grabs again.
+CREATE TABLE IF NOT EXISTS jobs (
+    id           UUID PRIMARY KEY,
+    kind         TEXT NOT NULL,
+    payload      JSONB NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'dead')),
+    unique_key   TEXT,
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    last_error   TEXT,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at  TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS jobs_queued_idx ON jobs (run_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS jobs_running_idx ON jobs (locked_until) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS jobs_finished_at_idx ON jobs (finished_at);
+-- At most one unfinished job per unique key.
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_unique_key_idx ON jobs (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('queued', 'running');
+
+-- Recurring jobs, the instance that locks a due row enqueues its job.
+CREATE TABLE IF NOT EXISTS job_schedules (
+    name        TEXT PRIMARY KEY,
+    spec        TEXT NOT NULL,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_run_at TIMESTAMP WITH TIME ZONE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS job_schedules;
+DROP TABLE IF EXISTS jobs;
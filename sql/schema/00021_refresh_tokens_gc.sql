@@ -0,0 +1,9 @@
+-- +goose Up
+-- Lets the garbage collector find expired and revoked tokens without a
+-- full scan.
+CREATE INDEX IF NOT EXISTS refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+CREATE INDEX IF NOT EXISTS refresh_tokens_revoked_at_idx ON refresh_tokens (revoked_at) WHERE revoked_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS refresh_tokens_revoked_at_idx;
+DROP INDEX IF EXISTS refresh_tokens_expires_at_idx;
@@ -0,0 +1,70 @@
+-- +goose Up
+-- Every local user is an ActivityPub actor, requests on their behalf are
+-- signed with this key. Created the first time it is needed.
+CREATE TABLE IF NOT EXISTS actor_keys (
+    user_id         UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    public_key_pem  TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Actors on other servers we heard from, with the key their requests are
+-- verified with.
+CREATE TABLE IF NOT EXISTS remote_actors (
+    id             TEXT PRIMARY KEY,
+    inbox          TEXT NOT NULL,
+    shared_inbox   TEXT,
+    public_key_id  TEXT NOT NULL UNIQUE,
+    public_key_pem TEXT NOT NULL,
+    fetched_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Remote actors following local users, they get their chirps.
+CREATE TABLE IF NOT EXISTS remote_followers (
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id   TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    follow_id  TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, actor_id)
+);
+
+-- Local users following remote actors. accepted_at is set once the remote
+-- server accepts the Follow.
+CREATE TABLE IF NOT EXISTS remote_follows (
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id    TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    follow_id   TEXT NOT NULL UNIQUE,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, actor_id)
+);
+
+-- Notes by followed remote actors. content is HTML as the remote server
+-- sent it.
+CREATE TABLE IF NOT EXISTS remote_notes (
+    id           TEXT PRIMARY KEY,
+    actor_id     TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    content      TEXT NOT NULL,
+    in_reply_to  TEXT,
+    published_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    received_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS remote_notes_actor_id_published_at_idx ON remote_notes (actor_id, published_at);
+
+-- Likes of local chirps by remote actors.
+CREATE TABLE IF NOT EXISTS remote_likes (
+    id         TEXT PRIMARY KEY,
+    actor_id   TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    chirp_id   UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (actor_id, chirp_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS remote_likes;
+DROP TABLE IF EXISTS remote_notes;
+DROP TABLE IF EXISTS remote_follows;
+DROP TABLE IF EXISTS remote_followers;
+DROP TABLE IF EXISTS remote_actors;
+DROP TABLE IF EXISTS actor_keys;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- The first response to a request sent with an Idempotency-Key, replayed
+-- to retries. status is NULL while the first request is still running.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key         TEXT NOT NULL,
+    -- SHA-256 of the method, target and body, a retry has to match.
+    fingerprint BYTEA NOT NULL,
+    status      INTEGER,
+    headers     JSONB NOT NULL DEFAULT '{}',
+    body        BYTEA NOT NULL DEFAULT '',
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Pages of GET /api/v2/chirps are read by (created_at, id).
+CREATE INDEX IF NOT EXISTS chirps_created_at_id_idx ON chirps (created_at, id) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_created_at_id_idx;
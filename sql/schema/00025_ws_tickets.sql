@@ -0,0 +1,13 @@
+-- +goose Up
+-- Single-use tickets authenticating WebSocket handshakes, only their
+-- SHA-256 is stored.
+CREATE TABLE IF NOT EXISTS ws_tickets (
+    ticket_hash BYTEA PRIMARY KEY,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at  TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ws_tickets_expires_at_idx ON ws_tickets (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS ws_tickets;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Local users following each other. Remote follows live in remote_follows.
+CREATE TABLE IF NOT EXISTS user_follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followed_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followed_id),
+    CHECK (follower_id <> followed_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_follows_followed_id_idx ON user_follows (followed_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_follows;